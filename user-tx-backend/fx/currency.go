@@ -0,0 +1,122 @@
+// Package fx validates currencies, holds amounts as exact minor units and
+// converts them to a reporting currency with a table of dated exchange
+// rates. The table is read from a JSON file or URL and can be reloaded
+// while the server runs.
+package fx
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "math/big"
+    "strconv"
+    "strings"
+)
+
+var (
+    ErrUnknownCurrency = errors.New("unknown currency")
+    ErrPrecision       = errors.New("too many decimals for currency")
+    ErrNoRate          = errors.New("no exchange rate")
+)
+
+// exponents maps the active ISO 4217 currency codes to the number of
+// digits of their minor unit. Precious metals, testing and other codes
+// without a minor unit are left out.
+var exponents = map[string]int{
+    "AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+    "AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+    "BMD": 2, "BND": 2, "BOB": 2, "BOV": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2,
+    "BYN": 2, "BZD": 2, "CAD": 2, "CDF": 2, "CHE": 2, "CHF": 2, "CHW": 2, "CLF": 4,
+    "CLP": 0, "CNY": 2, "COP": 2, "COU": 2, "CRC": 2, "CUC": 2, "CUP": 2, "CVE": 2,
+    "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2, "ERN": 2, "ETB": 2,
+    "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2, "GIP": 2, "GMD": 2,
+    "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2, "HUF": 2, "IDR": 2,
+    "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2, "JOD": 3, "JPY": 0,
+    "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0, "KWD": 3, "KYD": 2,
+    "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2, "LYD": 3, "MAD": 2,
+    "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2, "MRU": 2, "MUR": 2,
+    "MVR": 2, "MWK": 2, "MXN": 2, "MXV": 2, "MYR": 2, "MZN": 2, "NAD": 2, "NGN": 2,
+    "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2, "PGK": 2,
+    "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2, "RUB": 2,
+    "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2, "SHP": 2,
+    "SLE": 2, "SLL": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+    "SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2,
+    "TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "USN": 2, "UYI": 0, "UYU": 2,
+    "UYW": 4, "UZS": 2, "VED": 2, "VES": 2, "VND": 0, "VUV": 0, "WST": 2, "XAF": 0,
+    "XCD": 2, "XCG": 2, "XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2, "ZMW": 2, "ZWG": 2,
+    "ZWL": 2,
+}
+
+// Normalize upper-cases a currency code and checks it is an active ISO 4217
+// code.
+func Normalize(code string) (string, error) {
+    c := strings.ToUpper(strings.TrimSpace(code))
+    if _, ok := exponents[c]; !ok {
+        return "", fmt.Errorf("%w %q", ErrUnknownCurrency, code)
+    }
+    return c, nil
+}
+
+// Exponent returns the number of minor-unit digits of a currency, 2 for
+// codes outside the table.
+func Exponent(code string) int {
+    if e, ok := exponents[code]; ok {
+        return e
+    }
+    return 2
+}
+
+// ParseMinor reads a decimal amount such as "10.5" as minor units of
+// currency (1050 for USD). More decimals than the currency has are an
+// error rather than being rounded away.
+func ParseMinor(s, currency string) (int64, error) {
+    exp := Exponent(currency)
+    digits := strings.Replace(strings.TrimPrefix(s, "-"), ".", "", 1)
+    if digits == "" || strings.Trim(digits, "0123456789") != "" {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    r, ok := new(big.Rat).SetString(s)
+    if !ok {
+        return 0, fmt.Errorf("invalid amount %q", s)
+    }
+    r.Mul(r, new(big.Rat).SetInt(pow10(exp)))
+    if !r.IsInt() {
+        return 0, fmt.Errorf("%w: %s allows %d", ErrPrecision, currency, exp)
+    }
+    if !r.Num().IsInt64() {
+        return 0, fmt.Errorf("amount %q out of range", s)
+    }
+    return r.Num().Int64(), nil
+}
+
+// ToMinor reads a JSON amount as minor units, using the shortest decimal
+// that represents the float, i.e. the digits the client sent.
+func ToMinor(amount float64, currency string) (int64, error) {
+    if math.IsNaN(amount) || math.IsInf(amount, 0) {
+        return 0, fmt.Errorf("invalid amount")
+    }
+    return ParseMinor(strconv.FormatFloat(amount, 'f', -1, 64), currency)
+}
+
+// RoundMinor is ToMinor for amounts stored before they were exact: extra
+// decimals are rounded half away from zero instead of rejected.
+func RoundMinor(amount float64, currency string) int64 {
+    return int64(math.Round(amount * math.Pow10(Exponent(currency))))
+}
+
+// Float returns minor units as a float in major units, for display and for
+// analytics that weigh amounts.
+func Float(minor int64, currency string) float64 {
+    v, _ := new(big.Rat).SetFrac(big.NewInt(minor), pow10(Exponent(currency))).Float64()
+    return v
+}
+
+// Format renders minor units as a decimal string with the currency's
+// digits, e.g. "10.50".
+func Format(minor int64, currency string) string {
+    return new(big.Rat).SetFrac(big.NewInt(minor), pow10(Exponent(currency))).FloatString(Exponent(currency))
+}
+
+func pow10(n int) *big.Int {
+    return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,317 @@
+package fx
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "log"
+    "math/big"
+    "net/http"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+// DefaultReporting is the reporting currency when none is configured.
+const DefaultReporting = "USD"
+
+// File is the layout of the rates file, or of the response of a rates URL.
+// Every rate is the value of one unit of its currency in Base from its
+// effective date on, until a later rate for the currency takes over.
+type File struct {
+    Base  string        `json:"base"`
+    Rates []models.FXRate `json:"rates"`
+}
+
+// rate is a parsed models.FXRate.
+type rate struct {
+    value *big.Rat
+    from  time.Time
+}
+
+// Converter converts amounts to the reporting currency. It is safe for
+// concurrent use.
+type Converter struct {
+    source    string // path or http(s) URL
+    reporting string
+    client    *http.Client
+
+    mu     sync.RWMutex
+    file   File
+    rates  map[string][]rate // by currency, oldest first
+    loaded time.Time
+}
+
+// NewConverter loads the rates at source, a file path or an http(s) URL,
+// and converts to reporting (DefaultReporting when empty). Without a
+// source only amounts already in the reporting currency convert.
+func NewConverter(source, reporting string) (*Converter, error) {
+    if reporting == "" {
+        reporting = DefaultReporting
+    }
+    reporting, err := Normalize(reporting)
+    if err != nil {
+        return nil, fmt.Errorf("reporting currency: %w", err)
+    }
+    c := &Converter{
+        source:    source,
+        reporting: reporting,
+        client:    &http.Client{Timeout: 30 * time.Second},
+        rates:     map[string][]rate{},
+    }
+    if source == "" {
+        return c, nil
+    }
+    if err := c.Reload(); err != nil {
+        return nil, err
+    }
+    return c, nil
+}
+
+// Reporting returns the reporting currency.
+func (c *Converter) Reporting() string {
+    return c.reporting
+}
+
+// Reload re-reads the rates. On error the current rates stay active.
+func (c *Converter) Reload() error {
+    if c.source == "" {
+        return fmt.Errorf("no exchange rate source configured")
+    }
+    b, err := c.read()
+    if err != nil {
+        return err
+    }
+    var f File
+    if err := json.Unmarshal(b, &f); err != nil {
+        return fmt.Errorf("%s: %w", c.source, err)
+    }
+    if f.Base, err = Normalize(f.Base); err != nil {
+        return fmt.Errorf("%s: base: %w", c.source, err)
+    }
+    rates := make(map[string][]rate)
+    for i, r := range f.Rates {
+        cur, err := Normalize(r.Currency)
+        if err != nil {
+            return fmt.Errorf("%s: rate %d: %w", c.source, i, err)
+        }
+        v, ok := new(big.Rat).SetString(r.Rate)
+        if !ok || v.Sign() <= 0 {
+            return fmt.Errorf("%s: rate %d: invalid rate %q", c.source, i, r.Rate)
+        }
+        from, err := parseEffective(r.Effective)
+        if err != nil {
+            return fmt.Errorf("%s: rate %d: invalid effective %q", c.source, i, r.Effective)
+        }
+        f.Rates[i].Currency = cur
+        rates[cur] = append(rates[cur], rate{value: v, from: from})
+    }
+    for _, rs := range rates {
+        sort.SliceStable(rs, func(i, j int) bool { return rs[i].from.Before(rs[j].from) })
+    }
+
+    c.mu.Lock()
+    c.file = f
+    c.rates = rates
+    c.loaded = time.Now().UTC()
+    c.mu.Unlock()
+    return nil
+}
+
+func (c *Converter) read() ([]byte, error) {
+    if !strings.HasPrefix(c.source, "http://") && !strings.HasPrefix(c.source, "https://") {
+        return os.ReadFile(c.source)
+    }
+    resp, err := c.client.Get(c.source)
+    if err != nil {
+        return nil, err
+    }
+    defer resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return nil, fmt.Errorf("%s: %s", c.source, resp.Status)
+    }
+    return io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+}
+
+// Refresh reloads the rates every interval until stop is closed, e.g. to
+// follow a rates API.
+func (c *Converter) Refresh(interval time.Duration, stop <-chan struct{}) {
+    if c.source == "" || interval <= 0 {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            if err := c.Reload(); err != nil {
+                log.Printf("fx: reload failed, keeping previous rates: %v", err)
+            }
+        }
+    }
+}
+
+// Table returns the loaded rates.
+func (c *Converter) Table() models.FXTable {
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    t := models.FXTable{
+        Source:    c.source,
+        Reporting: c.reporting,
+        Base:      c.file.Base,
+        Rates:     append([]models.FXRate{}, c.file.Rates...),
+    }
+    if !c.loaded.IsZero() {
+        t.LoadedAt = c.loaded.Format(time.RFC3339)
+    }
+    return t
+}
+
+// parseEffective reads a date (2006-01-02, from midnight UTC) or an
+// RFC3339 time.
+func parseEffective(s string) (time.Time, error) {
+    if t, err := time.Parse("2006-01-02", s); err == nil {
+        return t, nil
+    }
+    return time.Parse(time.RFC3339Nano, s)
+}
+
+// toBase returns the value of one unit of cur in the base currency at at.
+// Callers must hold the lock.
+func (c *Converter) toBase(cur string, at time.Time) (*big.Rat, error) {
+    if cur == c.file.Base {
+        return big.NewRat(1, 1), nil
+    }
+    var found *big.Rat
+    for _, r := range c.rates[cur] {
+        if r.from.After(at) {
+            break
+        }
+        found = r.value
+    }
+    if found == nil {
+        return nil, fmt.Errorf("%w for %s at %s", ErrNoRate, cur, at.Format(time.RFC3339))
+    }
+    return found, nil
+}
+
+// Rate returns the value of one unit of from in to at the given time,
+// crossing through the base currency.
+func (c *Converter) Rate(from, to string, at time.Time) (*big.Rat, error) {
+    if from == to {
+        return big.NewRat(1, 1), nil
+    }
+    c.mu.RLock()
+    defer c.mu.RUnlock()
+    f, err := c.toBase(from, at)
+    if err != nil {
+        return nil, err
+    }
+    t, err := c.toBase(to, at)
+    if err != nil {
+        return nil, err
+    }
+    return new(big.Rat).Quo(f, t), nil
+}
+
+// Convert converts minor units of currency to the reporting currency at
+// the given time, rounding half away from zero to the reporting
+// currency's minor unit.
+func (c *Converter) Convert(minor int64, currency string, at time.Time) (models.Money, error) {
+    r, err := c.Rate(currency, c.reporting, at)
+    if err != nil {
+        return models.Money{}, err
+    }
+    x := new(big.Rat).SetInt64(minor)
+    x.Mul(x, r)
+    x.Mul(x, new(big.Rat).SetFrac(pow10(Exponent(c.reporting)), pow10(Exponent(currency))))
+    return models.Money{
+        Minor:             minor,
+        Currency:          currency,
+        ReportingMinor:    round(x),
+        ReportingCurrency: c.reporting,
+        Rate:              strings.TrimRight(strings.TrimRight(r.FloatString(10), "0"), "."),
+    }, nil
+}
+
+// Money validates a request's amount and currency and converts it at
+// timestamp, read by ParseTimestamp.
+func (c *Converter) Money(amount float64, currency, timestamp string) (models.Money, error) {
+    cur, err := Normalize(currency)
+    if err != nil {
+        return models.Money{}, err
+    }
+    minor, err := ToMinor(amount, cur)
+    if err != nil {
+        return models.Money{}, err
+    }
+    return c.MoneyMinor(minor, cur, timestamp)
+}
+
+// MoneyDecimal is Money for an amount written as a decimal string, read
+// exactly by ParseMinor.
+func (c *Converter) MoneyDecimal(amount, currency, timestamp string) (models.Money, error) {
+    cur, err := Normalize(currency)
+    if err != nil {
+        return models.Money{}, err
+    }
+    minor, err := ParseMinor(strings.TrimSpace(amount), cur)
+    if err != nil {
+        return models.Money{}, err
+    }
+    return c.MoneyMinor(minor, cur, timestamp)
+}
+
+// timestampLayouts are the forms ParseTimestamp accepts besides RFC3339:
+// those of an HTML datetime-local input, with or without seconds.
+var timestampLayouts = []string{
+    "2006-01-02T15:04:05.999999999",
+    "2006-01-02T15:04",
+}
+
+// ParseTimestamp reads a transaction timestamp: RFC3339, or a date and
+// time without a zone, taken as UTC like Neo4j's datetime() does.
+func ParseTimestamp(s string) (time.Time, error) {
+    if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+        return t, nil
+    }
+    for _, layout := range timestampLayouts {
+        if t, err := time.Parse(layout, s); err == nil {
+            return t, nil
+        }
+    }
+    return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+}
+
+// MoneyMinor is Money for an amount given in minor units of an already
+// normalized currency. Without a rate for the currency the amount is kept
+// and the reporting fields are left empty.
+func (c *Converter) MoneyMinor(minor int64, currency, timestamp string) (models.Money, error) {
+    at, err := ParseTimestamp(timestamp)
+    if err != nil {
+        return models.Money{}, err
+    }
+    m, err := c.Convert(minor, currency, at)
+    if errors.Is(err, ErrNoRate) {
+        return models.Money{Minor: minor, Currency: currency}, nil
+    }
+    return m, err
+}
+
+// round rounds half away from zero.
+func round(x *big.Rat) int64 {
+    abs := new(big.Rat).Abs(x)
+    abs.Add(abs, big.NewRat(1, 2))
+    n := new(big.Int).Quo(abs.Num(), abs.Denom())
+    if x.Sign() < 0 {
+        n.Neg(n)
+    }
+    return n.Int64()
+}
@@ -0,0 +1,86 @@
+package fx
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+)
+
+// writeRates writes a rates file with EUR at 1.10 USD from 2024.
+func writeRates(t *testing.T) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), "rates.json")
+    data := `{"base":"USD","rates":[{"currency":"EUR","rate":"1.10","effective":"2024-01-01"}]}`
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestMoneyConverts(t *testing.T) {
+    c, err := NewConverter(writeRates(t), "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := c.Money(10.05, "eur", "2024-06-01T00:00:00Z")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m.Minor != 1005 || m.Currency != "EUR" || m.ReportingMinor != 1106 || m.ReportingCurrency != "USD" || m.Rate != "1.1" {
+        t.Errorf("Money = %+v, want 1005 EUR as 1106 USD at 1.1", m)
+    }
+}
+
+func TestMoneyWithoutRate(t *testing.T) {
+    c, err := NewConverter("", "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    m, err := c.Money(10.05, "EUR", "2024-06-01T00:00:00Z")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m.Minor != 1005 || m.Currency != "EUR" || m.ReportingCurrency != "" || m.Rate != "" {
+        t.Errorf("Money = %+v, want 1005 EUR without a reporting amount", m)
+    }
+
+    // Before the first rate, too.
+    c, err = NewConverter(writeRates(t), "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if m, err := c.Money(1, "EUR", "2023-06-01T00:00:00Z"); err != nil || m.ReportingCurrency != "" {
+        t.Errorf("Money before the first rate = %+v, %v; want no reporting amount", m, err)
+    }
+}
+
+func TestMoneyRejects(t *testing.T) {
+    c, err := NewConverter("", "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := c.Money(1, "XXX", "2024-06-01T00:00:00Z"); !errors.Is(err, ErrUnknownCurrency) {
+        t.Errorf("unknown currency error = %v, want ErrUnknownCurrency", err)
+    }
+    if _, err := c.Money(1.005, "USD", "2024-06-01T00:00:00Z"); !errors.Is(err, ErrPrecision) {
+        t.Errorf("three decimals of USD error = %v, want ErrPrecision", err)
+    }
+}
+
+func TestParseTimestamp(t *testing.T) {
+    for in, want := range map[string]string{
+        "2024-06-01T10:00:00+02:00": "2024-06-01T08:00:00Z",
+        "2024-06-01T10:00:30.5":     "2024-06-01T10:00:30.5Z",
+        "2024-06-01T10:00":          "2024-06-01T10:00:00Z",
+    } {
+        got, err := ParseTimestamp(in)
+        if err != nil || got.UTC().Format(time.RFC3339Nano) != want {
+            t.Errorf("ParseTimestamp(%q) = %v, %v; want %s", in, got, err, want)
+        }
+    }
+    if _, err := ParseTimestamp("2024-06-01"); err == nil {
+        t.Error("ParseTimestamp of a bare date succeeded")
+    }
+}
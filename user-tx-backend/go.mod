@@ -5,10 +5,8 @@ go 1.20
 require (
 	github.com/gorilla/handlers v1.5.1
 	github.com/gorilla/mux v1.8.0
+	github.com/joho/godotenv v1.5.1
 	github.com/neo4j/neo4j-go-driver/v5 v5.13.0
 )
 
-require (
-	github.com/felixge/httpsnoop v1.0.1 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-)
+require github.com/felixge/httpsnoop v1.0.1 // indirect
@@ -0,0 +1,493 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// alertStatuses are the states an alert can be in. Any state may follow
+// any other; the lifecycle that matters is the case's.
+var alertStatuses = []string{"open", "acknowledged", "dismissed"}
+
+// alertColumns is the projection alertFromRecord reads. Queries bind the
+// alert to a, its transaction to t and its case to c, usually through
+// alertOptional.
+const alertColumns = `properties(a), t.id, c.id`
+
+const alertOptional = `
+             OPTIONAL MATCH (a)-[:FLAGS]->(t:Transaction)
+             OPTIONAL MATCH (c:Case)-[:INCLUDES]->(a)`
+
+// timeProp formats a datetime property (or a string one) as RFC3339.
+func timeProp(props map[string]any, key string) string {
+    switch v := props[key].(type) {
+    case time.Time:
+        return v.UTC().Format(time.RFC3339Nano)
+    case string:
+        return v
+    }
+    return ""
+}
+
+func alertFromRecord(values []any) models.Alert {
+    props := values[0].(map[string]any)
+    txID, _ := values[1].(string)
+    caseID, _ := values[2].(string)
+    return models.Alert{
+        ID:            stringProp(props, "id"),
+        RuleID:        stringProp(props, "ruleId"),
+        RuleName:      stringProp(props, "ruleName"),
+        Severity:      stringProp(props, "severity"),
+        Action:        stringProp(props, "action"),
+        Message:       stringProp(props, "message"),
+        Status:        stringProp(props, "status"),
+        TransactionID: txID,
+        SenderID:      stringProp(props, "senderId"),
+        ReceiverID:    stringProp(props, "receiverId"),
+        CaseID:        caseID,
+        CreatedAt:     timeProp(props, "createdAt"),
+    }
+}
+
+func collectAlerts(ctx context.Context, rs neo4j.ResultWithContext) ([]models.Alert, error) {
+    alerts := []models.Alert{}
+    for rs.Next(ctx) {
+        alerts = append(alerts, alertFromRecord(rs.Record().Values))
+    }
+    return alerts, rs.Err()
+}
+
+// CreateAlerts stores alerts as open Alert nodes, linked by FLAGS to their
+// transaction when they have one and by CONCERNS to the sender and
+// receiver, and returns them with IDs and creation times filled in.
+func (d *Driver) CreateAlerts(alerts []models.Alert) ([]models.Alert, error) {
+    if len(alerts) == 0 {
+        return alerts, nil
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    now := time.Now().UTC().Format(time.RFC3339Nano)
+    rows := make([]map[string]any, len(alerts))
+    for i, a := range alerts {
+        rows[i] = map[string]any{
+            "idx":           i,
+            "ruleId":        a.RuleID,
+            "ruleName":      a.RuleName,
+            "severity":      a.Severity,
+            "action":        a.Action,
+            "message":       a.Message,
+            "transactionId": a.TransactionID,
+            "senderId":      a.SenderID,
+            "receiverId":    a.ReceiverID,
+        }
+    }
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        out := make([]models.Alert, len(alerts))
+        rs, err := tx.Run(ctx,
+            `UNWIND $rows AS row
+             CREATE (a:Alert {
+               id:         randomUUID(),
+               ruleId:     row.ruleId,
+               ruleName:   row.ruleName,
+               severity:   row.severity,
+               action:     row.action,
+               message:    row.message,
+               status:     'open',
+               senderId:   row.senderId,
+               receiverId: row.receiverId,
+               createdAt:  datetime($now)
+             })
+             WITH a, row
+             OPTIONAL MATCH (t:Transaction) WHERE t.id = row.transactionId
+             FOREACH (_ IN CASE WHEN t IS NULL THEN [] ELSE [1] END |
+               CREATE (a)-[:FLAGS]->(t))
+             WITH a, row, t
+             OPTIONAL MATCH (u:User) WHERE u.id IN [row.senderId, row.receiverId]
+             FOREACH (_ IN CASE WHEN u IS NULL THEN [] ELSE [1] END |
+               MERGE (a)-[:CONCERNS]->(u))
+             WITH DISTINCT a, row, t
+             RETURN row.idx, properties(a), t.id
+             ORDER BY row.idx`,
+            map[string]any{"rows": rows, "now": now},
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            // no case yet, so the case column is null
+            out[rec.Values[0].(int64)] = alertFromRecord(append(rec.Values[1:3:3], nil))
+        }
+        return out, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.Alert), nil
+}
+
+// TransactionAlerts lists the alerts flagging a transaction, oldest first.
+func (d *Driver) TransactionAlerts(txID string) ([]models.Alert, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx, `MATCH (t:Transaction) WHERE t.id = $id RETURN count(t)`, map[string]any{"id": txID})
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if rec.Values[0].(int64) == 0 {
+            return nil, ErrTransactionNotFound
+        }
+        rs, err = tx.Run(ctx,
+            `MATCH (a:Alert)-[:FLAGS]->(t:Transaction) WHERE t.id = $id
+             OPTIONAL MATCH (c:Case)-[:INCLUDES]->(a)
+             RETURN `+alertColumns+`
+             ORDER BY a.createdAt, a.id`,
+            map[string]any{"id": txID},
+        )
+        if err != nil {
+            return nil, err
+        }
+        return collectAlerts(ctx, rs)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.Alert), nil
+}
+
+// GetAlert returns one alert.
+func (d *Driver) GetAlert(id string) (models.Alert, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (a:Alert) WHERE a.id = $id`+alertOptional+`
+             RETURN `+alertColumns,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        alerts, err := collectAlerts(ctx, rs)
+        if err != nil {
+            return nil, err
+        }
+        if len(alerts) == 0 {
+            return nil, ErrAlertNotFound
+        }
+        return alerts[0], nil
+    })
+    if err != nil {
+        return models.Alert{}, err
+    }
+    return raw.(models.Alert), nil
+}
+
+// ListAlerts returns the alerts matching q, newest first.
+func (d *Driver) ListAlerts(q models.AlertQuery) ([]models.Alert, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    var conds []string
+    params := map[string]any{"limit": pageLimit(q.Limit)}
+    for _, f := range []struct{ expr, param, value string }{
+        {"a.status = $status", "status", q.Status},
+        {"a.severity = $severity", "severity", q.Severity},
+        {"a.ruleId = $ruleId", "ruleId", q.RuleID},
+        {"(a.senderId = $userId OR a.receiverId = $userId)", "userId", q.UserID},
+        {"t.id = $transactionId", "transactionId", q.TransactionID},
+        {"c.id = $caseId", "caseId", q.CaseID},
+    } {
+        if f.value != "" {
+            conds = append(conds, f.expr)
+            params[f.param] = f.value
+        }
+    }
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (a:Alert)`+alertOptional+`
+             WITH a, t, c`+whereClause(conds)+`
+             RETURN `+alertColumns+`
+             ORDER BY a.createdAt DESC, a.id DESC
+             LIMIT $limit`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        return collectAlerts(ctx, rs)
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.Alert), nil
+}
+
+// UpdateAlert changes an alert's status and/or moves it to another case.
+func (d *Driver) UpdateAlert(id string, patch models.AlertPatch) (models.Alert, error) {
+    if patch.Status != nil && !hasString(alertStatuses, *patch.Status) {
+        return models.Alert{}, fmt.Errorf("%w: status must be open, acknowledged or dismissed", ErrInvalidQuery)
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (a:Alert) WHERE a.id = $id
+             SET a.status = coalesce($status, a.status)
+             RETURN a.id`,
+            map[string]any{"id": id, "status": optional(patch.Status)},
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rs.Next(ctx) {
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrAlertNotFound
+        }
+        if patch.CaseID != nil {
+            if _, err := tx.Run(ctx,
+                `MATCH (:Case)-[r:INCLUDES]->(a:Alert) WHERE a.id = $id DELETE r`,
+                map[string]any{"id": id},
+            ); err != nil {
+                return nil, err
+            }
+            if *patch.CaseID != "" {
+                rs, err := tx.Run(ctx,
+                    `MATCH (c:Case),(a:Alert) WHERE c.id = $caseId AND a.id = $id
+                     MERGE (c)-[:INCLUDES]->(a)
+                     SET c.updatedAt = datetime()
+                     RETURN c.id`,
+                    map[string]any{"id": id, "caseId": *patch.CaseID},
+                )
+                if err != nil {
+                    return nil, err
+                }
+                if !rs.Next(ctx) {
+                    if err := rs.Err(); err != nil {
+                        return nil, err
+                    }
+                    return nil, ErrCaseNotFound
+                }
+            }
+        }
+        rs, err = tx.Run(ctx,
+            `MATCH (a:Alert) WHERE a.id = $id`+alertOptional+`
+             RETURN `+alertColumns,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        alerts, err := collectAlerts(ctx, rs)
+        if err != nil {
+            return nil, err
+        }
+        return alerts[0], nil
+    })
+    if err != nil {
+        return models.Alert{}, err
+    }
+    return raw.(models.Alert), nil
+}
+
+// DeleteAlert removes an alert and its links.
+func (d *Driver) DeleteAlert(id string) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (a:Alert) WHERE a.id = $id
+             DETACH DELETE a
+             RETURN count(*)`,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if rec.Values[0].(int64) == 0 {
+            return nil, ErrAlertNotFound
+        }
+        return nil, nil
+    })
+    return err
+}
+
+// alertCase returns the case an alert belongs to. Callers must hold the
+// lock.
+func (m *MemoryStore) alertCase(alertID string) string {
+    for _, id := range m.caseIDs {
+        if hasString(m.cases[id].AlertIDs, alertID) {
+            return id
+        }
+    }
+    return ""
+}
+
+// CreateAlerts stores open alerts and fills in their IDs and creation
+// times.
+func (m *MemoryStore) CreateAlerts(alerts []models.Alert) ([]models.Alert, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    now := time.Now().UTC().Format(time.RFC3339Nano)
+    out := make([]models.Alert, len(alerts))
+    for i, a := range alerts {
+        if _, ok := m.txs[a.TransactionID]; !ok {
+            a.TransactionID = ""
+        }
+        a.ID = newID()
+        a.Status = "open"
+        a.CaseID = ""
+        a.CreatedAt = now
+        m.alerts[a.ID] = a
+        m.alertIDs = append(m.alertIDs, a.ID)
+        out[i] = a
+    }
+    return out, nil
+}
+
+// TransactionAlerts lists the alerts flagging a transaction, oldest first.
+func (m *MemoryStore) TransactionAlerts(txID string) ([]models.Alert, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    if _, ok := m.txs[txID]; !ok {
+        return nil, ErrTransactionNotFound
+    }
+    alerts := []models.Alert{}
+    for _, id := range m.alertIDs {
+        if a := m.alerts[id]; a.TransactionID == txID {
+            a.CaseID = m.alertCase(id)
+            alerts = append(alerts, a)
+        }
+    }
+    return alerts, nil
+}
+
+// GetAlert returns one alert.
+func (m *MemoryStore) GetAlert(id string) (models.Alert, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    a, ok := m.alerts[id]
+    if !ok {
+        return models.Alert{}, ErrAlertNotFound
+    }
+    a.CaseID = m.alertCase(id)
+    return a, nil
+}
+
+// ListAlerts returns the alerts matching q, newest first.
+func (m *MemoryStore) ListAlerts(q models.AlertQuery) ([]models.Alert, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    alerts := []models.Alert{}
+    for _, id := range m.alertIDs {
+        a := m.alerts[id]
+        a.CaseID = m.alertCase(id)
+        switch {
+        case q.Status != "" && a.Status != q.Status,
+            q.Severity != "" && a.Severity != q.Severity,
+            q.RuleID != "" && a.RuleID != q.RuleID,
+            q.UserID != "" && a.SenderID != q.UserID && a.ReceiverID != q.UserID,
+            q.TransactionID != "" && a.TransactionID != q.TransactionID,
+            q.CaseID != "" && a.CaseID != q.CaseID:
+            continue
+        }
+        alerts = append(alerts, a)
+    }
+    sort.SliceStable(alerts, func(i, j int) bool {
+        if alerts[i].CreatedAt != alerts[j].CreatedAt {
+            return alerts[i].CreatedAt > alerts[j].CreatedAt
+        }
+        return alerts[i].ID > alerts[j].ID
+    })
+    if limit := pageLimit(q.Limit); len(alerts) > limit {
+        alerts = alerts[:limit]
+    }
+    return alerts, nil
+}
+
+// UpdateAlert changes an alert's status and/or moves it to another case.
+func (m *MemoryStore) UpdateAlert(id string, patch models.AlertPatch) (models.Alert, error) {
+    if patch.Status != nil && !hasString(alertStatuses, *patch.Status) {
+        return models.Alert{}, fmt.Errorf("%w: status must be open, acknowledged or dismissed", ErrInvalidQuery)
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    a, ok := m.alerts[id]
+    if !ok {
+        return models.Alert{}, ErrAlertNotFound
+    }
+    if patch.CaseID != nil && *patch.CaseID != "" {
+        if _, ok := m.cases[*patch.CaseID]; !ok {
+            return models.Alert{}, ErrCaseNotFound
+        }
+    }
+    if patch.Status != nil {
+        a.Status = *patch.Status
+        m.alerts[id] = a
+    }
+    if patch.CaseID != nil {
+        if old := m.alertCase(id); old != "" {
+            c := m.cases[old]
+            c.AlertIDs = removeID(c.AlertIDs, id)
+            m.cases[old] = c
+        }
+        if *patch.CaseID != "" {
+            c := m.cases[*patch.CaseID]
+            c.AlertIDs = append(c.AlertIDs, id)
+            c.UpdatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+            m.cases[c.ID] = c
+        }
+    }
+    a.CaseID = m.alertCase(id)
+    return a, nil
+}
+
+// DeleteAlert removes an alert and takes it off its case.
+func (m *MemoryStore) DeleteAlert(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.alerts[id]; !ok {
+        return ErrAlertNotFound
+    }
+    if c := m.alertCase(id); c != "" {
+        cs := m.cases[c]
+        cs.AlertIDs = removeID(cs.AlertIDs, id)
+        m.cases[c] = cs
+    }
+    delete(m.alerts, id)
+    m.alertIDs = removeID(m.alertIDs, id)
+    return nil
+}
@@ -0,0 +1,712 @@
+package graph
+
+import (
+    "context"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// caseTransitions lists the statuses a case may move to from each status.
+// Reopening a closed case clears its disposition.
+var caseTransitions = map[string][]string{
+    "open":          {"investigating", "closed"},
+    "investigating": {"open", "escalated", "closed"},
+    "escalated":     {"investigating", "closed"},
+    "closed":        {"open"},
+}
+
+// applyCasePatch applies the scalar fields of patch to c and enforces the
+// status lifecycle. Closing requires a disposition; a disposition can only
+// be set on a case that is or becomes closed.
+func applyCasePatch(c models.Case, patch models.CasePatch, now string) (models.Case, error) {
+    if patch.Title != nil {
+        if strings.TrimSpace(*patch.Title) == "" {
+            return c, fmt.Errorf("%w: title must not be empty", ErrInvalidQuery)
+        }
+        c.Title = *patch.Title
+    }
+    if patch.Description != nil {
+        c.Description = *patch.Description
+    }
+    if patch.Assignee != nil {
+        c.Assignee = *patch.Assignee
+    }
+    if patch.Status != nil && *patch.Status != c.Status {
+        if _, ok := caseTransitions[*patch.Status]; !ok {
+            return c, fmt.Errorf("%w: status must be open, investigating, escalated or closed", ErrInvalidQuery)
+        }
+        if !hasString(caseTransitions[c.Status], *patch.Status) {
+            return c, fmt.Errorf("%w: %s to %s", ErrInvalidTransition, c.Status, *patch.Status)
+        }
+        if c.Status == "closed" {
+            c.Disposition = ""
+            c.ClosedAt = ""
+        }
+        c.Status = *patch.Status
+        if c.Status == "closed" {
+            c.ClosedAt = now
+        }
+    }
+    if patch.Disposition != nil {
+        if c.Status != "closed" {
+            return c, fmt.Errorf("%w: disposition can only be set when closing the case", ErrInvalidQuery)
+        }
+        c.Disposition = *patch.Disposition
+    }
+    if c.Status == "closed" && strings.TrimSpace(c.Disposition) == "" {
+        return c, fmt.Errorf("%w: closing a case requires a disposition", ErrInvalidQuery)
+    }
+    c.UpdatedAt = now
+    return c, nil
+}
+
+func validateCaseRequest(req models.CaseRequest) error {
+    if strings.TrimSpace(req.Title) == "" {
+        return fmt.Errorf("%w: title is required", ErrInvalidQuery)
+    }
+    return nil
+}
+
+func validateNote(note models.CaseNote) error {
+    if strings.TrimSpace(note.Text) == "" {
+        return fmt.Errorf("%w: text is required", ErrInvalidQuery)
+    }
+    return nil
+}
+
+func validateEvidence(ev models.CaseEvidence) error {
+    if strings.TrimSpace(ev.Kind) == "" {
+        return fmt.Errorf("%w: kind is required", ErrInvalidQuery)
+    }
+    if len(ev.Data) == 0 || !json.Valid(ev.Data) {
+        return fmt.Errorf("%w: data must be a JSON value", ErrInvalidQuery)
+    }
+    return nil
+}
+
+// distinct drops duplicates and empty IDs, keeping the first occurrence.
+func distinct(ids []string) []string {
+    seen := make(map[string]bool, len(ids))
+    out := []string{}
+    for _, id := range ids {
+        if id != "" && !seen[id] {
+            seen[id] = true
+            out = append(out, id)
+        }
+    }
+    return out
+}
+
+// caseReturn loads the case bound to c with everything linked to it. The
+// columns are the ones caseFromRecord reads.
+const caseReturn = `
+             OPTIONAL MATCH (c)-[:INCLUDES]->(a:Alert)
+             WITH c, collect(a.id) AS alertIds
+             OPTIONAL MATCH (c)-[:CONCERNS]->(u:User)
+             WITH c, alertIds, collect(u.id) AS userIds
+             OPTIONAL MATCH (c)-[:CONCERNS]->(t:Transaction)
+             WITH c, alertIds, userIds, collect(t.id) AS txIds
+             OPTIONAL MATCH (c)-[:HAS_NOTE]->(n:Note)
+             WITH c, alertIds, userIds, txIds, collect(properties(n)) AS notes
+             OPTIONAL MATCH (c)-[:HAS_EVIDENCE]->(e:Evidence)
+             WITH c, alertIds, userIds, txIds, notes, collect(properties(e)) AS evidence
+             RETURN properties(c), alertIds, userIds, txIds, notes, evidence`
+
+func stringList(v any) []string {
+    out := []string{}
+    for _, x := range v.([]any) {
+        if s, ok := x.(string); ok {
+            out = append(out, s)
+        }
+    }
+    sort.Strings(out)
+    return out
+}
+
+func caseFromRecord(values []any) models.Case {
+    props := values[0].(map[string]any)
+    c := models.Case{
+        ID:             stringProp(props, "id"),
+        Title:          stringProp(props, "title"),
+        Description:    stringProp(props, "description"),
+        Status:         stringProp(props, "status"),
+        Disposition:    stringProp(props, "disposition"),
+        Assignee:       stringProp(props, "assignee"),
+        CreatedAt:      timeProp(props, "createdAt"),
+        UpdatedAt:      timeProp(props, "updatedAt"),
+        ClosedAt:       timeProp(props, "closedAt"),
+        AlertIDs:       stringList(values[1]),
+        UserIDs:        stringList(values[2]),
+        TransactionIDs: stringList(values[3]),
+        Notes:          []models.CaseNote{},
+        Evidence:       []models.CaseEvidence{},
+    }
+    for _, v := range values[4].([]any) {
+        p := v.(map[string]any)
+        c.Notes = append(c.Notes, models.CaseNote{
+            ID:        stringProp(p, "id"),
+            Author:    stringProp(p, "author"),
+            Text:      stringProp(p, "text"),
+            CreatedAt: timeProp(p, "createdAt"),
+        })
+    }
+    for _, v := range values[5].([]any) {
+        p := v.(map[string]any)
+        c.Evidence = append(c.Evidence, models.CaseEvidence{
+            ID:        stringProp(p, "id"),
+            Kind:      stringProp(p, "kind"),
+            Title:     stringProp(p, "title"),
+            Source:    stringProp(p, "source"),
+            Data:      json.RawMessage(stringProp(p, "data")),
+            CreatedAt: timeProp(p, "createdAt"),
+        })
+    }
+    sort.SliceStable(c.Notes, func(i, j int) bool { return c.Notes[i].CreatedAt < c.Notes[j].CreatedAt })
+    sort.SliceStable(c.Evidence, func(i, j int) bool { return c.Evidence[i].CreatedAt < c.Evidence[j].CreatedAt })
+    return c
+}
+
+func readCase(ctx context.Context, tx neo4j.ManagedTransaction, id string) (models.Case, error) {
+    rs, err := tx.Run(ctx, `MATCH (c:Case) WHERE c.id = $id`+caseReturn, map[string]any{"id": id})
+    if err != nil {
+        return models.Case{}, err
+    }
+    if !rs.Next(ctx) {
+        if err := rs.Err(); err != nil {
+            return models.Case{}, err
+        }
+        return models.Case{}, ErrCaseNotFound
+    }
+    return caseFromRecord(rs.Record().Values), nil
+}
+
+// linkCase attaches alerts, users and transactions to a case. An alert
+// belongs to at most one case, so it is moved off any other case first.
+// Unknown IDs fail the whole write.
+func linkCase(ctx context.Context, tx neo4j.ManagedTransaction, id string, alertIDs, userIDs, txIDs []string) error {
+    links := []struct {
+        ids     []string
+        stmt    string
+        missing error
+    }{
+        {distinct(alertIDs), `UNWIND $ids AS aid
+             MATCH (a:Alert) WHERE a.id = aid
+             OPTIONAL MATCH (:Case)-[r:INCLUDES]->(a)
+             DELETE r
+             WITH DISTINCT a
+             MATCH (c:Case) WHERE c.id = $id
+             MERGE (c)-[:INCLUDES]->(a)
+             RETURN count(a)`, ErrAlertNotFound},
+        {distinct(userIDs), `UNWIND $ids AS uid
+             MATCH (u:User) WHERE u.id = uid
+             MATCH (c:Case) WHERE c.id = $id
+             MERGE (c)-[:CONCERNS]->(u)
+             RETURN count(u)`, ErrUserNotFound},
+        {distinct(txIDs), `UNWIND $ids AS tid
+             MATCH (t:Transaction) WHERE t.id = tid
+             MATCH (c:Case) WHERE c.id = $id
+             MERGE (c)-[:CONCERNS]->(t)
+             RETURN count(t)`, ErrTransactionNotFound},
+    }
+    for _, l := range links {
+        if len(l.ids) == 0 {
+            continue
+        }
+        rs, err := tx.Run(ctx, l.stmt, map[string]any{"id": id, "ids": l.ids})
+        if err != nil {
+            return err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return err
+        }
+        if rec.Values[0].(int64) != int64(len(l.ids)) {
+            return l.missing
+        }
+    }
+    return nil
+}
+
+// CreateCase opens a case and links the given alerts, users and
+// transactions to it.
+func (d *Driver) CreateCase(req models.CaseRequest) (models.Case, error) {
+    if err := validateCaseRequest(req); err != nil {
+        return models.Case{}, err
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `CREATE (c:Case {
+               id:          randomUUID(),
+               title:       $title,
+               description: $description,
+               assignee:    $assignee,
+               status:      'open',
+               createdAt:   datetime($now),
+               updatedAt:   datetime($now)
+             })
+             RETURN c.id`,
+            map[string]any{
+                "title":       req.Title,
+                "description": req.Description,
+                "assignee":    req.Assignee,
+                "now":         time.Now().UTC().Format(time.RFC3339Nano),
+            },
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        id := rec.Values[0].(string)
+        if err := linkCase(ctx, tx, id, req.AlertIDs, req.UserIDs, req.TransactionIDs); err != nil {
+            return nil, err
+        }
+        return readCase(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Case{}, err
+    }
+    return raw.(models.Case), nil
+}
+
+// GetCase returns a case with its links, notes and evidence.
+func (d *Driver) GetCase(id string) (models.Case, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        return readCase(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Case{}, err
+    }
+    return raw.(models.Case), nil
+}
+
+// ListCases returns the cases matching q, most recently updated first.
+func (d *Driver) ListCases(q models.CaseQuery) ([]models.Case, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    var conds []string
+    params := map[string]any{"limit": pageLimit(q.Limit)}
+    if q.Status != "" {
+        conds = append(conds, "c.status = $status")
+        params["status"] = q.Status
+    }
+    if q.Assignee != "" {
+        conds = append(conds, "c.assignee = $assignee")
+        params["assignee"] = q.Assignee
+    }
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (c:Case)`+whereClause(conds)+`
+             WITH c ORDER BY c.updatedAt DESC, c.id DESC LIMIT $limit`+caseReturn+`
+             ORDER BY c.updatedAt DESC, c.id DESC`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        cases := []models.Case{}
+        for rs.Next(ctx) {
+            cases = append(cases, caseFromRecord(rs.Record().Values))
+        }
+        return cases, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.Case), nil
+}
+
+// UpdateCase applies patch to a case. Status changes follow
+// caseTransitions; a disallowed one fails with ErrInvalidTransition.
+func (d *Driver) UpdateCase(id string, patch models.CasePatch) (models.Case, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        cur, err := readCase(ctx, tx, id)
+        if err != nil {
+            return nil, err
+        }
+        next, err := applyCasePatch(cur, patch, time.Now().UTC().Format(time.RFC3339Nano))
+        if err != nil {
+            return nil, err
+        }
+        var closedAt, disposition any
+        if next.ClosedAt != "" {
+            closedAt = next.ClosedAt
+        }
+        if next.Disposition != "" {
+            disposition = next.Disposition
+        }
+        if _, err := tx.Run(ctx,
+            `MATCH (c:Case) WHERE c.id = $id
+             SET c.title = $title,
+                 c.description = $description,
+                 c.assignee = $assignee,
+                 c.status = $status,
+                 c.disposition = $disposition,
+                 c.updatedAt = datetime($updatedAt),
+                 c.closedAt = CASE WHEN $closedAt IS NULL THEN null ELSE datetime($closedAt) END`,
+            map[string]any{
+                "id":          id,
+                "title":       next.Title,
+                "description": next.Description,
+                "assignee":    next.Assignee,
+                "status":      next.Status,
+                "disposition": disposition,
+                "updatedAt":   next.UpdatedAt,
+                "closedAt":    closedAt,
+            },
+        ); err != nil {
+            return nil, err
+        }
+        if err := linkCase(ctx, tx, id, patch.AddAlertIDs, patch.AddUserIDs, patch.AddTransactionIDs); err != nil {
+            return nil, err
+        }
+        return readCase(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Case{}, err
+    }
+    return raw.(models.Case), nil
+}
+
+// DeleteCase removes a case with its notes and evidence. Its alerts stay
+// and no longer belong to a case.
+func (d *Driver) DeleteCase(id string) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (c:Case) WHERE c.id = $id
+             OPTIONAL MATCH (c)-[:HAS_NOTE|HAS_EVIDENCE]->(x)
+             DETACH DELETE x
+             WITH DISTINCT c
+             DETACH DELETE c
+             RETURN count(*)`,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if rec.Values[0].(int64) == 0 {
+            return nil, ErrCaseNotFound
+        }
+        return nil, nil
+    })
+    return err
+}
+
+// AddCaseNote appends a note to a case.
+func (d *Driver) AddCaseNote(caseID string, note models.CaseNote) (models.CaseNote, error) {
+    if err := validateNote(note); err != nil {
+        return models.CaseNote{}, err
+    }
+    note.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (c:Case) WHERE c.id = $caseId
+             CREATE (c)-[:HAS_NOTE]->(n:Note {
+               id:        randomUUID(),
+               author:    $author,
+               text:      $text,
+               createdAt: datetime($now)
+             })
+             SET c.updatedAt = datetime($now)
+             RETURN n.id`,
+            map[string]any{"caseId": caseID, "author": note.Author, "text": note.Text, "now": note.CreatedAt},
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rs.Next(ctx) {
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrCaseNotFound
+        }
+        note.ID = rs.Record().Values[0].(string)
+        return note, nil
+    })
+    if err != nil {
+        return models.CaseNote{}, err
+    }
+    return raw.(models.CaseNote), nil
+}
+
+// AddCaseEvidence attaches a copy of an analytics result to a case. The
+// data is stored as a JSON string.
+func (d *Driver) AddCaseEvidence(caseID string, ev models.CaseEvidence) (models.CaseEvidence, error) {
+    if err := validateEvidence(ev); err != nil {
+        return models.CaseEvidence{}, err
+    }
+    ev.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (c:Case) WHERE c.id = $caseId
+             CREATE (c)-[:HAS_EVIDENCE]->(e:Evidence {
+               id:        randomUUID(),
+               kind:      $kind,
+               title:     $title,
+               source:    $source,
+               data:      $data,
+               createdAt: datetime($now)
+             })
+             SET c.updatedAt = datetime($now)
+             RETURN e.id`,
+            map[string]any{
+                "caseId": caseID,
+                "kind":   ev.Kind,
+                "title":  ev.Title,
+                "source": ev.Source,
+                "data":   string(ev.Data),
+                "now":    ev.CreatedAt,
+            },
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rs.Next(ctx) {
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrCaseNotFound
+        }
+        ev.ID = rs.Record().Values[0].(string)
+        return ev, nil
+    })
+    if err != nil {
+        return models.CaseEvidence{}, err
+    }
+    return raw.(models.CaseEvidence), nil
+}
+
+// copyCase returns c with its own slices, so callers can't mutate the
+// store. Callers must hold the lock.
+func copyCase(c models.Case) models.Case {
+    c.AlertIDs = append([]string{}, c.AlertIDs...)
+    c.UserIDs = append([]string{}, c.UserIDs...)
+    c.TransactionIDs = append([]string{}, c.TransactionIDs...)
+    c.Notes = append([]models.CaseNote{}, c.Notes...)
+    c.Evidence = append([]models.CaseEvidence{}, c.Evidence...)
+    sort.Strings(c.AlertIDs)
+    sort.Strings(c.UserIDs)
+    sort.Strings(c.TransactionIDs)
+    return c
+}
+
+// linkCase is the in-memory counterpart of linkCase. Callers must hold the
+// write lock.
+func (m *MemoryStore) linkCase(c models.Case, alertIDs, userIDs, txIDs []string) (models.Case, error) {
+    alertIDs, userIDs, txIDs = distinct(alertIDs), distinct(userIDs), distinct(txIDs)
+    for _, id := range alertIDs {
+        if _, ok := m.alerts[id]; !ok {
+            return c, ErrAlertNotFound
+        }
+    }
+    for _, id := range userIDs {
+        if _, ok := m.users[id]; !ok {
+            return c, ErrUserNotFound
+        }
+    }
+    for _, id := range txIDs {
+        if _, ok := m.txs[id]; !ok {
+            return c, ErrTransactionNotFound
+        }
+    }
+    for _, id := range alertIDs {
+        if old := m.alertCase(id); old != "" && old != c.ID {
+            oc := m.cases[old]
+            oc.AlertIDs = removeID(oc.AlertIDs, id)
+            m.cases[old] = oc
+        }
+        if !hasString(c.AlertIDs, id) {
+            c.AlertIDs = append(c.AlertIDs, id)
+        }
+    }
+    for _, id := range userIDs {
+        if !hasString(c.UserIDs, id) {
+            c.UserIDs = append(c.UserIDs, id)
+        }
+    }
+    for _, id := range txIDs {
+        if !hasString(c.TransactionIDs, id) {
+            c.TransactionIDs = append(c.TransactionIDs, id)
+        }
+    }
+    return c, nil
+}
+
+// CreateCase opens a case and links the given alerts, users and
+// transactions to it.
+func (m *MemoryStore) CreateCase(req models.CaseRequest) (models.Case, error) {
+    if err := validateCaseRequest(req); err != nil {
+        return models.Case{}, err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    now := time.Now().UTC().Format(time.RFC3339Nano)
+    c := models.Case{
+        ID:          newID(),
+        Title:       req.Title,
+        Description: req.Description,
+        Assignee:    req.Assignee,
+        Status:      "open",
+        CreatedAt:   now,
+        UpdatedAt:   now,
+    }
+    c, err := m.linkCase(c, req.AlertIDs, req.UserIDs, req.TransactionIDs)
+    if err != nil {
+        return models.Case{}, err
+    }
+    m.cases[c.ID] = c
+    m.caseIDs = append(m.caseIDs, c.ID)
+    return copyCase(c), nil
+}
+
+// GetCase returns a case with its links, notes and evidence.
+func (m *MemoryStore) GetCase(id string) (models.Case, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    c, ok := m.cases[id]
+    if !ok {
+        return models.Case{}, ErrCaseNotFound
+    }
+    return copyCase(c), nil
+}
+
+// ListCases returns the cases matching q, most recently updated first.
+func (m *MemoryStore) ListCases(q models.CaseQuery) ([]models.Case, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    cases := []models.Case{}
+    for _, id := range m.caseIDs {
+        c := m.cases[id]
+        if q.Status != "" && c.Status != q.Status || q.Assignee != "" && c.Assignee != q.Assignee {
+            continue
+        }
+        cases = append(cases, copyCase(c))
+    }
+    sort.SliceStable(cases, func(i, j int) bool {
+        if cases[i].UpdatedAt != cases[j].UpdatedAt {
+            return cases[i].UpdatedAt > cases[j].UpdatedAt
+        }
+        return cases[i].ID > cases[j].ID
+    })
+    if limit := pageLimit(q.Limit); len(cases) > limit {
+        cases = cases[:limit]
+    }
+    return cases, nil
+}
+
+// UpdateCase applies patch to a case. Status changes follow
+// caseTransitions; a disallowed one fails with ErrInvalidTransition.
+func (m *MemoryStore) UpdateCase(id string, patch models.CasePatch) (models.Case, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    cur, ok := m.cases[id]
+    if !ok {
+        return models.Case{}, ErrCaseNotFound
+    }
+    c, err := applyCasePatch(copyCase(cur), patch, time.Now().UTC().Format(time.RFC3339Nano))
+    if err != nil {
+        return models.Case{}, err
+    }
+    if c, err = m.linkCase(c, patch.AddAlertIDs, patch.AddUserIDs, patch.AddTransactionIDs); err != nil {
+        return models.Case{}, err
+    }
+    m.cases[id] = c
+    return copyCase(c), nil
+}
+
+// DeleteCase removes a case with its notes and evidence. Its alerts stay
+// and no longer belong to a case.
+func (m *MemoryStore) DeleteCase(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.cases[id]; !ok {
+        return ErrCaseNotFound
+    }
+    delete(m.cases, id)
+    m.caseIDs = removeID(m.caseIDs, id)
+    return nil
+}
+
+// AddCaseNote appends a note to a case.
+func (m *MemoryStore) AddCaseNote(caseID string, note models.CaseNote) (models.CaseNote, error) {
+    if err := validateNote(note); err != nil {
+        return models.CaseNote{}, err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    c, ok := m.cases[caseID]
+    if !ok {
+        return models.CaseNote{}, ErrCaseNotFound
+    }
+    note.ID = newID()
+    note.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+    c.Notes = append(c.Notes, note)
+    c.UpdatedAt = note.CreatedAt
+    m.cases[caseID] = c
+    return note, nil
+}
+
+// AddCaseEvidence attaches a copy of an analytics result to a case.
+func (m *MemoryStore) AddCaseEvidence(caseID string, ev models.CaseEvidence) (models.CaseEvidence, error) {
+    if err := validateEvidence(ev); err != nil {
+        return models.CaseEvidence{}, err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    c, ok := m.cases[caseID]
+    if !ok {
+        return models.CaseEvidence{}, ErrCaseNotFound
+    }
+    ev.ID = newID()
+    ev.CreatedAt = time.Now().UTC().Format(time.RFC3339Nano)
+    ev.Data = append(json.RawMessage{}, ev.Data...)
+    c.Evidence = append(c.Evidence, ev)
+    c.UpdatedAt = ev.CreatedAt
+    m.cases[caseID] = c
+    return ev, nil
+}
@@ -0,0 +1,114 @@
+package graph
+
+import (
+    "encoding/json"
+    "errors"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestCaseWorkflow(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    tx := s.tx(alice, bob, 5000, "", "")
+    alerts, err := s.CreateAlerts([]models.Alert{
+        {RuleID: "big", Severity: "high", TransactionID: tx, SenderID: alice, ReceiverID: bob},
+        {RuleID: "new", Severity: "low", TransactionID: tx, SenderID: alice, ReceiverID: bob},
+    })
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(alerts) != 2 || alerts[0].ID == "" || alerts[0].Status != "open" || alerts[0].CreatedAt == "" {
+        t.Fatalf("alerts = %+v, want two open alerts with IDs", alerts)
+    }
+    big, small := alerts[0].ID, alerts[1].ID
+
+    c, err := s.CreateCase(models.CaseRequest{Title: "Large transfer", AlertIDs: []string{big}, UserIDs: []string{alice, alice}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if c.Status != "open" || len(c.AlertIDs) != 1 || len(c.UserIDs) != 1 {
+        t.Fatalf("case = %+v, want an open case with the alert and Alice once", c)
+    }
+    if _, err := s.CreateCase(models.CaseRequest{Title: " "}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("untitled case: error = %v, want ErrInvalidQuery", err)
+    }
+    if _, err := s.CreateCase(models.CaseRequest{Title: "x", AlertIDs: []string{"nothing"}}); !errors.Is(err, ErrAlertNotFound) {
+        t.Errorf("case with an unknown alert: error = %v, want ErrAlertNotFound", err)
+    }
+
+    status := "acknowledged"
+    a, err := s.UpdateAlert(small, models.AlertPatch{Status: &status, CaseID: &c.ID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if a.Status != "acknowledged" || a.CaseID != c.ID {
+        t.Errorf("alert = %+v, want acknowledged on %s", a, c.ID)
+    }
+    inCase, err := s.ListAlerts(models.AlertQuery{CaseID: c.ID})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(inCase) != 2 {
+        t.Errorf("alerts on the case = %+v, want both", inCase)
+    }
+    bogus := "closed"
+    if _, err := s.UpdateAlert(big, models.AlertPatch{Status: &bogus}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("alert status closed: error = %v, want ErrInvalidQuery", err)
+    }
+
+    if _, err := s.AddCaseNote(c.ID, models.CaseNote{Author: "ana", Text: "Called the bank"}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.AddCaseEvidence(c.ID, models.CaseEvidence{Kind: "cycle", Data: json.RawMessage(`{"length":3}`)}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.AddCaseEvidence(c.ID, models.CaseEvidence{Kind: "cycle", Data: json.RawMessage(`{`)}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("evidence with invalid JSON: error = %v, want ErrInvalidQuery", err)
+    }
+
+    patch := func(p models.CasePatch) (models.Case, error) { return s.UpdateCase(c.ID, p) }
+    str := func(v string) *string { return &v }
+    if _, err := patch(models.CasePatch{Status: str("escalated")}); !errors.Is(err, ErrInvalidTransition) {
+        t.Errorf("open to escalated: error = %v, want ErrInvalidTransition", err)
+    }
+    if _, err := patch(models.CasePatch{Status: str("investigating")}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := patch(models.CasePatch{Status: str("closed")}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("closing without a disposition: error = %v, want ErrInvalidQuery", err)
+    }
+    if _, err := patch(models.CasePatch{Disposition: str("fraud")}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("disposition on an open case: error = %v, want ErrInvalidQuery", err)
+    }
+    closed, err := patch(models.CasePatch{Status: str("closed"), Disposition: str("fraud")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if closed.ClosedAt == "" || closed.Disposition != "fraud" || len(closed.Notes) != 1 || len(closed.Evidence) != 1 {
+        t.Errorf("closed case = %+v, want its close time, disposition, note and evidence", closed)
+    }
+    reopened, err := patch(models.CasePatch{Status: str("open")})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if reopened.ClosedAt != "" || reopened.Disposition != "" {
+        t.Errorf("reopened case = %+v, want no close time or disposition", reopened)
+    }
+
+    if err := s.DeleteCase(c.ID); err != nil {
+        t.Fatal(err)
+    }
+    a, err = s.GetAlert(big)
+    if err != nil {
+        t.Fatalf("alert after deleting its case: %v", err)
+    }
+    if a.CaseID != "" {
+        t.Errorf("alert = %+v, want it off the deleted case", a)
+    }
+    if _, err := s.GetCase(c.ID); !errors.Is(err, ErrCaseNotFound) {
+        t.Errorf("deleted case: error = %v, want ErrCaseNotFound", err)
+    }
+}
@@ -0,0 +1,360 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "math"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// centralityAlgorithms are the supported algorithms. Their names double as
+// the User property that write-back stores the score in.
+var centralityAlgorithms = []string{"pagerank", "degree", "betweenness"}
+
+// scoreProperties are the stored scores: the centrality algorithms and the
+// risk score written by risk recomputation.
+var scoreProperties = append(append([]string{}, centralityAlgorithms...), "risk")
+
+const (
+    pageRankDamping    = 0.85
+    pageRankIterations = 100
+    pageRankTolerance  = 1e-9
+)
+
+// projection is a weighted, directed user-to-user graph derived from the
+// property graph. Undirected links are stored in both directions.
+type projection struct {
+    nodes   []string // sorted user IDs
+    out     map[string]map[string]float64
+    skipped []models.SkippedHub
+}
+
+func (p *projection) add(a, b string, w float64) {
+    if a == b {
+        return
+    }
+    if p.out[a] == nil {
+        p.out[a] = make(map[string]float64)
+    }
+    p.out[a][b] += w
+}
+
+// project builds the user graph from a snapshot. "flow" links sender to
+// receiver weighted by amount, scaled so the average money edge weighs 1;
+// "shared" links users with a shared email or phone, and the senders of
+// transactions made from the same device, with weight 1 each way. Hubs
+// with more than MaxHubFanOut distinct users add no links and are listed in
+// skipped.
+func (s *Snapshot) project(edges string) *projection {
+    p := &projection{nodes: sortedKeys(s.Users), out: make(map[string]map[string]float64)}
+
+    if edges == "flow" || edges == "both" {
+        flow := &projection{out: make(map[string]map[string]float64)}
+        total, n := 0.0, 0
+        for _, t := range s.Transactions {
+            if t.FromUserID == t.ToUserID || Value(t) <= 0 {
+                continue
+            }
+            if flow.out[t.FromUserID][t.ToUserID] == 0 {
+                n++
+            }
+            flow.add(t.FromUserID, t.ToUserID, Value(t))
+            total += Value(t)
+        }
+        for a, nbrs := range flow.out {
+            for b, w := range nbrs {
+                p.add(a, b, w*float64(n)/total)
+            }
+        }
+    }
+
+    if edges == "shared" || edges == "both" {
+        sender := make(map[string]string, len(s.Transactions))
+        for _, t := range s.Transactions {
+            sender[t.ID] = t.FromUserID
+        }
+        for _, h := range s.Hubs {
+            ids := h.Members
+            if h.Type == "SHARED_DEVICE" {
+                ids = make([]string, len(h.Members))
+                for i, id := range h.Members {
+                    ids[i] = sender[id]
+                }
+            }
+            linked := LinkMembers(ids, func(a, b string) {
+                p.add(a, b, 1)
+                p.add(b, a, 1)
+            })
+            if !linked {
+                p.skipped = append(p.skipped, skippedHub(h, ids))
+            }
+        }
+    }
+    return p
+}
+
+// skippedHub describes h, whose members resolve to ids, as left out.
+func skippedHub(h Hub, ids []string) models.SkippedHub {
+    return models.SkippedHub{ID: h.ID, Type: h.Type, Members: len(DistinctMembers(ids))}
+}
+
+// pageRank runs weighted PageRank. Users without outgoing edges spread
+// their rank evenly over the graph.
+func (p *projection) pageRank() map[string]float64 {
+    n := float64(len(p.nodes))
+    rank := make(map[string]float64, len(p.nodes))
+    for _, id := range p.nodes {
+        rank[id] = 1 / n
+    }
+    outWeight := make(map[string]float64, len(p.out))
+    for a, nbrs := range p.out {
+        for _, w := range nbrs {
+            outWeight[a] += w
+        }
+    }
+
+    for i := 0; i < pageRankIterations; i++ {
+        dangling := 0.0
+        for _, id := range p.nodes {
+            if outWeight[id] == 0 {
+                dangling += rank[id]
+            }
+        }
+        next := make(map[string]float64, len(p.nodes))
+        base := (1-pageRankDamping)/n + pageRankDamping*dangling/n
+        for _, id := range p.nodes {
+            next[id] = base
+        }
+        for a, nbrs := range p.out {
+            for b, w := range nbrs {
+                next[b] += pageRankDamping * rank[a] * w / outWeight[a]
+            }
+        }
+        diff := 0.0
+        for _, id := range p.nodes {
+            diff += math.Abs(next[id] - rank[id])
+        }
+        rank = next
+        if diff < pageRankTolerance {
+            break
+        }
+    }
+    return rank
+}
+
+// degree returns the number of distinct neighbours, in either direction,
+// divided by n-1.
+func (p *projection) degree() map[string]float64 {
+    nbrs := make(map[string]map[string]bool, len(p.nodes))
+    for a, out := range p.out {
+        for b := range out {
+            if nbrs[a] == nil {
+                nbrs[a] = make(map[string]bool)
+            }
+            if nbrs[b] == nil {
+                nbrs[b] = make(map[string]bool)
+            }
+            nbrs[a][b], nbrs[b][a] = true, true
+        }
+    }
+    scores := make(map[string]float64, len(p.nodes))
+    for _, id := range p.nodes {
+        if len(p.nodes) > 1 {
+            scores[id] = float64(len(nbrs[id])) / float64(len(p.nodes)-1)
+        } else {
+            scores[id] = 0
+        }
+    }
+    return scores
+}
+
+// betweenness computes directed betweenness over hop counts (Brandes),
+// normalised by (n-1)(n-2).
+func (p *projection) betweenness() map[string]float64 {
+    scores := make(map[string]float64, len(p.nodes))
+    for _, id := range p.nodes {
+        scores[id] = 0
+    }
+    adj := make(map[string][]string, len(p.out))
+    for a, out := range p.out {
+        adj[a] = sortedKeys(out)
+    }
+
+    for _, s := range p.nodes {
+        var stack []string
+        preds := make(map[string][]string)
+        sigma := map[string]float64{s: 1}
+        dist := map[string]int{s: 0}
+        queue := []string{s}
+        for len(queue) > 0 {
+            v := queue[0]
+            queue = queue[1:]
+            stack = append(stack, v)
+            for _, w := range adj[v] {
+                if _, seen := dist[w]; !seen {
+                    dist[w] = dist[v] + 1
+                    queue = append(queue, w)
+                }
+                if dist[w] == dist[v]+1 {
+                    sigma[w] += sigma[v]
+                    preds[w] = append(preds[w], v)
+                }
+            }
+        }
+        delta := make(map[string]float64, len(stack))
+        for i := len(stack) - 1; i >= 0; i-- {
+            w := stack[i]
+            for _, v := range preds[w] {
+                delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+            }
+            if w != s {
+                scores[w] += delta[w]
+            }
+        }
+    }
+
+    if n := float64(len(p.nodes)); n > 2 {
+        for id := range scores {
+            scores[id] /= (n - 1) * (n - 2)
+        }
+    }
+    return scores
+}
+
+// ComputeCentrality scores every user with the requested algorithm over the
+// projected user graph and returns one page, highest score first. With
+// q.Write the scores of all users are stored on the User nodes. Hubs too
+// large to link are listed with the page.
+func ComputeCentrality(store GraphStore, q models.CentralityQuery) (models.CentralityResponse, error) {
+    var empty models.CentralityResponse
+    if q.Algorithm == "" {
+        q.Algorithm = "pagerank"
+    }
+    if !hasString(centralityAlgorithms, q.Algorithm) {
+        return empty, fmt.Errorf("%w: unknown algorithm %q", ErrInvalidQuery, q.Algorithm)
+    }
+    if q.Edges == "" {
+        q.Edges = "flow"
+    }
+    if !hasString([]string{"flow", "shared", "both"}, q.Edges) {
+        return empty, fmt.Errorf("%w: edges must be flow, shared or both", ErrInvalidQuery)
+    }
+    // Cursors are only valid for the ranking they were issued for.
+    sortBy := q.Algorithm + ":" + q.Edges
+    field := sortField{expr: "score", kind: "number"}
+    cur, err := decodeCursor(q.Cursor, sortBy, field)
+    if err != nil {
+        return empty, err
+    }
+
+    snap, err := store.Snapshot(SnapshotFilter{IncludeShared: q.Edges != "flow"})
+    if err != nil {
+        return empty, err
+    }
+    p := snap.project(q.Edges)
+    var scores map[string]float64
+    switch q.Algorithm {
+    case "pagerank":
+        scores = p.pageRank()
+    case "degree":
+        scores = p.degree()
+    case "betweenness":
+        scores = p.betweenness()
+    }
+
+    if q.Write {
+        if err := store.WriteUserScores(q.Algorithm, scores); err != nil {
+            return empty, err
+        }
+    }
+
+    items := make([]models.CentralityScore, 0, len(p.nodes))
+    for _, id := range p.nodes {
+        items = append(items, models.CentralityScore{UserID: id, Name: snap.Users[id].Name, Score: scores[id]})
+    }
+    page, err := paginate(items, sortBy, field, true, cur, pageLimit(q.Limit),
+        func(c models.CentralityScore, _ string) any { return c.Score },
+        func(c models.CentralityScore) string { return c.UserID })
+    if err != nil {
+        return empty, err
+    }
+    return models.CentralityResponse{Page: page, SkippedHubs: p.skipped}, nil
+}
+
+func hasString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+// userScores reads the score properties returned as a map projection
+// (u {.pagerank, .degree, .betweenness, .risk}), skipping unset ones.
+func userScores(v any) map[string]float64 {
+    props, _ := v.(map[string]any)
+    var scores map[string]float64
+    for _, alg := range scoreProperties {
+        if f, ok := props[alg].(float64); ok {
+            if scores == nil {
+                scores = make(map[string]float64)
+            }
+            scores[alg] = f
+        }
+    }
+    return scores
+}
+
+// WriteUserScores stores each score as a property named after the
+// algorithm on the matching User node.
+func (d *Driver) WriteUserScores(algorithm string, scores map[string]float64) error {
+    if !hasString(scoreProperties, algorithm) {
+        return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidQuery, algorithm)
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    rows := make([]map[string]any, 0, len(scores))
+    for id, score := range scores {
+        rows = append(rows, map[string]any{"id": id, "props": map[string]any{algorithm: score}})
+    }
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        _, err := tx.Run(ctx,
+            `UNWIND $rows AS row
+             MATCH (u:User) WHERE u.id = row.id
+             SET u += row.props`,
+            map[string]any{"rows": rows},
+        )
+        return nil, err
+    })
+    return err
+}
+
+// WriteUserScores stores each score on the matching user.
+func (m *MemoryStore) WriteUserScores(algorithm string, scores map[string]float64) error {
+    if !hasString(scoreProperties, algorithm) {
+        return fmt.Errorf("%w: unknown algorithm %q", ErrInvalidQuery, algorithm)
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for id, score := range scores {
+        u, ok := m.users[id]
+        if !ok {
+            continue
+        }
+        // Copy so users handed out earlier keep their old scores.
+        next := make(map[string]float64, len(u.Scores)+1)
+        for k, v := range u.Scores {
+            next[k] = v
+        }
+        next[algorithm] = score
+        u.Scores = next
+        m.users[id] = u
+    }
+    return nil
+}
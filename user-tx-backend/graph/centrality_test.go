@@ -0,0 +1,107 @@
+package graph
+
+import (
+    "errors"
+    "fmt"
+    "math"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestAnalyticsListSkippedHubs(t *testing.T) {
+    s := newTestStore(t)
+    sink := s.user("Sink", "sink@example.com", "0")
+    for i := 0; i <= MaxHubFanOut; i++ {
+        u := s.user(fmt.Sprintf("U%d", i), fmt.Sprintf("u%d@example.com", i), fmt.Sprint(i+1))
+        s.tx(u, sink, 1, "kiosk", "")
+    }
+    want := MaxHubFanOut + 1
+
+    scores, err := ComputeCentrality(s, models.CentralityQuery{Algorithm: "degree", Edges: "shared"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(scores.SkippedHubs) != 1 || scores.SkippedHubs[0].Type != "SHARED_DEVICE" || scores.SkippedHubs[0].Members != want {
+        t.Errorf("centrality skipped = %+v, want the kiosk with %d senders", scores.SkippedHubs, want)
+    }
+    for _, sc := range scores.Items {
+        if sc.Score != 0 {
+            t.Errorf("score of %s = %v, want 0 without the kiosk", sc.UserID, sc.Score)
+        }
+    }
+    flow, err := ComputeCentrality(s, models.CentralityQuery{Edges: "flow"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(flow.SkippedHubs) != 0 {
+        t.Errorf("flow skipped = %+v, want none", flow.SkippedHubs)
+    }
+
+    for _, projection := range []string{"user", "transaction"} {
+        comm, err := DetectCommunities(s, models.CommunityQuery{Projection: projection})
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(comm.SkippedHubs) != 1 || comm.SkippedHubs[0].Members != want {
+            t.Errorf("%s communities skipped = %+v, want the kiosk with %d members", projection, comm.SkippedHubs, want)
+        }
+    }
+}
+
+func TestComputeCentrality(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    s.tx(a, b, 10, "", "")
+    s.tx(d, b, 10, "", "")
+    s.tx(b, c, 20, "", "")
+
+    scores := func(q models.CentralityQuery) map[string]float64 {
+        t.Helper()
+        page, err := ComputeCentrality(s, q)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if len(page.Items) != 4 || page.Total != 4 {
+            t.Fatalf("%s scores = %+v, want all 4 users", q.Algorithm, page)
+        }
+        m := make(map[string]float64, len(page.Items))
+        for i, sc := range page.Items {
+            if i > 0 && sc.Score > page.Items[i-1].Score {
+                t.Errorf("%s scores = %+v, want highest first", q.Algorithm, page.Items)
+            }
+            m[sc.UserID] = sc.Score
+        }
+        return m
+    }
+
+    pr := scores(models.CentralityQuery{Algorithm: "pagerank", Write: true})
+    if !(pr[c] > pr[b] && pr[b] > pr[a] && math.Abs(pr[a]-pr[d]) < 1e-12) {
+        t.Errorf("pagerank = %v, want C above B above A and D", pr)
+    }
+    if sum := pr[a] + pr[b] + pr[c] + pr[d]; math.Abs(sum-1) > 1e-6 {
+        t.Errorf("pagerank sums to %v, want 1", sum)
+    }
+    deg := scores(models.CentralityQuery{Algorithm: "degree"})
+    if deg[b] != 1 || deg[a] != 1.0/3 || deg[c] != 1.0/3 {
+        t.Errorf("degree = %v, want 1 for B and 1/3 for the others", deg)
+    }
+    btw := scores(models.CentralityQuery{Algorithm: "betweenness"})
+    if math.Abs(btw[b]-1.0/3) > 1e-12 || btw[a] != 0 || btw[c] != 0 {
+        t.Errorf("betweenness = %v, want 1/3 for B and 0 for the others", btw)
+    }
+
+    u, _, err := s.GetUserRelationships(c)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if u.Scores["pagerank"] != pr[c] {
+        t.Errorf("stored scores = %v, want pagerank %v", u.Scores, pr[c])
+    }
+    if _, err := ComputeCentrality(s, models.CentralityQuery{Algorithm: "closeness"}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("unknown algorithm: error = %v, want ErrInvalidQuery", err)
+    }
+}
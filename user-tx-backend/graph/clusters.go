@@ -0,0 +1,1025 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// Transactions that share a sender or receiver, a device or an IP address
+// are in one cluster. Each transaction stores the ID of its cluster as
+// clusterId, and each cluster is a (:Cluster {id, size}) node. A new
+// transaction joins the cluster of its neighbours; when it connects
+// several, the largest keeps its ID and only the smaller ones are
+// relabelled, so IDs are stable and every transaction is relabelled at
+// most log n times. Updates and deletes that can split a cluster
+// recompute only that cluster. RebuildClusters recomputes everything, for
+// backfill and to check the stored clusters.
+
+// clusterRels are the relationships that put transactions in one cluster.
+const clusterRels = "SENT|RECEIVED_BY|USED_DEVICE|FROM_IP"
+
+// components groups txIDs into connected components, joining the
+// transactions of each group (the transactions attached to one user,
+// device or IP). Components and their members are in txIDs order.
+func components(txIDs []string, groups map[string][]string) [][]string {
+    parent := make(map[string]string, len(txIDs))
+    var find func(string) string
+    find = func(x string) string {
+        p, ok := parent[x]
+        if !ok || p == x {
+            return x
+        }
+        root := find(p)
+        parent[x] = root
+        return root
+    }
+    for _, ids := range groups {
+        for _, id := range ids[1:] {
+            if a, b := find(ids[0]), find(id); a != b {
+                parent[b] = a
+            }
+        }
+    }
+
+    index := make(map[string]int)
+    var comps [][]string
+    for _, id := range txIDs {
+        root := find(id)
+        i, ok := index[root]
+        if !ok {
+            i = len(comps)
+            index[root] = i
+            comps = append(comps, nil)
+        }
+        comps[i] = append(comps[i], id)
+    }
+    return comps
+}
+
+// partition assigns the connected components of txIDs a cluster ID each.
+// A stored ID stays with the component holding most of its transactions,
+// the larger component on a tie, so a consistent graph keeps every ID and
+// a split cluster keeps its ID on its largest part. Other components are
+// named by fresh.
+func partition(
+    txIDs []string,
+    groups map[string][]string,
+    stored map[string]string,
+    fresh func(members []string) string,
+) (assign map[string]string, sizes map[string]int) {
+    comps := components(txIDs, groups)
+
+    type claim struct {
+        comp, count int
+        id          string
+    }
+    var claims []claim
+    for i, comp := range comps {
+        counts := make(map[string]int)
+        for _, id := range comp {
+            if s := stored[id]; s != "" {
+                counts[s]++
+            }
+        }
+        for id, n := range counts {
+            claims = append(claims, claim{comp: i, count: n, id: id})
+        }
+    }
+    sort.Slice(claims, func(i, j int) bool {
+        a, b := claims[i], claims[j]
+        if a.count != b.count {
+            return a.count > b.count
+        }
+        if la, lb := len(comps[a.comp]), len(comps[b.comp]); la != lb {
+            return la > lb
+        }
+        if a.comp != b.comp {
+            return a.comp < b.comp
+        }
+        return a.id < b.id
+    })
+
+    names := make([]string, len(comps))
+    taken := make(map[string]bool)
+    for _, c := range claims {
+        if names[c.comp] == "" && !taken[c.id] {
+            names[c.comp] = c.id
+            taken[c.id] = true
+        }
+    }
+
+    assign = make(map[string]string, len(txIDs))
+    sizes = make(map[string]int, len(comps))
+    for i, comp := range comps {
+        if names[i] == "" {
+            names[i] = fresh(comp)
+        }
+        for _, id := range comp {
+            assign[id] = names[i]
+        }
+        sizes[names[i]] = len(comp)
+    }
+    return assign, sizes
+}
+
+// randomCluster names a new cluster.
+func randomCluster([]string) string {
+    return newID()
+}
+
+// smallestID names a cluster that is not stored after its smallest
+// transaction ID, which is stable across reads.
+func smallestID(ids []string) string {
+    first := ids[0]
+    for _, id := range ids[1:] {
+        if id < first {
+            first = id
+        }
+    }
+    return first
+}
+
+// clusterList lists the cluster of each transaction in txIDs order.
+func clusterList(txIDs []string, assign map[string]string) []models.TransactionCluster {
+    clusters := make([]models.TransactionCluster, 0, len(txIDs))
+    for _, id := range txIDs {
+        clusters = append(clusters, models.TransactionCluster{TransactionID: id, ClusterID: assign[id]})
+    }
+    return clusters
+}
+
+// resized counts the cluster records that differ between two size tables.
+func resized(before, after map[string]int) int {
+    n := 0
+    for id, size := range after {
+        if before[id] != size {
+            n++
+        }
+    }
+    for id := range before {
+        if _, ok := after[id]; !ok {
+            n++
+        }
+    }
+    return n
+}
+
+// relinked reports whether a patch moves a transaction to other users, a
+// device or an IP, which can change its cluster.
+func relinked(patch models.TransactionPatch) bool {
+    return patch.FromUserID != nil || patch.ToUserID != nil || patch.DeviceID != nil || patch.IP != nil
+}
+
+// clusterMembers loads the transactions matching where, with their stored
+// cluster and the users, devices and IPs they are attached to.
+func clusterMembers(
+    ctx context.Context,
+    tx neo4j.ManagedTransaction,
+    where string,
+    params map[string]any,
+) (txIDs []string, stored map[string]string, groups map[string][]string, err error) {
+    rs, err := tx.Run(ctx,
+        `MATCH (t:Transaction) WHERE `+where+`
+         OPTIONAL MATCH (t)-[:`+clusterRels+`]-(h)
+         RETURN t.id, coalesce(t.clusterId, ''), labels(h)[0] + ':' + h.id`,
+        params,
+    )
+    if err != nil {
+        return nil, nil, nil, err
+    }
+    stored = make(map[string]string)
+    groups = make(map[string][]string)
+    for rs.Next(ctx) {
+        rec := rs.Record()
+        id := rec.Values[0].(string)
+        if _, ok := stored[id]; !ok {
+            txIDs = append(txIDs, id)
+            stored[id] = rec.Values[1].(string)
+        }
+        if key, ok := rec.Values[2].(string); ok {
+            groups[key] = append(groups[key], id)
+        }
+    }
+    return txIDs, stored, groups, rs.Err()
+}
+
+// writeClusters stores the given cluster of each transaction, the sizes of
+// the given clusters, and deletes the dropped cluster records.
+func writeClusters(
+    ctx context.Context,
+    tx neo4j.ManagedTransaction,
+    assign map[string]string,
+    sizes map[string]int,
+    drop []string,
+) error {
+    var rows, sizeRows []map[string]any
+    for _, id := range sortedKeys(assign) {
+        rows = append(rows, map[string]any{"id": id, "cluster": assign[id]})
+    }
+    for _, id := range sortedKeys(sizes) {
+        sizeRows = append(sizeRows, map[string]any{"id": id, "size": sizes[id]})
+    }
+    if _, err := tx.Run(ctx,
+        `UNWIND $rows AS row
+         MATCH (t:Transaction) WHERE t.id = row.id
+         SET t.clusterId = row.cluster`,
+        map[string]any{"rows": rows},
+    ); err != nil {
+        return err
+    }
+    if _, err := tx.Run(ctx,
+        `UNWIND $sizes AS s
+         MERGE (c:Cluster {id: s.id})
+         SET c.size = s.size`,
+        map[string]any{"sizes": sizeRows},
+    ); err != nil {
+        return err
+    }
+    _, err := tx.Run(ctx,
+        `UNWIND $drop AS id
+         MATCH (c:Cluster) WHERE c.id = id
+         DELETE c`,
+        map[string]any{"drop": append([]string{}, drop...)},
+    )
+    return err
+}
+
+// joinPlan works out how new transactions join the clusters. groups maps
+// each user, device or IP of a new transaction to the new transactions
+// attached to it, and existing to the cluster of the stored transactions
+// attached to it, if any. Each connected set of new transactions and
+// clusters ends up in the largest of those clusters, or a new one when
+// there is none. It returns the cluster of each new transaction, the new
+// size of each cluster that grew, and the clusters merged into another.
+func joinPlan(
+    ids []string,
+    groups map[string][]string,
+    existing map[string]string,
+    sizes map[string]int,
+) (assign map[string]string, grown map[string]int, merged map[string]string) {
+    const clusterKey = "Cluster:" // not a transaction ID
+    nodes := append([]string{}, ids...)
+    linked := make(map[string][]string, len(groups))
+    seen := make(map[string]bool)
+    for _, key := range sortedKeys(groups) {
+        linked[key] = append([]string{}, groups[key]...)
+        if c := existing[key]; c != "" {
+            linked[key] = append(linked[key], clusterKey+c)
+            if !seen[c] {
+                seen[c] = true
+                nodes = append(nodes, clusterKey+c)
+            }
+        }
+    }
+
+    assign = make(map[string]string, len(ids))
+    grown = make(map[string]int)
+    merged = make(map[string]string)
+    for _, comp := range components(nodes, linked) {
+        var clusters, txs []string
+        for _, n := range comp {
+            if c, ok := strings.CutPrefix(n, clusterKey); ok {
+                clusters = append(clusters, c)
+            } else {
+                txs = append(txs, n)
+            }
+        }
+        sort.Slice(clusters, func(i, j int) bool {
+            if a, b := sizes[clusters[i]], sizes[clusters[j]]; a != b {
+                return a > b
+            }
+            return clusters[i] < clusters[j]
+        })
+        winner := newID()
+        if len(clusters) > 0 {
+            winner = clusters[0]
+        }
+        size := len(txs)
+        for _, c := range clusters {
+            size += sizes[c]
+            if c != winner {
+                merged[c] = winner
+            }
+        }
+        for _, id := range txs {
+            assign[id] = winner
+        }
+        grown[winner] = size
+    }
+    return assign, grown, merged
+}
+
+// joinCluster puts a transaction without a cluster into the cluster of the
+// transactions it shares a user, device or IP with.
+func joinCluster(ctx context.Context, tx neo4j.ManagedTransaction, id string) error {
+    return joinClusters(ctx, tx, []string{id})
+}
+
+// joinClusters puts transactions without a cluster into the clusters of
+// the transactions they share a user, device or IP with, and of each other,
+// as joinPlan says. One stored neighbour per user, device or IP is enough,
+// as all of them are in the same cluster.
+func joinClusters(ctx context.Context, tx neo4j.ManagedTransaction, ids []string) error {
+    if len(ids) == 0 {
+        return nil
+    }
+    params := map[string]any{"ids": ids}
+    rs, err := tx.Run(ctx,
+        `UNWIND $ids AS id
+         MATCH (t:Transaction)-[:`+clusterRels+`]-(h)
+         WHERE t.id = id
+         RETURN t.id, labels(h)[0] + ':' + h.id`,
+        params,
+    )
+    if err != nil {
+        return err
+    }
+    groups := make(map[string][]string)
+    for rs.Next(ctx) {
+        rec := rs.Record()
+        key := rec.Values[1].(string)
+        groups[key] = append(groups[key], rec.Values[0].(string))
+    }
+    if err := rs.Err(); err != nil {
+        return err
+    }
+
+    rs, err = tx.Run(ctx,
+        `UNWIND $ids AS id
+         MATCH (t:Transaction)-[:`+clusterRels+`]-(h)
+         WHERE t.id = id
+         WITH DISTINCT h
+         CALL {
+           WITH h
+           MATCH (h)-[:`+clusterRels+`]-(o:Transaction)
+           WHERE o.clusterId IS NOT NULL
+           RETURN o.clusterId AS cluster
+           LIMIT 1
+         }
+         OPTIONAL MATCH (c:Cluster) WHERE c.id = cluster
+         RETURN labels(h)[0] + ':' + h.id, cluster, coalesce(c.size, 0)`,
+        params,
+    )
+    if err != nil {
+        return err
+    }
+    existing := make(map[string]string)
+    sizes := make(map[string]int)
+    for rs.Next(ctx) {
+        rec := rs.Record()
+        cluster := rec.Values[1].(string)
+        existing[rec.Values[0].(string)] = cluster
+        sizes[cluster] = int(rec.Values[2].(int64))
+    }
+    if err := rs.Err(); err != nil {
+        return err
+    }
+
+    assign, grown, merged := joinPlan(ids, groups, existing, sizes)
+    var moves []map[string]any
+    for _, from := range sortedKeys(merged) {
+        moves = append(moves, map[string]any{"from": from, "to": merged[from]})
+    }
+    if _, err := tx.Run(ctx,
+        `UNWIND $moves AS m
+         MATCH (o:Transaction) WHERE o.clusterId = m.from
+         SET o.clusterId = m.to`,
+        map[string]any{"moves": moves},
+    ); err != nil {
+        return err
+    }
+    return writeClusters(ctx, tx, assign, grown, sortedKeys(merged))
+}
+
+// clustersOf returns the clusters of the given transactions.
+func clustersOf(ctx context.Context, tx neo4j.ManagedTransaction, ids []string) ([]string, error) {
+    rs, err := tx.Run(ctx,
+        `UNWIND $ids AS id
+         MATCH (t:Transaction) WHERE t.id = id AND t.clusterId IS NOT NULL
+         RETURN DISTINCT t.clusterId`,
+        map[string]any{"ids": ids},
+    )
+    if err != nil {
+        return nil, err
+    }
+    var clusters []string
+    for rs.Next(ctx) {
+        clusters = append(clusters, rs.Record().Values[0].(string))
+    }
+    return clusters, rs.Err()
+}
+
+// splitClusters recomputes the given clusters after transactions left
+// them. The largest part of each keeps its ID, the others get new ones,
+// and clusters left empty are deleted.
+func splitClusters(ctx context.Context, tx neo4j.ManagedTransaction, ids []string) error {
+    if len(ids) == 0 {
+        return nil
+    }
+    txIDs, stored, groups, err := clusterMembers(ctx, tx, "t.clusterId IN $ids", map[string]any{"ids": ids})
+    if err != nil {
+        return err
+    }
+    assign, sizes := partition(txIDs, groups, stored, randomCluster)
+    for id, cluster := range assign {
+        if stored[id] == cluster {
+            delete(assign, id)
+        }
+    }
+    var drop []string
+    for _, id := range ids {
+        if _, ok := sizes[id]; !ok {
+            drop = append(drop, id)
+        }
+    }
+    return writeClusters(ctx, tx, assign, sizes, drop)
+}
+
+// leaveCluster takes a transaction out of its cluster before it is linked
+// again, e.g. to another device, and splits what is left.
+func leaveCluster(ctx context.Context, tx neo4j.ManagedTransaction, id string) error {
+    old, err := clustersOf(ctx, tx, []string{id})
+    if err != nil {
+        return err
+    }
+    if _, err := tx.Run(ctx,
+        `MATCH (t:Transaction) WHERE t.id = $id REMOVE t.clusterId`,
+        map[string]any{"id": id},
+    ); err != nil {
+        return err
+    }
+    return splitClusters(ctx, tx, old)
+}
+
+// ClusterTransactions returns the stored cluster of every transaction.
+// Leaving out transactions in excludeStatuses can split clusters, so the
+// clusters of the others are computed instead; each part keeps the stored
+// ID where partition allows and is otherwise named after its smallest
+// transaction ID.
+func (d *Driver) ClusterTransactions(excludeStatuses []string) ([]models.TransactionCluster, error) {
+    if err := checkStatuses(excludeStatuses); err != nil {
+        return nil, err
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        if len(excludeStatuses) > 0 {
+            txIDs, stored, groups, err := clusterMembers(ctx, tx,
+                "NOT coalesce(t.status, $legacy) IN $exclude",
+                map[string]any{"exclude": append([]string{}, excludeStatuses...), "legacy": legacyStatus},
+            )
+            if err != nil {
+                return nil, err
+            }
+            assign, _ := partition(txIDs, groups, stored, smallestID)
+            return clusterList(txIDs, assign), nil
+        }
+        rs, err := tx.Run(ctx,
+            `MATCH (t:Transaction)
+             RETURN t.id, coalesce(t.clusterId, t.id)`,
+            nil,
+        )
+        if err != nil {
+            return nil, err
+        }
+        clusters := []models.TransactionCluster{}
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            clusters = append(clusters, models.TransactionCluster{
+                TransactionID: rec.Values[0].(string),
+                ClusterID:     rec.Values[1].(string),
+            })
+        }
+        return clusters, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.TransactionCluster), nil
+}
+
+// RebuildClusters recomputes every cluster from the users, devices and IPs
+// of all transactions, keeping stored IDs where partition allows. With
+// check it only reports what it would change.
+func (d *Driver) RebuildClusters(check bool) (models.ClusterRebuildReport, error) {
+    report := models.ClusterRebuildReport{Check: check}
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    type state struct {
+        txIDs  []string
+        stored map[string]string
+        groups map[string][]string
+        sizes  map[string]int
+    }
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        var s state
+        var err error
+        if s.txIDs, s.stored, s.groups, err = clusterMembers(ctx, tx, "true", nil); err != nil {
+            return nil, err
+        }
+        rs, err := tx.Run(ctx, `MATCH (c:Cluster) RETURN c.id, coalesce(c.size, 0)`, nil)
+        if err != nil {
+            return nil, err
+        }
+        s.sizes = make(map[string]int)
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            s.sizes[rec.Values[0].(string)] = int(rec.Values[1].(int64))
+        }
+        return s, rs.Err()
+    })
+    if err != nil {
+        return report, fmt.Errorf("RebuildClusters: %w", err)
+    }
+    s := raw.(state)
+
+    assign, sizes := partition(s.txIDs, s.groups, s.stored, randomCluster)
+    changed := make(map[string]string)
+    for id, cluster := range assign {
+        if s.stored[id] != cluster {
+            changed[id] = cluster
+        }
+    }
+    report.Transactions = len(s.txIDs)
+    report.Clusters = len(sizes)
+    report.Reassigned = len(changed)
+    report.Resized = resized(s.sizes, sizes)
+    if check {
+        return report, nil
+    }
+
+    ids := sortedKeys(changed)
+    for start := 0; start < len(ids); start += migrationBatch {
+        end := start + migrationBatch
+        if end > len(ids) {
+            end = len(ids)
+        }
+        batch := make(map[string]string, end-start)
+        for _, id := range ids[start:end] {
+            batch[id] = changed[id]
+        }
+        if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+            return nil, writeClusters(ctx, tx, batch, nil, nil)
+        }); err != nil {
+            return report, fmt.Errorf("RebuildClusters: %w", err)
+        }
+    }
+    var drop []string
+    for id := range s.sizes {
+        if _, ok := sizes[id]; !ok {
+            drop = append(drop, id)
+        }
+    }
+    if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        return nil, writeClusters(ctx, tx, nil, sizes, drop)
+    }); err != nil {
+        return report, fmt.Errorf("RebuildClusters: %w", err)
+    }
+    return report, nil
+}
+
+// BackfillClusters rebuilds the clusters when some transaction has none,
+// e.g. on the first start after clusters were stored. It is cheap to run
+// on every start.
+func (d *Driver) BackfillClusters() (int, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (t:Transaction) WHERE t.clusterId IS NULL RETURN count(t) > 0`,
+            nil,
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        return rec.Values[0].(bool), nil
+    })
+    if err != nil {
+        return 0, fmt.Errorf("BackfillClusters: %w", err)
+    }
+    if !raw.(bool) {
+        return 0, nil
+    }
+    report, err := d.RebuildClusters(false)
+    return report.Reassigned, err
+}
+
+// clusterGroups indexes the memory store's transactions for which keep
+// returns true by their sender, receiver, device and IP. Callers must hold
+// the lock.
+func (m *MemoryStore) clusterGroups(keep func(id string) bool) map[string][]string {
+    groups := make(map[string][]string)
+    for _, id := range m.txIDs {
+        if !keep(id) {
+            continue
+        }
+        t := m.txs[id]
+        groups["User:"+t.FromUserID] = append(groups["User:"+t.FromUserID], id)
+        if t.ToUserID != t.FromUserID {
+            groups["User:"+t.ToUserID] = append(groups["User:"+t.ToUserID], id)
+        }
+    }
+    for _, k := range hubKinds {
+        if k.owner != "Transaction" {
+            continue
+        }
+        for key, ids := range m.hubGroups(k) {
+            for _, id := range ids {
+                if keep(id) {
+                    groups[key] = append(groups[key], id)
+                }
+            }
+        }
+    }
+    return groups
+}
+
+// joinCluster is joinCluster for the memory store. Callers must hold the
+// write lock.
+func (m *MemoryStore) joinCluster(id string) {
+    m.joinClusters([]string{id})
+}
+
+// joinClusters is joinClusters for the memory store. Callers must hold the
+// write lock.
+func (m *MemoryStore) joinClusters(ids []string) {
+    isNew := make(map[string]bool, len(ids))
+    for _, id := range ids {
+        isNew[id] = true
+    }
+    groups := make(map[string][]string)
+    existing := make(map[string]string)
+    for key, members := range m.clusterGroups(func(string) bool { return true }) {
+        for _, id := range members {
+            if isNew[id] {
+                groups[key] = append(groups[key], id)
+            } else if c, ok := m.clusterOf[id]; ok {
+                existing[key] = c
+            }
+        }
+    }
+
+    assign, grown, merged := joinPlan(ids, groups, existing, m.clusterSize)
+    if len(merged) > 0 {
+        for id, c := range m.clusterOf {
+            if to, ok := merged[c]; ok {
+                m.clusterOf[id] = to
+            }
+        }
+    }
+    for c := range merged {
+        delete(m.clusterSize, c)
+    }
+    for id, c := range assign {
+        m.clusterOf[id] = c
+    }
+    for c, n := range grown {
+        m.clusterSize[c] = n
+    }
+}
+
+// splitClusters is splitClusters for the memory store. Callers must hold
+// the write lock.
+func (m *MemoryStore) splitClusters(ids []string) {
+    var txIDs []string
+    for _, id := range m.txIDs {
+        if c, ok := m.clusterOf[id]; ok && hasString(ids, c) {
+            txIDs = append(txIDs, id)
+        }
+    }
+    groups := m.clusterGroups(func(id string) bool {
+        c, ok := m.clusterOf[id]
+        return ok && hasString(ids, c)
+    })
+    assign, sizes := partition(txIDs, groups, m.clusterOf, randomCluster)
+    for _, id := range ids {
+        delete(m.clusterSize, id)
+    }
+    for id, c := range assign {
+        m.clusterOf[id] = c
+    }
+    for c, n := range sizes {
+        m.clusterSize[c] = n
+    }
+}
+
+// leaveCluster is leaveCluster for the memory store. Callers must hold the
+// write lock.
+func (m *MemoryStore) leaveCluster(id string) {
+    c, ok := m.clusterOf[id]
+    if !ok {
+        return
+    }
+    delete(m.clusterOf, id)
+    m.splitClusters([]string{c})
+}
+
+// ClusterTransactions returns the stored cluster of every transaction,
+// computing them as the Driver does when statuses are excluded.
+func (m *MemoryStore) ClusterTransactions(excludeStatuses []string) ([]models.TransactionCluster, error) {
+    if err := checkStatuses(excludeStatuses); err != nil {
+        return nil, err
+    }
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    if len(excludeStatuses) == 0 {
+        return clusterList(m.txIDs, m.clusterOf), nil
+    }
+    keep := func(id string) bool { return !hasString(excludeStatuses, m.txs[id].Status) }
+    var txIDs []string
+    for _, id := range m.txIDs {
+        if keep(id) {
+            txIDs = append(txIDs, id)
+        }
+    }
+    assign, _ := partition(txIDs, m.clusterGroups(keep), m.clusterOf, smallestID)
+    return clusterList(txIDs, assign), nil
+}
+
+// RebuildClusters recomputes every cluster, keeping stored IDs where
+// partition allows. With check it only reports what it would change.
+func (m *MemoryStore) RebuildClusters(check bool) (models.ClusterRebuildReport, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    return m.rebuildClusters(check, randomCluster), nil
+}
+
+// rebuildClusters does RebuildClusters, naming new clusters by fresh.
+// Callers must hold the write lock.
+func (m *MemoryStore) rebuildClusters(check bool, fresh func([]string) string) models.ClusterRebuildReport {
+    groups := m.clusterGroups(func(string) bool { return true })
+    assign, sizes := partition(m.txIDs, groups, m.clusterOf, fresh)
+    report := models.ClusterRebuildReport{
+        Check:        check,
+        Transactions: len(m.txIDs),
+        Clusters:     len(sizes),
+        Resized:      resized(m.clusterSize, sizes),
+    }
+    for id, c := range assign {
+        if m.clusterOf[id] != c {
+            report.Reassigned++
+        }
+    }
+    if !check {
+        m.clusterOf, m.clusterSize = assign, sizes
+    }
+    return report
+}
+
+// clusterGraphRels are the relationships of a cluster's subgraph: those
+// that define it and the follow-up links between its transactions.
+var clusterGraphRels = map[string]bool{
+    "SENT": true, "RECEIVED_BY": true, "USED_DEVICE": true, "FROM_IP": true,
+    "REVERSAL_OF": true, "CHARGEBACK_OF": true,
+}
+
+// ClusterGraph returns the transactions of a cluster with their users,
+// devices and IPs and the relationships between them, in the export
+// format.
+func (d *Driver) ClusterGraph(clusterID string) (models.GraphExportResponse, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        export := models.GraphExportResponse{}
+        params := map[string]any{"id": clusterID}
+
+        // 1) The transactions, then their users, devices and IPs
+        rs, err := tx.Run(ctx,
+            `MATCH (t:Transaction) WHERE t.clusterId = $id
+             OPTIONAL MATCH (t)-[:`+clusterRels+`]-(n)
+             WITH collect(DISTINCT t) AS txs, collect(DISTINCT n) AS others
+             UNWIND txs + others AS x
+             RETURN x.id, labels(x)[0], properties(x)`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            export.Nodes = append(export.Nodes, models.GraphNode{
+                ID:         rec.Values[0].(string),
+                Type:       rec.Values[1].(string),
+                Properties: rec.Values[2].(map[string]any),
+            })
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+        if len(export.Nodes) == 0 {
+            return nil, ErrClusterNotFound
+        }
+
+        // 2) Their relationships, follow-ups only inside the cluster
+        rs, err = tx.Run(ctx,
+            `MATCH (t:Transaction) WHERE t.clusterId = $id
+             MATCH (t)-[r:`+clusterRels+`|REVERSAL_OF|CHARGEBACK_OF]-(n)
+             WHERE NOT n:Transaction OR (n.clusterId = $id AND startNode(r) = t)
+             RETURN startNode(r).id, labels(startNode(r))[0], type(r),
+                    endNode(r).id, labels(endNode(r))[0]`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            export.Relationships = append(export.Relationships, models.GraphRelationship{
+                SourceID:     rec.Values[0].(string),
+                SourceType:   rec.Values[1].(string),
+                Relationship: rec.Values[2].(string),
+                TargetID:     rec.Values[3].(string),
+                TargetType:   rec.Values[4].(string),
+            })
+        }
+        return export, rs.Err()
+    })
+    if err != nil {
+        return models.GraphExportResponse{}, err
+    }
+    return raw.(models.GraphExportResponse), nil
+}
+
+// ClusterGraph returns the subgraph of a cluster, as the Driver does.
+func (m *MemoryStore) ClusterGraph(clusterID string) (models.GraphExportResponse, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    keep := make(map[string]bool)
+    for id, c := range m.clusterOf {
+        if c == clusterID {
+            keep[id] = true
+        }
+    }
+    if len(keep) == 0 {
+        return models.GraphExportResponse{}, ErrClusterNotFound
+    }
+
+    all := m.exportGraph()
+    export := models.GraphExportResponse{}
+    for _, r := range all.Relationships {
+        src, dst := keep[r.SourceID], keep[r.TargetID]
+        follow := r.SourceType == "Transaction" && r.TargetType == "Transaction"
+        if !clusterGraphRels[r.Relationship] || (follow && !(src && dst)) || (!src && !dst) {
+            continue
+        }
+        export.Relationships = append(export.Relationships, r)
+    }
+    ends := make(map[string]bool)
+    for _, r := range export.Relationships {
+        ends[r.SourceID], ends[r.TargetID] = true, true
+    }
+    for _, n := range all.Nodes {
+        if n.Type == "Transaction" && keep[n.ID] {
+            export.Nodes = append(export.Nodes, n)
+        }
+    }
+    for _, n := range all.Nodes {
+        if n.Type != "Transaction" && ends[n.ID] {
+            export.Nodes = append(export.Nodes, n)
+        }
+    }
+    return export, nil
+}
+
+// clusterSorts orders cluster summaries by each SummarizeClusters sort.
+var clusterSorts = map[string]func(a, b models.ClusterSummary) bool{
+    "size":    func(a, b models.ClusterSummary) bool { return a.Transactions < b.Transactions },
+    "users":   func(a, b models.ClusterSummary) bool { return a.Users < b.Users },
+    "devices": func(a, b models.ClusterSummary) bool { return a.Devices < b.Devices },
+    "total":   func(a, b models.ClusterSummary) bool { return a.TotalAmount < b.TotalAmount },
+    "max":     func(a, b models.ClusterSummary) bool { return a.MaxAmount < b.MaxAmount },
+    "first":   func(a, b models.ClusterSummary) bool { return a.FirstSeen < b.FirstSeen },
+    "last":    func(a, b models.ClusterSummary) bool { return a.LastSeen < b.LastSeen },
+    "id":      func(a, b models.ClusterSummary) bool { return a.ClusterID < b.ClusterID },
+}
+
+// SummarizeClusters describes each transaction cluster: its size, distinct
+// users and devices, total and largest amount, currencies and the time
+// between its first and last transaction. Clusters are filtered by size,
+// ordered by q.Sort and then by ID, and cut to q.Limit.
+func SummarizeClusters(store GraphStore, q models.ClusterSummaryQuery) (models.ClusterSummaryResponse, error) {
+    resp := models.ClusterSummaryResponse{Clusters: []models.ClusterSummary{}}
+    if q.Sort == "" {
+        q.Sort = "size"
+    }
+    less, ok := clusterSorts[q.Sort]
+    if !ok {
+        return resp, fmt.Errorf("%w: sort must be size, users, devices, total, max, first, last or id", ErrInvalidQuery)
+    }
+    if q.MinSize < 0 || q.MaxSize < 0 || q.Limit < 0 {
+        return resp, fmt.Errorf("%w: minSize, maxSize and limit must not be negative", ErrInvalidQuery)
+    }
+
+    clusters, err := store.ClusterTransactions(q.ExcludeStatuses)
+    if err != nil {
+        return resp, err
+    }
+    txs, err := store.GetAllTransactions()
+    if err != nil {
+        return resp, err
+    }
+    byID := make(map[string]models.Transaction, len(txs))
+    for _, t := range txs {
+        byID[t.ID] = t
+    }
+
+    type acc struct {
+        summary             models.ClusterSummary
+        users, devices, cur map[string]bool
+        first, last         time.Time
+    }
+    var order []string
+    accs := make(map[string]*acc)
+    for _, c := range clusters {
+        t, ok := byID[c.TransactionID]
+        if !ok {
+            continue
+        }
+        a := accs[c.ClusterID]
+        if a == nil {
+            a = &acc{
+                summary: models.ClusterSummary{ClusterID: c.ClusterID},
+                users:   map[string]bool{}, devices: map[string]bool{}, cur: map[string]bool{},
+            }
+            accs[c.ClusterID] = a
+            order = append(order, c.ClusterID)
+        }
+        v := Value(t)
+        s := &a.summary
+        s.Transactions++
+        s.TotalAmount += v
+        if s.Transactions == 1 || v > s.MaxAmount {
+            s.MaxAmount = v
+        }
+        if s.Currency == "" {
+            s.Currency = t.ReportingCurrency
+        }
+        a.users[t.FromUserID], a.users[t.ToUserID] = true, true
+        if t.DeviceID != "" {
+            a.devices[t.DeviceID] = true
+        }
+        a.cur[t.Currency] = true
+        if ts, err := time.Parse(time.RFC3339Nano, t.Timestamp); err == nil {
+            if a.first.IsZero() || ts.Before(a.first) {
+                a.first = ts
+            }
+            if ts.After(a.last) {
+                a.last = ts
+            }
+        }
+    }
+
+    for _, id := range order {
+        a := accs[id]
+        s := a.summary
+        if (q.MinSize > 0 && s.Transactions < q.MinSize) || (q.MaxSize > 0 && s.Transactions > q.MaxSize) {
+            continue
+        }
+        s.Users, s.Devices = len(a.users), len(a.devices)
+        s.Currencies = sortedKeys(a.cur)
+        if !a.first.IsZero() {
+            s.FirstSeen = a.first.Format(time.RFC3339)
+            s.LastSeen = a.last.Format(time.RFC3339)
+            s.SpanSeconds = a.last.Sub(a.first).Seconds()
+        }
+        resp.Clusters = append(resp.Clusters, s)
+    }
+    sort.SliceStable(resp.Clusters, func(i, j int) bool {
+        a, b := resp.Clusters[i], resp.Clusters[j]
+        if q.Desc {
+            a, b = b, a
+        }
+        if less(a, b) {
+            return true
+        }
+        if less(b, a) {
+            return false
+        }
+        return resp.Clusters[i].ClusterID < resp.Clusters[j].ClusterID
+    })
+    resp.Total = len(resp.Clusters)
+    if q.Limit > 0 && len(resp.Clusters) > q.Limit {
+        resp.Clusters = resp.Clusters[:q.Limit]
+    }
+    return resp, nil
+}
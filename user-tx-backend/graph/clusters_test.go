@@ -0,0 +1,80 @@
+package graph
+
+import (
+    "errors"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestSummarizeClusters(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    e := s.user("E", "e@example.com", "5")
+    ring := []string{
+        s.tx(a, b, 10, "dev-1", ""),
+        s.tx(b, c, 30, "dev-2", ""),
+        s.tx(c, a, 20, "dev-1", ""),
+    }
+    single := s.tx(d, e, 5, "", "")
+    cluster := s.clusters()
+
+    resp, err := SummarizeClusters(s, models.ClusterSummaryQuery{Desc: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.Total != 2 || len(resp.Clusters) != 2 {
+        t.Fatalf("summary = %+v, want 2 clusters", resp)
+    }
+    got := resp.Clusters[0]
+    want := models.ClusterSummary{
+        ClusterID: cluster[ring[0]], Transactions: 3, Users: 3, Devices: 2,
+        TotalAmount: 60, MaxAmount: 30, Currency: "USD",
+        FirstSeen: "2024-01-01T12:00:00Z", LastSeen: "2024-01-01T12:02:00Z", SpanSeconds: 120,
+    }
+    if got.ClusterID != want.ClusterID || got.Transactions != want.Transactions || got.Users != want.Users ||
+        got.Devices != want.Devices || got.TotalAmount != want.TotalAmount || got.MaxAmount != want.MaxAmount ||
+        got.Currency != want.Currency || got.FirstSeen != want.FirstSeen || got.LastSeen != want.LastSeen ||
+        got.SpanSeconds != want.SpanSeconds || len(got.Currencies) != 1 || got.Currencies[0] != "USD" {
+        t.Errorf("largest cluster = %+v, want %+v", got, want)
+    }
+    if got := resp.Clusters[1]; got.ClusterID != cluster[single] || got.Transactions != 1 || got.Devices != 0 {
+        t.Errorf("second cluster = %+v, want the single transfer", got)
+    }
+
+    resp, err = SummarizeClusters(s, models.ClusterSummaryQuery{MinSize: 2})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.Total != 1 || resp.Clusters[0].ClusterID != cluster[ring[0]] {
+        t.Errorf("clusters of 2 or more = %+v, want the ring", resp)
+    }
+    resp, err = SummarizeClusters(s, models.ClusterSummaryQuery{Sort: "total"})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.Clusters[0].ClusterID != cluster[single] {
+        t.Errorf("clusters by total = %+v, want the smallest total first", resp.Clusters)
+    }
+    if _, err := SummarizeClusters(s, models.ClusterSummaryQuery{Sort: "color"}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("unknown sort: error = %v, want ErrInvalidQuery", err)
+    }
+
+    g, err := s.ClusterGraph(cluster[ring[0]])
+    if err != nil {
+        t.Fatal(err)
+    }
+    types := make(map[string]int)
+    for _, n := range g.Nodes {
+        types[n.Type]++
+    }
+    if types["Transaction"] != 3 || types["User"] != 3 || types["Device"] != 2 || len(g.Nodes) != 8 {
+        t.Errorf("cluster graph node types = %v, want 3 transactions, 3 users and 2 devices", types)
+    }
+    if _, err := s.ClusterGraph("nothing"); !errors.Is(err, ErrClusterNotFound) {
+        t.Errorf("unknown cluster: error = %v, want ErrClusterNotFound", err)
+    }
+}
@@ -0,0 +1,388 @@
+package graph
+
+import (
+    "fmt"
+    "sort"
+
+    "user-tx-backend/models"
+)
+
+const (
+    defaultCommunitySize = 2
+    maxLouvainLevels     = 20
+    maxLabelIterations   = 100
+)
+
+// wgraph is an undirected weighted graph over dense indices. adj[i][j] is
+// symmetric; after aggregation adj[i][i] holds twice the internal weight.
+type wgraph struct {
+    adj []map[int]float64
+}
+
+func newWGraph(n int) *wgraph {
+    g := &wgraph{adj: make([]map[int]float64, n)}
+    for i := range g.adj {
+        g.adj[i] = make(map[int]float64)
+    }
+    return g
+}
+
+func (g *wgraph) link(a, b int, w float64) {
+    if a == b || w <= 0 {
+        return
+    }
+    g.adj[a][b] += w
+    g.adj[b][a] += w
+}
+
+func (g *wgraph) strength(i int) float64 {
+    k := 0.0
+    for _, w := range g.adj[i] {
+        k += w
+    }
+    return k
+}
+
+// neighbours returns the neighbours of i in index order, so every run visits
+// them the same way.
+func (g *wgraph) neighbours(i int) []int {
+    nbrs := make([]int, 0, len(g.adj[i]))
+    for j := range g.adj[i] {
+        nbrs = append(nbrs, j)
+    }
+    sort.Ints(nbrs)
+    return nbrs
+}
+
+// modularity scores a partition of g (community index per node).
+func (g *wgraph) modularity(comm []int) float64 {
+    m2 := 0.0
+    in := make(map[int]float64)
+    tot := make(map[int]float64)
+    for i := range g.adj {
+        k := g.strength(i)
+        m2 += k
+        tot[comm[i]] += k
+        for j, w := range g.adj[i] {
+            if comm[i] == comm[j] {
+                in[comm[i]] += w
+            }
+        }
+    }
+    if m2 == 0 {
+        return 0
+    }
+    q := 0.0
+    for c, t := range tot {
+        q += in[c]/m2 - (t/m2)*(t/m2)
+    }
+    return q
+}
+
+// louvain greedily moves nodes to the neighbouring community with the best
+// modularity gain, then collapses communities into nodes and repeats until
+// nothing moves.
+func (g *wgraph) louvain() []int {
+    comm := make([]int, len(g.adj))
+    for i := range comm {
+        comm[i] = i
+    }
+    level := g
+    for l := 0; l < maxLouvainLevels; l++ {
+        local, moved := level.louvainPass()
+        if !moved {
+            break
+        }
+        // Renumber the communities densely and aggregate.
+        index := make(map[int]int)
+        for _, c := range local {
+            if _, ok := index[c]; !ok {
+                index[c] = len(index)
+            }
+        }
+        next := newWGraph(len(index))
+        for i, nbrs := range level.adj {
+            for j, w := range nbrs {
+                next.adj[index[local[i]]][index[local[j]]] += w
+            }
+        }
+        for i := range comm {
+            comm[i] = index[local[comm[i]]]
+        }
+        level = next
+    }
+    return comm
+}
+
+// louvainPass is the local-moving phase of one Louvain level.
+func (g *wgraph) louvainPass() ([]int, bool) {
+    n := len(g.adj)
+    comm := make([]int, n)
+    k := make([]float64, n)
+    tot := make([]float64, n)
+    m2 := 0.0
+    for i := range g.adj {
+        comm[i] = i
+        k[i] = g.strength(i)
+        tot[i] = k[i]
+        m2 += k[i]
+    }
+    if m2 == 0 {
+        return comm, false
+    }
+
+    moved := false
+    for improved := true; improved; {
+        improved = false
+        for i := 0; i < n; i++ {
+            ci := comm[i]
+            tot[ci] -= k[i]
+            links := make(map[int]float64)
+            for _, j := range g.neighbours(i) {
+                if j != i {
+                    links[comm[j]] += g.adj[i][j]
+                }
+            }
+            best, bestGain := ci, links[ci]-tot[ci]*k[i]/m2
+            cands := make([]int, 0, len(links))
+            for c := range links {
+                cands = append(cands, c)
+            }
+            sort.Ints(cands)
+            for _, c := range cands {
+                if gain := links[c] - tot[c]*k[i]/m2; gain > bestGain+1e-12 {
+                    best, bestGain = c, gain
+                }
+            }
+            tot[best] += k[i]
+            if best != ci {
+                comm[i] = best
+                improved, moved = true, true
+            }
+        }
+    }
+    return comm, moved
+}
+
+// labelPropagation lets every node adopt the label carrying the most
+// weight among its neighbours until labels stop changing. Ties keep the
+// current label, then prefer the smallest.
+func (g *wgraph) labelPropagation() []int {
+    labels := make([]int, len(g.adj))
+    for i := range labels {
+        labels[i] = i
+    }
+    for it := 0; it < maxLabelIterations; it++ {
+        changed := false
+        for i := range g.adj {
+            weight := make(map[int]float64)
+            for _, j := range g.neighbours(i) {
+                weight[labels[j]] += g.adj[i][j]
+            }
+            if len(weight) == 0 {
+                continue
+            }
+            top := 0.0
+            for _, w := range weight {
+                if w > top {
+                    top = w
+                }
+            }
+            best := labels[i]
+            if weight[best] < top {
+                best = len(labels)
+                for l, w := range weight {
+                    if w == top && l < best {
+                        best = l
+                    }
+                }
+            }
+            if best != labels[i] {
+                labels[i] = best
+                changed = true
+            }
+        }
+        if !changed {
+            break
+        }
+    }
+    return labels
+}
+
+// communityGraph builds the projection to partition. Users are linked as in
+// the centrality projection; transactions are linked when they share a
+// user or a device. Hubs too large to link are returned with it.
+func (s *Snapshot) communityGraph(q models.CommunityQuery) ([]string, *wgraph, []models.SkippedHub) {
+    if q.Projection == "transaction" {
+        ids := make([]string, len(s.Transactions))
+        index := make(map[string]int, len(s.Transactions))
+        byUser := make(map[string][]int)
+        for i, t := range s.Transactions {
+            ids[i] = t.ID
+            index[t.ID] = i
+            byUser[t.FromUserID] = append(byUser[t.FromUserID], i)
+            if t.ToUserID != t.FromUserID {
+                byUser[t.ToUserID] = append(byUser[t.ToUserID], i)
+            }
+        }
+        g := newWGraph(len(ids))
+        var skipped []models.SkippedHub
+        for _, txs := range byUser {
+            for a := 0; a < len(txs); a++ {
+                for b := a + 1; b < len(txs); b++ {
+                    g.link(txs[a], txs[b], 1)
+                }
+            }
+        }
+        for _, h := range s.Hubs {
+            if h.Type != "SHARED_DEVICE" {
+                continue
+            }
+            linked := LinkMembers(h.Members, func(a, b string) {
+                ia, okA := index[a]
+                ib, okB := index[b]
+                if okA && okB {
+                    g.link(ia, ib, 1)
+                }
+            })
+            if !linked {
+                skipped = append(skipped, skippedHub(h, h.Members))
+            }
+        }
+        return ids, g, skipped
+    }
+
+    p := s.project(q.Edges)
+    index := make(map[string]int, len(p.nodes))
+    for i, id := range p.nodes {
+        index[id] = i
+    }
+    g := newWGraph(len(p.nodes))
+    for a, nbrs := range p.out {
+        for b, w := range nbrs {
+            // Directed weights are summed into one undirected edge.
+            g.link(index[a], index[b], w)
+        }
+    }
+    return p.nodes, g, p.skipped
+}
+
+// DetectCommunities partitions the user or transaction projection with
+// Louvain or label propagation and summarises each community's money flow.
+func DetectCommunities(store GraphStore, q models.CommunityQuery) (models.CommunitiesResponse, error) {
+    var resp models.CommunitiesResponse
+    if q.Algorithm == "" {
+        q.Algorithm = "louvain"
+    }
+    if q.Algorithm != "louvain" && q.Algorithm != "labelpropagation" {
+        return resp, fmt.Errorf("%w: algorithm must be louvain or labelpropagation", ErrInvalidQuery)
+    }
+    if q.Projection == "" {
+        q.Projection = "user"
+    }
+    if q.Projection != "user" && q.Projection != "transaction" {
+        return resp, fmt.Errorf("%w: projection must be user or transaction", ErrInvalidQuery)
+    }
+    if q.Edges == "" {
+        q.Edges = "both"
+    }
+    if !hasString([]string{"flow", "shared", "both"}, q.Edges) {
+        return resp, fmt.Errorf("%w: edges must be flow, shared or both", ErrInvalidQuery)
+    }
+    if q.MinSize == 0 {
+        q.MinSize = defaultCommunitySize
+    }
+
+    snap, err := store.Snapshot(SnapshotFilter{IncludeShared: true})
+    if err != nil {
+        return resp, err
+    }
+    ids, g, skipped := snap.communityGraph(q)
+    var comm []int
+    if q.Algorithm == "louvain" {
+        comm = g.louvain()
+    } else {
+        comm = g.labelPropagation()
+    }
+    resp = models.CommunitiesResponse{
+        Algorithm:   q.Algorithm,
+        Projection:  q.Projection,
+        Modularity:  g.modularity(comm),
+        Communities: []models.Community{},
+        SkippedHubs: skipped,
+    }
+
+    members := make(map[int][]string)
+    of := make(map[string]int, len(ids))
+    for i, id := range ids {
+        members[comm[i]] = append(members[comm[i]], id)
+        of[id] = comm[i]
+    }
+    internal := make(map[int]float64)
+    external := make(map[int]float64)
+    if q.Projection == "user" {
+        for _, t := range snap.Transactions {
+            s, r := of[t.FromUserID], of[t.ToUserID]
+            if s == r {
+                internal[s] += Value(t)
+            } else {
+                external[s] += Value(t)
+                external[r] += Value(t)
+            }
+        }
+    } else {
+        // Flow of the users a community touches: its own transactions are
+        // internal, their other transactions external.
+        touches := make(map[string]map[int]bool)
+        for _, t := range snap.Transactions {
+            c := of[t.ID]
+            internal[c] += Value(t)
+            for _, u := range []string{t.FromUserID, t.ToUserID} {
+                if touches[u] == nil {
+                    touches[u] = make(map[int]bool)
+                }
+                touches[u][c] = true
+            }
+        }
+        for _, t := range snap.Transactions {
+            seen := map[int]bool{of[t.ID]: true}
+            for _, u := range []string{t.FromUserID, t.ToUserID} {
+                for c := range touches[u] {
+                    if !seen[c] {
+                        seen[c] = true
+                        external[c] += Value(t)
+                    }
+                }
+            }
+        }
+    }
+
+    for c, ms := range members {
+        if len(ms) < q.MinSize {
+            continue
+        }
+        sort.Strings(ms)
+        resp.Communities = append(resp.Communities, models.Community{
+            ID:           ms[0],
+            Size:         len(ms),
+            Members:      ms,
+            TotalVolume:  internal[c] + external[c],
+            InternalFlow: internal[c],
+            ExternalFlow: external[c],
+        })
+    }
+    sort.Slice(resp.Communities, func(i, j int) bool {
+        a, b := resp.Communities[i], resp.Communities[j]
+        if a.Size != b.Size {
+            return a.Size > b.Size
+        }
+        if a.TotalVolume != b.TotalVolume {
+            return a.TotalVolume > b.TotalVolume
+        }
+        return a.ID < b.ID
+    })
+    if limit := pageLimit(q.Limit); len(resp.Communities) > limit {
+        resp.Communities = resp.Communities[:limit]
+    }
+    return resp, nil
+}
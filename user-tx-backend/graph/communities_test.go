@@ -0,0 +1,67 @@
+package graph
+
+import (
+    "errors"
+    "fmt"
+    "sort"
+    "strings"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestDetectCommunities(t *testing.T) {
+    s := newTestStore(t)
+    ids := make([]string, 6)
+    for i := range ids {
+        ids[i] = s.user(fmt.Sprintf("U%d", i), fmt.Sprintf("u%d@example.com", i), fmt.Sprint(i))
+    }
+    // Two rings of three joined by one small transfer.
+    for _, ring := range [][]string{ids[:3], ids[3:]} {
+        for i, id := range ring {
+            s.tx(id, ring[(i+1)%3], 100, "", "")
+        }
+    }
+    s.tx(ids[2], ids[3], 1, "", "")
+    want := []string{memberKey(ids[:3]), memberKey(ids[3:])}
+    sort.Strings(want)
+
+    for _, alg := range []string{"louvain", "labelpropagation"} {
+        resp, err := DetectCommunities(s, models.CommunityQuery{Algorithm: alg})
+        if err != nil {
+            t.Fatal(err)
+        }
+        var got []string
+        for _, c := range resp.Communities {
+            got = append(got, memberKey(c.Members))
+            if c.InternalFlow != 300 || c.ExternalFlow != 1 || c.TotalVolume != 301 {
+                t.Errorf("%s community %s = %+v, want 300 internal and 1 external", alg, c.ID, c)
+            }
+        }
+        sort.Strings(got)
+        if strings.Join(got, " | ") != strings.Join(want, " | ") {
+            t.Errorf("%s communities = %v, want the two rings %v", alg, got, want)
+        }
+        if resp.Modularity <= 0.3 {
+            t.Errorf("%s modularity = %v, want the split to score above 0.3", alg, resp.Modularity)
+        }
+    }
+
+    resp, err := DetectCommunities(s, models.CommunityQuery{MinSize: 4})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(resp.Communities) != 0 {
+        t.Errorf("communities of 4 or more = %+v, want none", resp.Communities)
+    }
+    if _, err := DetectCommunities(s, models.CommunityQuery{Projection: "device"}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("unknown projection: error = %v, want ErrInvalidQuery", err)
+    }
+}
+
+// memberKey joins the sorted ids of a community.
+func memberKey(ids []string) string {
+    sorted := append([]string{}, ids...)
+    sort.Strings(sorted)
+    return strings.Join(sorted, ",")
+}
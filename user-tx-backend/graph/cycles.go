@@ -0,0 +1,190 @@
+package graph
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+const (
+    defaultCycleLength = 4
+    maxCycleLength     = 8
+    // maxCycleCandidates bounds the search on dense graphs; a search cut
+    // short is reported as truncated.
+    maxCycleCandidates = 10000
+)
+
+// FindCycles finds directed User-SENT->Transaction-RECEIVED_BY->User loops
+// that bring money back to where it started, ranked by total value.
+// Each user appears at most once per cycle. Truncated is set when the
+// search stopped after maxCycleCandidates closed loops, so cycles may be
+// missing.
+func FindCycles(store GraphStore, q models.CycleQuery) (models.CyclesResponse, error) {
+    resp := models.CyclesResponse{Cycles: []models.Cycle{}}
+    if q.MaxLength == 0 {
+        q.MaxLength = defaultCycleLength
+    }
+    if q.MaxLength < 1 || q.MaxLength > maxCycleLength {
+        return resp, fmt.Errorf("%w: maxLength must be between 1 and %d", ErrInvalidQuery, maxCycleLength)
+    }
+    if q.MaxDecay != nil && (*q.MaxDecay < 0 || *q.MaxDecay > 1) {
+        return resp, fmt.Errorf("%w: maxDecay must be between 0 and 1", ErrInvalidQuery)
+    }
+    var maxElapsed time.Duration
+    if q.MaxElapsed != "" {
+        var err error
+        if maxElapsed, err = time.ParseDuration(q.MaxElapsed); err != nil || maxElapsed <= 0 {
+            return resp, fmt.Errorf("%w: invalid window %q", ErrInvalidQuery, q.MaxElapsed)
+        }
+    }
+    from, to, err := parseWindow(q.From, q.To)
+    if err != nil {
+        return resp, err
+    }
+
+    snap, err := store.Snapshot(SnapshotFilter{From: from, To: to, MinAmount: q.MinAmount})
+    if err != nil {
+        return resp, err
+    }
+
+    cycles, truncated := snap.cycles(q, maxElapsed)
+    sort.SliceStable(cycles, func(i, j int) bool { return cycles[i].TotalValue > cycles[j].TotalValue })
+    if limit := pageLimit(q.Limit); len(cycles) > limit {
+        cycles = cycles[:limit]
+    }
+    if cycles != nil {
+        resp.Cycles = cycles
+    }
+    resp.Truncated = truncated
+    return resp, nil
+}
+
+// cycles enumerates simple cycles by DFS. Every cycle is found once, from
+// its smallest user ID, and only through users with larger IDs. It reports
+// whether it stopped at maxCycleCandidates.
+func (s *Snapshot) cycles(q models.CycleQuery, maxElapsed time.Duration) ([]models.Cycle, bool) {
+    out := s.outgoing()
+    starts := make([]string, 0, len(out))
+    for id := range out {
+        starts = append(starts, id)
+    }
+    sort.Strings(starts)
+
+    var found []models.Cycle
+    candidates, truncated := 0, false
+    for _, start := range starts {
+        if truncated {
+            break
+        }
+        var path []models.Transaction
+        onPath := map[string]bool{start: true}
+
+        var dfs func(u string)
+        dfs = func(u string) {
+            for _, t := range out[u] {
+                if candidates >= maxCycleCandidates {
+                    truncated = true
+                    return
+                }
+                v := t.ToUserID
+                hops := append(path, t)
+                if !s.pathFeasible(hops, q.Chronological, maxElapsed) {
+                    continue
+                }
+                if v == start {
+                    candidates++
+                    if c, ok := s.evaluateCycle(hops, q, maxElapsed); ok {
+                        found = append(found, c)
+                    }
+                    continue
+                }
+                if v < start || onPath[v] || len(hops) >= q.MaxLength {
+                    continue
+                }
+                path = hops
+                onPath[v] = true
+                dfs(v)
+                onPath[v] = false
+                path = path[:len(path)-1]
+            }
+        }
+        dfs(start)
+    }
+    return found, truncated
+}
+
+// pathFeasible prunes partial paths that can no longer close into a valid
+// cycle: more than one step back in time, or a span beyond maxElapsed.
+func (s *Snapshot) pathFeasible(hops []models.Transaction, chronological bool, maxElapsed time.Duration) bool {
+    if chronological {
+        descents := 0
+        for i := 1; i < len(hops); i++ {
+            if s.Time(hops[i].ID).Before(s.Time(hops[i-1].ID)) {
+                descents++
+            }
+        }
+        if descents > 1 {
+            return false
+        }
+    }
+    if maxElapsed > 0 {
+        first, last := s.span(hops)
+        if last.Sub(first) > maxElapsed {
+            return false
+        }
+    }
+    return true
+}
+
+func (s *Snapshot) span(hops []models.Transaction) (time.Time, time.Time) {
+    first, last := s.Time(hops[0].ID), s.Time(hops[0].ID)
+    for _, t := range hops[1:] {
+        ts := s.Time(t.ID)
+        if ts.Before(first) {
+            first = ts
+        }
+        if ts.After(last) {
+            last = ts
+        }
+    }
+    return first, last
+}
+
+// evaluateCycle rotates a closed path to start at its earliest transaction
+// and applies the ordering and amount-decay constraints.
+func (s *Snapshot) evaluateCycle(
+    hops []models.Transaction,
+    q models.CycleQuery,
+    maxElapsed time.Duration,
+) (models.Cycle, bool) {
+    first := 0
+    for i := range hops {
+        if s.Time(hops[i].ID).Before(s.Time(hops[first].ID)) {
+            first = i
+        }
+    }
+    rotated := append(append([]models.Transaction{}, hops[first:]...), hops[:first]...)
+
+    c := models.Cycle{Length: len(rotated)}
+    for i, t := range rotated {
+        if i > 0 {
+            prev := rotated[i-1]
+            if q.Chronological && s.Time(t.ID).Before(s.Time(prev.ID)) {
+                return c, false
+            }
+            if q.MaxDecay != nil && Value(t) < Value(prev)*(1-*q.MaxDecay) {
+                return c, false
+            }
+        }
+        c.TotalValue += Value(t)
+        c.Segments = append(c.Segments, s.hopSegments(t)...)
+    }
+    start, end := s.span(rotated)
+    if maxElapsed > 0 && end.Sub(start) > maxElapsed {
+        return c, false
+    }
+    c.ElapsedSeconds = end.Sub(start).Seconds()
+    return c, true
+}
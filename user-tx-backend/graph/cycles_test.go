@@ -0,0 +1,80 @@
+package graph
+
+import (
+    "errors"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestFindCycles(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    e := s.user("E", "e@example.com", "5")
+    s.tx(a, b, 100, "", "")
+    s.tx(b, c, 90, "", "")
+    s.tx(c, a, 80, "", "")
+    s.tx(d, e, 10, "", "")
+    s.tx(e, d, 10, "", "")
+    s.tx(a, d, 50, "", "")
+
+    resp, err := FindCycles(s, models.CycleQuery{})
+    if err != nil {
+        t.Fatal(err)
+    }
+    cycles := resp.Cycles
+    if len(cycles) != 2 {
+        t.Fatalf("cycles = %+v, want the triangle and the round trip", cycles)
+    }
+    if got := cycles[0]; got.Length != 3 || got.TotalValue != 270 || got.ElapsedSeconds != 120 || len(got.Segments) != 6 {
+        t.Errorf("first cycle = %+v, want A→B→C→A worth 270 over 120s", got)
+    }
+    if got := cycles[1]; got.Length != 2 || got.TotalValue != 20 || got.Segments[0].From.ID != d {
+        t.Errorf("second cycle = %+v, want D→E→D worth 20", got)
+    }
+
+    for _, tc := range []struct {
+        name string
+        q    models.CycleQuery
+        want float64 // total value of the only cycle
+    }{
+        {"maxLength", models.CycleQuery{MaxLength: 2}, 20},
+        {"maxElapsed", models.CycleQuery{MaxElapsed: "90s"}, 20},
+        {"minAmount", models.CycleQuery{MinAmount: 50}, 270},
+    } {
+        resp, err := FindCycles(s, tc.q)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        if len(resp.Cycles) != 1 || resp.Cycles[0].TotalValue != tc.want || resp.Truncated {
+            t.Errorf("%s: cycles = %+v, want one worth %v", tc.name, resp, tc.want)
+        }
+    }
+    if _, err := FindCycles(s, models.CycleQuery{MaxLength: maxCycleLength + 1}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("maxLength over the limit: error = %v, want ErrInvalidQuery", err)
+    }
+}
+
+func TestFindCyclesTruncates(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    // 101 × 100 pairs of transfers close more loops than the search tries.
+    for i := 0; i < 101; i++ {
+        s.tx(a, b, 10, "", "")
+    }
+    for i := 0; i < 100; i++ {
+        s.tx(b, a, 10, "", "")
+    }
+
+    resp, err := FindCycles(s, models.CycleQuery{MaxLength: 2, Limit: 1})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !resp.Truncated || len(resp.Cycles) != 1 {
+        t.Errorf("cycles = %d, truncated = %v; want 1 and truncated", len(resp.Cycles), resp.Truncated)
+    }
+}
@@ -2,11 +2,11 @@ package graph
 
 import (
     "context"
-    "errors"
     "time"
     "fmt"
 
     "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/fx"
     "user-tx-backend/models"
 )
 
@@ -29,56 +29,90 @@ func NewDriver(uri, username, password string) (*Driver, error) {
     return &Driver{drv}, nil
 }
 
+// EnsureSchema adds uniqueness constraints on the external `id` of User and
+// Transaction nodes, the indexes behind list filters and sorting, and
+// backfills `id` on nodes created before it existed.
+// It is idempotent and safe to run on every start.
+func (d *Driver) EnsureSchema() error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    stmts := []string{
+        `CREATE CONSTRAINT user_id IF NOT EXISTS FOR (u:User) REQUIRE u.id IS UNIQUE`,
+        `CREATE CONSTRAINT transaction_id IF NOT EXISTS FOR (t:Transaction) REQUIRE t.id IS UNIQUE`,
+        `CREATE CONSTRAINT alert_id IF NOT EXISTS FOR (a:Alert) REQUIRE a.id IS UNIQUE`,
+        `CREATE CONSTRAINT case_id IF NOT EXISTS FOR (c:Case) REQUIRE c.id IS UNIQUE`,
+        `CREATE CONSTRAINT watchlist_entry_id IF NOT EXISTS FOR (e:WatchlistEntry) REQUIRE e.id IS UNIQUE`,
+        `CREATE CONSTRAINT person_id IF NOT EXISTS FOR (p:Person) REQUIRE p.id IS UNIQUE`,
+        `CREATE CONSTRAINT email_id IF NOT EXISTS FOR (e:Email) REQUIRE e.id IS UNIQUE`,
+        `CREATE CONSTRAINT phone_id IF NOT EXISTS FOR (p:Phone) REQUIRE p.id IS UNIQUE`,
+        `CREATE CONSTRAINT device_id IF NOT EXISTS FOR (d:Device) REQUIRE d.id IS UNIQUE`,
+        `CREATE CONSTRAINT ip_address_id IF NOT EXISTS FOR (i:IPAddress) REQUIRE i.id IS UNIQUE`,
+        `CREATE CONSTRAINT status_change_id IF NOT EXISTS FOR (s:StatusChange) REQUIRE s.id IS UNIQUE`,
+        `CREATE CONSTRAINT cluster_id IF NOT EXISTS FOR (c:Cluster) REQUIRE c.id IS UNIQUE`,
+        `CREATE INDEX user_name IF NOT EXISTS FOR (u:User) ON (u.name)`,
+        `CREATE INDEX user_email IF NOT EXISTS FOR (u:User) ON (u.email)`,
+        `CREATE INDEX user_phone IF NOT EXISTS FOR (u:User) ON (u.phone)`,
+        `CREATE INDEX transaction_timestamp IF NOT EXISTS FOR (t:Transaction) ON (t.timestamp)`,
+        `CREATE INDEX transaction_amount IF NOT EXISTS FOR (t:Transaction) ON (t.amount)`,
+        `CREATE INDEX transaction_device IF NOT EXISTS FOR (t:Transaction) ON (t.deviceId)`,
+        `CREATE INDEX transaction_status IF NOT EXISTS FOR (t:Transaction) ON (t.status)`,
+        `CREATE INDEX transaction_cluster IF NOT EXISTS FOR (t:Transaction) ON (t.clusterId)`,
+        `CREATE INDEX user_created IF NOT EXISTS FOR (u:User) ON (u.createdAt)`,
+        `CREATE INDEX transaction_created IF NOT EXISTS FOR (t:Transaction) ON (t.createdAt)`,
+        `CREATE INDEX expired_link_owner IF NOT EXISTS FOR (x:ExpiredLink) ON (x.owner)`,
+        `CREATE INDEX revision_valid_to IF NOT EXISTS FOR (r:Revision) ON (r.validTo)`,
+        `MATCH (u:User) WHERE u.id IS NULL SET u.id = randomUUID()`,
+        `MATCH (t:Transaction) WHERE t.id IS NULL SET t.id = randomUUID()`,
+    }
+    for _, stmt := range stmts {
+        if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+            _, err := tx.Run(ctx, stmt, nil)
+            return nil, err
+        }); err != nil {
+            return fmt.Errorf("EnsureSchema: %w", err)
+        }
+    }
+    return nil
+}
+
 func (d *Driver) Close() {
     _ = d.drv.Close(context.Background())
 }
 
-func (d *Driver) CreateUser(name, email, phone string) (int64, error) {
+func (d *Driver) CreateUser(name, email, phone string) (string, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
     defer session.Close(ctx)
 
     rawID, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         rec, err := tx.Run(ctx,
-            `CREATE (u:User { name: $name, email: $email, phone: $phone })
-             RETURN id(u)`,
+            `CREATE (u:User { id: randomUUID(), name: $name, email: $email, phone: $phone, createdAt: datetime() })
+             RETURN u.id`,
             map[string]any{"name": name, "email": email, "phone": phone},
         )
         if err != nil {
             return nil, err
         }
         if rec.Next(ctx) {
-            return rec.Record().Values[0].(int64), nil
+            return rec.Record().Values[0].(string), nil
         }
         return nil, fmt.Errorf("CreateUser: no record returned")
     })
     if err != nil {
-        return 0, err
-    }
-    newID := rawID.(int64)
-
-    if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        _, err := tx.Run(ctx,
-            `MATCH (u:User), (o:User)
-             WHERE id(u) = $id AND o.email = u.email AND id(o) <> $id
-             MERGE (u)-[:SHARED_EMAIL]-(o)`,
-            map[string]any{"id": newID},
-        )
-        return nil, err
-    }); err != nil {
-        return newID, fmt.Errorf("CreateUser: failed to link SHARED_EMAIL: %w", err)
+        return "", err
     }
+    newID := rawID.(string)
 
     if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        _, err := tx.Run(ctx,
-            `MATCH (u:User), (o:User)
-             WHERE id(u) = $id AND o.phone = u.phone AND id(o) <> $id
-             MERGE (u)-[:SHARED_PHONE]-(o)`,
-            map[string]any{"id": newID},
-        )
+        if err := linkHubs(ctx, tx, "User", []string{newID}); err != nil {
+            return nil, err
+        }
+        _, err := tx.Run(ctx, alignLinksQuery("User"), map[string]any{"ids": []string{newID}})
         return nil, err
     }); err != nil {
-        return newID, fmt.Errorf("CreateUser: failed to link SHARED_PHONE: %w", err)
+        return newID, fmt.Errorf("CreateUser: %w", err)
     }
 
     return newID, nil
@@ -94,7 +128,7 @@ func (d *Driver) GetAllUsers() ([]models.User, error) {
     raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
             `MATCH (u:User)
-             RETURN id(u) AS id, u.name AS name, u.email AS email, u.phone AS phone`,
+             RETURN u.id AS id, u.name AS name, u.email AS email, u.phone AS phone`,
             nil,
         )
         if err != nil {
@@ -104,7 +138,7 @@ func (d *Driver) GetAllUsers() ([]models.User, error) {
         for result.Next(ctx) {
             rec := result.Record()
             users = append(users, models.User{
-                ID:    rec.Values[0].(int64),
+                ID:    rec.Values[0].(string),
                 Name:  rec.Values[1].(string),
                 Email: rec.Values[2].(string),
                 Phone: rec.Values[3].(string),
@@ -118,12 +152,14 @@ func (d *Driver) GetAllUsers() ([]models.User, error) {
     return raw.([]models.User), nil
 }
 
-// CreateTransaction inserts a Transaction node and links sender→transaction→receiver.
+// CreateTransaction inserts a Transaction node in its initial status with
+// the first entry of its history, links sender→transaction→receiver,
+// points it at its Device and IPAddress and adds it to its cluster.
 func (d *Driver) CreateTransaction(
-    fromID, toID int64,
-    amount float64,
-    currency, timestamp, description, deviceId string,
-) (int64, error) {
+    fromID, toID string,
+    amount models.Money,
+    timestamp, description, deviceId, ip, status string,
+) (string, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
     defer session.Close(ctx)
@@ -131,57 +167,66 @@ func (d *Driver) CreateTransaction(
     rawID, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         rec, err := tx.Run(ctx,
             `MATCH (u1:User),(u2:User)
-             WHERE id(u1) = $fromId AND id(u2) = $toId
+             WHERE u1.id = $fromId AND u2.id = $toId
              CREATE (t:Transaction {
-               amount:      $amt,
-               currency:    $currency,
+               id:          randomUUID(),
                timestamp:   datetime($ts),
                description: $desc,
-               deviceId:    $deviceId
+               deviceId:    $deviceId,
+               ip:          $ip,
+               status:      $status,
+               createdAt:   datetime()
              })
+             SET t += $money
              CREATE (u1)-[:SENT]->(t)
              CREATE (t)-[:RECEIVED_BY]->(u2)
-             RETURN id(t)`,
+             CREATE (t)-[:HAS_STATUS_CHANGE]->(:StatusChange {
+               id: randomUUID(), to: $status, at: datetime(), seq: 0
+             })
+             RETURN t.id`,
             map[string]any{
                 "fromId":   fromID,
                 "toId":     toID,
-                "amt":      amount,
-                "currency": currency,
+                "money":    moneyProps(amount),
                 "ts":       timestamp,
                 "desc":     description,
                 "deviceId": deviceId,
+                "ip":       ip,
+                "status":   status,
             },
         )
         if err != nil {
             return nil, err
         }
         if rec.Next(ctx) {
-            return rec.Record().Values[0].(int64), nil
+            return rec.Record().Values[0].(string), nil
         }
         return nil, fmt.Errorf("CreateTransaction: no record returned")
     })
     if err != nil {
-        return 0, err
+        return "", err
     }
-    newID := rawID.(int64)
+    newID := rawID.(string)
 
     if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        _, err := tx.Run(ctx,
-            `MATCH (t:Transaction),(o:Transaction)
-             WHERE id(t) = $id
-               AND o.deviceId = t.deviceId
-               AND id(o) <> $id
-             MERGE (t)-[:SHARED_DEVICE]-(o)`,
-            map[string]any{"id": newID},
-        )
-        return nil, err
+        ids := []string{newID}
+        if err := linkHubs(ctx, tx, "Transaction", ids); err != nil {
+            return nil, err
+        }
+        if _, err := tx.Run(ctx, alignLinksQuery("Transaction"), map[string]any{"ids": ids}); err != nil {
+            return nil, err
+        }
+        if err := backdate(ctx, tx, ids); err != nil {
+            return nil, err
+        }
+        return nil, joinCluster(ctx, tx, newID)
     }); err != nil {
-        return newID, fmt.Errorf("CreateTransaction: failed to link SHARED_DEVICE: %w", err)
+        return newID, fmt.Errorf("CreateTransaction: %w", err)
     }
 
     return newID, nil
 }
-// GetAllTransactions retrieves every transaction, including from/to IDs and deviceId.
+// GetAllTransactions retrieves every transaction, including from/to IDs, deviceId and ip.
 func (d *Driver) GetAllTransactions() ([]models.Transaction, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
@@ -190,14 +235,17 @@ func (d *Driver) GetAllTransactions() ([]models.Transaction, error) {
     raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
             `MATCH (u1:User)-[:SENT]->(t:Transaction)-[:RECEIVED_BY]->(u2:User)
-             RETURN id(t)           AS id,
-                    id(u1)         AS fromId,
-                    id(u2)         AS toId,
+             RETURN t.id           AS id,
+                    u1.id         AS fromId,
+                    u2.id         AS toId,
                     t.amount       AS amt,
                     t.currency     AS currency,
-                    toString(t.timestamp) AS ts,
+                    t.timestamp    AS ts,
                     t.description  AS desc,
-                    t.deviceId     AS deviceId`,
+                    t.deviceId     AS deviceId,
+                    coalesce(t.ip, '') AS ip,
+                    coalesce(t.status, 'settled') AS status,
+                    `+moneyColumns("t"),
             nil,
         )
         if err != nil {
@@ -206,16 +254,20 @@ func (d *Driver) GetAllTransactions() ([]models.Transaction, error) {
         var txs []models.Transaction
         for result.Next(ctx) {
             r := result.Record()
-            txs = append(txs, models.Transaction{
-                ID:          r.Values[0].(int64),
-                FromUserID:  r.Values[1].(int64),
-                ToUserID:    r.Values[2].(int64),
+            t := models.Transaction{
+                ID:          r.Values[0].(string),
+                FromUserID:  r.Values[1].(string),
+                ToUserID:    r.Values[2].(string),
                 Amount:      r.Values[3].(float64),
                 Currency:    r.Values[4].(string),
-                Timestamp:   r.Values[5].(string),
+                Timestamp:   storedTime(r.Values[5]),
                 Description: r.Values[6].(string),
                 DeviceID:    r.Values[7].(string),
-            })
+                IP:          r.Values[8].(string),
+                Status:      r.Values[9].(string),
+            }
+            setMoney(&t, r.Values[10:])
+            txs = append(txs, t)
         }
         return txs, result.Err()
     })
@@ -227,7 +279,7 @@ func (d *Driver) GetAllTransactions() ([]models.Transaction, error) {
 
 // GetUserRelationships fetches a user plus both sent and received transactions.
 func (d *Driver) GetUserRelationships(
-    userID int64,
+    userID string,
 ) (models.User, models.UserConnections, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
@@ -237,8 +289,8 @@ func (d *Driver) GetUserRelationships(
     var user models.User
     if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         rec, err := tx.Run(ctx,
-            `MATCH (u:User) WHERE id(u) = $uid
-             RETURN id(u), u.name, u.email, u.phone`,
+            `MATCH (u:User) WHERE u.id = $uid
+             RETURN u.id, u.name, u.email, u.phone`,
             map[string]any{"uid": userID},
         )
         if err != nil {
@@ -247,26 +299,33 @@ func (d *Driver) GetUserRelationships(
         if rec.Next(ctx) {
             r := rec.Record()
             user = models.User{
-                ID:    r.Values[0].(int64),
+                ID:    r.Values[0].(string),
                 Name:  r.Values[1].(string),
                 Email: r.Values[2].(string),
                 Phone: r.Values[3].(string),
             }
             return nil, nil
         }
-        return nil, errors.New("user not found")
+        return nil, ErrUserNotFound
     }); err != nil {
         return user, models.UserConnections{}, err
     }
 
     conns := models.UserConnections{}
 
-    // 2) Shared‐attribute links (email & phone)
+    // 2) Shared‐attribute links (email & phone, through their hubs) and
+    //    probable matches
     if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
-            `MATCH (u:User)-[r:SHARED_EMAIL|SHARED_PHONE]-(o:User)
-             WHERE id(u) = $uid
-             RETURN type(r), id(o), o.name, o.email, o.phone`,
+            `MATCH (u:User)-[:HAS_EMAIL|HAS_PHONE]->(h)<-[r:HAS_EMAIL|HAS_PHONE]-(o:User)
+             WHERE u.id = $uid AND o <> u
+             RETURN CASE type(r) WHEN 'HAS_EMAIL' THEN 'SHARED_EMAIL' ELSE 'SHARED_PHONE' END AS rel,
+                    o.id AS id, o.name AS name, o.email AS email, o.phone AS phone, null AS confidence
+             UNION ALL
+             MATCH (u:User)-[r:SAME_EMAIL_CANONICAL|SAME_PHONE_E164|SIMILAR_NAME]-(o:User)
+             WHERE u.id = $uid
+             RETURN type(r) AS rel,
+                    o.id AS id, o.name AS name, o.email AS email, o.phone AS phone, r.confidence AS confidence`,
             map[string]any{"uid": userID},
         )
         if err != nil {
@@ -274,15 +333,19 @@ func (d *Driver) GetUserRelationships(
         }
         for result.Next(ctx) {
             r := result.Record()
-            conns.Users = append(conns.Users, models.RelConnection[models.User]{
+            conn := models.RelConnection[models.User]{
                 Node: models.User{
-                    ID:    r.Values[1].(int64),
+                    ID:    r.Values[1].(string),
                     Name:  r.Values[2].(string),
                     Email: r.Values[3].(string),
                     Phone: r.Values[4].(string),
                 },
                 Relationship: r.Values[0].(string),
-            })
+            }
+            if conf, ok := r.Values[5].(float64); ok {
+                conn.Confidence = conf
+            }
+            conns.Users = append(conns.Users, conn)
         }
         return nil, result.Err()
     }); err != nil {
@@ -293,9 +356,11 @@ func (d *Driver) GetUserRelationships(
     if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
             `MATCH (u:User)-[r:SENT]->(t:Transaction)-[:RECEIVED_BY]->(v:User)
-             WHERE id(u) = $uid
-             RETURN type(r), id(t), id(u), id(v),
-                    t.amount, t.currency, toString(t.timestamp), t.description, t.deviceId`,
+             WHERE u.id = $uid
+             RETURN type(r), t.id, u.id, v.id,
+                    t.amount, t.currency, t.timestamp, t.description, t.deviceId,
+                    coalesce(t.ip, ''), coalesce(t.status, 'settled'),
+                    `+moneyColumns("t"),
             map[string]any{"uid": userID},
         )
         if err != nil {
@@ -303,17 +368,21 @@ func (d *Driver) GetUserRelationships(
         }
         for result.Next(ctx) {
             r := result.Record()
+            t := models.Transaction{
+                ID:          r.Values[1].(string),
+                FromUserID:  r.Values[2].(string),
+                ToUserID:    r.Values[3].(string),
+                Amount:      r.Values[4].(float64),
+                Currency:    r.Values[5].(string),
+                Timestamp:   storedTime(r.Values[6]),
+                Description: r.Values[7].(string),
+                DeviceID:    r.Values[8].(string),
+                IP:          r.Values[9].(string),
+                Status:      r.Values[10].(string),
+            }
+            setMoney(&t, r.Values[11:])
             conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
-                Node: models.Transaction{
-                    ID:          r.Values[1].(int64),
-                    FromUserID:  r.Values[2].(int64),
-                    ToUserID:    r.Values[3].(int64),
-                    Amount:      r.Values[4].(float64),
-                    Currency:    r.Values[5].(string),
-                    Timestamp:   r.Values[6].(string),
-                    Description: r.Values[7].(string),
-                    DeviceID:    r.Values[8].(string),
-                },
+                Node:         t,
                 Relationship: r.Values[0].(string),
             })
         }
@@ -326,9 +395,11 @@ func (d *Driver) GetUserRelationships(
     if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
             `MATCH (x:User)-[:SENT]->(t:Transaction)-[r:RECEIVED_BY]->(u:User)
-             WHERE id(u) = $uid
-             RETURN type(r), id(t), id(x), id(u),
-                    t.amount, t.currency, toString(t.timestamp), t.description, t.deviceId`,
+             WHERE u.id = $uid
+             RETURN type(r), t.id, x.id, u.id,
+                    t.amount, t.currency, t.timestamp, t.description, t.deviceId,
+                    coalesce(t.ip, ''), coalesce(t.status, 'settled'),
+                    `+moneyColumns("t"),
             map[string]any{"uid": userID},
         )
         if err != nil {
@@ -336,16 +407,54 @@ func (d *Driver) GetUserRelationships(
         }
         for result.Next(ctx) {
             r := result.Record()
+            t := models.Transaction{
+                ID:          r.Values[1].(string),
+                FromUserID:  r.Values[2].(string),
+                ToUserID:    r.Values[3].(string),
+                Amount:      r.Values[4].(float64),
+                Currency:    r.Values[5].(string),
+                Timestamp:   storedTime(r.Values[6]),
+                Description: r.Values[7].(string),
+                DeviceID:    r.Values[8].(string),
+                IP:          r.Values[9].(string),
+                Status:      r.Values[10].(string),
+            }
+            setMoney(&t, r.Values[11:])
             conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
-                Node: models.Transaction{
-                    ID:          r.Values[1].(int64),
-                    FromUserID:  r.Values[2].(int64),
-                    ToUserID:    r.Values[3].(int64),
-                    Amount:      r.Values[4].(float64),
-                    Currency:    r.Values[5].(string),
-                    Timestamp:   r.Values[6].(string),
-                    Description: r.Values[7].(string),
-                    DeviceID:    r.Values[8].(string),
+                Node:         t,
+                Relationship: r.Values[0].(string),
+            })
+        }
+        return nil, result.Err()
+    }); err != nil {
+        return user, conns, err
+    }
+
+    // 5) Watchlist hits
+    if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        result, err := tx.Run(ctx,
+            `MATCH (u:User)-[r:WATCHLIST_HIT]->(e:WatchlistEntry)
+             WHERE u.id = $uid
+             RETURN type(r), e.id, e.list, coalesce(e.name, ''), coalesce(e.program, ''),
+                    r.matchType, r.matchedValue, r.score, r.screenedAt
+             ORDER BY r.score DESC, e.id`,
+            map[string]any{"uid": userID},
+        )
+        if err != nil {
+            return nil, err
+        }
+        for result.Next(ctx) {
+            r := result.Record()
+            conns.Watchlist = append(conns.Watchlist, models.RelConnection[models.WatchlistHit]{
+                Node: models.WatchlistHit{
+                    EntryID:      r.Values[1].(string),
+                    List:         r.Values[2].(string),
+                    Name:         r.Values[3].(string),
+                    Program:      r.Values[4].(string),
+                    MatchType:    r.Values[5].(string),
+                    MatchedValue: r.Values[6].(string),
+                    Score:        r.Values[7].(float64),
+                    ScreenedAt:   storedTime(r.Values[8]),
                 },
                 Relationship: r.Values[0].(string),
             })
@@ -355,12 +464,94 @@ func (d *Driver) GetUserRelationships(
         return user, conns, err
     }
 
+    // 6) Email and phone nodes
+    if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        result, err := tx.Run(ctx, identifiersQuery("User"), map[string]any{"id": userID})
+        if err != nil {
+            return nil, err
+        }
+        for result.Next(ctx) {
+            conns.Identifiers = append(conns.Identifiers, identifierFromRecord(result.Record().Values))
+        }
+        return nil, result.Err()
+    }); err != nil {
+        return user, conns, err
+    }
+
     return user, conns, nil
 }
 
-// GetTransactionRelationships fetches a transaction plus its sender and receiver.
+// GetTransaction fetches one transaction with its sender and receiver.
+func (d *Driver) GetTransaction(id string) (models.Transaction, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        return readTransaction(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Transaction{}, err
+    }
+    return raw.(models.Transaction), nil
+}
+
+// storedTime formats a datetime read from Neo4j, which the driver returns
+// as a time.Time, as RFC3339. Cypher's toString() can't be used: it leaves
+// out zero seconds, which RFC3339 requires. Missing values give "".
+func storedTime(v any) string {
+    switch t := v.(type) {
+    case time.Time:
+        return t.Format(time.RFC3339Nano)
+    case string:
+        return t
+    }
+    return ""
+}
+
+// readTransaction reads one transaction inside tx.
+func readTransaction(ctx context.Context, tx neo4j.ManagedTransaction, id string) (models.Transaction, error) {
+    res, err := tx.Run(ctx,
+        `MATCH (u1:User)-[:SENT]->(t:Transaction)-[:RECEIVED_BY]->(u2:User)
+         WHERE t.id = $id
+         RETURN t.id, u1.id, u2.id,
+                t.amount, t.currency, t.timestamp, t.description, t.deviceId,
+                coalesce(t.ip, ''), coalesce(t.status, 'settled'),
+                `+moneyColumns("t"),
+        map[string]any{"id": id},
+    )
+    if err != nil {
+        return models.Transaction{}, err
+    }
+    if !res.Next(ctx) {
+        if err := res.Err(); err != nil {
+            return models.Transaction{}, err
+        }
+        return models.Transaction{}, ErrTransactionNotFound
+    }
+    r := res.Record()
+    t := models.Transaction{
+        ID:          r.Values[0].(string),
+        FromUserID:  r.Values[1].(string),
+        ToUserID:    r.Values[2].(string),
+        Amount:      r.Values[3].(float64),
+        Currency:    r.Values[4].(string),
+        Timestamp:   storedTime(r.Values[5]),
+        Description: r.Values[6].(string),
+        DeviceID:    r.Values[7].(string),
+        IP:          r.Values[8].(string),
+        Status:      r.Values[9].(string),
+    }
+    setMoney(&t, r.Values[10:])
+    return t, nil
+}
+
+// GetTransactionRelationships fetches a transaction plus its sender and
+// receiver, its Device and IPAddress nodes, its reversals and chargebacks
+// (or the original it reverses) and up to sharedTxLimit other transactions
+// on its device or IP.
 func (d *Driver) GetTransactionRelationships(
-    txID int64,
+    txID string,
 ) (models.Transaction, models.TxConnections, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
@@ -371,9 +562,11 @@ func (d *Driver) GetTransactionRelationships(
     if _, err := session.ExecuteRead(ctx, func(txn neo4j.ManagedTransaction) (any, error) {
         rec, err := txn.Run(ctx,
             `MATCH (t:Transaction)
-             WHERE id(t) = $txid
-             RETURN id(t), t.amount, t.currency,
-                    toString(t.timestamp), t.description, t.deviceId`,
+             WHERE t.id = $txid
+             RETURN t.id, t.amount, t.currency,
+                    t.timestamp, t.description, t.deviceId, coalesce(t.ip, ''),
+                    coalesce(t.status, 'settled'),
+                    `+moneyColumns("t"),
             map[string]any{"txid": txID},
         )
         if err != nil {
@@ -382,16 +575,19 @@ func (d *Driver) GetTransactionRelationships(
         if rec.Next(ctx) {
             r := rec.Record()
             txNode = models.Transaction{
-                ID:          r.Values[0].(int64),
+                ID:          r.Values[0].(string),
                 Amount:      r.Values[1].(float64),
                 Currency:    r.Values[2].(string),
-                Timestamp:   r.Values[3].(string),
+                Timestamp:   storedTime(r.Values[3]),
                 Description: r.Values[4].(string),
                 DeviceID:    r.Values[5].(string),
+                IP:          r.Values[6].(string),
+                Status:      r.Values[7].(string),
             }
+            setMoney(&txNode, r.Values[8:])
             return nil, nil
         }
-        return nil, errors.New("transaction not found")
+        return nil, ErrTransactionNotFound
     }); err != nil {
         return txNode, models.TxConnections{}, err
     }
@@ -402,8 +598,8 @@ func (d *Driver) GetTransactionRelationships(
     if _, err := session.ExecuteRead(ctx, func(txn neo4j.ManagedTransaction) (any, error) {
         rec, err := txn.Run(ctx,
             `MATCH (u:User)-[r:SENT]->(t:Transaction)
-             WHERE id(t) = $txid
-             RETURN type(r), id(u), u.name, u.email, u.phone`,
+             WHERE t.id = $txid
+             RETURN type(r), u.id, u.name, u.email, u.phone`,
             map[string]any{"txid": txID},
         )
         if err != nil {
@@ -413,7 +609,7 @@ func (d *Driver) GetTransactionRelationships(
             r := rec.Record()
             conns.Users = append(conns.Users, models.RelConnection[models.User]{
                 Node: models.User{
-                    ID:    r.Values[1].(int64),
+                    ID:    r.Values[1].(string),
                     Name:  r.Values[2].(string),
                     Email: r.Values[3].(string),
                     Phone: r.Values[4].(string),
@@ -430,8 +626,8 @@ func (d *Driver) GetTransactionRelationships(
     if _, err := session.ExecuteRead(ctx, func(txnn neo4j.ManagedTransaction) (any, error) {
         rec, err := txnn.Run(ctx,
             `MATCH (t:Transaction)-[r:RECEIVED_BY]->(u:User)
-             WHERE id(t) = $txid
-             RETURN type(r), id(u), u.name, u.email, u.phone`,
+             WHERE t.id = $txid
+             RETURN type(r), u.id, u.name, u.email, u.phone`,
             map[string]any{"txid": txID},
         )
         if err != nil {
@@ -441,7 +637,7 @@ func (d *Driver) GetTransactionRelationships(
             r := rec.Record()
             conns.Users = append(conns.Users, models.RelConnection[models.User]{
                 Node: models.User{
-                    ID:    r.Values[1].(int64),
+                    ID:    r.Values[1].(string),
                     Name:  r.Values[2].(string),
                     Email: r.Values[3].(string),
                     Phone: r.Values[4].(string),
@@ -454,13 +650,78 @@ func (d *Driver) GetTransactionRelationships(
         return txNode, conns, err
     }
 
+    // 4) Device and IP nodes
+    if _, err := session.ExecuteRead(ctx, func(txn neo4j.ManagedTransaction) (any, error) {
+        rec, err := txn.Run(ctx, identifiersQuery("Transaction"), map[string]any{"id": txID})
+        if err != nil {
+            return nil, err
+        }
+        for rec.Next(ctx) {
+            conns.Identifiers = append(conns.Identifiers, identifierFromRecord(rec.Record().Values))
+        }
+        return nil, rec.Err()
+    }); err != nil {
+        return txNode, conns, err
+    }
+
+    // 5) Reversals and chargebacks of or by the transaction, then
+    //    transactions on the same device or IP, newest first
+    if _, err := session.ExecuteRead(ctx, func(txn neo4j.ManagedTransaction) (any, error) {
+        rec, err := txn.Run(ctx,
+            `MATCH (t:Transaction)-[r:REVERSAL_OF|CHARGEBACK_OF]-(o:Transaction)
+             WHERE t.id = $txid
+             RETURN type(r) AS rel,
+                    o.id AS id, o.amount AS amount, o.currency AS currency,
+                    o.timestamp AS ts, o.description AS desc,
+                    o.deviceId AS deviceId, coalesce(o.ip, '') AS ip,
+                    coalesce(o.status, 'settled') AS status,
+                    `+moneyColumns("o")+`
+             UNION ALL
+             MATCH (t:Transaction)-[r:USED_DEVICE|FROM_IP]->(h)<-[:USED_DEVICE|FROM_IP]-(o:Transaction)
+             WHERE t.id = $txid AND o <> t
+             WITH r, o
+             ORDER BY o.timestamp DESC
+             LIMIT $limit
+             RETURN CASE type(r) WHEN 'USED_DEVICE' THEN 'SHARED_DEVICE' ELSE 'SHARED_IP' END AS rel,
+                    o.id AS id, o.amount AS amount, o.currency AS currency,
+                    o.timestamp AS ts, o.description AS desc,
+                    o.deviceId AS deviceId, coalesce(o.ip, '') AS ip,
+                    coalesce(o.status, 'settled') AS status,
+                    `+moneyColumns("o"),
+            map[string]any{"txid": txID, "limit": sharedTxLimit},
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rec.Next(ctx) {
+            r := rec.Record()
+            o := models.Transaction{
+                ID:          r.Values[1].(string),
+                Amount:      r.Values[2].(float64),
+                Currency:    r.Values[3].(string),
+                Timestamp:   storedTime(r.Values[4]),
+                Description: r.Values[5].(string),
+                DeviceID:    r.Values[6].(string),
+                IP:          r.Values[7].(string),
+                Status:      r.Values[8].(string),
+            }
+            setMoney(&o, r.Values[9:])
+            conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
+                Node:         o,
+                Relationship: r.Values[0].(string),
+            })
+        }
+        return nil, rec.Err()
+    }); err != nil {
+        return txNode, conns, err
+    }
+
     return txNode, conns, nil
 }
 
-// SeedData populates sample users, shared‐attribute links, and transactions.
-func SeedData(d *Driver) error {
-    ctx := context.Background()
-
+// SeedData populates sample users, shared‐attribute links, and transactions
+// through any GraphStore. Amounts are converted with conv.
+func SeedData(d GraphStore, conv *fx.Converter) error {
     // 1) Sample users
     sampleUsers := []struct {
         name, email, phone string
@@ -471,7 +732,7 @@ func SeedData(d *Driver) error {
         {"Dave",  "dave@example.com",  "1111111111"}, // shares phone with Alice
         {"Eve",   "eve@example.com",   "2222222222"}, // shares phone with Bob
     }
-    userIDs := make([]int64, len(sampleUsers))
+    userIDs := make([]string, len(sampleUsers))
     for i, u := range sampleUsers {
         id, err := d.CreateUser(u.name, u.email, u.phone)
         if err != nil {
@@ -480,71 +741,83 @@ func SeedData(d *Driver) error {
         userIDs[i] = id
     }
 
-    // 2) Shared‐attribute relationships (email & phone)
-    sharedRels := []struct {
-        relType           string
-        idxA, idxB        int
-    }{
-        {"SHARED_EMAIL", 0, 2}, 
-        {"SHARED_PHONE", 0, 3}, 
-        {"SHARED_PHONE", 1, 4}, 
-    }
-    for _, s := range sharedRels {
-        session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
-        _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-            _, err := tx.Run(ctx,
-                `MATCH (a:User),(b:User)
-                 WHERE id(a)=$a AND id(b)=$b
-                 MERGE (a)-[:`+s.relType+`]-(b)`,
-                map[string]any{
-                    "a": userIDs[s.idxA],
-                    "b": userIDs[s.idxB],
-                },
-            )
-            return nil, err
-        })
-        session.Close(ctx)
-        if err != nil {
-            return err
-        }
-    }
+    // 2) Shared‐attribute relationships (email & phone) are derived by
+    //    CreateUser itself: Alice–Carol share an email, Alice–Dave and
+    //    Bob–Eve share a phone.
 
-    // 3) Sample transactions (with deviceId) covering various links
+    // 3) Sample transactions (with deviceId and ip) covering various links
     txDefs := []struct {
         from, to     int
         amount       float64
         currency     string
         description  string
         deviceId     string
+        ip           string
+        status       string
     }{
-        {0, 1, 100.0, "USD", "Payment A→B", "dev-001"}, 
-        {1, 2, 150.0, "USD", "Payment B→C", "dev-001"},
-        {2, 0, 200.0, "USD", "Payment C→A", "dev-002"},
-        {3, 4, 250.0, "USD", "Payment D→E", "dev-003"},
-        {4, 3, 300.0, "USD", "Payment E→D", "dev-003"},
-        {0, 3, 350.0, "USD", "Payment A→D", "dev-004"},
-        {2, 4, 400.0, "USD", "Payment C→E", "dev-002"},
+        {0, 1, 100.0, "USD", "Payment A→B", "dev-001", "203.0.113.10", "settled"},
+        {1, 2, 150.0, "USD", "Payment B→C", "dev-001", "203.0.113.10", "settled"},
+        {2, 0, 200.0, "USD", "Payment C→A", "dev-002", "198.51.100.7", "settled"},
+        {3, 4, 250.0, "USD", "Payment D→E", "dev-003", "192.0.2.44", "settled"},
+        {4, 3, 300.0, "USD", "Payment E→D", "dev-003", "192.0.2.44", "failed"},
+        {0, 3, 350.0, "USD", "Payment A→D", "dev-004", "203.0.113.10", "settled"},
+        {2, 4, 400.0, "USD", "Payment C→E", "dev-002", "", "pending"},
     }
+    txIDs := make([]string, len(txDefs))
     for i, t := range txDefs {
         ts := time.Now().Add(time.Duration(-i) * time.Hour).Format(time.RFC3339)
-        if _, err := d.CreateTransaction(
+        initial := t.status
+        if initial == "failed" {
+            initial = "pending"
+        }
+        money, err := conv.Money(t.amount, t.currency, ts)
+        if err != nil {
+            return err
+        }
+        id, err := d.CreateTransaction(
             userIDs[t.from],
             userIDs[t.to],
-            t.amount,
-            t.currency,
+            money,
             ts,
             t.description,
             t.deviceId,
-        ); err != nil {
+            t.ip,
+            initial,
+        )
+        if err != nil {
             return err
         }
+        if t.status != initial {
+            if _, err := d.SetTransactionStatus(id, models.StatusRequest{Status: t.status, Reason: "declined by issuer"}); err != nil {
+                return err
+            }
+        }
+        txIDs[i] = id
     }
 
-    return nil
+    // 4) Dave returns Alice's payment: a reversal of A→D
+    ts := time.Now().Add(-30 * time.Minute).Format(time.RFC3339)
+    money, err := conv.Money(350.0, "USD", ts)
+    if err != nil {
+        return err
+    }
+    id, err := d.CreateTransaction(userIDs[3], userIDs[0], money, ts, "Reversal of A→D", "dev-003", "192.0.2.44", "settled")
+    if err != nil {
+        return err
+    }
+    return d.LinkFollowUp(id, txIDs[5], "REVERSAL_OF")
 }
 
+// shortestPathRels are the relationships ShortestPathSegments walks: money
+// and identifier links. Alerts, cases, persons, watchlist entries and
+// follow-ups are not connections between users.
+const shortestPathRels = "SENT|RECEIVED_BY|HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP"
+
+// ShortestPathSegments returns the hops of a shortest path between two
+// users over shortestPathRels. Paths may run through identifier nodes,
+// which are named by their value.
 func (d *Driver) ShortestPathSegments(
-    fromID, toID int64,
+    fromID, toID string,
 ) ([]models.PathSegment, error) {
     ctx := context.Background()
     session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
@@ -552,19 +825,23 @@ func (d *Driver) ShortestPathSegments(
 
     raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
         result, err := tx.Run(ctx,
-            `MATCH (a:User),(b:User), p = shortestPath((a)-[*]-(b))
-             WHERE id(a) = $from AND id(b) = $to
+            `MATCH (a:User),(b:User), p = shortestPath((a)-[:`+shortestPathRels+`*]-(b))
+             WHERE a.id = $from AND b.id = $to
              UNWIND relationships(p) AS r
              WITH r, startNode(r) AS fn, endNode(r) AS tn
              RETURN
                labels(fn)[0]                                        AS fromLabel,
-               id(fn)                                               AS fromId,
-               CASE WHEN fn:User THEN fn.name ELSE '' END           AS fromName,
+               fn.id                                               AS fromId,
+               CASE WHEN fn:User THEN fn.name
+                    WHEN fn:Transaction THEN ''
+                    ELSE coalesce(fn.value, '') END                 AS fromName,
                CASE WHEN fn:Transaction THEN fn.deviceId ELSE '' END AS fromDeviceId,
 
                labels(tn)[0]                                        AS toLabel,
-               id(tn)                                               AS toId,
-               CASE WHEN tn:User THEN tn.name ELSE '' END           AS toName,
+               tn.id                                               AS toId,
+               CASE WHEN tn:User THEN tn.name
+                    WHEN tn:Transaction THEN ''
+                    ELSE coalesce(tn.value, '') END                 AS toName,
                CASE WHEN tn:Transaction THEN tn.deviceId ELSE '' END AS toDeviceId,
 
                type(r)                                              AS relationship`,
@@ -580,13 +857,13 @@ func (d *Driver) ShortestPathSegments(
 
             from := models.PathNode{
                 Type:     rec.Values[0].(string),
-                ID:       rec.Values[1].(int64),
+                ID:       rec.Values[1].(string),
                 Name:     rec.Values[2].(string),
                 DeviceID: rec.Values[3].(string),
             }
             to := models.PathNode{
                 Type:     rec.Values[4].(string),
-                ID:       rec.Values[5].(int64),
+                ID:       rec.Values[5].(string),
                 Name:     rec.Values[6].(string),
                 DeviceID: rec.Values[7].(string),
             }
@@ -602,7 +879,7 @@ func (d *Driver) ShortestPathSegments(
             return nil, err
         }
         if len(segments) == 0 {
-            return nil, errors.New("no path found")
+            return nil, ErrNoPath
         }
         return segments, nil
     })
@@ -612,216 +889,11 @@ func (d *Driver) ShortestPathSegments(
     return raw.([]models.PathSegment), nil
 }
 
-func (d *Driver) ClusterTransactions() ([]models.TransactionCluster, error) {
-    ctx := context.Background()
-    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-    defer session.Close(ctx)
-
-    // 1) Load all transaction IDs
-    rawTx, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        rs, err := tx.Run(ctx, `MATCH (t:Transaction) RETURN id(t)`, nil)
-        if err != nil {
-            return nil, err
-        }
-        var ids []int64
-        for rs.Next(ctx) {
-            ids = append(ids, rs.Record().Values[0].(int64))
-        }
-        return ids, rs.Err()
-    })
-    if err != nil {
-        return nil, err
-    }
-    txIDs := rawTx.([]int64)
-
-    // 2) Load every transaction–transaction edge via a shared user
-    rawPairs, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        rs, err := tx.Run(ctx, `
-            MATCH (t1:Transaction)-[:SENT|RECEIVED_BY]-(u:User)-[:SENT|RECEIVED_BY]-(t2:Transaction)
-            WHERE id(t1)<id(t2)
-            RETURN id(t1), id(t2)
-        `, nil)
-        if err != nil {
-            return nil, err
-        }
-        var pairs [][2]int64
-        for rs.Next(ctx) {
-            rec := rs.Record()
-            pairs = append(pairs, [2]int64{
-                rec.Values[0].(int64),
-                rec.Values[1].(int64),
-            })
-        }
-        return pairs, rs.Err()
-    })
-    if err != nil {
-        return nil, err
-    }
-    transactionPairs := rawPairs.([][2]int64)
-
-    // 3) Build adjacency list
-    adj := make(map[int64][]int64, len(txIDs))
-    for _, id := range txIDs {
-        adj[id] = []int64{}
-    }
-    for _, p := range transactionPairs {
-        t1, t2 := p[0], p[1]
-        adj[t1] = append(adj[t1], t2)
-        adj[t2] = append(adj[t2], t1)
-    }
-
-    // 4) BFS to find connected components
-    visited := make(map[int64]bool, len(txIDs))
-    var clusters []models.TransactionCluster
-
-    for _, start := range txIDs {
-        if visited[start] {
-            continue
-        }
-        // collect this component
-        queue := []int64{start}
-        visited[start] = true
-        comp := []int64{start}
-
-        for len(queue) > 0 {
-            curr := queue[0]
-            queue = queue[1:]
-            for _, nei := range adj[curr] {
-                if !visited[nei] {
-                    visited[nei] = true
-                    queue = append(queue, nei)
-                    comp = append(comp, nei)
-                }
-            }
-        }
-
-        // determine cluster ID = smallest transaction ID in comp
-        minID := comp[0]
-        for _, tid := range comp[1:] {
-            if tid < minID {
-                minID = tid
-            }
-        }
-
-        // emit one entry per transaction in this component
-        for _, tid := range comp {
-            clusters = append(clusters, models.TransactionCluster{
-                TransactionID: tid,
-                ClusterID:     minID,
-            })
-        }
-    }
-
-    return clusters, nil
-}
-// ExportGraph pulls every node and relationship for export.
+// ExportGraph collects the StreamGraph export into one document. The history
+// that temporal views read and the Cluster records, which are rebuilt on
+// restore, are left out.
 func (d *Driver) ExportGraph() (models.GraphExportResponse, error) {
-    ctx := context.Background()
-    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
-    defer session.Close(ctx)
-
-    export := models.GraphExportResponse{}
-
-    // 1) Nodes
-    rawNodes, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        rs, err := tx.Run(ctx,
-            `MATCH (n)
-             RETURN id(n) AS id,
-                    labels(n)[0] AS type,
-                    properties(n)    AS props`,
-            nil,
-        )
-        if err != nil {
-            return nil, err
-        }
-        var nodes []models.GraphNode
-        for rs.Next(ctx) {
-            rec := rs.Record()
-            nodes = append(nodes, models.GraphNode{
-                ID:         rec.Values[0].(int64),
-                Type:       rec.Values[1].(string),
-                Properties: rec.Values[2].(map[string]any),
-            })
-        }
-        return nodes, rs.Err()
-    })
-    if err != nil {
-        return export, err
-    }
-    export.Nodes = rawNodes.([]models.GraphNode)
-
-    // 2) Relationships
-    rawRels, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        rs, err := tx.Run(ctx,
-            `MATCH (a)-[r]->(b)
-             RETURN id(a)             AS sourceId,
-                    labels(a)[0]       AS sourceType,
-                    type(r)            AS relationship,
-                    id(b)             AS targetId,
-                    labels(b)[0]      AS targetType`,
-            nil,
-        )
-        if err != nil {
-            return nil, err
-        }
-        var rels []models.GraphRelationship
-        for rs.Next(ctx) {
-            rec := rs.Record()
-            rels = append(rels, models.GraphRelationship{
-                SourceID:     rec.Values[0].(int64),
-                SourceType:   rec.Values[1].(string),
-                Relationship: rec.Values[2].(string),
-                TargetID:     rec.Values[3].(int64),
-                TargetType:   rec.Values[4].(string),
-            })
-        }
-        return rels, rs.Err()
-    })
-    if err != nil {
-        return export, err
-    }
-    export.Relationships = rawRels.([]models.GraphRelationship)
-
-    // 3) Add TT edges for shared deviceId
-    rawTT, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
-        rs, err := tx.Run(ctx,
-            `MATCH (t1:Transaction),(t2:Transaction)
-             WHERE id(t1) < id(t2)
-               AND (
-                 (t1.ip IS NOT NULL AND t1.ip = t2.ip)
-                  OR
-                 (t1.deviceId IS NOT NULL AND t1.deviceId = t2.deviceId)
-               )
-             RETURN id(t1)                                   AS sourceId,
-                    labels(t1)[0]                             AS sourceType,
-                    CASE
-                      WHEN t1.ip = t2.ip THEN 'SHARED_IP'
-                      ELSE 'SHARED_DEVICE'
-                    END                                         AS relationship,
-                    id(t2)                                   AS targetId,
-                    labels(t2)[0]                             AS targetType`,
-            nil,
-        )
-        if err != nil {
-            return nil, err
-        }
-        var ttRels []models.GraphRelationship
-        for rs.Next(ctx) {
-            rec := rs.Record()
-            ttRels = append(ttRels, models.GraphRelationship{
-                SourceID:     rec.Values[0].(int64),
-                SourceType:   rec.Values[1].(string),
-                Relationship: rec.Values[2].(string),
-                TargetID:     rec.Values[3].(int64),
-                TargetType:   rec.Values[4].(string),
-            })
-        }
-        return ttRels, rs.Err()
-    })
-    if err != nil {
-        return export, err
-    }
-    export.Relationships = append(export.Relationships, rawTT.([]models.GraphRelationship)...)
-
-    return export, nil
+    var c graphCollector
+    err := d.StreamGraph(context.Background(), &c)
+    return c.export, err
 }
\ No newline at end of file
@@ -0,0 +1,150 @@
+package graph
+
+import (
+    "context"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// GraphWriter receives an export one record at a time: every node first,
+// then every relationship. An error from either method stops the export.
+type GraphWriter interface {
+    Node(n models.GraphNode) error
+    Relationship(r models.GraphRelationship) error
+}
+
+// graphCollector gathers a streamed export into one document.
+type graphCollector struct {
+    export models.GraphExportResponse
+}
+
+func (c *graphCollector) Node(n models.GraphNode) error {
+    c.export.Nodes = append(c.export.Nodes, n)
+    return nil
+}
+
+func (c *graphCollector) Relationship(r models.GraphRelationship) error {
+    c.export.Relationships = append(c.export.Relationships, r)
+    return nil
+}
+
+// StreamGraph writes the export to out as the records come off the Neo4j
+// cursors, so nothing is held in memory beyond the driver's fetch buffer.
+// Both queries run in one read transaction and see the same graph. It
+// stops when ctx is cancelled, e.g. when the client disconnects.
+func (d *Driver) StreamGraph(ctx context.Context, out GraphWriter) error {
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    // Not ExecuteRead: a retry after some records were written would
+    // write them again.
+    tx, err := session.BeginTransaction(ctx)
+    if err != nil {
+        return err
+    }
+    defer tx.Close(ctx)
+
+    // 1) Nodes
+    rs, err := tx.Run(ctx,
+        `MATCH (n) WHERE NOT labels(n)[0] IN $skip
+         RETURN n.id AS id,
+                labels(n)[0] AS type,
+                properties(n)    AS props`,
+        map[string]any{"skip": append([]string{"Cluster"}, historyLabels...)},
+    )
+    if err != nil {
+        return err
+    }
+    for rs.Next(ctx) {
+        rec := rs.Record()
+        err := out.Node(models.GraphNode{
+            ID:         rec.Values[0].(string),
+            Type:       rec.Values[1].(string),
+            Properties: rec.Values[2].(map[string]any),
+        })
+        if err != nil {
+            return err
+        }
+    }
+    if err := rs.Err(); err != nil {
+        return err
+    }
+
+    // 2) Relationships
+    rs, err = tx.Run(ctx,
+        `MATCH (a)-[r]->(b)
+         RETURN a.id             AS sourceId,
+                labels(a)[0]       AS sourceType,
+                type(r)            AS relationship,
+                b.id             AS targetId,
+                labels(b)[0]      AS targetType`,
+        nil,
+    )
+    if err != nil {
+        return err
+    }
+    for rs.Next(ctx) {
+        rec := rs.Record()
+        err := out.Relationship(models.GraphRelationship{
+            SourceID:     rec.Values[0].(string),
+            SourceType:   rec.Values[1].(string),
+            Relationship: rec.Values[2].(string),
+            TargetID:     rec.Values[3].(string),
+            TargetType:   rec.Values[4].(string),
+        })
+        if err != nil {
+            return err
+        }
+    }
+    if err := rs.Err(); err != nil {
+        return err
+    }
+    return tx.Commit(ctx)
+}
+
+// exportChunk is the number of items StreamGraph reads per hold of the
+// read lock.
+const exportChunk = 1000
+
+// StreamGraph writes the export to out. The IDs to export are listed
+// first; their records are then read exportChunk items at a time under
+// the read lock and written after it is released, so neither the whole
+// graph is copied nor a slow client holds up writers. Records created
+// after the start are left out and deleted ones skipped.
+func (m *MemoryStore) StreamGraph(ctx context.Context, out GraphWriter) error {
+    m.mu.RLock()
+    steps := m.exportSteps()
+    m.mu.RUnlock()
+
+    var buf graphCollector
+    for _, step := range steps {
+        for start := 0; start < step.n; start += exportChunk {
+            if err := ctx.Err(); err != nil {
+                return err
+            }
+            end := start + exportChunk
+            if end > step.n {
+                end = step.n
+            }
+            buf.export.Nodes = buf.export.Nodes[:0]
+            buf.export.Relationships = buf.export.Relationships[:0]
+            m.mu.RLock()
+            for i := start; i < end; i++ {
+                step.add(i, &buf)
+            }
+            m.mu.RUnlock()
+            for _, n := range buf.export.Nodes {
+                if err := out.Node(n); err != nil {
+                    return err
+                }
+            }
+            for _, r := range buf.export.Relationships {
+                if err := out.Relationship(r); err != nil {
+                    return err
+                }
+            }
+        }
+    }
+    return nil
+}
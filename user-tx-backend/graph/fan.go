@@ -0,0 +1,139 @@
+package graph
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+const (
+    defaultFanWindow         = 24 * time.Hour
+    defaultFanCounterparties = 5
+)
+
+// FindFanPatterns flags users who receive transfers from many distinct
+// senders (fan-in) or send to many distinct receivers (fan-out) within a
+// sliding time window. Each user is reported once per direction, for the
+// busiest window that crosses the thresholds.
+func FindFanPatterns(store GraphStore, q models.FanQuery) ([]models.FanSuspect, error) {
+    var directions []string
+    switch q.Direction {
+    case "":
+        directions = []string{"in", "out"}
+    case "in", "out":
+        directions = []string{q.Direction}
+    default:
+        return nil, fmt.Errorf("%w: direction must be in or out", ErrInvalidQuery)
+    }
+    window := defaultFanWindow
+    if q.Window != "" {
+        var err error
+        if window, err = time.ParseDuration(q.Window); err != nil || window <= 0 {
+            return nil, fmt.Errorf("%w: invalid window %q", ErrInvalidQuery, q.Window)
+        }
+    }
+    if q.MinCounterparties == 0 {
+        q.MinCounterparties = defaultFanCounterparties
+    }
+    if q.MinCounterparties < 1 {
+        return nil, fmt.Errorf("%w: minCounterparties must be positive", ErrInvalidQuery)
+    }
+    from, to, err := parseWindow(q.From, q.To)
+    if err != nil {
+        return nil, err
+    }
+
+    snap, err := store.Snapshot(SnapshotFilter{From: from, To: to})
+    if err != nil {
+        return nil, err
+    }
+
+    var suspects []models.FanSuspect
+    for _, dir := range directions {
+        byUser := make(map[string][]models.Transaction)
+        for _, t := range snap.Transactions {
+            if q.MaxAmount != nil && Value(t) > *q.MaxAmount {
+                continue
+            }
+            if dir == "in" {
+                byUser[t.ToUserID] = append(byUser[t.ToUserID], t)
+            } else {
+                byUser[t.FromUserID] = append(byUser[t.FromUserID], t)
+            }
+        }
+        for _, id := range sortedKeys(byUser) {
+            if s, ok := snap.busiestWindow(byUser[id], dir, window, q); ok {
+                s.User = snap.Users[id]
+                suspects = append(suspects, s)
+            }
+        }
+    }
+
+    sort.SliceStable(suspects, func(i, j int) bool {
+        a, b := suspects[i], suspects[j]
+        if a.Counterparties != b.Counterparties {
+            return a.Counterparties > b.Counterparties
+        }
+        return a.TotalAmount > b.TotalAmount
+    })
+    if limit := pageLimit(q.Limit); len(suspects) > limit {
+        suspects = suspects[:limit]
+    }
+    return suspects, nil
+}
+
+// busiestWindow slides a window over one user's transactions (in time
+// order) and returns the window with the most distinct counterparties,
+// then the largest total, among those meeting the thresholds.
+func (s *Snapshot) busiestWindow(
+    txs []models.Transaction,
+    dir string,
+    window time.Duration,
+    q models.FanQuery,
+) (models.FanSuspect, bool) {
+    counterparty := func(t models.Transaction) string {
+        if dir == "in" {
+            return t.FromUserID
+        }
+        return t.ToUserID
+    }
+
+    var best models.FanSuspect
+    found := false
+    seen := make(map[string]int)
+    total := 0.0
+    left := 0
+    for right, t := range txs {
+        seen[counterparty(t)]++
+        total += Value(t)
+        for s.Time(t.ID).Sub(s.Time(txs[left].ID)) > window {
+            old := txs[left]
+            if seen[counterparty(old)]--; seen[counterparty(old)] == 0 {
+                delete(seen, counterparty(old))
+            }
+            total -= Value(old)
+            left++
+        }
+
+        if len(seen) < q.MinCounterparties || total < q.MinTotal {
+            continue
+        }
+        if found && (len(seen) < best.Counterparties ||
+            len(seen) == best.Counterparties && total <= best.TotalAmount) {
+            continue
+        }
+        found = true
+        best = models.FanSuspect{
+            Direction:        dir,
+            Counterparties:   len(seen),
+            TransactionCount: right - left + 1,
+            TotalAmount:      total,
+            WindowStart:      txs[left].Timestamp,
+            WindowEnd:        t.Timestamp,
+            Transactions:     append([]models.Transaction{}, txs[left:right+1]...),
+        }
+    }
+    return best, found
+}
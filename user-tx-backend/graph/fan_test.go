@@ -0,0 +1,60 @@
+package graph
+
+import (
+    "fmt"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestFindFanPatterns(t *testing.T) {
+    s := newTestStore(t)
+    hub := s.user("Hub", "hub@example.com", "0")
+    var senders, receivers []string
+    for i := 1; i <= 6; i++ {
+        id := s.user(fmt.Sprintf("U%d", i), fmt.Sprintf("u%d@example.com", i), fmt.Sprint(i))
+        if i <= 3 {
+            senders = append(senders, id)
+        } else {
+            receivers = append(receivers, id)
+        }
+    }
+    for _, id := range senders {
+        s.tx(id, hub, 10, "", "")
+    }
+    for _, id := range receivers {
+        s.tx(hub, id, 25, "", "")
+    }
+
+    suspects, err := FindFanPatterns(s, models.FanQuery{MinCounterparties: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(suspects) != 2 {
+        t.Fatalf("suspects = %+v, want the hub fanning in and out", suspects)
+    }
+    for _, got := range suspects {
+        want := map[string]float64{"in": 30, "out": 75}[got.Direction]
+        if got.User.ID != hub || got.Counterparties != 3 || got.TransactionCount != 3 || got.TotalAmount != want {
+            t.Errorf("%s suspect = %+v, want the hub with 3 counterparties moving %v", got.Direction, got, want)
+        }
+    }
+
+    maxAmount := 20.0
+    for _, tc := range []struct {
+        name string
+        q    models.FanQuery
+    }{
+        {"narrow window", models.FanQuery{Direction: "in", Window: "90s", MinCounterparties: 3}},
+        {"maxAmount", models.FanQuery{Direction: "out", MaxAmount: &maxAmount, MinCounterparties: 3}},
+        {"minTotal", models.FanQuery{Direction: "in", MinCounterparties: 3, MinTotal: 31}},
+    } {
+        suspects, err := FindFanPatterns(s, tc.q)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        if len(suspects) != 0 {
+            t.Errorf("%s: suspects = %+v, want none", tc.name, suspects)
+        }
+    }
+}
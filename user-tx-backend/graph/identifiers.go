@@ -0,0 +1,382 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// hubKind describes an identifier node. Users and transactions point at the
+// node of each value they carry instead of being linked pairwise, so a
+// value shared by n nodes costs n relationships rather than n². The pairwise
+// "shared" link is derived through the hub when it is needed.
+type hubKind struct {
+    label  string // node label
+    rel    string // relationship from the owner to the hub
+    owner  string // owner label
+    prop   string // owner property holding the value
+    prefix string // of the hub id, "<prefix>:<value>"
+    shared string // derived link between two owners of one hub
+}
+
+var hubKinds = []hubKind{
+    {label: "Email", rel: "HAS_EMAIL", owner: "User", prop: "email", prefix: "email", shared: "SHARED_EMAIL"},
+    {label: "Phone", rel: "HAS_PHONE", owner: "User", prop: "phone", prefix: "phone", shared: "SHARED_PHONE"},
+    {label: "Device", rel: "USED_DEVICE", owner: "Transaction", prop: "deviceId", prefix: "device", shared: "SHARED_DEVICE"},
+    {label: "IPAddress", rel: "FROM_IP", owner: "Transaction", prop: "ip", prefix: "ip", shared: "SHARED_IP"},
+}
+
+// sharedDerived lists the pairwise link types older versions stored as
+// relationships. They are derived through hubs now.
+var sharedDerived = []string{"SHARED_EMAIL", "SHARED_PHONE", "SHARED_DEVICE"}
+
+func hubID(k hubKind, value string) string {
+    return k.prefix + ":" + value
+}
+
+// hubByID returns the kind and value of a hub id.
+func hubByID(id string) (hubKind, string, bool) {
+    prefix, value, ok := strings.Cut(id, ":")
+    if !ok {
+        return hubKind{}, "", false
+    }
+    for _, k := range hubKinds {
+        if k.prefix == prefix {
+            return k, value, true
+        }
+    }
+    return hubKind{}, "", false
+}
+
+func hubByRel(rel string) (hubKind, bool) {
+    for _, k := range hubKinds {
+        if k.rel == rel {
+            return k, true
+        }
+    }
+    return hubKind{}, false
+}
+
+// derivedLabel reports whether nodes of label are hubs, which are rebuilt
+// from user and transaction values rather than restored.
+func derivedLabel(label string) bool {
+    for _, k := range hubKinds {
+        if k.label == label {
+            return true
+        }
+    }
+    return false
+}
+
+// derivedRel reports whether a relationship type points at a hub or is a
+// pairwise shared link, both rebuilt rather than restored.
+func derivedRel(typ string) bool {
+    for _, k := range hubKinds {
+        if k.rel == typ || k.shared == typ {
+            return true
+        }
+    }
+    return false
+}
+
+// linkHubsQuery points the owners listed in $ids at the hubs of their
+// current value. A link to a previous value is recorded as an ExpiredLink
+// and dropped; a link to the current value is kept with its createdAt.
+// Owners without a value get no hub.
+func linkHubsQuery(k hubKind) string {
+    return fmt.Sprintf(`UNWIND $ids AS id
+             MATCH (n:%[1]s) WHERE n.id = id
+             OPTIONAL MATCH (n)-[old:%[2]s]->(h:%[3]s)
+             WHERE h.id <> '%[5]s:' + coalesce(n.%[4]s, '')
+             FOREACH (_ IN CASE WHEN old IS NULL THEN [] ELSE [1] END |
+               CREATE (:ExpiredLink {
+                 id: randomUUID(), owner: n.id, rel: '%[2]s', hub: h.id,
+                 createdAt: old.createdAt, deletedAt: datetime()
+               }))
+             DELETE old
+             WITH DISTINCT n
+             WHERE coalesce(n.%[4]s, '') <> ''
+             MERGE (h:%[3]s {id: '%[5]s:' + n.%[4]s})
+               ON CREATE SET h.value = n.%[4]s
+             MERGE (n)-[r:%[2]s]->(h)
+               ON CREATE SET r.createdAt = datetime()`, k.owner, k.rel, k.label, k.prop, k.prefix)
+}
+
+// linkHubs runs linkHubsQuery for every hub kind of owner. It runs on
+// create, on bulk import (once per batch), on restore and after an update.
+func linkHubs(ctx context.Context, tx neo4j.ManagedTransaction, owner string, ids []string) error {
+    for _, k := range hubKinds {
+        if k.owner != owner {
+            continue
+        }
+        if _, err := tx.Run(ctx, linkHubsQuery(k), map[string]any{"ids": ids}); err != nil {
+            return fmt.Errorf("failed to link %s: %w", k.rel, err)
+        }
+    }
+    return nil
+}
+
+// ownedHubsQuery lists the ids of the hubs an owner with id $id points at.
+func ownedHubsQuery(owner string) string {
+    return `MATCH (n:` + owner + `)-[:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->(h)
+             WHERE n.id = $id
+             RETURN h.id`
+}
+
+// hubIDs runs a query returning hub ids in its first column. Callers read
+// them before an update or delete and pass them to pruneHubs afterwards.
+func hubIDs(ctx context.Context, tx neo4j.ManagedTransaction, query string, params map[string]any) ([]string, error) {
+    rs, err := tx.Run(ctx, query, params)
+    if err != nil {
+        return nil, err
+    }
+    var ids []string
+    for rs.Next(ctx) {
+        ids = append(ids, rs.Record().Values[0].(string))
+    }
+    return ids, rs.Err()
+}
+
+// pruneHubs deletes those of the given hubs that nothing points at any
+// more.
+func pruneHubs(ctx context.Context, tx neo4j.ManagedTransaction, ids []string) error {
+    for _, k := range hubKinds {
+        var own []string
+        for _, id := range ids {
+            if hk, _, ok := hubByID(id); ok && hk.label == k.label {
+                own = append(own, id)
+            }
+        }
+        if len(own) == 0 {
+            continue
+        }
+        if _, err := tx.Run(ctx,
+            fmt.Sprintf(`UNWIND $ids AS id
+             MATCH (h:%s) WHERE h.id = id AND NOT (h)<-[:%s]-()
+             DELETE h`, k.label, k.rel),
+            map[string]any{"ids": own},
+        ); err != nil {
+            return fmt.Errorf("failed to prune %s nodes: %w", k.label, err)
+        }
+    }
+    return nil
+}
+
+// migrationBatch bounds the rows one migration transaction touches.
+const migrationBatch = 10000
+
+// MigrateSharedLinks moves a database from pairwise SHARED_EMAIL,
+// SHARED_PHONE and SHARED_DEVICE relationships to hub nodes: every user and
+// transaction with a value is linked to its hub, then the pairwise edges
+// are deleted. Both steps run in batches and resume where they stopped, so
+// it is safe to run on every start.
+func (d *Driver) MigrateSharedLinks() (linked, deleted int64, err error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    batched := func(query string) (int64, error) {
+        var total int64
+        for {
+            raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+                rs, err := tx.Run(ctx, query, map[string]any{"batch": migrationBatch})
+                if err != nil {
+                    return nil, err
+                }
+                rec, err := rs.Single(ctx)
+                if err != nil {
+                    return nil, err
+                }
+                return rec.Values[0].(int64), nil
+            })
+            if err != nil {
+                return total, err
+            }
+            n := raw.(int64)
+            total += n
+            if n < migrationBatch {
+                return total, nil
+            }
+        }
+    }
+
+    for _, k := range hubKinds {
+        n, err := batched(fmt.Sprintf(`MATCH (n:%[1]s)
+             WHERE coalesce(n.%[4]s, '') <> '' AND NOT (n)-[:%[2]s]->(:%[3]s)
+             WITH n LIMIT $batch
+             MERGE (h:%[3]s {id: '%[5]s:' + n.%[4]s})
+               ON CREATE SET h.value = n.%[4]s
+             MERGE (n)-[:%[2]s]->(h)
+             RETURN count(n)`, k.owner, k.rel, k.label, k.prop, k.prefix))
+        linked += n
+        if err != nil {
+            return linked, deleted, fmt.Errorf("MigrateSharedLinks: %s: %w", k.rel, err)
+        }
+    }
+    deleted, err = batched(`MATCH ()-[r:` + strings.Join(sharedDerived, "|") + `]->()
+             WITH r LIMIT $batch
+             DELETE r
+             RETURN count(r)`)
+    if err != nil {
+        return linked, deleted, fmt.Errorf("MigrateSharedLinks: %w", err)
+    }
+    return linked, deleted, nil
+}
+
+// identifiersQuery lists the hubs an owner with id $id points at with their
+// degree; the columns are the ones identifierFromRecord reads.
+func identifiersQuery(owner string) string {
+    return `MATCH (n:` + owner + `)-[r:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->(h)
+             WHERE n.id = $id
+             RETURN type(r), h.id, labels(h)[0], h.value, size([(h)<-[]-() | 1])`
+}
+
+func identifierFromRecord(values []any) models.RelConnection[models.Identifier] {
+    value, _ := values[3].(string)
+    return models.RelConnection[models.Identifier]{
+        Node: models.Identifier{
+            ID:     values[1].(string),
+            Type:   values[2].(string),
+            Value:  value,
+            Degree: int(values[4].(int64)),
+        },
+        Relationship: values[0].(string),
+    }
+}
+
+// sharedTxLimit caps the transactions listed as sharing a device or IP
+// with one transaction; the identifier's degree gives the full count.
+const sharedTxLimit = 100
+
+// sharedHubs lists the hubs of a kind that have at least two members, by
+// hub ID.
+func sharedHubs(typ string, groups map[string][]string) []Hub {
+    var hubs []Hub
+    for _, key := range sortedKeys(groups) {
+        if ids := groups[key]; len(ids) > 1 {
+            hubs = append(hubs, Hub{ID: key, Type: typ, Members: ids})
+        }
+    }
+    return hubs
+}
+
+// hubValue returns the value a user or transaction carries for a kind.
+func hubValue(k hubKind, u models.User, t models.Transaction) string {
+    switch k.prop {
+    case "email":
+        return u.Email
+    case "phone":
+        return u.Phone
+    case "deviceId":
+        return t.DeviceID
+    default:
+        return t.IP
+    }
+}
+
+// hubGroups indexes the memory store's users or transactions by the hub
+// they would point at, and by the hubs of earlier values in a temporal
+// view. Callers must hold the lock.
+func (m *MemoryStore) hubGroups(k hubKind) map[string][]string {
+    groups := make(map[string][]string)
+    ids := m.userIDs
+    if k.owner == "Transaction" {
+        ids = m.txIDs
+    }
+    for _, id := range ids {
+        if v := hubValue(k, m.users[id], m.txs[id]); v != "" {
+            groups[hubID(k, v)] = append(groups[hubID(k, v)], id)
+        }
+    }
+    for _, r := range m.earlier {
+        if r.typ == k.rel && !hasString(groups[r.dst], r.src) {
+            groups[r.dst] = append(groups[r.dst], r.src)
+        }
+    }
+    return groups
+}
+
+// ownHubs lists the hubs of kind k a user or transaction points at: that
+// of its value and, in a temporal view, those of earlier values. Callers
+// must hold the lock.
+func (m *MemoryStore) ownHubs(k hubKind, u models.User, t models.Transaction) []string {
+    var hubs []string
+    if v := hubValue(k, u, t); v != "" {
+        hubs = append(hubs, hubID(k, v))
+    }
+    id := u.ID
+    if k.owner == "Transaction" {
+        id = t.ID
+    }
+    for _, r := range m.earlier {
+        if r.src == id && r.typ == k.rel && !hasString(hubs, r.dst) {
+            hubs = append(hubs, r.dst)
+        }
+    }
+    return hubs
+}
+
+// sharers lists the other users or transactions on the hubs of kind k of
+// u or t, once each. Callers must hold the lock.
+func (m *MemoryStore) sharers(k hubKind, u models.User, t models.Transaction) []string {
+    self := u.ID
+    if k.owner == "Transaction" {
+        self = t.ID
+    }
+    var ids []string
+    groups := m.hubGroups(k)
+    for _, hub := range m.ownHubs(k, u, t) {
+        for _, id := range groups[hub] {
+            if id != self && !hasString(ids, id) {
+                ids = append(ids, id)
+            }
+        }
+    }
+    return ids
+}
+
+// identifiers lists the hubs of a user or transaction with their degree.
+// Callers must hold the lock.
+func (m *MemoryStore) identifiers(owner string, u models.User, t models.Transaction) []models.RelConnection[models.Identifier] {
+    var conns []models.RelConnection[models.Identifier]
+    for _, k := range hubKinds {
+        if k.owner != owner {
+            continue
+        }
+        for _, id := range m.ownHubs(k, u, t) {
+            _, v, _ := hubByID(id)
+            conns = append(conns, models.RelConnection[models.Identifier]{
+                Node:         models.Identifier{ID: id, Type: k.label, Value: v, Degree: len(m.hubGroups(k)[id])},
+                Relationship: k.rel,
+            })
+        }
+    }
+    return conns
+}
+
+// sharingTransactions lists up to sharedTxLimit other transactions on the
+// device or IP of t, newest first. Callers must hold the lock.
+func (m *MemoryStore) sharingTransactions(t models.Transaction) []models.RelConnection[models.Transaction] {
+    var conns []models.RelConnection[models.Transaction]
+    for _, k := range hubKinds {
+        if k.owner != "Transaction" {
+            continue
+        }
+        for _, oid := range m.sharers(k, models.User{}, t) {
+            o := m.txs[oid]
+            o.FromUserID, o.ToUserID = "", ""
+            conns = append(conns, models.RelConnection[models.Transaction]{Node: o, Relationship: k.shared})
+        }
+    }
+    sort.SliceStable(conns, func(i, j int) bool {
+        return conns[i].Node.Timestamp > conns[j].Node.Timestamp
+    })
+    if len(conns) > sharedTxLimit {
+        conns = conns[:sharedTxLimit]
+    }
+    return conns
+}
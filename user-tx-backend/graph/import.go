@@ -0,0 +1,250 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// checkEndpoints rejects transaction rows whose sender or receiver is not a
+// known user. It fills results for rejected rows and returns the indexes of
+// the rows that may be written.
+func checkEndpoints(
+    rows []models.TransactionRequest,
+    results []models.ImportRowResult,
+    known map[string]bool,
+) []int {
+    var ok []int
+    for i, r := range rows {
+        switch {
+        case !known[r.FromUserID]:
+            results[i] = models.ImportRowResult{Status: "rejected", Reason: "unknown sender " + r.FromUserID}
+        case !known[r.ToUserID]:
+            results[i] = models.ImportRowResult{Status: "rejected", Reason: "unknown receiver " + r.ToUserID}
+        default:
+            ok = append(ok, i)
+        }
+    }
+    return ok
+}
+
+// ImportUsers creates a batch of users in one UNWIND transaction and links
+// them to their Email and Phone nodes once for the whole batch. Results are
+// index-aligned with rows.
+func (d *Driver) ImportUsers(rows []models.UserRequest) ([]models.ImportRowResult, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    batch := make([]map[string]any, len(rows))
+    for i, r := range rows {
+        batch[i] = map[string]any{"idx": i, "name": r.Name, "email": r.Email, "phone": r.Phone}
+    }
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        results := make([]models.ImportRowResult, len(rows))
+        rs, err := tx.Run(ctx,
+            `UNWIND $rows AS row
+             CREATE (u:User { id: randomUUID(), name: row.name, email: row.email, phone: row.phone, createdAt: datetime() })
+             RETURN row.idx, u.id`,
+            map[string]any{"rows": batch},
+        )
+        if err != nil {
+            return nil, err
+        }
+        var ids []string
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            id := rec.Values[1].(string)
+            results[rec.Values[0].(int64)] = models.ImportRowResult{Status: "accepted", ID: id}
+            ids = append(ids, id)
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        if err := linkHubs(ctx, tx, "User", ids); err != nil {
+            return nil, fmt.Errorf("ImportUsers: %w", err)
+        }
+        return results, nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.ImportRowResult), nil
+}
+
+// ImportTransactions creates a batch of transactions in one UNWIND
+// transaction, each with the first entry of its status history, links
+// them to their Device and IPAddress nodes once for the whole batch and
+// adds each to its cluster.
+// Rows referring to unknown users are rejected. Results are index-aligned
+// with rows.
+func (d *Driver) ImportTransactions(rows []models.TransactionRequest) ([]models.ImportRowResult, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    var userIDs []string
+    for _, r := range rows {
+        userIDs = append(userIDs, r.FromUserID, r.ToUserID)
+    }
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        results := make([]models.ImportRowResult, len(rows))
+
+        // 1) Resolve which referenced users exist
+        rs, err := tx.Run(ctx,
+            `UNWIND $ids AS id
+             MATCH (u:User) WHERE u.id = id
+             RETURN DISTINCT u.id`,
+            map[string]any{"ids": userIDs},
+        )
+        if err != nil {
+            return nil, err
+        }
+        known := make(map[string]bool)
+        for rs.Next(ctx) {
+            known[rs.Record().Values[0].(string)] = true
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        var batch []map[string]any
+        for _, i := range checkEndpoints(rows, results, known) {
+            r := rows[i]
+            batch = append(batch, map[string]any{
+                "idx":         i,
+                "fromUserId":  r.FromUserID,
+                "toUserId":    r.ToUserID,
+                "money":       moneyProps(r.Money),
+                "timestamp":   r.Timestamp,
+                "description": r.Description,
+                "deviceId":    r.DeviceID,
+                "ip":          r.IP,
+                "status":      r.Status,
+            })
+        }
+
+        // 2) Create the transactions with their SENT/RECEIVED_BY edges
+        rs, err = tx.Run(ctx,
+            `UNWIND $rows AS row
+             MATCH (u1:User),(u2:User)
+             WHERE u1.id = row.fromUserId AND u2.id = row.toUserId
+             CREATE (t:Transaction {
+               id:          randomUUID(),
+               timestamp:   datetime(row.timestamp),
+               description: row.description,
+               deviceId:    row.deviceId,
+               ip:          row.ip,
+               status:      row.status,
+               createdAt:   datetime()
+             })
+             SET t += row.money
+             CREATE (u1)-[:SENT]->(t)
+             CREATE (t)-[:RECEIVED_BY]->(u2)
+             CREATE (t)-[:HAS_STATUS_CHANGE]->(:StatusChange {
+               id: randomUUID(), to: row.status, at: datetime(), seq: 0
+             })
+             RETURN row.idx, t.id`,
+            map[string]any{"rows": batch},
+        )
+        if err != nil {
+            return nil, err
+        }
+        var ids []string
+        for rs.Next(ctx) {
+            rec := rs.Record()
+            id := rec.Values[1].(string)
+            results[rec.Values[0].(int64)] = models.ImportRowResult{Status: "accepted", ID: id}
+            ids = append(ids, id)
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 3) Link devices and IPs once for the batch
+        if err := linkHubs(ctx, tx, "Transaction", ids); err != nil {
+            return nil, fmt.Errorf("ImportTransactions: %w", err)
+        }
+
+        // 4) Date them, and their users, from their timestamps
+        if err := backdate(ctx, tx, ids); err != nil {
+            return nil, fmt.Errorf("ImportTransactions: %w", err)
+        }
+
+        // 5) Add them to their clusters once for the batch
+        if err := joinClusters(ctx, tx, ids); err != nil {
+            return nil, fmt.Errorf("ImportTransactions: %w", err)
+        }
+        return results, nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.ImportRowResult), nil
+}
+
+// ImportUsers creates a batch of users.
+func (m *MemoryStore) ImportUsers(rows []models.UserRequest) ([]models.ImportRowResult, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    results := make([]models.ImportRowResult, len(rows))
+    for i, r := range rows {
+        id := newID()
+        m.users[id] = models.User{ID: id, Name: r.Name, Email: r.Email, Phone: r.Phone}
+        m.userIDs = append(m.userIDs, id)
+        m.record(id, "User")
+        results[i] = models.ImportRowResult{Status: "accepted", ID: id}
+    }
+    return results, nil
+}
+
+// ImportTransactions creates a batch of transactions. Rows referring to
+// unknown users are rejected.
+func (m *MemoryStore) ImportTransactions(rows []models.TransactionRequest) ([]models.ImportRowResult, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    results := make([]models.ImportRowResult, len(rows))
+    known := make(map[string]bool, len(m.users))
+    for id := range m.users {
+        known[id] = true
+    }
+
+    var ids []string
+    for _, i := range checkEndpoints(rows, results, known) {
+        r := rows[i]
+        ts, err := time.Parse(time.RFC3339Nano, r.Timestamp)
+        if err != nil {
+            results[i] = models.ImportRowResult{Status: "rejected", Reason: "invalid timestamp " + r.Timestamp}
+            continue
+        }
+        id := newID()
+        m.txIDs = append(m.txIDs, id)
+        t := models.Transaction{
+            ID:          id,
+            FromUserID:  r.FromUserID,
+            ToUserID:    r.ToUserID,
+            Timestamp:   ts.Format(time.RFC3339Nano),
+            Description: r.Description,
+            DeviceID:    r.DeviceID,
+            IP:          r.IP,
+            Status:      r.Status,
+        }
+        applyMoney(&t, r.Money)
+        m.txs[id] = t
+        m.history[id] = []memStatusChange{{newID(), models.StatusChange{To: r.Status, At: time.Now().UTC().Format(time.RFC3339Nano)}}}
+        m.record(id, "Transaction")
+        m.backdate(id, ts.UTC())
+        ids = append(ids, id)
+        results[i] = models.ImportRowResult{Status: "accepted", ID: id}
+    }
+    m.joinClusters(ids)
+    return results, nil
+}
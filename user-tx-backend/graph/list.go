@@ -0,0 +1,568 @@
+package graph
+
+import (
+    "context"
+    "encoding/base64"
+    "encoding/json"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+const (
+    defaultPageSize = 100
+    maxPageSize     = 1000
+)
+
+// sortField describes a sortable property: its Cypher expression and the
+// kind of value ("string", "number" or "time") a cursor carries for it.
+type sortField struct {
+    expr string
+    kind string
+}
+
+var userSortFields = map[string]sortField{
+    "id":    {"u.id", "string"},
+    "name":  {"u.name", "string"},
+    "email": {"u.email", "string"},
+    "phone": {"u.phone", "string"},
+}
+
+var transactionSortFields = map[string]sortField{
+    "id":        {"t.id", "string"},
+    "amount":    {"t.amount", "number"},
+    "timestamp": {"t.timestamp", "time"},
+}
+
+// cursor marks the last item of a page for keyset pagination: the value of
+// the sort field and the ID used as tie-breaker.
+type cursor struct {
+    Sort  string `json:"s"`
+    Value any    `json:"v,omitempty"`
+    ID    string `json:"id"`
+}
+
+// encodeCursor issues the cursor after an item. Times go in as RFC3339 in
+// UTC, whatever offset the item was stored with.
+func encodeCursor(sortBy string, value any, id string) string {
+    if t, ok := value.(time.Time); ok {
+        value = t.UTC().Format(time.RFC3339Nano)
+    }
+    b, _ := json.Marshal(cursor{Sort: sortBy, Value: value, ID: id})
+    return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// sortKey turns a sort value into what compareSortValues compares: times
+// are parsed, so they order by instant whatever their offset.
+func sortKey(kind string, v any) (any, error) {
+    if kind != "time" {
+        return v, nil
+    }
+    s, _ := v.(string)
+    t, err := time.Parse(time.RFC3339Nano, s)
+    if err != nil {
+        return nil, fmt.Errorf("invalid timestamp %q", s)
+    }
+    return t, nil
+}
+
+// decodeCursor parses an opaque cursor and checks it belongs to sortBy.
+// An empty string yields a nil cursor.
+func decodeCursor(s, sortBy string, field sortField) (*cursor, error) {
+    if s == "" {
+        return nil, nil
+    }
+    b, err := base64.RawURLEncoding.DecodeString(s)
+    if err != nil {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    var c cursor
+    if err := json.Unmarshal(b, &c); err != nil || c.ID == "" {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    if c.Sort != sortBy {
+        return nil, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidQuery, c.Sort)
+    }
+    if sortBy == "id" {
+        return &c, nil
+    }
+    ok := false
+    switch field.kind {
+    case "number":
+        _, ok = c.Value.(float64)
+    case "time":
+        var v string
+        if v, ok = c.Value.(string); ok {
+            _, err := time.Parse(time.RFC3339Nano, v)
+            ok = err == nil
+        }
+    default:
+        _, ok = c.Value.(string)
+    }
+    if !ok {
+        return nil, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+    }
+    return &c, nil
+}
+
+func pageLimit(n int) int {
+    if n <= 0 {
+        return defaultPageSize
+    }
+    if n > maxPageSize {
+        return maxPageSize
+    }
+    return n
+}
+
+func userSortValue(u models.User, sortBy string) any {
+    switch sortBy {
+    case "name":
+        return u.Name
+    case "email":
+        return u.Email
+    case "phone":
+        return u.Phone
+    }
+    return nil
+}
+
+func transactionSortValue(t models.Transaction, sortBy string) any {
+    switch sortBy {
+    case "amount":
+        return t.Amount
+    case "timestamp":
+        return t.Timestamp
+    }
+    return nil
+}
+
+// resolveSort validates the requested sort field, falling back to def.
+func resolveSort(fields map[string]sortField, sortBy, def string) (string, sortField, error) {
+    if sortBy == "" {
+        sortBy = def
+    }
+    f, ok := fields[sortBy]
+    if !ok {
+        return "", sortField{}, fmt.Errorf("%w: cannot sort by %q", ErrInvalidQuery, sortBy)
+    }
+    return sortBy, f, nil
+}
+
+// parseWindow validates the optional RFC3339 bounds of a time window.
+func parseWindow(from, to string) (time.Time, time.Time, error) {
+    var start, end time.Time
+    var err error
+    if from != "" {
+        if start, err = time.Parse(time.RFC3339Nano, from); err != nil {
+            return start, end, fmt.Errorf("%w: invalid from %q", ErrInvalidQuery, from)
+        }
+    }
+    if to != "" {
+        if end, err = time.Parse(time.RFC3339Nano, to); err != nil {
+            return start, end, fmt.Errorf("%w: invalid to %q", ErrInvalidQuery, to)
+        }
+    }
+    return start, end, nil
+}
+
+// keysetCondition builds the Cypher predicate selecting rows after the
+// cursor for a (sort field, id) ordering.
+func keysetCondition(field sortField, idExpr string, desc bool) string {
+    op := ">"
+    if desc {
+        op = "<"
+    }
+    if field.expr == idExpr {
+        return fmt.Sprintf("%s %s $cursorId", idExpr, op)
+    }
+    value := "$cursorValue"
+    if field.kind == "time" {
+        value = "datetime($cursorValue)"
+    }
+    return fmt.Sprintf("(%s %s %s OR (%s = %s AND %s %s $cursorId))",
+        field.expr, op, value, field.expr, value, idExpr, op)
+}
+
+func whereClause(conds []string) string {
+    if len(conds) == 0 {
+        return ""
+    }
+    return "\n             WHERE " + strings.Join(conds, " AND ")
+}
+
+// ListUsers returns one page of users matching q.
+func (d *Driver) ListUsers(q models.UserQuery) (models.Page[models.User], error) {
+    page := models.Page[models.User]{Items: []models.User{}}
+    sortBy, field, err := resolveSort(userSortFields, q.SortBy, "name")
+    if err != nil {
+        return page, err
+    }
+    cur, err := decodeCursor(q.Cursor, sortBy, field)
+    if err != nil {
+        return page, err
+    }
+    limit := pageLimit(q.Limit)
+
+    var conds []string
+    params := map[string]any{"limit": limit + 1}
+    for _, f := range []struct{ prop, value string }{
+        {"name", q.NamePrefix},
+        {"email", q.EmailPrefix},
+        {"phone", q.PhonePrefix},
+    } {
+        if f.value != "" {
+            conds = append(conds, fmt.Sprintf("u.%s STARTS WITH $%s", f.prop, f.prop))
+            params[f.prop] = f.value
+        }
+    }
+    pageConds := conds
+    if cur != nil {
+        pageConds = append(append([]string{}, conds...), keysetCondition(field, "u.id", q.Desc))
+        params["cursorValue"] = cur.Value
+        params["cursorId"] = cur.ID
+    }
+    dir := "ASC"
+    if q.Desc {
+        dir = "DESC"
+    }
+
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    _, err = session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx, `MATCH (u:User)`+whereClause(conds)+`
+             RETURN count(u)`, params)
+        if err != nil {
+            return nil, err
+        }
+        if rs.Next(ctx) {
+            page.Total = rs.Record().Values[0].(int64)
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        rs, err = tx.Run(ctx, `MATCH (u:User)`+whereClause(pageConds)+`
+             RETURN u.id, u.name, u.email, u.phone, u {.pagerank, .degree, .betweenness, .risk}
+             ORDER BY `+field.expr+` `+dir+`, u.id `+dir+`
+             LIMIT $limit`, params)
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            page.Items = append(page.Items, models.User{
+                ID:     r.Values[0].(string),
+                Name:   r.Values[1].(string),
+                Email:  r.Values[2].(string),
+                Phone:  r.Values[3].(string),
+                Scores: userScores(r.Values[4]),
+            })
+        }
+        return nil, rs.Err()
+    })
+    if err != nil {
+        return page, err
+    }
+    if len(page.Items) > limit {
+        page.Items = page.Items[:limit]
+        last := page.Items[limit-1]
+        page.NextCursor = encodeCursor(sortBy, userSortValue(last, sortBy), last.ID)
+    }
+    return page, nil
+}
+
+// ListTransactions returns one page of transactions matching q.
+func (d *Driver) ListTransactions(q models.TransactionQuery) (models.Page[models.Transaction], error) {
+    page := models.Page[models.Transaction]{Items: []models.Transaction{}}
+    sortBy, field, err := resolveSort(transactionSortFields, q.SortBy, "timestamp")
+    if err != nil {
+        return page, err
+    }
+    cur, err := decodeCursor(q.Cursor, sortBy, field)
+    if err != nil {
+        return page, err
+    }
+    if _, _, err := parseWindow(q.From, q.To); err != nil {
+        return page, err
+    }
+    if q.Status != "" {
+        if err := checkStatuses([]string{q.Status}); err != nil {
+            return page, err
+        }
+    }
+    limit := pageLimit(q.Limit)
+
+    var conds []string
+    params := map[string]any{"limit": limit + 1}
+    add := func(cond, name string, value any) {
+        conds = append(conds, cond)
+        params[name] = value
+    }
+    if q.MinAmount != nil {
+        add("t.amount >= $minAmount", "minAmount", *q.MinAmount)
+    }
+    if q.MaxAmount != nil {
+        add("t.amount <= $maxAmount", "maxAmount", *q.MaxAmount)
+    }
+    if q.Currency != "" {
+        add("t.currency = $currency", "currency", q.Currency)
+    }
+    if q.From != "" {
+        add("t.timestamp >= datetime($from)", "from", q.From)
+    }
+    if q.To != "" {
+        add("t.timestamp < datetime($to)", "to", q.To)
+    }
+    if q.SenderID != "" {
+        add("u1.id = $sender", "sender", q.SenderID)
+    }
+    if q.ReceiverID != "" {
+        add("u2.id = $receiver", "receiver", q.ReceiverID)
+    }
+    if q.DeviceID != "" {
+        add("t.deviceId = $deviceId", "deviceId", q.DeviceID)
+    }
+    if q.IP != "" {
+        add("t.ip = $ip", "ip", q.IP)
+    }
+    if q.Status != "" {
+        add("coalesce(t.status, '"+legacyStatus+"') = $status", "status", q.Status)
+    }
+    pageConds := conds
+    if cur != nil {
+        pageConds = append(append([]string{}, conds...), keysetCondition(field, "t.id", q.Desc))
+        params["cursorValue"] = cur.Value
+        params["cursorId"] = cur.ID
+    }
+    dir := "ASC"
+    if q.Desc {
+        dir = "DESC"
+    }
+
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    const match = `MATCH (u1:User)-[:SENT]->(t:Transaction)-[:RECEIVED_BY]->(u2:User)`
+    _, err = session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx, match+whereClause(conds)+`
+             RETURN count(t)`, params)
+        if err != nil {
+            return nil, err
+        }
+        if rs.Next(ctx) {
+            page.Total = rs.Record().Values[0].(int64)
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        rs, err = tx.Run(ctx, match+whereClause(pageConds)+`
+             RETURN t.id, u1.id, u2.id,
+                    t.amount, t.currency, t.timestamp, t.description, t.deviceId,
+                    coalesce(t.ip, ''), coalesce(t.status, '`+legacyStatus+`'),
+                    `+moneyColumns("t")+`
+             ORDER BY `+field.expr+` `+dir+`, t.id `+dir+`
+             LIMIT $limit`, params)
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            t := models.Transaction{
+                ID:          r.Values[0].(string),
+                FromUserID:  r.Values[1].(string),
+                ToUserID:    r.Values[2].(string),
+                Amount:      r.Values[3].(float64),
+                Currency:    r.Values[4].(string),
+                Timestamp:   storedTime(r.Values[5]),
+                Description: r.Values[6].(string),
+                DeviceID:    r.Values[7].(string),
+                IP:          r.Values[8].(string),
+                Status:      r.Values[9].(string),
+            }
+            setMoney(&t, r.Values[10:])
+            page.Items = append(page.Items, t)
+        }
+        return nil, rs.Err()
+    })
+    if err != nil {
+        return page, err
+    }
+    if len(page.Items) > limit {
+        page.Items = page.Items[:limit]
+        last := page.Items[limit-1]
+        key, err := sortKey(field.kind, transactionSortValue(last, sortBy))
+        if err != nil {
+            return page, fmt.Errorf("transaction %s: %w", last.ID, err)
+        }
+        page.NextCursor = encodeCursor(sortBy, key, last.ID)
+    }
+    return page, nil
+}
+
+// compareSortValues orders two sort keys of the given kind.
+func compareSortValues(kind string, a, b any) int {
+    switch kind {
+    case "number":
+        x, y := a.(float64), b.(float64)
+        switch {
+        case x < y:
+            return -1
+        case x > y:
+            return 1
+        }
+        return 0
+    case "time":
+        return a.(time.Time).Compare(b.(time.Time))
+    }
+    return strings.Compare(a.(string), b.(string))
+}
+
+// paginate sorts items by (sort value, id), skips everything up to the
+// cursor and cuts one page. It backs the MemoryStore list methods. An
+// item whose sort value can't be read is an error, not misplaced.
+func paginate[T any](
+    items []T,
+    sortBy string,
+    field sortField,
+    desc bool,
+    cur *cursor,
+    limit int,
+    value func(T, string) any,
+    id func(T) string,
+) (models.Page[T], error) {
+    page := models.Page[T]{Items: []T{}, Total: int64(len(items))}
+    keys := make(map[string]any, len(items))
+    for _, it := range items {
+        key, err := sortKey(field.kind, value(it, sortBy))
+        if err != nil {
+            return page, fmt.Errorf("%s: %w", id(it), err)
+        }
+        keys[id(it)] = key
+    }
+    cmp := func(ak any, aid string, bk any, bid string) int {
+        if sortBy != "id" {
+            if c := compareSortValues(field.kind, ak, bk); c != 0 {
+                return c
+            }
+        }
+        return strings.Compare(aid, bid)
+    }
+    sort.SliceStable(items, func(i, j int) bool {
+        a, b := id(items[i]), id(items[j])
+        c := cmp(keys[a], a, keys[b], b)
+        if desc {
+            return c > 0
+        }
+        return c < 0
+    })
+
+    start := 0
+    if cur != nil {
+        curKey, err := sortKey(field.kind, cur.Value)
+        if err != nil && sortBy != "id" {
+            return page, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+        }
+        for start < len(items) {
+            c := cmp(keys[id(items[start])], id(items[start]), curKey, cur.ID)
+            if (!desc && c > 0) || (desc && c < 0) {
+                break
+            }
+            start++
+        }
+    }
+    end := start + limit
+    if end < len(items) {
+        last := id(items[end-1])
+        page.NextCursor = encodeCursor(sortBy, keys[last], last)
+    } else {
+        end = len(items)
+    }
+    page.Items = append(page.Items, items[start:end]...)
+    return page, nil
+}
+
+// ListUsers returns one page of users matching q.
+func (m *MemoryStore) ListUsers(q models.UserQuery) (models.Page[models.User], error) {
+    sortBy, field, err := resolveSort(userSortFields, q.SortBy, "name")
+    if err != nil {
+        return models.Page[models.User]{}, err
+    }
+    cur, err := decodeCursor(q.Cursor, sortBy, field)
+    if err != nil {
+        return models.Page[models.User]{}, err
+    }
+
+    m.mu.RLock()
+    var users []models.User
+    for _, id := range m.userIDs {
+        u := m.users[id]
+        if strings.HasPrefix(u.Name, q.NamePrefix) &&
+            strings.HasPrefix(u.Email, q.EmailPrefix) &&
+            strings.HasPrefix(u.Phone, q.PhonePrefix) {
+            users = append(users, u)
+        }
+    }
+    m.mu.RUnlock()
+
+    return paginate(users, sortBy, field, q.Desc, cur, pageLimit(q.Limit),
+        userSortValue, func(u models.User) string { return u.ID })
+}
+
+// ListTransactions returns one page of transactions matching q.
+func (m *MemoryStore) ListTransactions(q models.TransactionQuery) (models.Page[models.Transaction], error) {
+    sortBy, field, err := resolveSort(transactionSortFields, q.SortBy, "timestamp")
+    if err != nil {
+        return models.Page[models.Transaction]{}, err
+    }
+    cur, err := decodeCursor(q.Cursor, sortBy, field)
+    if err != nil {
+        return models.Page[models.Transaction]{}, err
+    }
+    from, to, err := parseWindow(q.From, q.To)
+    if err != nil {
+        return models.Page[models.Transaction]{}, err
+    }
+    if q.Status != "" {
+        if err := checkStatuses([]string{q.Status}); err != nil {
+            return models.Page[models.Transaction]{}, err
+        }
+    }
+
+    m.mu.RLock()
+    var txs []models.Transaction
+    for _, id := range m.txIDs {
+        t := m.txs[id]
+        ts, err := time.Parse(time.RFC3339Nano, t.Timestamp)
+        if err != nil {
+            m.mu.RUnlock()
+            return models.Page[models.Transaction]{}, fmt.Errorf("transaction %s: invalid timestamp %q", id, t.Timestamp)
+        }
+        switch {
+        case q.MinAmount != nil && t.Amount < *q.MinAmount,
+            q.MaxAmount != nil && t.Amount > *q.MaxAmount,
+            q.Currency != "" && t.Currency != q.Currency,
+            q.From != "" && ts.Before(from),
+            q.To != "" && !ts.Before(to),
+            q.SenderID != "" && t.FromUserID != q.SenderID,
+            q.ReceiverID != "" && t.ToUserID != q.ReceiverID,
+            q.DeviceID != "" && t.DeviceID != q.DeviceID,
+            q.IP != "" && t.IP != q.IP,
+            q.Status != "" && t.Status != q.Status:
+            continue
+        }
+        txs = append(txs, t)
+    }
+    m.mu.RUnlock()
+
+    return paginate(txs, sortBy, field, q.Desc, cur, pageLimit(q.Limit),
+        transactionSortValue, func(t models.Transaction) string { return t.ID })
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestListTransactionsCursorRoundTrip(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    // In time order; the first is stored with an offset and sorts by instant.
+    var want []string
+    for _, ts := range []string{
+        "2024-01-01T12:00:00+02:00",
+        "2024-01-01T10:30:00Z",
+        "2024-01-01T10:30:00Z",
+        "2024-01-01T11:00:00.5Z",
+        "2024-01-01T12:00:00Z",
+    } {
+        id, err := s.CreateTransaction(a, b, models.Money{Minor: 100, Currency: "USD"}, ts, "", "", "", "settled")
+        if err != nil {
+            t.Fatal(err)
+        }
+        want = append(want, id)
+    }
+    // The two at 10:30 tie and are ordered by ID.
+    if want[1] > want[2] {
+        want[1], want[2] = want[2], want[1]
+    }
+
+    for _, desc := range []bool{false, true} {
+        var got []string
+        q := models.TransactionQuery{SortBy: "timestamp", Desc: desc, Limit: 2}
+        for pages := 0; ; pages++ {
+            page, err := s.ListTransactions(q)
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, tx := range page.Items {
+                got = append(got, tx.ID)
+            }
+            if page.NextCursor == "" || pages > len(want) {
+                break
+            }
+            q.Cursor = page.NextCursor
+        }
+        expect := want
+        if desc {
+            expect = nil
+            for i := len(want) - 1; i >= 0; i-- {
+                expect = append(expect, want[i])
+            }
+        }
+        if len(got) != len(expect) {
+            t.Fatalf("desc=%v: paged %v, want %v", desc, got, expect)
+        }
+        for i := range expect {
+            if got[i] != expect[i] {
+                t.Fatalf("desc=%v: paged %v, want %v", desc, got, expect)
+            }
+        }
+    }
+
+    if _, err := s.ListTransactions(models.TransactionQuery{SortBy: "amount", Cursor: "bm90LWpzb24"}); err == nil {
+        t.Error("ListTransactions with a malformed cursor succeeded")
+    }
+}
+
+func TestEncodeCursorUsesUTC(t *testing.T) {
+    key, err := sortKey("time", "2024-01-01T12:00:00+02:00")
+    if err != nil {
+        t.Fatal(err)
+    }
+    c, err := decodeCursor(encodeCursor("timestamp", key, "t1"), "timestamp", transactionSortFields["timestamp"])
+    if err != nil {
+        t.Fatal(err)
+    }
+    if c.Value != "2024-01-01T10:00:00Z" || c.ID != "t1" {
+        t.Errorf("cursor = %+v, want 2024-01-01T10:00:00Z and t1", c)
+    }
+    if _, err := sortKey("time", "2024-01-01T12:00Z"); err == nil {
+        t.Error("sortKey accepted a time without seconds")
+    }
+}
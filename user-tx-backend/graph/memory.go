@@ -0,0 +1,725 @@
+package graph
+
+import (
+    "crypto/rand"
+    "fmt"
+    "strings"
+    "sync"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+// memRel is a directed relationship held by the MemoryStore.
+type memRel struct {
+    src, dst string
+    typ      string
+}
+
+// MemoryStore is a thread-safe, in-process GraphStore. Its Email, Phone,
+// Device and IPAddress nodes are derived from the user and transaction
+// values on read, and the shared links from those, as the Neo4j Driver
+// derives them through its hub nodes. It is meant for unit tests and demos
+// that run without a database.
+type MemoryStore struct {
+    mu      sync.RWMutex
+    users   map[string]models.User
+    txs     map[string]models.Transaction
+    userIDs []string // insertion order
+    txIDs   []string // insertion order
+
+    txScores map[string]map[string]float64 // stored transaction scores
+    hits     map[string][]models.WatchlistHit // WATCHLIST_HIT by user
+    identity []models.IdentityLink              // probable-match links
+
+    history   map[string][]memStatusChange // status changes by transaction
+    followUps []memRel                     // REVERSAL_OF and CHARGEBACK_OF
+
+    // Lifetimes, for temporal views: when users and transactions were
+    // created and their current identifier links made (by id+"|"+rel),
+    // ended links, deleted users and transactions and the values edited
+    // ones had, oldest first.
+    created      map[string]time.Time
+    linked       map[string]time.Time
+    expired      []timedLink
+    deletedUsers []timedUser
+    deletedTxs   []timedTransaction
+    revisions    map[string][]revision
+    earlier      []memRel // links of earlier values, in a view only
+
+    clusterOf   map[string]string // transaction → cluster
+    clusterSize map[string]int    // transactions by cluster
+
+    persons   map[string]models.Person
+    personIDs []string // insertion order
+
+    alerts   map[string]models.Alert
+    alertIDs []string // insertion order
+    cases    map[string]models.Case
+    caseIDs  []string // insertion order
+}
+
+func NewMemoryStore() *MemoryStore {
+    return &MemoryStore{
+        users:    make(map[string]models.User),
+        txs:      make(map[string]models.Transaction),
+        txScores: make(map[string]map[string]float64),
+        hits:     make(map[string][]models.WatchlistHit),
+        alerts:   make(map[string]models.Alert),
+        cases:    make(map[string]models.Case),
+        persons:  make(map[string]models.Person),
+        history:  make(map[string][]memStatusChange),
+        created:  make(map[string]time.Time),
+        linked:   make(map[string]time.Time),
+
+        revisions:   make(map[string][]revision),
+        clusterOf:   make(map[string]string),
+        clusterSize: make(map[string]int),
+    }
+}
+
+// newID returns a random (version 4) UUID, matching Neo4j's randomUUID().
+func newID() string {
+    var b [16]byte
+    if _, err := rand.Read(b[:]); err != nil {
+        panic(err)
+    }
+    b[6] = b[6]&0x0f | 0x40
+    b[8] = b[8]&0x3f | 0x80
+    return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func (m *MemoryStore) CreateUser(name, email, phone string) (string, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    id := newID()
+    m.users[id] = models.User{ID: id, Name: name, Email: email, Phone: phone}
+    m.userIDs = append(m.userIDs, id)
+    m.record(id, "User")
+    return id, nil
+}
+
+// GetAllUsers retrieves all users in creation order.
+func (m *MemoryStore) GetAllUsers() ([]models.User, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var users []models.User
+    for _, id := range m.userIDs {
+        users = append(users, m.users[id])
+    }
+    return users, nil
+}
+
+// CreateTransaction inserts a Transaction. Its device and IP are read as
+// hub memberships, so nothing else needs linking.
+func (m *MemoryStore) CreateTransaction(
+    fromID, toID string,
+    amount models.Money,
+    timestamp, description, deviceId, ip, status string,
+) (string, error) {
+    ts, err := time.Parse(time.RFC3339Nano, timestamp)
+    if err != nil {
+        return "", fmt.Errorf("CreateTransaction: invalid timestamp %q: %w", timestamp, err)
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.users[fromID]; !ok {
+        return "", fmt.Errorf("CreateTransaction: no record returned")
+    }
+    if _, ok := m.users[toID]; !ok {
+        return "", fmt.Errorf("CreateTransaction: no record returned")
+    }
+
+    id := newID()
+    m.txIDs = append(m.txIDs, id)
+    t := models.Transaction{
+        ID:          id,
+        FromUserID:  fromID,
+        ToUserID:    toID,
+        Timestamp:   ts.Format(time.RFC3339Nano),
+        Description: description,
+        DeviceID:    deviceId,
+        IP:          ip,
+        Status:      status,
+    }
+    applyMoney(&t, amount)
+    m.txs[id] = t
+    m.history[id] = []memStatusChange{{newID(), models.StatusChange{To: status, At: time.Now().UTC().Format(time.RFC3339Nano)}}}
+    m.record(id, "Transaction")
+    m.backdate(id, ts.UTC())
+    m.joinCluster(id)
+    return id, nil
+}
+
+// GetAllTransactions retrieves every transaction in creation order.
+func (m *MemoryStore) GetAllTransactions() ([]models.Transaction, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    var txs []models.Transaction
+    for _, id := range m.txIDs {
+        txs = append(txs, m.txs[id])
+    }
+    return txs, nil
+}
+
+// GetTransaction fetches one transaction.
+func (m *MemoryStore) GetTransaction(id string) (models.Transaction, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    t, ok := m.txs[id]
+    if !ok {
+        return models.Transaction{}, ErrTransactionNotFound
+    }
+    return t, nil
+}
+
+// GetUserRelationships fetches a user plus shared-attribute links, both
+// sent and received transactions, and its email and phone nodes.
+func (m *MemoryStore) GetUserRelationships(
+    userID string,
+) (models.User, models.UserConnections, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    user, ok := m.users[userID]
+    if !ok {
+        return models.User{}, models.UserConnections{}, ErrUserNotFound
+    }
+
+    conns := models.UserConnections{}
+    for _, k := range hubKinds {
+        if k.owner != "User" {
+            continue
+        }
+        for _, other := range m.sharers(k, user, models.Transaction{}) {
+            conns.Users = append(conns.Users, models.RelConnection[models.User]{
+                Node:         m.users[other],
+                Relationship: k.shared,
+            })
+        }
+    }
+    for _, l := range m.identity {
+        other := l.To
+        if l.To == userID {
+            other = l.From
+        } else if l.From != userID {
+            continue
+        }
+        conns.Users = append(conns.Users, models.RelConnection[models.User]{
+            Node:         m.users[other],
+            Relationship: l.Type,
+            Confidence:   l.Confidence,
+        })
+    }
+    for _, id := range m.txIDs {
+        if t := m.txs[id]; t.FromUserID == userID {
+            conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
+                Node:         t,
+                Relationship: "SENT",
+            })
+        }
+    }
+    for _, id := range m.txIDs {
+        if t := m.txs[id]; t.ToUserID == userID {
+            conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
+                Node:         t,
+                Relationship: "RECEIVED_BY",
+            })
+        }
+    }
+    for _, h := range m.hits[userID] {
+        conns.Watchlist = append(conns.Watchlist, models.RelConnection[models.WatchlistHit]{
+            Node:         h,
+            Relationship: "WATCHLIST_HIT",
+        })
+    }
+    conns.Identifiers = m.identifiers("User", user, models.Transaction{})
+    return user, conns, nil
+}
+
+// GetTransactionRelationships fetches a transaction plus its sender and
+// receiver, its device and IP nodes, and its reversals and chargebacks (or
+// the original it follows up) ahead of the transactions sharing them.
+func (m *MemoryStore) GetTransactionRelationships(
+    txID string,
+) (models.Transaction, models.TxConnections, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    t, ok := m.txs[txID]
+    if !ok {
+        return models.Transaction{}, models.TxConnections{}, ErrTransactionNotFound
+    }
+
+    // The Neo4j driver does not fill the endpoints on the node itself.
+    txNode := t
+    txNode.FromUserID, txNode.ToUserID = "", ""
+
+    conns := models.TxConnections{
+        Users: []models.RelConnection[models.User]{
+            {Node: m.users[t.FromUserID], Relationship: "SENT"},
+            {Node: m.users[t.ToUserID], Relationship: "RECEIVED_BY"},
+        },
+        Identifiers: m.identifiers("Transaction", models.User{}, t),
+    }
+    for _, r := range m.followUps {
+        other := r.dst
+        if r.dst == txID {
+            other = r.src
+        } else if r.src != txID {
+            continue
+        }
+        o := m.txs[other]
+        o.FromUserID, o.ToUserID = "", ""
+        conns.Transactions = append(conns.Transactions, models.RelConnection[models.Transaction]{
+            Node:         o,
+            Relationship: r.typ,
+        })
+    }
+    conns.Transactions = append(conns.Transactions, m.sharingTransactions(t)...)
+    return txNode, conns, nil
+}
+
+// ShortestPathSegments runs an undirected, unweighted BFS over the
+// shortestPathRels relationships, as the Driver does.
+func (m *MemoryStore) ShortestPathSegments(
+    fromID, toID string,
+) ([]models.PathSegment, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    if _, ok := m.users[fromID]; !ok {
+        return nil, ErrNoPath
+    }
+    if _, ok := m.users[toID]; !ok || fromID == toID {
+        return nil, ErrNoPath
+    }
+
+    walk := strings.Split(shortestPathRels, "|")
+    var rels []memRel
+    for _, r := range m.allRels() {
+        if hasString(walk, r.typ) {
+            rels = append(rels, r)
+        }
+    }
+    adj := make(map[string][]int, len(rels))
+    for i, r := range rels {
+        adj[r.src] = append(adj[r.src], i)
+        adj[r.dst] = append(adj[r.dst], i)
+    }
+
+    // prev maps a visited node to the index of the relationship used to reach it
+    prev := map[string]int{fromID: -1}
+    queue := []string{fromID}
+    for len(queue) > 0 {
+        curr := queue[0]
+        queue = queue[1:]
+        if curr == toID {
+            break
+        }
+        for _, ri := range adj[curr] {
+            nei := rels[ri].src
+            if nei == curr {
+                nei = rels[ri].dst
+            }
+            if _, seen := prev[nei]; !seen {
+                prev[nei] = ri
+                queue = append(queue, nei)
+            }
+        }
+    }
+    if _, ok := prev[toID]; !ok {
+        return nil, ErrNoPath
+    }
+
+    var segments []models.PathSegment
+    for node := toID; prev[node] != -1; {
+        r := rels[prev[node]]
+        segments = append(segments, models.PathSegment{
+            From:         m.pathNode(r.src),
+            To:           m.pathNode(r.dst),
+            Relationship: r.typ,
+        })
+        if node == r.src {
+            node = r.dst
+        } else {
+            node = r.src
+        }
+    }
+    for i, j := 0, len(segments)-1; i < j; i, j = i+1, j-1 {
+        segments[i], segments[j] = segments[j], segments[i]
+    }
+    return segments, nil
+}
+
+// ExportGraph returns every node and relationship, including the Email,
+// Phone, Device and IPAddress nodes and the links to them and the
+// StatusChange nodes of each transaction.
+func (m *MemoryStore) ExportGraph() (models.GraphExportResponse, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return m.exportGraph(), nil
+}
+
+// exportGraph does ExportGraph. Callers must hold the lock.
+func (m *MemoryStore) exportGraph() models.GraphExportResponse {
+    var c graphCollector
+    for _, step := range m.exportSteps() {
+        for i := 0; i < step.n; i++ {
+            step.add(i, &c)
+        }
+    }
+    return c.export
+}
+
+// exportStep is one part of the export: add writes the records of its
+// i-th item, for i below n. The steps list the IDs when they are made and
+// look up the records when add runs, skipping users and transactions
+// deleted since, so StreamGraph can run them a chunk at a time. Callers
+// must hold the lock for both.
+type exportStep struct {
+    n   int
+    add func(i int, out *graphCollector)
+}
+
+// each is the exportStep that runs add for every ID of ids.
+func each(ids []string, add func(id string, out *graphCollector)) exportStep {
+    return exportStep{len(ids), func(i int, out *graphCollector) { add(ids[i], out) }}
+}
+
+// exportSteps returns the steps of the export: users, transactions, hubs,
+// status changes, watchlist entries, Persons, alerts and cases with their
+// notes and evidence, then the relationships between them. These are the
+// labels and relationship types the Neo4j export has.
+func (m *MemoryStore) exportSteps() []exportStep {
+    userIDs := append([]string(nil), m.userIDs...)
+    txIDs := append([]string(nil), m.txIDs...)
+    followUps := append([]memRel(nil), m.followUps...)
+    identity := append([]models.IdentityLink(nil), m.identity...)
+    personIDs := append([]string(nil), m.personIDs...)
+    alertIDs := append([]string(nil), m.alertIDs...)
+    caseIDs := append([]string(nil), m.caseIDs...)
+    entries := make(map[string]models.WatchlistHit)
+    for _, hits := range m.hits {
+        for _, h := range hits {
+            entries[h.EntryID] = h
+        }
+    }
+    rel := func(src, typ, dst string) models.GraphRelationship {
+        return models.GraphRelationship{
+            SourceID:     src,
+            SourceType:   m.label(src),
+            Relationship: typ,
+            TargetID:     dst,
+            TargetType:   m.label(dst),
+        }
+    }
+    typed := func(src, srcType, typ, dst, dstType string) models.GraphRelationship {
+        return models.GraphRelationship{
+            SourceID:     src,
+            SourceType:   srcType,
+            Relationship: typ,
+            TargetID:     dst,
+            TargetType:   dstType,
+        }
+    }
+
+    steps := []exportStep{
+        each(userIDs, func(id string, out *graphCollector) {
+            u, ok := m.users[id]
+            if !ok {
+                return
+            }
+            props := map[string]any{
+                "id":    id,
+                "name":  u.Name,
+                "email": u.Email,
+                "phone": u.Phone,
+            }
+            if c := m.created[id]; !c.IsZero() {
+                props["createdAt"] = c.Format(time.RFC3339Nano)
+            }
+            for alg, score := range u.Scores {
+                props[alg] = score
+            }
+            out.Node(models.GraphNode{ID: id, Type: "User", Properties: props})
+        }),
+        each(txIDs, func(id string, out *graphCollector) {
+            t, ok := m.txs[id]
+            if !ok {
+                return
+            }
+            props := map[string]any{
+                "id":          id,
+                "amount":      t.Amount,
+                "currency":    t.Currency,
+                "timestamp":   t.Timestamp,
+                "description": t.Description,
+                "deviceId":    t.DeviceID,
+                "status":      t.Status,
+            }
+            if t.IP != "" {
+                props["ip"] = t.IP
+            }
+            exportMoney(props, t)
+            if c := m.created[id]; !c.IsZero() {
+                props["createdAt"] = c.Format(time.RFC3339Nano)
+            }
+            if c, ok := m.clusterOf[id]; ok {
+                props["clusterId"] = c
+            }
+            for name, score := range m.txScores[id] {
+                props[name] = score
+            }
+            out.Node(models.GraphNode{ID: id, Type: "Transaction", Properties: props})
+        }),
+    }
+
+    groups := make([]map[string][]string, len(hubKinds))
+    for i, k := range hubKinds {
+        k := k
+        groups[i] = m.hubGroups(k)
+        steps = append(steps, each(sortedKeys(groups[i]), func(id string, out *graphCollector) {
+            _, value, _ := hubByID(id)
+            out.Node(models.GraphNode{
+                ID:         id,
+                Type:       k.label,
+                Properties: map[string]any{"id": id, "value": value},
+            })
+        }))
+    }
+
+    steps = append(steps,
+        each(txIDs, func(id string, out *graphCollector) {
+            for i, c := range m.history[id] {
+                props := map[string]any{"id": c.id, "to": c.To, "at": c.At, "seq": int64(i)}
+                if c.From != "" {
+                    props["from"] = c.From
+                }
+                if c.Reason != "" {
+                    props["reason"] = c.Reason
+                }
+                out.Node(models.GraphNode{ID: c.id, Type: "StatusChange", Properties: props})
+            }
+        }),
+        each(sortedKeys(entries), func(id string, out *graphCollector) {
+            h := entries[id]
+            out.Node(models.GraphNode{ID: id, Type: "WatchlistEntry", Properties: map[string]any{
+                "id":      id,
+                "list":    h.List,
+                "name":    h.Name,
+                "program": h.Program,
+            }})
+        }),
+        each(personIDs, func(id string, out *graphCollector) {
+            if p, ok := m.persons[id]; ok {
+                out.Node(models.GraphNode{ID: id, Type: "Person", Properties: map[string]any{
+                    "id":        id,
+                    "name":      p.Name,
+                    "createdAt": p.CreatedAt,
+                }})
+            }
+        }),
+        each(alertIDs, func(id string, out *graphCollector) {
+            if a, ok := m.alerts[id]; ok {
+                out.Node(models.GraphNode{ID: id, Type: "Alert", Properties: map[string]any{
+                    "id":         id,
+                    "ruleId":     a.RuleID,
+                    "ruleName":   a.RuleName,
+                    "severity":   a.Severity,
+                    "action":     a.Action,
+                    "message":    a.Message,
+                    "status":     a.Status,
+                    "senderId":   a.SenderID,
+                    "receiverId": a.ReceiverID,
+                    "createdAt":  a.CreatedAt,
+                }})
+            }
+        }),
+        each(caseIDs, func(id string, out *graphCollector) {
+            c, ok := m.cases[id]
+            if !ok {
+                return
+            }
+            props := map[string]any{
+                "id":          id,
+                "title":       c.Title,
+                "description": c.Description,
+                "assignee":    c.Assignee,
+                "status":      c.Status,
+                "createdAt":   c.CreatedAt,
+                "updatedAt":   c.UpdatedAt,
+            }
+            if c.Disposition != "" {
+                props["disposition"] = c.Disposition
+            }
+            if c.ClosedAt != "" {
+                props["closedAt"] = c.ClosedAt
+            }
+            out.Node(models.GraphNode{ID: id, Type: "Case", Properties: props})
+            for _, n := range c.Notes {
+                out.Node(models.GraphNode{ID: n.ID, Type: "Note", Properties: map[string]any{
+                    "id":        n.ID,
+                    "author":    n.Author,
+                    "text":      n.Text,
+                    "createdAt": n.CreatedAt,
+                }})
+            }
+            for _, e := range c.Evidence {
+                props := map[string]any{
+                    "id":        e.ID,
+                    "kind":      e.Kind,
+                    "title":     e.Title,
+                    "data":      string(e.Data),
+                    "createdAt": e.CreatedAt,
+                }
+                if e.Source != "" {
+                    props["source"] = e.Source
+                }
+                out.Node(models.GraphNode{ID: e.ID, Type: "Evidence", Properties: props})
+            }
+        }),
+        each(txIDs, func(id string, out *graphCollector) {
+            if t, ok := m.txs[id]; ok {
+                out.Relationship(rel(t.FromUserID, "SENT", id))
+                out.Relationship(rel(id, "RECEIVED_BY", t.ToUserID))
+            }
+        }),
+    )
+    for i, k := range hubKinds {
+        k, members := k, groups[i]
+        steps = append(steps, each(sortedKeys(members), func(hid string, out *graphCollector) {
+            for _, id := range members[hid] {
+                if m.exists(id) {
+                    out.Relationship(rel(id, k.rel, hid))
+                }
+            }
+        }))
+    }
+    return append(steps,
+        exportStep{len(followUps), func(i int, out *graphCollector) {
+            if r := followUps[i]; m.exists(r.src) && m.exists(r.dst) {
+                out.Relationship(rel(r.src, r.typ, r.dst))
+            }
+        }},
+        each(txIDs, func(id string, out *graphCollector) {
+            for _, c := range m.history[id] {
+                out.Relationship(typed(id, "Transaction", "HAS_STATUS_CHANGE", c.id, "StatusChange"))
+            }
+        }),
+        each(userIDs, func(id string, out *graphCollector) {
+            if _, ok := m.users[id]; !ok {
+                return
+            }
+            for _, h := range m.hits[id] {
+                out.Relationship(typed(id, "User", "WATCHLIST_HIT", h.EntryID, "WatchlistEntry"))
+            }
+        }),
+        exportStep{len(identity), func(i int, out *graphCollector) {
+            if l := identity[i]; m.exists(l.From) && m.exists(l.To) {
+                out.Relationship(typed(l.From, "User", l.Type, l.To, "User"))
+            }
+        }},
+        each(personIDs, func(id string, out *graphCollector) {
+            for _, uid := range m.persons[id].UserIDs {
+                if m.exists(uid) {
+                    out.Relationship(typed(uid, "User", "RESOLVED_AS", id, "Person"))
+                }
+            }
+        }),
+        each(alertIDs, func(id string, out *graphCollector) {
+            a, ok := m.alerts[id]
+            if !ok {
+                return
+            }
+            if m.exists(a.TransactionID) {
+                out.Relationship(typed(id, "Alert", "FLAGS", a.TransactionID, "Transaction"))
+            }
+            for _, uid := range distinct([]string{a.SenderID, a.ReceiverID}) {
+                if m.exists(uid) {
+                    out.Relationship(typed(id, "Alert", "CONCERNS", uid, "User"))
+                }
+            }
+        }),
+        each(caseIDs, func(id string, out *graphCollector) {
+            c, ok := m.cases[id]
+            if !ok {
+                return
+            }
+            for _, aid := range c.AlertIDs {
+                out.Relationship(typed(id, "Case", "INCLUDES", aid, "Alert"))
+            }
+            for _, uid := range c.UserIDs {
+                out.Relationship(typed(id, "Case", "CONCERNS", uid, "User"))
+            }
+            for _, tid := range c.TransactionIDs {
+                out.Relationship(typed(id, "Case", "CONCERNS", tid, "Transaction"))
+            }
+            for _, n := range c.Notes {
+                out.Relationship(typed(id, "Case", "HAS_NOTE", n.ID, "Note"))
+            }
+            for _, e := range c.Evidence {
+                out.Relationship(typed(id, "Case", "HAS_EVIDENCE", e.ID, "Evidence"))
+            }
+        }),
+    )
+}
+
+// exists reports whether id is a stored user or transaction. Callers must
+// hold the lock.
+func (m *MemoryStore) exists(id string) bool {
+    _, user := m.users[id]
+    _, tx := m.txs[id]
+    return user || tx
+}
+
+// allRels lists SENT and RECEIVED_BY edges, then the links from users and
+// transactions to their hubs and the follow-up links between transactions.
+// Callers must hold the lock.
+func (m *MemoryStore) allRels() []memRel {
+    var rels []memRel
+    for _, id := range m.txIDs {
+        t := m.txs[id]
+        rels = append(rels,
+            memRel{src: t.FromUserID, dst: id, typ: "SENT"},
+            memRel{src: id, dst: t.ToUserID, typ: "RECEIVED_BY"},
+        )
+    }
+    for _, k := range hubKinds {
+        groups := m.hubGroups(k)
+        for _, hid := range sortedKeys(groups) {
+            for _, id := range groups[hid] {
+                rels = append(rels, memRel{src: id, dst: hid, typ: k.rel})
+            }
+        }
+    }
+    return append(rels, m.followUps...)
+}
+
+func (m *MemoryStore) label(id string) string {
+    if _, ok := m.users[id]; ok {
+        return "User"
+    }
+    if _, ok := m.txs[id]; ok {
+        return "Transaction"
+    }
+    k, _, _ := hubByID(id)
+    return k.label
+}
+
+func (m *MemoryStore) pathNode(id string) models.PathNode {
+    if u, ok := m.users[id]; ok {
+        return models.PathNode{ID: id, Type: "User", Name: u.Name}
+    }
+    if t, ok := m.txs[id]; ok {
+        return models.PathNode{ID: id, Type: "Transaction", DeviceID: t.DeviceID}
+    }
+    k, value, _ := hubByID(id)
+    return models.PathNode{ID: id, Type: k.label, Name: value}
+}
@@ -0,0 +1,445 @@
+package graph
+
+import (
+    "context"
+    "errors"
+    "strings"
+    "testing"
+    "time"
+
+    "user-tx-backend/fx"
+    "user-tx-backend/models"
+)
+
+// testStart is the timestamp of the first transaction a test creates.
+var testStart = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// testStore wraps a MemoryStore with helpers that fail the test on error.
+type testStore struct {
+    *MemoryStore
+    t    *testing.T
+    conv *fx.Converter
+    n    int // transactions created, to space out their timestamps
+}
+
+func newTestStore(t *testing.T) *testStore {
+    t.Helper()
+    conv, err := fx.NewConverter("", "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return &testStore{MemoryStore: NewMemoryStore(), t: t, conv: conv}
+}
+
+func (s *testStore) user(name, email, phone string) string {
+    s.t.Helper()
+    id, err := s.CreateUser(name, email, phone)
+    if err != nil {
+        s.t.Fatalf("CreateUser(%q): %v", name, err)
+    }
+    return id
+}
+
+// tx creates a settled USD transaction, a minute after the previous one.
+func (s *testStore) tx(from, to string, amount float64, device, ip string) string {
+    s.t.Helper()
+    ts := testStart.Add(time.Duration(s.n) * time.Minute).Format(time.RFC3339)
+    s.n++
+    money, err := s.conv.Money(amount, "USD", ts)
+    if err != nil {
+        s.t.Fatal(err)
+    }
+    id, err := s.CreateTransaction(from, to, money, ts, "test", device, ip, "settled")
+    if err != nil {
+        s.t.Fatalf("CreateTransaction: %v", err)
+    }
+    return id
+}
+
+// clusters maps each transaction to its cluster.
+func (s *testStore) clusters() map[string]string {
+    s.t.Helper()
+    cs, err := s.ClusterTransactions(nil)
+    if err != nil {
+        s.t.Fatal(err)
+    }
+    m := make(map[string]string, len(cs))
+    for _, c := range cs {
+        m[c.TransactionID] = c.ClusterID
+    }
+    return m
+}
+
+func TestMemoryStoreCreateAndList(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    id := s.tx(alice, bob, 12.5, "dev-1", "203.0.113.1")
+
+    users, err := s.GetAllUsers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 2 || users[0].ID != alice || users[1].ID != bob {
+        t.Fatalf("GetAllUsers = %+v, want Alice then Bob", users)
+    }
+
+    txs, err := s.GetAllTransactions()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(txs) != 1 {
+        t.Fatalf("GetAllTransactions returned %d transactions, want 1", len(txs))
+    }
+    got := txs[0]
+    if got.ID != id || got.FromUserID != alice || got.ToUserID != bob {
+        t.Errorf("transaction = %+v, want %s from Alice to Bob", got, id)
+    }
+    if got.Amount != 12.5 || got.AmountMinor != 1250 || got.Currency != "USD" || got.Status != "settled" {
+        t.Errorf("transaction = %+v, want 12.50 USD settled", got)
+    }
+
+    if _, err := s.CreateTransaction(alice, "nobody", models.Money{Minor: 1, Currency: "USD"}, got.Timestamp, "", "", "", "settled"); err == nil {
+        t.Error("CreateTransaction to an unknown user succeeded")
+    }
+}
+
+func TestMemoryStoreSharedLinks(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    carol := s.user("Carol", "alice@example.com", "333")
+    dave := s.user("Dave", "dave@example.com", "111")
+    t1 := s.tx(alice, carol, 10, "dev-1", "")
+    t2 := s.tx(dave, carol, 20, "dev-1", "")
+
+    _, conns, err := s.GetUserRelationships(alice)
+    if err != nil {
+        t.Fatal(err)
+    }
+    shared := make(map[string]string)
+    for _, c := range conns.Users {
+        shared[c.Node.ID] = c.Relationship
+    }
+    if shared[carol] != "SHARED_EMAIL" || shared[dave] != "SHARED_PHONE" {
+        t.Errorf("Alice's shared links = %v, want Carol SHARED_EMAIL and Dave SHARED_PHONE", shared)
+    }
+
+    _, txConns, err := s.GetTransactionRelationships(t1)
+    if err != nil {
+        t.Fatal(err)
+    }
+    found := false
+    for _, c := range txConns.Transactions {
+        found = found || (c.Node.ID == t2 && c.Relationship == "SHARED_DEVICE")
+    }
+    if !found {
+        t.Errorf("transaction connections = %+v, want %s over SHARED_DEVICE", txConns.Transactions, t2)
+    }
+
+    if _, _, err := s.GetUserRelationships("nobody"); !errors.Is(err, ErrUserNotFound) {
+        t.Errorf("GetUserRelationships(unknown) error = %v, want ErrUserNotFound", err)
+    }
+    if _, _, err := s.GetTransactionRelationships("nothing"); !errors.Is(err, ErrTransactionNotFound) {
+        t.Errorf("GetTransactionRelationships(unknown) error = %v, want ErrTransactionNotFound", err)
+    }
+}
+
+func TestMemoryStoreShortestPath(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    loner := s.user("D", "d@example.com", "4")
+    s.tx(a, b, 10, "", "")
+    s.tx(b, c, 10, "", "")
+
+    segs, err := s.ShortestPathSegments(a, c)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var rels []string
+    for _, seg := range segs {
+        rels = append(rels, seg.Relationship)
+    }
+    if len(segs) != 4 || segs[0].From.ID != a || segs[3].To.ID != c {
+        t.Errorf("path A→C = %v, want 4 hops from A to C", rels)
+    }
+    if _, err := s.ShortestPathSegments(a, loner); !errors.Is(err, ErrNoPath) {
+        t.Errorf("path to an unconnected user error = %v, want ErrNoPath", err)
+    }
+}
+
+func TestMemoryStoreClusters(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    t1 := s.tx(a, b, 10, "", "")
+    t2 := s.tx(b, a, 10, "", "")
+    t3 := s.tx(c, d, 10, "", "")
+
+    got := s.clusters()
+    if got[t1] == "" || got[t1] != got[t2] {
+        t.Errorf("transactions between A and B in clusters %q and %q, want one", got[t1], got[t2])
+    }
+    if got[t3] == got[t1] {
+        t.Errorf("C→D shares cluster %q with A↔B", got[t3])
+    }
+}
+
+func TestMemoryStoreClusterSplit(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    t1 := s.tx(a, b, 10, "", "")
+    t2 := s.tx(a, a, 10, "", "")
+    bridge := s.tx(b, c, 10, "", "")
+    t3 := s.tx(c, d, 10, "", "")
+    before := s.clusters()
+    if before[t1] != before[t3] {
+        t.Fatalf("clusters = %v, want one cluster over the bridge", before)
+    }
+
+    if err := s.DeleteTransaction(bridge); err != nil {
+        t.Fatal(err)
+    }
+    got := s.clusters()
+    if got[t1] != before[t1] || got[t2] != got[t1] {
+        t.Errorf("clusters = %v, want the larger side to keep %q", got, before[t1])
+    }
+    if got[t3] == "" || got[t3] == got[t1] {
+        t.Errorf("clusters = %v, want %s split off", got, t3)
+    }
+    report, err := s.RebuildClusters(true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if report.Clusters != 2 || report.Reassigned != 0 || report.Resized != 0 {
+        t.Errorf("rebuild check = %+v, want 2 clusters and nothing to fix", report)
+    }
+}
+
+func TestMemoryStoreExport(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    id := s.tx(a, b, 10, "dev-1", "")
+
+    export, err := s.ExportGraph()
+    if err != nil {
+        t.Fatal(err)
+    }
+    types := make(map[string]int)
+    for _, n := range export.Nodes {
+        types[n.Type]++
+    }
+    if types["User"] != 2 || types["Transaction"] != 1 || types["Device"] != 1 {
+        t.Errorf("exported node types = %v, want 2 users, 1 transaction, 1 device", types)
+    }
+    rels := make(map[string]bool)
+    for _, r := range export.Relationships {
+        rels[r.SourceID+" "+r.Relationship+" "+r.TargetID] = true
+    }
+    if !rels[a+" SENT "+id] || !rels[id+" RECEIVED_BY "+b] || !rels[id+" USED_DEVICE device:dev-1"] {
+        t.Errorf("exported relationships = %v, want SENT, RECEIVED_BY and USED_DEVICE", rels)
+    }
+}
+
+func TestMemoryStoreShortestPathSkipsFollowUps(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    original := s.tx(a, b, 10, "", "")
+    refund := s.tx(c, d, 10, "", "")
+    if err := s.LinkFollowUp(refund, original, "REVERSAL_OF"); err != nil {
+        t.Fatal(err)
+    }
+
+    if segs, err := s.ShortestPathSegments(a, d); !errors.Is(err, ErrNoPath) {
+        t.Errorf("path A→D = %+v, %v; want ErrNoPath, not a path over REVERSAL_OF", segs, err)
+    }
+}
+
+func TestMemoryStoreImportJoinsClusters(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    e := s.user("E", "e@example.com", "5")
+    f := s.user("F", "f@example.com", "6")
+    t1 := s.tx(a, b, 10, "", "")
+    t2 := s.tx(c, d, 10, "", "")
+    t3 := s.tx(a, a, 10, "", "")
+    before := s.clusters()
+
+    row := func(from, to string) models.TransactionRequest {
+        money, err := s.conv.Money(10, "USD", testStart.Format(time.RFC3339))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return models.TransactionRequest{FromUserID: from, ToUserID: to, Timestamp: testStart.Format(time.RFC3339), Money: money, Status: "settled"}
+    }
+    // B→C joins A↔B and C→D; E↔F start a cluster of their own.
+    results, err := s.ImportTransactions([]models.TransactionRequest{row(b, c), row(e, f), row(f, e)})
+    if err != nil {
+        t.Fatal(err)
+    }
+    got := s.clusters()
+    bridge, ef, fe := results[0].ID, results[1].ID, results[2].ID
+    if got[bridge] != got[t1] || got[t1] != got[t2] || got[t2] != got[t3] {
+        t.Errorf("clusters = %v, want %s, %s, %s and %s together", got, bridge, t1, t2, t3)
+    }
+    if want := before[t1]; got[t1] != want {
+        t.Errorf("merged cluster = %q, want the larger %q kept", got[t1], want)
+    }
+    if got[ef] == "" || got[ef] != got[fe] || got[ef] == got[t1] {
+        t.Errorf("clusters = %v, want %s and %s in a new cluster", got, ef, fe)
+    }
+    report, err := s.RebuildClusters(true)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if report.Clusters != 2 || report.Reassigned != 0 || report.Resized != 0 {
+        t.Errorf("rebuild check = %+v, want 2 clusters and nothing to fix", report)
+    }
+}
+
+// deletingWriter collects a streamed export and deletes a transaction when
+// the first record arrives.
+type deletingWriter struct {
+    graphCollector
+    s  *testStore
+    id string
+}
+
+func (w *deletingWriter) Node(n models.GraphNode) error {
+    if w.id != "" {
+        // Needs the write lock: StreamGraph must not hold the read lock
+        // while writing.
+        if err := w.s.DeleteTransaction(w.id); err != nil {
+            return err
+        }
+        w.id = ""
+    }
+    return w.graphCollector.Node(n)
+}
+
+func TestMemoryStoreStreamGraph(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    kept := s.tx(a, b, 10, "dev-1", "")
+    gone := s.tx(b, a, 10, "dev-1", "")
+
+    var all graphCollector
+    if err := s.StreamGraph(context.Background(), &all); err != nil {
+        t.Fatal(err)
+    }
+    export, err := s.ExportGraph()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if want, got := graphKeys(export), graphKeys(all.export); strings.Join(got, "\n") != strings.Join(want, "\n") {
+        t.Errorf("streamed graph = %v, want %v", got, want)
+    }
+
+    w := &deletingWriter{s: s, id: gone}
+    if err := s.StreamGraph(context.Background(), w); err != nil {
+        t.Fatal(err)
+    }
+    keys := strings.Join(graphKeys(w.export), "\n")
+    if strings.Contains(keys, gone) || !strings.Contains(keys, kept) {
+        t.Errorf("streamed graph = %v, want %s without %s, deleted while streaming", keys, kept, gone)
+    }
+}
+
+// exportedNodes returns the IDs of the nodes s exports.
+func (s *testStore) exportedNodes() map[string]bool {
+    s.t.Helper()
+    export, err := s.ExportGraph()
+    if err != nil {
+        s.t.Fatal(err)
+    }
+    ids := make(map[string]bool, len(export.Nodes))
+    for _, n := range export.Nodes {
+        ids[n.ID] = true
+    }
+    return ids
+}
+
+func TestMemoryStoreUpdateRelinksHubs(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    carol := s.user("Carol", "carol@example.com", "333")
+    t1 := s.tx(alice, bob, 10, "dev-1", "")
+    t2 := s.tx(bob, carol, 10, "dev-2", "")
+
+    email := "bob@example.com"
+    if _, err := s.UpdateUser(alice, models.UserPatch{Email: &email}); err != nil {
+        t.Fatal(err)
+    }
+    _, conns, err := s.GetUserRelationships(bob)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(conns.Users) != 1 || conns.Users[0].Node.ID != alice || conns.Users[0].Relationship != "SHARED_EMAIL" {
+        t.Errorf("Bob's shared links = %+v, want Alice over SHARED_EMAIL", conns.Users)
+    }
+    device := "dev-2"
+    if _, err := s.UpdateTransaction(t1, models.TransactionPatch{DeviceID: &device}); err != nil {
+        t.Fatal(err)
+    }
+    _, txConns, err := s.GetTransactionRelationships(t2)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(txConns.Transactions) != 1 || txConns.Transactions[0].Node.ID != t1 {
+        t.Errorf("transactions sharing dev-2 = %+v, want %s", txConns.Transactions, t1)
+    }
+
+    nodes := s.exportedNodes()
+    for _, gone := range []string{"email:alice@example.com", "device:dev-1"} {
+        if nodes[gone] {
+            t.Errorf("%s is still exported with no members", gone)
+        }
+    }
+    if !nodes["email:bob@example.com"] || !nodes["device:dev-2"] {
+        t.Errorf("exported nodes = %v, want the shared email and device", nodes)
+    }
+}
+
+func TestMemoryStoreDeletePrunesHubs(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    id := s.tx(alice, bob, 10, "dev-1", "203.0.113.1")
+
+    if err := s.DeleteUser(alice, false); !errors.Is(err, ErrUserHasTransactions) {
+        t.Fatalf("DeleteUser without cascade = %v, want ErrUserHasTransactions", err)
+    }
+    if err := s.DeleteTransaction(id); err != nil {
+        t.Fatal(err)
+    }
+    nodes := s.exportedNodes()
+    if nodes[id] || nodes["device:dev-1"] || nodes["ip:203.0.113.1"] {
+        t.Errorf("exported nodes = %v, want the transaction and its device and IP gone", nodes)
+    }
+    s.tx(alice, bob, 10, "dev-1", "")
+    if err := s.DeleteUser(alice, true); err != nil {
+        t.Fatal(err)
+    }
+    nodes = s.exportedNodes()
+    if nodes[alice] || nodes["email:alice@example.com"] || nodes["phone:111"] || nodes["device:dev-1"] {
+        t.Errorf("exported nodes = %v, want Alice and the identifiers only Alice used gone", nodes)
+    }
+    if !nodes[bob] || !nodes["email:bob@example.com"] {
+        t.Errorf("exported nodes = %v, want Bob and bob@example.com kept", nodes)
+    }
+}
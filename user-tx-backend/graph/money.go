@@ -0,0 +1,187 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/fx"
+    "user-tx-backend/models"
+)
+
+// Value is a transaction's amount in the reporting currency, the figure
+// analytics compare and add up. Transactions without an exchange rate
+// count with their own amount.
+func Value(t models.Transaction) float64 {
+    if t.ReportingCurrency == "" {
+        return t.Amount
+    }
+    return t.ReportingAmount
+}
+
+// moneyColumns projects the exact and reporting amounts of the transaction
+// bound to v. They are appended after a query's other columns and read by
+// setMoney. amountMinor is null on transactions stored before amounts were
+// exact.
+func moneyColumns(v string) string {
+    return fmt.Sprintf(`%[1]s.amountMinor AS amountMinor,
+                    coalesce(%[1]s.reportingCurrency, '') AS reportingCurrency,
+                    coalesce(%[1]s.reportingAmountMinor, 0) AS reportingAmountMinor,
+                    coalesce(%[1]s.fxRate, '') AS fxRate`, v)
+}
+
+// setMoney reads the moneyColumns onto t, whose amount and currency are
+// already set. A missing amountMinor is the amount rounded to the
+// currency's minor unit.
+func setMoney(t *models.Transaction, values []any) {
+    if minor, ok := values[0].(int64); ok {
+        t.AmountMinor = minor
+    } else {
+        t.AmountMinor = fx.RoundMinor(t.Amount, t.Currency)
+    }
+    t.ReportingCurrency = values[1].(string)
+    t.ReportingAmountMinor = values[2].(int64)
+    t.FXRate = values[3].(string)
+    if t.ReportingCurrency != "" {
+        t.ReportingAmount = fx.Float(t.ReportingAmountMinor, t.ReportingCurrency)
+    }
+}
+
+// applyMoney copies a validated amount onto a transaction.
+func applyMoney(t *models.Transaction, m models.Money) {
+    t.Amount = fx.Float(m.Minor, m.Currency)
+    t.Currency = m.Currency
+    t.AmountMinor = m.Minor
+    t.ReportingCurrency = m.ReportingCurrency
+    t.ReportingAmountMinor = m.ReportingMinor
+    t.ReportingAmount = fx.Float(m.ReportingMinor, m.ReportingCurrency)
+    t.FXRate = m.Rate
+}
+
+// moneyProps are the stored properties of a validated amount. amount and
+// reportingAmount keep the major-unit values that filters and indexes use.
+// Without a rate the reporting properties are null, which removes them.
+func moneyProps(m models.Money) map[string]any {
+    props := map[string]any{
+        "amount":               fx.Float(m.Minor, m.Currency),
+        "currency":             m.Currency,
+        "amountMinor":          m.Minor,
+        "reportingCurrency":    nil,
+        "reportingAmountMinor": nil,
+        "reportingAmount":      nil,
+        "fxRate":               nil,
+    }
+    if m.ReportingCurrency != "" {
+        props["reportingCurrency"] = m.ReportingCurrency
+        props["reportingAmountMinor"] = m.ReportingMinor
+        props["reportingAmount"] = fx.Float(m.ReportingMinor, m.ReportingCurrency)
+        props["fxRate"] = m.Rate
+    }
+    return props
+}
+
+// exportMoney adds the stored money properties of a transaction to its
+// export properties.
+func exportMoney(props map[string]any, t models.Transaction) {
+    props["amountMinor"] = t.AmountMinor
+    if t.ReportingCurrency == "" {
+        return
+    }
+    props["reportingCurrency"] = t.ReportingCurrency
+    props["reportingAmountMinor"] = t.ReportingAmountMinor
+    props["reportingAmount"] = t.ReportingAmount
+    props["fxRate"] = t.FXRate
+}
+
+// setMoneyQuery writes the moneyProps in $money of the transactions in
+// $rows, each {id, money}.
+const setMoneyQuery = `UNWIND $rows AS row
+             MATCH (t:Transaction) WHERE t.id = row.id
+             SET t += row.money`
+
+// SetTransactionMoney replaces the amounts of the given transactions, in
+// one transaction per batch.
+func (d *Driver) SetTransactionMoney(amounts map[string]models.Money) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    ids := sortedKeys(amounts)
+    for start := 0; start < len(ids); start += migrationBatch {
+        end := start + migrationBatch
+        if end > len(ids) {
+            end = len(ids)
+        }
+        var rows []map[string]any
+        for _, id := range ids[start:end] {
+            rows = append(rows, map[string]any{"id": id, "money": moneyProps(amounts[id])})
+        }
+        if _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+            _, err := tx.Run(ctx, setMoneyQuery, map[string]any{"rows": rows})
+            return nil, err
+        }); err != nil {
+            return fmt.Errorf("SetTransactionMoney: %w", err)
+        }
+    }
+    return nil
+}
+
+// SetTransactionMoney replaces the amounts of the given transactions.
+func (m *MemoryStore) SetTransactionMoney(amounts map[string]models.Money) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for id, money := range amounts {
+        if t, ok := m.txs[id]; ok {
+            applyMoney(&t, money)
+            m.txs[id] = t
+        }
+    }
+    return nil
+}
+
+// ConvertAmounts converts stored transactions to the reporting currency
+// at their own time. With all false only transactions without a reporting
+// amount are, e.g. those stored before a rate for their currency was
+// loaded, or before amounts were exact; their float amount is rounded to
+// the currency's minor unit and stored. With all true every transaction
+// is, e.g. after the reporting currency or the rates changed. Transactions
+// with an unknown currency or without a rate are reported as failed and
+// keep their previous reporting amount.
+func ConvertAmounts(store GraphStore, conv *fx.Converter, all bool) (models.FXRecomputeResponse, error) {
+    resp := models.FXRecomputeResponse{ReportingCurrency: conv.Reporting(), Failed: []string{}}
+    txs, err := store.GetAllTransactions()
+    if err != nil {
+        return resp, err
+    }
+    amounts := make(map[string]models.Money)
+    for _, t := range txs {
+        if !all && t.ReportingCurrency != "" {
+            continue
+        }
+        cur, err := fx.Normalize(t.Currency)
+        if err != nil {
+            resp.Failed = append(resp.Failed, t.ID)
+            continue
+        }
+        money, err := conv.MoneyMinor(t.AmountMinor, cur, t.Timestamp)
+        if err != nil {
+            resp.Failed = append(resp.Failed, t.ID)
+            continue
+        }
+        if money.ReportingCurrency == "" {
+            resp.Failed = append(resp.Failed, t.ID)
+            if t.ReportingCurrency != "" {
+                continue
+            }
+        } else {
+            resp.Converted++
+        }
+        amounts[t.ID] = money
+    }
+    if err := store.SetTransactionMoney(amounts); err != nil {
+        resp.Converted = 0
+        return resp, err
+    }
+    return resp, nil
+}
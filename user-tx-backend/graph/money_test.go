@@ -0,0 +1,60 @@
+package graph
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+    "time"
+
+    "user-tx-backend/fx"
+)
+
+func TestConvertAmountsAfterRateAdded(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    ts := testStart.Format(time.RFC3339)
+    money, err := s.conv.Money(20, "EUR", ts)
+    if err != nil {
+        t.Fatal(err)
+    }
+    id, err := s.CreateTransaction(a, b, money, ts, "", "", "", "settled")
+    if err != nil {
+        t.Fatal(err)
+    }
+    usd := s.tx(a, b, 5, "", "")
+
+    path := filepath.Join(t.TempDir(), "rates.json")
+    data := `{"base":"USD","rates":[{"currency":"EUR","rate":"1.5","effective":"2023-01-01"}]}`
+    if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+        t.Fatal(err)
+    }
+    conv, err := fx.NewConverter(path, "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    resp, err := ConvertAmounts(s, conv, false)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if resp.Converted != 1 || len(resp.Failed) != 0 {
+        t.Errorf("ConvertAmounts = %+v, want only the EUR transaction converted", resp)
+    }
+
+    txs, err := s.GetAllTransactions()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, tx := range txs {
+        switch tx.ID {
+        case id:
+            if tx.AmountMinor != 2000 || tx.ReportingCurrency != "USD" || tx.ReportingAmountMinor != 3000 {
+                t.Errorf("EUR transaction = %+v, want 2000 EUR as 3000 USD", tx)
+            }
+        case usd:
+            if tx.ReportingAmountMinor != 500 {
+                t.Errorf("USD transaction = %+v, want 500 USD", tx)
+            }
+        }
+    }
+}
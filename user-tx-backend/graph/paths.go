@@ -0,0 +1,456 @@
+package graph
+
+import (
+    "container/heap"
+    "fmt"
+    "math"
+    "sort"
+    "strings"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+// maxPathHops bounds the relationships on a constrained path.
+const maxPathHops = 20
+
+// pathEdge is one way of traversing a relationship. seg keeps the
+// relationship's own direction, whichever way it is walked.
+//
+// A shared link is walked through its hub in two edges: one into the hub,
+// with no cost or hop and seg.To the hub, and one out of it, carrying the
+// link's cost and hop with seg.From the hub. segments joins the two back
+// into one SHARED_* segment. This keeps a hub with n members at 2n edges
+// instead of n² pairs.
+type pathEdge struct {
+    to   string
+    seg  models.PathSegment
+    cost float64
+    hops int
+}
+
+// intoHub reports whether e enters a hub.
+func (e pathEdge) intoHub() bool {
+    return e.hops == 0
+}
+
+// pathRelTypes are the relationship types pathGraph walks, and so the
+// values PathQuery.Types may hold.
+var pathRelTypes = map[string]bool{
+    "SENT": true, "RECEIVED_BY": true,
+    "SHARED_EMAIL": true, "SHARED_PHONE": true, "SHARED_DEVICE": true,
+}
+
+// normalizePathQuery fills defaults and rejects unknown values.
+func normalizePathQuery(q models.PathQuery) (models.PathQuery, error) {
+    if q.Weight == "" {
+        q.Weight = "hops"
+    }
+    if !hasString([]string{"hops", "amount", "recency"}, q.Weight) {
+        return q, fmt.Errorf("%w: weight must be hops, amount or recency", ErrInvalidQuery)
+    }
+    if q.MaxHops < 0 || q.MaxHops > maxPathHops {
+        return q, fmt.Errorf("%w: maxHops must be at most %d", ErrInvalidQuery, maxPathHops)
+    }
+    for _, t := range q.Types {
+        if !pathRelTypes[t] {
+            return q, fmt.Errorf("%w: unknown relationship type %q", ErrInvalidQuery, t)
+        }
+    }
+    return q, nil
+}
+
+// pathGraph lists the traversable relationships per node. Money hops cost
+// 1, 1/amount (amounts below 1 count as 1) or 1 + the age in days relative
+// to the newest transaction; shared links always cost 1 and are undirected.
+// Shared links go through their hub, see pathEdge.
+func (s *Snapshot) pathGraph(q models.PathQuery) map[string][]pathEdge {
+    allowed := func(typ string) bool {
+        return len(q.Types) == 0 || hasString(q.Types, typ)
+    }
+    var newest time.Time
+    if n := len(s.Transactions); n > 0 {
+        newest = s.Time(s.Transactions[n-1].ID)
+    }
+    moneyCost := func(t models.Transaction) float64 {
+        switch q.Weight {
+        case "amount":
+            return 1 / math.Max(Value(t), 1)
+        case "recency":
+            return 1 + newest.Sub(s.Time(t.ID)).Hours()/24
+        }
+        return 1
+    }
+
+    adj := make(map[string][]pathEdge)
+    add := func(seg models.PathSegment, cost float64, undirected bool) {
+        adj[seg.From.ID] = append(adj[seg.From.ID], pathEdge{to: seg.To.ID, seg: seg, cost: cost, hops: 1})
+        if undirected {
+            adj[seg.To.ID] = append(adj[seg.To.ID], pathEdge{to: seg.From.ID, seg: seg, cost: cost, hops: 1})
+        }
+    }
+    txs := make(map[string]models.Transaction, len(s.Transactions))
+    for _, t := range s.Transactions {
+        txs[t.ID] = t
+        hops := s.hopSegments(t)
+        for _, seg := range hops {
+            if allowed(seg.Relationship) {
+                add(seg, moneyCost(t), !q.Directed)
+            }
+        }
+    }
+    for _, h := range s.Hubs {
+        if !allowed(h.Type) {
+            continue
+        }
+        hub := models.PathNode{ID: h.ID}
+        for _, id := range h.Members {
+            var node models.PathNode
+            if h.Type == "SHARED_DEVICE" {
+                t, ok := txs[id]
+                if !ok {
+                    continue
+                }
+                node = s.transactionNode(t)
+            } else {
+                node = s.userNode(id)
+            }
+            adj[id] = append(adj[id], pathEdge{to: h.ID, seg: models.PathSegment{From: node, To: hub, Relationship: h.Type}})
+            adj[h.ID] = append(adj[h.ID], pathEdge{to: id, seg: models.PathSegment{From: hub, To: node, Relationship: h.Type}, cost: 1, hops: 1})
+        }
+    }
+    return adj
+}
+
+// pathState is a node reached with a given number of hops. Hops only
+// matter when a hop limit is set; otherwise they are always 0.
+type pathState struct {
+    node string
+    hops int
+}
+
+type pathItem struct {
+    state pathState
+    cost  float64
+}
+
+type pathQueue []pathItem
+
+func (q pathQueue) Len() int           { return len(q) }
+func (q pathQueue) Less(i, j int) bool { return q[i].cost < q[j].cost }
+func (q pathQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+func (q *pathQueue) Push(x any)        { *q = append(*q, x.(pathItem)) }
+func (q *pathQueue) Pop() any {
+    old := *q
+    it := old[len(old)-1]
+    *q = old[:len(old)-1]
+    return it
+}
+
+// cheapestPath runs Dijkstra from one node to another, never entering a
+// node in avoid or walking a relationship in banned (both may be nil). With
+// maxHops > 0 the search runs over (node, hops) states so the hop limit is
+// respected exactly.
+func cheapestPath(
+    adj map[string][]pathEdge,
+    from, to string,
+    maxHops int,
+    avoid, banned map[string]bool,
+) ([]pathEdge, float64, bool) {
+    type step struct {
+        prev pathState
+        edge pathEdge
+    }
+    start := pathState{node: from}
+    dist := map[pathState]float64{start: 0}
+    prev := make(map[pathState]step)
+    done := make(map[pathState]bool)
+    pq := &pathQueue{{state: start}}
+
+    for pq.Len() > 0 {
+        it := heap.Pop(pq).(pathItem)
+        cur := it.state
+        if done[cur] {
+            continue
+        }
+        done[cur] = true
+        if cur.node == to {
+            var edges []pathEdge
+            for st := cur; st != start; st = prev[st].prev {
+                edges = append(edges, prev[st].edge)
+            }
+            for i, j := 0, len(edges)-1; i < j; i, j = i+1, j-1 {
+                edges[i], edges[j] = edges[j], edges[i]
+            }
+            return edges, it.cost, true
+        }
+        if maxHops > 0 && cur.hops >= maxHops {
+            continue
+        }
+        for _, e := range adj[cur.node] {
+            if avoid[e.to] || banned[segmentKey(e.seg)] {
+                continue
+            }
+            next := pathState{node: e.to}
+            if maxHops > 0 {
+                next.hops = cur.hops + e.hops
+            }
+            c := it.cost + e.cost
+            if d, ok := dist[next]; ok && d <= c {
+                continue
+            }
+            dist[next] = c
+            prev[next] = step{prev: cur, edge: e}
+            heap.Push(pq, pathItem{state: next, cost: c})
+        }
+    }
+    return nil, 0, false
+}
+
+// segmentKey identifies a relationship independently of the direction it
+// is walked in.
+func segmentKey(seg models.PathSegment) string {
+    return seg.From.ID + "|" + seg.Relationship + "|" + seg.To.ID
+}
+
+// segments renders edges as path segments, joining each walk through a hub
+// into the shared link between the members on either side. The link keeps
+// the direction it was walked in.
+func segments(edges []pathEdge) []models.PathSegment {
+    var segs []models.PathSegment
+    for i := 0; i < len(edges); i++ {
+        seg := edges[i].seg
+        if edges[i].intoHub() && i+1 < len(edges) {
+            i++
+            seg.To = edges[i].seg.To
+        }
+        segs = append(segs, seg)
+    }
+    return segs
+}
+
+// pathHops counts the relationships of a path as segments renders them.
+func pathHops(edges []pathEdge) int {
+    n := 0
+    for _, e := range edges {
+        n += e.hops
+    }
+    return n
+}
+
+// FindPath returns the cheapest path between two users under q, and its
+// cost.
+func FindPath(store GraphStore, fromID, toID string, q models.PathQuery) ([]models.PathSegment, float64, error) {
+    q, err := normalizePathQuery(q)
+    if err != nil {
+        return nil, 0, err
+    }
+    snap, err := store.Snapshot(SnapshotFilter{IncludeShared: true})
+    if err != nil {
+        return nil, 0, err
+    }
+    if _, ok := snap.Users[fromID]; !ok {
+        return nil, 0, ErrNoPath
+    }
+    if _, ok := snap.Users[toID]; !ok || fromID == toID {
+        return nil, 0, ErrNoPath
+    }
+    edges, cost, ok := cheapestPath(snap.pathGraph(q), fromID, toID, q.MaxHops, nil, nil)
+    if !ok {
+        return nil, 0, ErrNoPath
+    }
+    return segments(edges), cost, nil
+}
+
+const (
+    defaultPathCount = 3
+    maxPathCount     = 100
+    // defaultAllPathsHops applies when all paths are requested without a
+    // hop limit.
+    defaultAllPathsHops = 6
+    maxAllPaths         = 1000
+)
+
+type weightedPath struct {
+    edges []pathEdge
+    cost  float64
+}
+
+func (p weightedPath) key() string { return pathKey(p.edges) }
+
+func pathKey(edges []pathEdge) string {
+    var b strings.Builder
+    for _, e := range edges {
+        b.WriteString(segmentKey(e.seg))
+        b.WriteByte(';')
+    }
+    return b.String()
+}
+
+// nodes lists the nodes of p in walking order, starting at from.
+func (p weightedPath) nodes(from string) []string {
+    ns := []string{from}
+    for _, e := range p.edges {
+        ns = append(ns, e.to)
+    }
+    return ns
+}
+
+func lessPath(a, b weightedPath) bool {
+    if a.cost != b.cost {
+        return a.cost < b.cost
+    }
+    if len(a.edges) != len(b.edges) {
+        return len(a.edges) < len(b.edges)
+    }
+    return a.key() < b.key()
+}
+
+// kShortestPaths is Yen's algorithm: each next path deviates from an
+// accepted one at some spur node, with the accepted root kept and the
+// edges already used from that root banned.
+func kShortestPaths(adj map[string][]pathEdge, from, to string, k, maxHops int) []weightedPath {
+    first, cost, ok := cheapestPath(adj, from, to, maxHops, nil, nil)
+    if !ok {
+        return nil
+    }
+    accepted := []weightedPath{{edges: first, cost: cost}}
+    seen := map[string]bool{accepted[0].key(): true}
+    var candidates []weightedPath
+
+    for len(accepted) < k {
+        last := accepted[len(accepted)-1]
+        nodes := last.nodes(from)
+        for i := 0; i < len(last.edges); i++ {
+            root := last.edges[:i]
+            hopsLeft := 0
+            if maxHops > 0 {
+                if hopsLeft = maxHops - pathHops(root); hopsLeft <= 0 {
+                    break
+                }
+            }
+            rootKey := pathKey(root)
+            banned := make(map[string]bool)
+            for _, p := range accepted {
+                if len(p.edges) > i && pathKey(p.edges[:i]) == rootKey {
+                    banned[segmentKey(p.edges[i].seg)] = true
+                }
+            }
+            avoid := make(map[string]bool, i)
+            for _, n := range nodes[:i] {
+                avoid[n] = true
+            }
+
+            spur, spurCost, ok := cheapestPath(adj, nodes[i], to, hopsLeft, avoid, banned)
+            if !ok {
+                continue
+            }
+            p := weightedPath{edges: append(append([]pathEdge{}, root...), spur...), cost: spurCost}
+            for _, e := range root {
+                p.cost += e.cost
+            }
+            if key := p.key(); !seen[key] {
+                seen[key] = true
+                candidates = append(candidates, p)
+            }
+        }
+        if len(candidates) == 0 {
+            break
+        }
+        sort.Slice(candidates, func(a, b int) bool { return lessPath(candidates[a], candidates[b]) })
+        accepted = append(accepted, candidates[0])
+        candidates = candidates[1:]
+    }
+    return accepted
+}
+
+// allSimplePaths enumerates every path without repeated nodes of at most
+// maxHops relationships, stopping after maxAllPaths.
+func allSimplePaths(adj map[string][]pathEdge, from, to string, maxHops int) ([]weightedPath, bool) {
+    var found []weightedPath
+    truncated := false
+    onPath := map[string]bool{from: true}
+    var path []pathEdge
+
+    var dfs func(node string, cost float64, hops int)
+    dfs = func(node string, cost float64, hops int) {
+        for _, e := range adj[node] {
+            if truncated {
+                return
+            }
+            if onPath[e.to] {
+                continue
+            }
+            if e.to == to {
+                if len(found) == maxAllPaths {
+                    truncated = true
+                    return
+                }
+                edges := append(append([]pathEdge{}, path...), e)
+                found = append(found, weightedPath{edges: edges, cost: cost + e.cost})
+                continue
+            }
+            // Leave room for at least one more hop to reach to.
+            if hops+e.hops+1 > maxHops {
+                continue
+            }
+            onPath[e.to] = true
+            path = append(path, e)
+            dfs(e.to, cost+e.cost, hops+e.hops)
+            path = path[:len(path)-1]
+            onPath[e.to] = false
+        }
+    }
+    dfs(from, 0, 0)
+    sort.Slice(found, func(a, b int) bool { return lessPath(found[a], found[b]) })
+    return found, truncated
+}
+
+// FindPaths returns the K cheapest distinct simple paths between two users,
+// or with q.All every simple path up to q.MaxHops, cheapest first.
+func FindPaths(store GraphStore, fromID, toID string, q models.PathQuery) (models.PathsResponse, error) {
+    resp := models.PathsResponse{Paths: []models.RankedPath{}}
+    q, err := normalizePathQuery(q)
+    if err != nil {
+        return resp, err
+    }
+    if q.K == 0 {
+        q.K = defaultPathCount
+    }
+    if q.K < 1 || q.K > maxPathCount {
+        return resp, fmt.Errorf("%w: k must be between 1 and %d", ErrInvalidQuery, maxPathCount)
+    }
+    if q.All && q.MaxHops == 0 {
+        q.MaxHops = defaultAllPathsHops
+    }
+
+    snap, err := store.Snapshot(SnapshotFilter{IncludeShared: true})
+    if err != nil {
+        return resp, err
+    }
+    if _, ok := snap.Users[fromID]; !ok {
+        return resp, ErrNoPath
+    }
+    if _, ok := snap.Users[toID]; !ok || fromID == toID {
+        return resp, ErrNoPath
+    }
+
+    adj := snap.pathGraph(q)
+    var paths []weightedPath
+    if q.All {
+        paths, resp.Truncated = allSimplePaths(adj, fromID, toID, q.MaxHops)
+    } else {
+        paths = kShortestPaths(adj, fromID, toID, q.K, q.MaxHops)
+    }
+    if len(paths) == 0 {
+        return resp, ErrNoPath
+    }
+    for _, p := range paths {
+        resp.Paths = append(resp.Paths, models.RankedPath{
+            Segments: segments(p.edges),
+            Cost:     p.cost,
+            Hops:     pathHops(p.edges),
+        })
+    }
+    return resp, nil
+}
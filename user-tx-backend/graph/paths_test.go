@@ -0,0 +1,160 @@
+package graph
+
+import (
+    "errors"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestFindPathTypes(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "555")
+    b := s.user("B", "b@example.com", "555")
+    c := s.user("C", "c@example.com", "777")
+    s.tx(a, c, 10, "", "")
+
+    segs, _, err := FindPath(s, a, b, models.PathQuery{Types: []string{"SHARED_PHONE"}})
+    if err != nil {
+        t.Fatalf("FindPath over SHARED_PHONE: %v", err)
+    }
+    if len(segs) != 1 || segs[0].Relationship != "SHARED_PHONE" {
+        t.Errorf("path = %+v, want one SHARED_PHONE hop", segs)
+    }
+
+    if _, _, err := FindPath(s, a, c, models.PathQuery{Types: []string{"SHARED_PHONE"}}); !errors.Is(err, ErrNoPath) {
+        t.Errorf("A→C over SHARED_PHONE only: error = %v, want ErrNoPath", err)
+    }
+    for _, typ := range []string{"HAS_STATUS_CHANGE", "REVERSAL_OF", "NOPE"} {
+        if _, _, err := FindPath(s, a, b, models.PathQuery{Types: []string{typ}}); !errors.Is(err, ErrInvalidQuery) {
+            t.Errorf("Types [%s]: error = %v, want ErrInvalidQuery", typ, err)
+        }
+    }
+}
+
+func TestFindPathThroughHub(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "555")
+    b := s.user("B", "b@example.com", "555")
+    c := s.user("C", "c@example.com", "777")
+    s.tx(b, c, 10, "", "")
+
+    segs, cost, err := FindPath(s, a, c, models.PathQuery{MaxHops: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    var rels []string
+    for _, seg := range segs {
+        rels = append(rels, seg.Relationship)
+    }
+    if len(segs) != 3 || rels[0] != "SHARED_PHONE" || segs[0].From.ID != a || segs[0].To.ID != b || cost != 3 {
+        t.Errorf("path A→C = %v at cost %v, want SHARED_PHONE A→B, SENT, RECEIVED_BY at cost 3", rels, cost)
+    }
+    if _, _, err := FindPath(s, a, c, models.PathQuery{MaxHops: 2}); !errors.Is(err, ErrNoPath) {
+        t.Errorf("maxHops 2: error = %v, want ErrNoPath", err)
+    }
+
+    paths, err := FindPaths(s, a, c, models.PathQuery{All: true, MaxHops: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(paths.Paths) != 1 || paths.Paths[0].Hops != 3 || len(paths.Paths[0].Segments) != 3 {
+        t.Errorf("all paths = %+v, want one of 3 hops", paths.Paths)
+    }
+}
+
+func TestFindPathLargeDevice(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    for i := 0; i < 2*MaxHubFanOut; i++ {
+        s.tx(a, b, 1, "kiosk", "")
+    }
+    s.tx(c, d, 1, "kiosk", "")
+
+    snap, err := s.Snapshot(SnapshotFilter{IncludeShared: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(snap.Hubs) != 1 || len(snap.Hubs[0].Members) != 2*MaxHubFanOut+1 {
+        t.Fatalf("hubs = %d, want the kiosk with every transaction", len(snap.Hubs))
+    }
+    segs, _, err := FindPath(s, a, d, models.PathQuery{Types: []string{"SENT", "RECEIVED_BY", "SHARED_DEVICE"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(segs) != 3 || segs[1].Relationship != "SHARED_DEVICE" {
+        t.Errorf("path A→D = %+v, want SENT, SHARED_DEVICE, RECEIVED_BY", segs)
+    }
+}
+
+func TestLinkMembers(t *testing.T) {
+    var pairs []string
+    if !LinkMembers([]string{"a", "b", "", "a", "c"}, func(x, y string) { pairs = append(pairs, x+y) }) {
+        t.Fatal("LinkMembers skipped a small hub")
+    }
+    if len(pairs) != 3 || pairs[0] != "ab" || pairs[1] != "ac" || pairs[2] != "bc" {
+        t.Errorf("pairs = %v, want ab ac bc", pairs)
+    }
+
+    big := make([]string, MaxHubFanOut+1)
+    for i := range big {
+        big[i] = string(rune('A' + i))
+    }
+    if LinkMembers(big, func(x, y string) { t.Fatal("linked a hub over MaxHubFanOut") }) {
+        t.Error("LinkMembers reported linking a hub over MaxHubFanOut")
+    }
+}
+
+func TestFindPathsRanksAlternatives(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "555")
+    b := s.user("B", "b@example.com", "222")
+    c := s.user("C", "c@example.com", "333")
+    d := s.user("D", "d@example.com", "555")
+    s.tx(a, b, 10, "", "")
+    s.tx(d, b, 10, "", "")
+    s.tx(a, c, 10, "", "")
+    s.tx(c, b, 10, "", "")
+
+    hops := func(resp models.PathsResponse) []int {
+        var got []int
+        for _, p := range resp.Paths {
+            got = append(got, p.Hops)
+            if p.Cost != float64(p.Hops) || len(p.Segments) != p.Hops {
+                t.Errorf("path = %+v, want one segment and unit cost per hop", p)
+            }
+        }
+        return got
+    }
+
+    resp, err := FindPaths(s, a, b, models.PathQuery{K: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := hops(resp); len(got) != 3 || got[0] != 2 || got[1] != 3 || got[2] != 4 {
+        t.Errorf("3 shortest A→B = %v hops, want 2 direct, 3 through D's phone, 4 through C", got)
+    }
+    resp, err = FindPaths(s, a, b, models.PathQuery{K: 3, Types: []string{"SENT", "RECEIVED_BY"}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := hops(resp); len(got) != 2 || got[0] != 2 || got[1] != 4 {
+        t.Errorf("money-only A→B = %v hops, want 2 and 4", got)
+    }
+    resp, err = FindPaths(s, a, b, models.PathQuery{All: true, MaxHops: 3})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := hops(resp); len(got) != 2 || got[0] != 2 || got[1] != 3 {
+        t.Errorf("all A→B within 3 hops = %v, want 2 and 3", got)
+    }
+    if _, err := FindPaths(s, b, c, models.PathQuery{Directed: true, Types: []string{"SENT", "RECEIVED_BY"}}); !errors.Is(err, ErrNoPath) {
+        t.Errorf("directed B→C: error = %v, want ErrNoPath against the flow", err)
+    }
+    if _, err := FindPaths(s, a, b, models.PathQuery{K: maxPathCount + 1}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("k over the limit: error = %v, want ErrInvalidQuery", err)
+    }
+}
@@ -0,0 +1,466 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// identityLinkTypes are the probable-match relationships written by entity
+// resolution.
+var identityLinkTypes = []string{"SAME_EMAIL_CANONICAL", "SAME_PHONE_E164", "SIMILAR_NAME"}
+
+// isIdentityLinkType reports whether typ is one of identityLinkTypes.
+func isIdentityLinkType(typ string) bool {
+    for _, t := range identityLinkTypes {
+        if typ == t {
+            return true
+        }
+    }
+    return false
+}
+
+func validIdentityLink(l models.IdentityLink) error {
+    if !isIdentityLinkType(l.Type) {
+        return fmt.Errorf("%w: unknown identity link type %q", ErrInvalidQuery, l.Type)
+    }
+    if l.Confidence <= 0 || l.Confidence > 1 {
+        return fmt.Errorf("%w: confidence must be in (0, 1]", ErrInvalidQuery)
+    }
+    return nil
+}
+
+// validatePersonRequest drops duplicate user IDs and requires at least one.
+func validatePersonRequest(req models.PersonRequest) (models.PersonRequest, error) {
+    req.UserIDs = distinct(req.UserIDs)
+    if len(req.UserIDs) == 0 {
+        return req, fmt.Errorf("%w: userIds must not be empty", ErrInvalidQuery)
+    }
+    return req, nil
+}
+
+// IdentityLinks returns every probable-match link between users.
+func (d *Driver) IdentityLinks() ([]models.IdentityLink, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (a:User)-[r:SAME_EMAIL_CANONICAL|SAME_PHONE_E164|SIMILAR_NAME]->(b:User)
+             RETURN a.id, b.id, type(r), r.confidence`,
+            nil,
+        )
+        if err != nil {
+            return nil, err
+        }
+        links := []models.IdentityLink{}
+        for rs.Next(ctx) {
+            v := rs.Record().Values
+            conf, _ := v[3].(float64)
+            links = append(links, models.IdentityLink{
+                From:       v[0].(string),
+                To:         v[1].(string),
+                Type:       v[2].(string),
+                Confidence: conf,
+            })
+        }
+        return links, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return raw.([]models.IdentityLink), nil
+}
+
+// SetIdentityLinks drops the probable-match links touching any of userIDs
+// and writes links in their place. Links are directed from From to To.
+func (d *Driver) SetIdentityLinks(userIDs []string, links []models.IdentityLink) error {
+    byType := make(map[string][]map[string]any)
+    for _, l := range links {
+        if err := validIdentityLink(l); err != nil {
+            return err
+        }
+        byType[l.Type] = append(byType[l.Type], map[string]any{
+            "from":       l.From,
+            "to":         l.To,
+            "confidence": l.Confidence,
+        })
+    }
+
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        if _, err := tx.Run(ctx,
+            `MATCH (u:User)-[r:SAME_EMAIL_CANONICAL|SAME_PHONE_E164|SIMILAR_NAME]-(:User)
+             WHERE u.id IN $ids
+             WITH DISTINCT r
+             DELETE r`,
+            map[string]any{"ids": userIDs},
+        ); err != nil {
+            return nil, err
+        }
+        for _, typ := range identityLinkTypes {
+            if len(byType[typ]) == 0 {
+                continue
+            }
+            // The type comes from identityLinkTypes, never from input.
+            if _, err := tx.Run(ctx, fmt.Sprintf(
+                `UNWIND $links AS l
+                 MATCH (a:User) WHERE a.id = l.from
+                 MATCH (b:User) WHERE b.id = l.to
+                 MERGE (a)-[r:%s]->(b)
+                 SET r.confidence = l.confidence`, typ),
+                map[string]any{"links": byType[typ]},
+            ); err != nil {
+                return nil, err
+            }
+        }
+        return nil, nil
+    })
+    return err
+}
+
+// personReturn completes a query that has bound p to one or more Persons;
+// the columns are the ones personFromRecord reads.
+const personReturn = `
+             OPTIONAL MATCH (u:User)-[:RESOLVED_AS]->(p)
+             WITH p, collect(u.id) AS userIds
+             RETURN properties(p), userIds`
+
+func personFromRecord(values []any) models.Person {
+    props := values[0].(map[string]any)
+    return models.Person{
+        ID:        stringProp(props, "id"),
+        Name:      stringProp(props, "name"),
+        CreatedAt: timeProp(props, "createdAt"),
+        UserIDs:   stringList(values[1]),
+    }
+}
+
+func readPerson(ctx context.Context, tx neo4j.ManagedTransaction, id string) (models.Person, error) {
+    rs, err := tx.Run(ctx, `MATCH (p:Person) WHERE p.id = $id`+personReturn, map[string]any{"id": id})
+    if err != nil {
+        return models.Person{}, err
+    }
+    if !rs.Next(ctx) {
+        if err := rs.Err(); err != nil {
+            return models.Person{}, err
+        }
+        return models.Person{}, ErrPersonNotFound
+    }
+    return personFromRecord(rs.Record().Values), nil
+}
+
+// CreatePerson merges users into a new Person. Users are moved off any
+// Person they belonged to, and Persons left without users are deleted.
+func (d *Driver) CreatePerson(req models.PersonRequest) (models.Person, error) {
+    req, err := validatePersonRequest(req)
+    if err != nil {
+        return models.Person{}, err
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `UNWIND $ids AS uid
+             MATCH (u:User) WHERE u.id = uid
+             RETURN u.id, u.name`,
+            map[string]any{"ids": req.UserIDs},
+        )
+        if err != nil {
+            return nil, err
+        }
+        names := make(map[string]string)
+        for rs.Next(ctx) {
+            v := rs.Record().Values
+            name, _ := v[1].(string)
+            names[v[0].(string)] = name
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+        if len(names) < len(req.UserIDs) {
+            return nil, ErrUserNotFound
+        }
+        name := req.Name
+        if name == "" {
+            name = names[req.UserIDs[0]]
+        }
+
+        rs, err = tx.Run(ctx,
+            `CREATE (p:Person {id: randomUUID(), name: $name, createdAt: datetime($now)})
+             WITH p
+             UNWIND $ids AS uid
+             MATCH (u:User) WHERE u.id = uid
+             OPTIONAL MATCH (u)-[old:RESOLVED_AS]->(:Person)
+             DELETE old
+             WITH DISTINCT p, u
+             CREATE (u)-[:RESOLVED_AS]->(p)
+             RETURN DISTINCT p.id`,
+            map[string]any{
+                "name": name,
+                "ids":  req.UserIDs,
+                "now":  time.Now().UTC().Format(time.RFC3339Nano),
+            },
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        id := rec.Values[0].(string)
+        if _, err := tx.Run(ctx,
+            `MATCH (p:Person) WHERE NOT (p)<-[:RESOLVED_AS]-() DELETE p`,
+            nil,
+        ); err != nil {
+            return nil, err
+        }
+        return readPerson(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Person{}, err
+    }
+    return raw.(models.Person), nil
+}
+
+// GetPerson returns a Person with the users resolved to it.
+func (d *Driver) GetPerson(id string) (models.Person, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        return readPerson(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Person{}, err
+    }
+    return raw.(models.Person), nil
+}
+
+// ListPersons returns every Person, newest first.
+func (d *Driver) ListPersons() ([]models.Person, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx, `MATCH (p:Person)`+personReturn, nil)
+        if err != nil {
+            return nil, err
+        }
+        persons := []models.Person{}
+        for rs.Next(ctx) {
+            persons = append(persons, personFromRecord(rs.Record().Values))
+        }
+        return persons, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    persons := raw.([]models.Person)
+    sortPersons(persons)
+    return persons, nil
+}
+
+// DeletePerson removes a Person; its users are left unresolved.
+func (d *Driver) DeletePerson(id string) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        rs, err := tx.Run(ctx,
+            `MATCH (p:Person) WHERE p.id = $id
+             DETACH DELETE p
+             RETURN count(*)`,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        rec, err := rs.Single(ctx)
+        if err != nil {
+            return nil, err
+        }
+        if rec.Values[0].(int64) == 0 {
+            return nil, ErrPersonNotFound
+        }
+        return nil, nil
+    })
+    return err
+}
+
+func sortPersons(persons []models.Person) {
+    sort.SliceStable(persons, func(i, j int) bool {
+        if persons[i].CreatedAt != persons[j].CreatedAt {
+            return persons[i].CreatedAt > persons[j].CreatedAt
+        }
+        return persons[i].ID > persons[j].ID
+    })
+}
+
+// IdentityLinks returns every probable-match link between users.
+func (m *MemoryStore) IdentityLinks() ([]models.IdentityLink, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    return append([]models.IdentityLink{}, m.identity...), nil
+}
+
+// SetIdentityLinks replaces the probable-match links touching userIDs.
+// Links to unknown users are skipped, as the Driver's MATCH skips them.
+func (m *MemoryStore) SetIdentityLinks(userIDs []string, links []models.IdentityLink) error {
+    for _, l := range links {
+        if err := validIdentityLink(l); err != nil {
+            return err
+        }
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    drop := make(map[string]bool, len(userIDs))
+    for _, id := range userIDs {
+        drop[id] = true
+    }
+    kept := m.identity[:0]
+    for _, l := range m.identity {
+        if !drop[l.From] && !drop[l.To] {
+            kept = append(kept, l)
+        }
+    }
+    m.identity = kept
+    for _, l := range links {
+        _, okA := m.users[l.From]
+        _, okB := m.users[l.To]
+        if !okA || !okB {
+            continue
+        }
+        merged := false
+        for i, cur := range m.identity {
+            if cur.Type == l.Type && cur.From == l.From && cur.To == l.To {
+                m.identity[i].Confidence = l.Confidence
+                merged = true
+                break
+            }
+        }
+        if !merged {
+            m.identity = append(m.identity, l)
+        }
+    }
+    return nil
+}
+
+// unlinkIdentity drops the probable-match links and Person membership of a
+// deleted user. Callers must hold the write lock.
+func (m *MemoryStore) unlinkIdentity(userID string) {
+    kept := m.identity[:0]
+    for _, l := range m.identity {
+        if l.From != userID && l.To != userID {
+            kept = append(kept, l)
+        }
+    }
+    m.identity = kept
+    m.leavePersons([]string{userID})
+}
+
+// leavePersons removes users from the Persons they belong to and deletes
+// Persons left empty. Callers must hold the write lock.
+func (m *MemoryStore) leavePersons(userIDs []string) {
+    for _, pid := range append([]string{}, m.personIDs...) {
+        p := m.persons[pid]
+        for _, uid := range userIDs {
+            p.UserIDs = removeID(p.UserIDs, uid)
+        }
+        if len(p.UserIDs) == 0 {
+            delete(m.persons, pid)
+            m.personIDs = removeID(m.personIDs, pid)
+            continue
+        }
+        m.persons[pid] = p
+    }
+}
+
+func copyPerson(p models.Person) models.Person {
+    p.UserIDs = append([]string{}, p.UserIDs...)
+    sort.Strings(p.UserIDs)
+    return p
+}
+
+// CreatePerson merges users into a new Person, moving them off any other.
+func (m *MemoryStore) CreatePerson(req models.PersonRequest) (models.Person, error) {
+    req, err := validatePersonRequest(req)
+    if err != nil {
+        return models.Person{}, err
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for _, uid := range req.UserIDs {
+        if _, ok := m.users[uid]; !ok {
+            return models.Person{}, ErrUserNotFound
+        }
+    }
+    p := models.Person{
+        ID:        newID(),
+        Name:      req.Name,
+        UserIDs:   append([]string{}, req.UserIDs...),
+        CreatedAt: time.Now().UTC().Format(time.RFC3339Nano),
+    }
+    if p.Name == "" {
+        p.Name = m.users[req.UserIDs[0]].Name
+    }
+    m.leavePersons(req.UserIDs)
+    m.persons[p.ID] = p
+    m.personIDs = append(m.personIDs, p.ID)
+    return copyPerson(p), nil
+}
+
+// GetPerson returns a Person with the users resolved to it.
+func (m *MemoryStore) GetPerson(id string) (models.Person, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    p, ok := m.persons[id]
+    if !ok {
+        return models.Person{}, ErrPersonNotFound
+    }
+    return copyPerson(p), nil
+}
+
+// ListPersons returns every Person, newest first.
+func (m *MemoryStore) ListPersons() ([]models.Person, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    persons := make([]models.Person, 0, len(m.personIDs))
+    for _, id := range m.personIDs {
+        persons = append(persons, copyPerson(m.persons[id]))
+    }
+    sortPersons(persons)
+    return persons, nil
+}
+
+// DeletePerson removes a Person; its users are left unresolved.
+func (m *MemoryStore) DeletePerson(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.persons[id]; !ok {
+        return ErrPersonNotFound
+    }
+    delete(m.persons, id)
+    m.personIDs = removeID(m.personIDs, id)
+    return nil
+}
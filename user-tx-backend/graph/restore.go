@@ -0,0 +1,733 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// Labels and relationship types a restore may create. Both end up inlined
+// in Cypher, so nothing outside these sets is ever accepted. Hub nodes and
+// the links to them are derived from user and transaction values instead
+// (see derivedLabel and derivedRel).
+var (
+    restorableLabels = map[string]bool{
+        "User":         true,
+        "Transaction":  true,
+        "StatusChange": true,
+    }
+    restorableRelTypes = map[string]bool{
+        "SENT":              true,
+        "RECEIVED_BY":       true,
+        "HAS_STATUS_CHANGE": true,
+        "REVERSAL_OF":       true,
+        "CHARGEBACK_OF":     true,
+    }
+    // temporalProps lists properties stored as Neo4j datetimes.
+    temporalProps = map[string][]string{
+        "User":         {"createdAt"},
+        "Transaction":  {"timestamp", "createdAt"},
+        "StatusChange": {"at"},
+    }
+    // skippedLabels are the labels exports include but restores leave
+    // out: case-management, screening and resolution records, which stay
+    // with the store they were made in. Their relationships, and the
+    // probable matches between users, are left out with them.
+    skippedLabels = map[string]bool{
+        "Alert":          true,
+        "Case":           true,
+        "Note":           true,
+        "Evidence":       true,
+        "WatchlistEntry": true,
+        "Person":         true,
+    }
+    // integerProps lists properties stored as integers, which JSON numbers
+    // would otherwise restore as floats.
+    integerProps = map[string][]string{
+        "Transaction": {"amountMinor", "reportingAmountMinor"},
+    }
+)
+
+// restorePlan is the validated, ID-remapped content of an export document.
+type restorePlan struct {
+    nodes []models.GraphNode
+    rels  []models.GraphRelationship
+}
+
+func normalizeRestoreOptions(opts models.RestoreOptions) (models.RestoreOptions, error) {
+    switch opts.Mode {
+    case "":
+        opts.Mode = "merge"
+    case "merge", "replace":
+    default:
+        return opts, fmt.Errorf("%w: mode must be merge or replace", ErrInvalidQuery)
+    }
+    return opts, nil
+}
+
+// replacedLabels are the labels a replace restore deletes: the ones it
+// restores, the hubs and clusters derived from them, and their history.
+func replacedLabels() []string {
+    labels := sortedKeys(restorableLabels)
+    for _, k := range hubKinds {
+        labels = append(labels, k.label)
+    }
+    labels = append(labels, "Cluster")
+    return append(labels, historyLabels...)
+}
+
+// rebuiltRelTypes are the relationship types a restore writes or derives.
+// A replace carries every other link of a deleted user or transaction over
+// to the restored node with the same ID.
+func rebuiltRelTypes() []string {
+    types := sortedKeys(restorableRelTypes)
+    for _, k := range hubKinds {
+        types = append(types, k.rel, k.shared)
+    }
+    return types
+}
+
+// restoreLookupIDs lists the IDs whose presence in the target store matters
+// for a merge: exported node IDs when they are kept, and relationship
+// endpoints missing from the document.
+func restoreLookupIDs(doc models.GraphExportResponse, opts models.RestoreOptions) []string {
+    if opts.Mode != "merge" {
+        return nil
+    }
+    inDoc := make(map[string]bool, len(doc.Nodes))
+    var ids []string
+    for _, n := range doc.Nodes {
+        inDoc[n.ID] = true
+        if opts.KeepIDs {
+            ids = append(ids, n.ID)
+        }
+    }
+    for _, r := range doc.Relationships {
+        for _, id := range []string{r.SourceID, r.TargetID} {
+            if !inDoc[id] {
+                ids = append(ids, id)
+            }
+        }
+    }
+    return ids
+}
+
+// planRestore validates an export document against the target store and
+// assigns the IDs restored nodes will get. existing holds the IDs from
+// restoreLookupIDs that are already present in the store.
+func planRestore(
+    doc models.GraphExportResponse,
+    opts models.RestoreOptions,
+    existing map[string]bool,
+) (restorePlan, models.RestoreReport) {
+    report := models.RestoreReport{
+        Mode:      opts.Mode,
+        DryRun:    opts.DryRun,
+        IDMap:     make(map[string]string),
+        Conflicts: []models.RestoreConflict{},
+    }
+    conflict := func(kind, id, reason string) {
+        report.Conflicts = append(report.Conflicts, models.RestoreConflict{Kind: kind, ID: id, Reason: reason})
+    }
+
+    skip := func(kind string) {
+        if report.Skipped == nil {
+            report.Skipped = make(map[string]int)
+        }
+        report.Skipped[kind]++
+    }
+
+    var plan restorePlan
+    inDoc := make(map[string]bool, len(doc.Nodes))
+    for _, n := range doc.Nodes {
+        switch {
+        case derivedLabel(n.Type):
+            continue
+        case skippedLabels[n.Type]:
+            skip(n.Type)
+            continue
+        case n.ID == "":
+            conflict("node", n.ID, "missing id")
+            continue
+        case !restorableLabels[n.Type]:
+            conflict("node", n.ID, "unsupported node type "+n.Type)
+            continue
+        case inDoc[n.ID]:
+            conflict("node", n.ID, "duplicate id in document")
+            continue
+        }
+        inDoc[n.ID] = true
+
+        id := newID()
+        if opts.KeepIDs {
+            id = n.ID
+            if existing[n.ID] {
+                conflict("node", n.ID, "id already exists; existing node kept")
+                report.IDMap[n.ID] = n.ID
+                continue
+            }
+        }
+        report.IDMap[n.ID] = id
+
+        props := make(map[string]any, len(n.Properties)+1)
+        for k, v := range n.Properties {
+            props[k] = v
+        }
+        props["id"] = id
+        plan.nodes = append(plan.nodes, models.GraphNode{ID: id, Type: n.Type, Properties: props})
+    }
+
+    resolve := func(id, label string) (string, bool) {
+        if mapped, ok := report.IDMap[id]; ok {
+            return mapped, true
+        }
+        return id, !inDoc[id] && existing[id] && restorableLabels[label]
+    }
+    seen := make(map[string]bool)
+    for _, r := range doc.Relationships {
+        key := r.SourceID + "->" + r.TargetID + ":" + r.Relationship
+        if derivedRel(r.Relationship) {
+            continue
+        }
+        if skippedLabels[r.SourceType] || skippedLabels[r.TargetType] || isIdentityLinkType(r.Relationship) {
+            skip(r.Relationship)
+            continue
+        }
+        if !restorableRelTypes[r.Relationship] {
+            conflict("relationship", key, "unsupported relationship type "+r.Relationship)
+            continue
+        }
+        src, ok := resolve(r.SourceID, r.SourceType)
+        if !ok {
+            conflict("relationship", key, "unknown source node")
+            continue
+        }
+        dst, ok := resolve(r.TargetID, r.TargetType)
+        if !ok {
+            conflict("relationship", key, "unknown target node")
+            continue
+        }
+
+        dedup := src + "|" + dst + "|" + r.Relationship
+        if seen[dedup] {
+            continue
+        }
+        seen[dedup] = true
+
+        r.SourceID, r.TargetID = src, dst
+        plan.rels = append(plan.rels, r)
+    }
+    plan.prune(existing, &report)
+
+    report.NodesCreated = len(plan.nodes)
+    report.RelationshipsCreated = len(plan.rels)
+    return plan, report
+}
+
+// prune drops transactions without both a sender and a receiver, which
+// neither store can hold, then status changes and follow-up links of
+// transactions that are neither restored nor present.
+func (plan *restorePlan) prune(existing map[string]bool, report *models.RestoreReport) {
+    exported := make(map[string]string, len(report.IDMap))
+    for from, to := range report.IDMap {
+        exported[to] = from
+    }
+    senders := make(map[string]bool)
+    receivers := make(map[string]bool)
+    changeOf := make(map[string]string) // StatusChange ID to transaction ID
+    for _, r := range plan.rels {
+        switch r.Relationship {
+        case "SENT":
+            senders[r.TargetID] = true
+        case "RECEIVED_BY":
+            receivers[r.SourceID] = true
+        case "HAS_STATUS_CHANGE":
+            changeOf[r.TargetID] = r.SourceID
+        }
+    }
+
+    kept := make(map[string]bool, len(plan.nodes))
+    nodes := plan.nodes[:0]
+    for _, n := range plan.nodes {
+        if n.Type == "Transaction" && (!senders[n.ID] || !receivers[n.ID]) {
+            report.Conflicts = append(report.Conflicts, models.RestoreConflict{
+                Kind:   "node",
+                ID:     exported[n.ID],
+                Reason: "transaction without sender or receiver",
+            })
+            delete(report.IDMap, exported[n.ID])
+            continue
+        }
+        nodes = append(nodes, n)
+        kept[n.ID] = true
+    }
+    present := func(id string) bool { return kept[id] || existing[id] }
+    plan.nodes = nodes[:0]
+    for _, n := range nodes {
+        if n.Type == "StatusChange" && !present(changeOf[n.ID]) {
+            delete(kept, n.ID)
+            delete(report.IDMap, exported[n.ID])
+            continue
+        }
+        plan.nodes = append(plan.nodes, n)
+    }
+
+    rels := plan.rels[:0]
+    for _, r := range plan.rels {
+        if present(r.SourceID) && present(r.TargetID) {
+            rels = append(rels, r)
+        }
+    }
+    plan.rels = rels
+}
+
+// RestoreGraph rebuilds nodes and relationships from an ExportGraph
+// document. In merge mode existing data is kept; replace first deletes the
+// users, transactions and status changes with their hubs, clusters and
+// history, and links the alerts, cases and Persons it keeps to restored
+// nodes that have the same ID. Skipped entries are reported as conflicts.
+// Restored users and transactions are linked to their hubs, which are not
+// counted as created, and the clusters are rebuilt, keeping exported
+// cluster IDs where they still fit.
+func (d *Driver) RestoreGraph(
+    doc models.GraphExportResponse,
+    opts models.RestoreOptions,
+) (models.RestoreReport, error) {
+    opts, err := normalizeRestoreOptions(opts)
+    if err != nil {
+        return models.RestoreReport{}, err
+    }
+
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    // 1) Look up which relevant IDs already exist
+    existing := make(map[string]bool)
+    if ids := restoreLookupIDs(doc, opts); len(ids) > 0 {
+        if _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+            for _, label := range sortedKeys(restorableLabels) {
+                rs, err := tx.Run(ctx,
+                    `MATCH (n:`+label+`) WHERE n.id IN $ids RETURN n.id`,
+                    map[string]any{"ids": ids},
+                )
+                if err != nil {
+                    return nil, err
+                }
+                for rs.Next(ctx) {
+                    existing[rs.Record().Values[0].(string)] = true
+                }
+                if err := rs.Err(); err != nil {
+                    return nil, err
+                }
+            }
+            return nil, nil
+        }); err != nil {
+            return models.RestoreReport{}, err
+        }
+    }
+
+    plan, report := planRestore(doc, opts, existing)
+    if opts.DryRun {
+        return report, nil
+    }
+
+    // 2) Write everything in one transaction
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        nodesCreated, relsCreated := 0, 0
+        var carried []keptLink
+        if opts.Mode == "replace" {
+            links, err := readKeptLinks(ctx, tx)
+            if err != nil {
+                return nil, err
+            }
+            carried = links
+            if _, err := tx.Run(ctx,
+                `MATCH (n) WHERE any(l IN labels(n) WHERE l IN $labels)
+                 DETACH DELETE n`,
+                map[string]any{"labels": replacedLabels()},
+            ); err != nil {
+                return nil, err
+            }
+        }
+
+        byLabel := make(map[string][]map[string]any)
+        for _, n := range plan.nodes {
+            byLabel[n.Type] = append(byLabel[n.Type], n.Properties)
+        }
+        for _, label := range sortedKeys(byLabel) {
+            set := ""
+            for _, p := range temporalProps[label] {
+                set += fmt.Sprintf(", x.%s = CASE WHEN x.%s IS NULL THEN NULL ELSE datetime(x.%s) END", p, p, p)
+            }
+            for _, p := range integerProps[label] {
+                set += fmt.Sprintf(", x.%s = toInteger(x.%s)", p, p)
+            }
+            if label == "User" || label == "Transaction" {
+                set += ", x.createdAt = coalesce(x.createdAt, datetime())"
+            }
+            rs, err := tx.Run(ctx,
+                `UNWIND $nodes AS props
+                 CREATE (x:`+label+`)
+                 SET x = props`+set,
+                map[string]any{"nodes": byLabel[label]},
+            )
+            if err != nil {
+                return nil, err
+            }
+            sum, err := rs.Consume(ctx)
+            if err != nil {
+                return nil, err
+            }
+            nodesCreated += sum.Counters().NodesCreated()
+        }
+
+        byShape := make(map[string][]map[string]any)
+        for _, r := range plan.rels {
+            shape := r.SourceType + "|" + r.Relationship + "|" + r.TargetType
+            byShape[shape] = append(byShape[shape], map[string]any{"src": r.SourceID, "dst": r.TargetID})
+        }
+        for _, shape := range sortedKeys(byShape) {
+            parts := strings.Split(shape, "|")
+            rs, err := tx.Run(ctx,
+                `UNWIND $rels AS r
+                 MATCH (a:`+parts[0]+`) WHERE a.id = r.src
+                 MATCH (b:`+parts[2]+`) WHERE b.id = r.dst
+                 MERGE (a)-[:`+parts[1]+`]->(b)`,
+                map[string]any{"rels": byShape[shape]},
+            )
+            if err != nil {
+                return nil, err
+            }
+            sum, err := rs.Consume(ctx)
+            if err != nil {
+                return nil, err
+            }
+            relsCreated += sum.Counters().RelationshipsCreated()
+        }
+
+        for _, label := range []string{"User", "Transaction"} {
+            var ids []string
+            for _, n := range plan.nodes {
+                if n.Type == label {
+                    ids = append(ids, n.ID)
+                }
+            }
+            if len(ids) > 0 {
+                if err := linkHubs(ctx, tx, label, ids); err != nil {
+                    return nil, err
+                }
+                // Restored values are as old as their owner.
+                if _, err := tx.Run(ctx,
+                    `UNWIND $ids AS id
+                     MATCH (n:`+label+`)-[r:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->()
+                     WHERE n.id = id
+                     SET r.createdAt = n.createdAt`,
+                    map[string]any{"ids": ids},
+                ); err != nil {
+                    return nil, err
+                }
+            }
+        }
+        if opts.Mode == "replace" {
+            if err := relinkKept(ctx, tx, carried); err != nil {
+                return nil, err
+            }
+        }
+        return [2]int{nodesCreated, relsCreated}, nil
+    })
+    if err != nil {
+        return models.RestoreReport{}, err
+    }
+    counts := raw.([2]int)
+    report.NodesCreated, report.RelationshipsCreated = counts[0], counts[1]
+    if _, err := d.RebuildClusters(false); err != nil {
+        return report, err
+    }
+    return report, nil
+}
+
+// keptLink is a relationship between a node a replace keeps, or another
+// user or transaction, and a user or transaction it deletes.
+type keptLink struct {
+    srcLabel, srcID, typ, dstLabel, dstID string
+    props                                 map[string]any
+}
+
+// readKeptLinks lists the links of users and transactions that a restore
+// does not rebuild: alert and case links, Person membership, watchlist hits
+// and probable matches.
+func readKeptLinks(ctx context.Context, tx neo4j.ManagedTransaction) ([]keptLink, error) {
+    rs, err := tx.Run(ctx,
+        `MATCH (a)-[r]->(b)
+         WHERE (a:User OR a:Transaction OR b:User OR b:Transaction)
+           AND NOT type(r) IN $rebuilt
+         RETURN labels(a)[0], a.id, type(r), properties(r), labels(b)[0], b.id`,
+        map[string]any{"rebuilt": rebuiltRelTypes()},
+    )
+    if err != nil {
+        return nil, err
+    }
+    var links []keptLink
+    for rs.Next(ctx) {
+        v := rs.Record().Values
+        l := keptLink{typ: v[2].(string), props: v[3].(map[string]any)}
+        l.srcLabel, _ = v[0].(string)
+        l.srcID, _ = v[1].(string)
+        l.dstLabel, _ = v[4].(string)
+        l.dstID, _ = v[5].(string)
+        links = append(links, l)
+    }
+    return links, rs.Err()
+}
+
+// relinkKept recreates the links from readKeptLinks whose ends both exist
+// after the restore, and deletes the Persons left without users, as
+// deleting a user would.
+func relinkKept(ctx context.Context, tx neo4j.ManagedTransaction, links []keptLink) error {
+    byShape := make(map[string][]map[string]any)
+    for _, l := range links {
+        shape := l.srcLabel + "|" + l.typ + "|" + l.dstLabel
+        byShape[shape] = append(byShape[shape], map[string]any{"src": l.srcID, "dst": l.dstID, "props": l.props})
+    }
+    for _, shape := range sortedKeys(byShape) {
+        parts := strings.Split(shape, "|")
+        if _, err := tx.Run(ctx,
+            "UNWIND $rels AS r\n"+
+                "MATCH (a:`"+parts[0]+"`) WHERE a.id = r.src\n"+
+                "MATCH (b:`"+parts[2]+"`) WHERE b.id = r.dst\n"+
+                "CREATE (a)-[x:`"+parts[1]+"`]->(b) SET x = r.props",
+            map[string]any{"rels": byShape[shape]},
+        ); err != nil {
+            return err
+        }
+    }
+    _, err := tx.Run(ctx, `MATCH (p:Person) WHERE NOT (p)<-[:RESOLVED_AS]-() DELETE p`, nil)
+    return err
+}
+
+// RestoreGraph rebuilds users and transactions, with their status history
+// and follow-up links, from an ExportGraph document. Transactions need both
+// a SENT and a RECEIVED_BY relationship in the document to be restored;
+// status changes and follow-up links of transactions that are not restored
+// or present are dropped. Replace keeps alerts, cases and Persons, linked
+// to whatever is restored under the same ID. Transactions exported without
+// a status are settled.
+func (m *MemoryStore) RestoreGraph(
+    doc models.GraphExportResponse,
+    opts models.RestoreOptions,
+) (models.RestoreReport, error) {
+    opts, err := normalizeRestoreOptions(opts)
+    if err != nil {
+        return models.RestoreReport{}, err
+    }
+
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    existing := make(map[string]bool)
+    for _, id := range restoreLookupIDs(doc, opts) {
+        _, isUser := m.users[id]
+        _, isTx := m.txs[id]
+        existing[id] = isUser || isTx
+    }
+    plan, report := planRestore(doc, opts, existing)
+    if opts.DryRun {
+        return report, nil
+    }
+
+    // The memory store keeps a transaction's endpoints on the node itself,
+    // and its history and follow-up links beside it.
+    senders := make(map[string]string)
+    receivers := make(map[string]string)
+    changeOf := make(map[string]string) // StatusChange ID to transaction ID
+    var followUps []memRel
+    for _, r := range plan.rels {
+        switch r.Relationship {
+        case "SENT":
+            senders[r.TargetID] = r.SourceID
+        case "RECEIVED_BY":
+            receivers[r.SourceID] = r.TargetID
+        case "HAS_STATUS_CHANGE":
+            changeOf[r.TargetID] = r.SourceID
+        default:
+            followUps = append(followUps, memRel{src: r.SourceID, dst: r.TargetID, typ: r.Relationship})
+        }
+    }
+
+    if opts.Mode == "replace" {
+        m.users = make(map[string]models.User)
+        m.txs = make(map[string]models.Transaction)
+        m.userIDs, m.txIDs = nil, nil
+        m.txScores = make(map[string]map[string]float64)
+        m.history = make(map[string][]memStatusChange)
+        m.followUps = nil
+        m.created = make(map[string]time.Time)
+        m.linked = make(map[string]time.Time)
+        m.expired, m.deletedUsers, m.deletedTxs = nil, nil, nil
+        m.revisions = make(map[string][]revision)
+        m.clusterOf = make(map[string]string)
+        m.clusterSize = make(map[string]int)
+    }
+    m.followUps = append(m.followUps, followUps...)
+    seqs := make(map[string]float64)
+    for _, n := range plan.nodes {
+        p := n.Properties
+        if n.Type == "StatusChange" {
+            tid := changeOf[n.ID]
+            at := stringProp(p, "at")
+            if t, err := time.Parse(time.RFC3339Nano, at); err == nil {
+                at = t.Format(time.RFC3339Nano)
+            }
+            m.history[tid] = append(m.history[tid], memStatusChange{n.ID, models.StatusChange{
+                From:   stringProp(p, "from"),
+                To:     stringProp(p, "to"),
+                Reason: stringProp(p, "reason"),
+                At:     at,
+            }})
+            seqs[n.ID], _ = p["seq"].(float64)
+            continue
+        }
+        if n.Type == "User" {
+            m.users[n.ID] = models.User{
+                ID:    n.ID,
+                Name:  stringProp(p, "name"),
+                Email: stringProp(p, "email"),
+                Phone: stringProp(p, "phone"),
+            }
+            m.userIDs = append(m.userIDs, n.ID)
+            m.restoreLifetime(n.ID, "User", p)
+            continue
+        }
+        ts := stringProp(p, "timestamp")
+        if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+            ts = t.Format(time.RFC3339Nano)
+        }
+        amount, _ := p["amount"].(float64)
+        status := stringProp(p, "status")
+        if status == "" {
+            status = legacyStatus
+        }
+        t := models.Transaction{
+            ID:          n.ID,
+            FromUserID:  senders[n.ID],
+            ToUserID:    receivers[n.ID],
+            Amount:      amount,
+            Currency:    stringProp(p, "currency"),
+            Timestamp:   ts,
+            Description: stringProp(p, "description"),
+            DeviceID:    stringProp(p, "deviceId"),
+            IP:          stringProp(p, "ip"),
+            Status:      status,
+        }
+        var minor any // rounded from amount when missing
+        if v, ok := p["amountMinor"].(float64); ok {
+            minor = int64(v)
+        }
+        reporting, _ := p["reportingAmountMinor"].(float64)
+        setMoney(&t, []any{minor, stringProp(p, "reportingCurrency"), int64(reporting), stringProp(p, "fxRate")})
+        m.txs[n.ID] = t
+        m.txIDs = append(m.txIDs, n.ID)
+        m.restoreLifetime(n.ID, "Transaction", p)
+        if c := stringProp(p, "clusterId"); c != "" {
+            m.clusterOf[n.ID] = c
+        }
+    }
+    if countType(plan.nodes, "StatusChange") > 0 {
+        for _, h := range m.history {
+            sort.SliceStable(h, func(i, j int) bool {
+                a, _ := time.Parse(time.RFC3339Nano, h[i].At)
+                b, _ := time.Parse(time.RFC3339Nano, h[j].At)
+                if !a.Equal(b) {
+                    return a.Before(b)
+                }
+                return seqs[h[i].id] < seqs[h[j].id]
+            })
+        }
+    }
+    if opts.Mode == "replace" {
+        m.dropMissingLinks()
+    }
+    m.rebuildClusters(false, randomCluster)
+    return report, nil
+}
+
+// dropMissingLinks unlinks alerts, cases, Persons, watchlist hits and
+// probable matches from the users and transactions a replace did not bring
+// back, as deleting them would. Callers must hold the write lock.
+func (m *MemoryStore) dropMissingLinks() {
+    for aid, a := range m.alerts {
+        if _, ok := m.txs[a.TransactionID]; !ok && a.TransactionID != "" {
+            a.TransactionID = ""
+            m.alerts[aid] = a
+        }
+    }
+    for cid, c := range m.cases {
+        c.UserIDs = presentIDs(c.UserIDs, m.users)
+        c.TransactionIDs = presentIDs(c.TransactionIDs, m.txs)
+        m.cases[cid] = c
+    }
+    for uid := range m.hits {
+        if _, ok := m.users[uid]; !ok {
+            delete(m.hits, uid)
+        }
+    }
+    kept := m.identity[:0]
+    for _, l := range m.identity {
+        _, from := m.users[l.From]
+        _, to := m.users[l.To]
+        if from && to {
+            kept = append(kept, l)
+        }
+    }
+    m.identity = kept
+    var gone []string
+    for _, p := range m.persons {
+        for _, uid := range p.UserIDs {
+            if _, ok := m.users[uid]; !ok {
+                gone = append(gone, uid)
+            }
+        }
+    }
+    m.leavePersons(gone)
+}
+
+// presentIDs returns the IDs in ids that are keys of m, in order.
+func presentIDs[V any](ids []string, m map[string]V) []string {
+    out := ids[:0]
+    for _, id := range ids {
+        if _, ok := m[id]; ok {
+            out = append(out, id)
+        }
+    }
+    return out
+}
+
+func countType(nodes []models.GraphNode, typ string) int {
+    n := 0
+    for _, node := range nodes {
+        if node.Type == typ {
+            n++
+        }
+    }
+    return n
+}
+
+func stringProp(props map[string]any, key string) string {
+    s, _ := props[key].(string)
+    return s
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+    keys := make([]string, 0, len(m))
+    for k := range m {
+        keys = append(keys, k)
+    }
+    sort.Strings(keys)
+    return keys
+}
@@ -0,0 +1,223 @@
+package graph
+
+import (
+    "encoding/json"
+    "sort"
+    "strings"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+// graphKeys lists a document's nodes and relationships as sorted strings.
+func graphKeys(doc models.GraphExportResponse) []string {
+    var keys []string
+    for _, n := range doc.Nodes {
+        keys = append(keys, n.Type+" "+n.ID)
+    }
+    for _, r := range doc.Relationships {
+        keys = append(keys, r.SourceID+" "+r.Relationship+" "+r.TargetID)
+    }
+    sort.Strings(keys)
+    return keys
+}
+
+// exportJSON exports the store and decodes the document again, as restore
+// receives it over the API.
+func exportJSON(t *testing.T, s *testStore) models.GraphExportResponse {
+    t.Helper()
+    export, err := s.ExportGraph()
+    if err != nil {
+        t.Fatal(err)
+    }
+    data, err := json.Marshal(export)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var doc models.GraphExportResponse
+    if err := json.Unmarshal(data, &doc); err != nil {
+        t.Fatal(err)
+    }
+    return doc
+}
+
+func TestRestoreRoundTrip(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    original := s.tx(a, b, 10, "dev-1", "203.0.113.1")
+    refund := s.tx(b, a, 10, "", "")
+    // Reverses the original, adding a status change.
+    if err := s.LinkFollowUp(refund, original, "REVERSAL_OF"); err != nil {
+        t.Fatal(err)
+    }
+    doc := exportJSON(t, s)
+
+    target := newTestStore(t)
+    target.user("Z", "z@example.com", "9")
+    report, err := target.RestoreGraph(doc, models.RestoreOptions{Mode: "replace", KeepIDs: true})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(report.Conflicts) != 0 {
+        t.Errorf("conflicts = %+v, want none", report.Conflicts)
+    }
+    want, got := graphKeys(doc), graphKeys(exportJSON(t, target))
+    if len(got) != len(want) {
+        t.Fatalf("restored graph = %v, want %v", got, want)
+    }
+    for i := range want {
+        if got[i] != want[i] {
+            t.Fatalf("restored graph = %v, want %v", got, want)
+        }
+    }
+    txs, err := target.GetAllTransactions()
+    if err != nil {
+        t.Fatal(err)
+    }
+    for _, tx := range txs {
+        if tx.ID == original && (tx.Status != "reversed" || tx.AmountMinor != 1000) {
+            t.Errorf("restored transaction = %+v, want reversed with 1000 minor units", tx)
+        }
+    }
+}
+
+func TestRestoreReplaceKeepsCaseData(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    id := s.tx(a, b, 10, "", "")
+    alerts, err := s.CreateAlerts([]models.Alert{{RuleID: "r1", TransactionID: id, SenderID: a, ReceiverID: b}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    c, err := s.CreateCase(models.CaseRequest{Title: "case", UserIDs: []string{a, b}, TransactionIDs: []string{id}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    p, err := s.CreatePerson(models.PersonRequest{Name: "AB", UserIDs: []string{a, b}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    doc := exportJSON(t, s)
+
+    // The same users and transaction come back: every link survives.
+    if _, err := s.RestoreGraph(doc, models.RestoreOptions{Mode: "replace", KeepIDs: true}); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := s.GetAlert(alerts[0].ID); err != nil || got.TransactionID != id {
+        t.Errorf("alert = %+v, %v; want it kept on %s", got, err, id)
+    }
+    if got, err := s.GetCase(c.ID); err != nil || len(got.UserIDs) != 2 || len(got.TransactionIDs) != 1 {
+        t.Errorf("case = %+v, %v; want it kept with both users and the transaction", got, err)
+    }
+    if got, err := s.GetPerson(p.ID); err != nil || len(got.UserIDs) != 2 {
+        t.Errorf("person = %+v, %v; want it kept with both users", got, err)
+    }
+
+    // Only A comes back: links to B and the transaction are dropped.
+    onlyA := models.GraphExportResponse{Nodes: []models.GraphNode{{ID: a, Type: "User", Properties: map[string]any{"name": "A"}}}}
+    if _, err := s.RestoreGraph(onlyA, models.RestoreOptions{Mode: "replace", KeepIDs: true}); err != nil {
+        t.Fatal(err)
+    }
+    if got, err := s.GetAlert(alerts[0].ID); err != nil || got.TransactionID != "" {
+        t.Errorf("alert = %+v, %v; want it kept without a transaction", got, err)
+    }
+    if got, err := s.GetCase(c.ID); err != nil || len(got.UserIDs) != 1 || got.UserIDs[0] != a || len(got.TransactionIDs) != 0 {
+        t.Errorf("case = %+v, %v; want it kept with A only", got, err)
+    }
+    if got, err := s.GetPerson(p.ID); err != nil || len(got.UserIDs) != 1 {
+        t.Errorf("person = %+v, %v; want it kept with A only", got, err)
+    }
+}
+
+func TestRestoreSkipsCaseData(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    id := s.tx(a, b, 10, "dev-1", "203.0.113.1")
+    alerts, err := s.CreateAlerts([]models.Alert{{RuleID: "r1", TransactionID: id, SenderID: a, ReceiverID: b}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    c, err := s.CreateCase(models.CaseRequest{Title: "case", AlertIDs: []string{alerts[0].ID}})
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.AddCaseNote(c.ID, models.CaseNote{Author: "analyst", Text: "looked"}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.AddCaseEvidence(c.ID, models.CaseEvidence{Kind: "cycle", Data: json.RawMessage(`{}`)}); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.CreatePerson(models.PersonRequest{UserIDs: []string{a, b}}); err != nil {
+        t.Fatal(err)
+    }
+    if err := s.SetIdentityLinks([]string{a}, []models.IdentityLink{{From: a, To: b, Type: "SIMILAR_NAME", Confidence: 0.9}}); err != nil {
+        t.Fatal(err)
+    }
+    hit := models.WatchlistHit{EntryID: "e1", List: "sanctions", MatchType: "name", MatchedValue: "A", Score: 1, ScreenedAt: "2024-01-01T00:00:00Z"}
+    if err := s.SetWatchlistHits(map[string][]models.WatchlistHit{a: {hit}}); err != nil {
+        t.Fatal(err)
+    }
+    doc := exportJSON(t, s)
+
+    // The same labels as the Neo4j export.
+    labels := make(map[string]bool)
+    for _, n := range doc.Nodes {
+        labels[n.Type] = true
+    }
+    want := []string{"Alert", "Case", "Device", "Email", "Evidence", "IPAddress", "Note", "Person",
+        "Phone", "StatusChange", "Transaction", "User", "WatchlistEntry"}
+    if got := sortedKeys(labels); strings.Join(got, ",") != strings.Join(want, ",") {
+        t.Fatalf("exported labels = %v, want %v", got, want)
+    }
+
+    skipped := map[string]int{
+        "Alert": 1, "Case": 1, "Note": 1, "Evidence": 1, "Person": 1, "WatchlistEntry": 1,
+        "FLAGS": 1, "CONCERNS": 2, "INCLUDES": 1, "HAS_NOTE": 1, "HAS_EVIDENCE": 1,
+        "RESOLVED_AS": 2, "WATCHLIST_HIT": 1, "SIMILAR_NAME": 1,
+    }
+    for _, mode := range []string{"replace", "merge"} {
+        report, err := newTestStore(t).RestoreGraph(doc, models.RestoreOptions{Mode: mode})
+        if err != nil {
+            t.Fatalf("%s: %v", mode, err)
+        }
+        if report.NodesCreated != 4 || len(report.Conflicts) != 0 {
+            t.Errorf("%s report = %+v, want the users, transaction and status change restored without conflicts", mode, report)
+        }
+        if len(report.Skipped) != len(skipped) {
+            t.Errorf("%s skipped = %v, want %v", mode, report.Skipped, skipped)
+        }
+        for kind, n := range skipped {
+            if report.Skipped[kind] != n {
+                t.Errorf("%s skipped = %v, want %v", mode, report.Skipped, skipped)
+                break
+            }
+        }
+    }
+}
+
+func TestPlanRestoreDropsOpenTransactions(t *testing.T) {
+    doc := models.GraphExportResponse{
+        Nodes: []models.GraphNode{
+            {ID: "u1", Type: "User"},
+            {ID: "t1", Type: "Transaction"},
+            {ID: "s1", Type: "StatusChange"},
+        },
+        Relationships: []models.GraphRelationship{
+            {SourceID: "u1", SourceType: "User", Relationship: "SENT", TargetID: "t1", TargetType: "Transaction"},
+            {SourceID: "t1", SourceType: "Transaction", Relationship: "HAS_STATUS_CHANGE", TargetID: "s1", TargetType: "StatusChange"},
+        },
+    }
+    plan, report := planRestore(doc, models.RestoreOptions{Mode: "merge", KeepIDs: true}, nil)
+    if len(plan.nodes) != 1 || plan.nodes[0].ID != "u1" || len(plan.rels) != 0 {
+        t.Errorf("plan = %+v, want only u1", plan)
+    }
+    if len(report.Conflicts) != 1 || report.Conflicts[0].ID != "t1" {
+        t.Errorf("conflicts = %+v, want t1 without a receiver", report.Conflicts)
+    }
+    if _, ok := report.IDMap["t1"]; ok {
+        t.Errorf("idMap = %v, want no entry for the dropped transaction", report.IDMap)
+    }
+}
@@ -0,0 +1,58 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// transactionScoreProperties are the scores that can be stored on
+// transactions.
+var transactionScoreProperties = []string{"risk"}
+
+// WriteTransactionScores stores each score as a property named after it on
+// the matching Transaction node.
+func (d *Driver) WriteTransactionScores(name string, scores map[string]float64) error {
+    if !hasString(transactionScoreProperties, name) {
+        return fmt.Errorf("%w: unknown score %q", ErrInvalidQuery, name)
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    rows := make([]map[string]any, 0, len(scores))
+    for id, score := range scores {
+        rows = append(rows, map[string]any{"id": id, "props": map[string]any{name: score}})
+    }
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        _, err := tx.Run(ctx,
+            `UNWIND $rows AS row
+             MATCH (t:Transaction) WHERE t.id = row.id
+             SET t += row.props`,
+            map[string]any{"rows": rows},
+        )
+        return nil, err
+    })
+    return err
+}
+
+// WriteTransactionScores stores each score on the matching transaction.
+func (m *MemoryStore) WriteTransactionScores(name string, scores map[string]float64) error {
+    if !hasString(transactionScoreProperties, name) {
+        return fmt.Errorf("%w: unknown score %q", ErrInvalidQuery, name)
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for id, score := range scores {
+        if _, ok := m.txs[id]; !ok {
+            continue
+        }
+        if m.txScores[id] == nil {
+            m.txScores[id] = make(map[string]float64)
+        }
+        m.txScores[id][name] = score
+    }
+    return nil
+}
@@ -0,0 +1,296 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "sort"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// SnapshotFilter restricts the transactions loaded into a Snapshot.
+// Zero values disable a filter.
+type SnapshotFilter struct {
+    From            time.Time // inclusive
+    To              time.Time // exclusive
+    MinAmount       float64
+    IncludeShared   bool     // also load the Email, Phone and Device hubs
+    ExcludeStatuses []string // e.g. failed and reversed transfers
+}
+
+// Hub is an Email, Phone or Device node with the users or transactions
+// pointing at it, for hubs with at least two. IP addresses are left out;
+// they are too often shared by unrelated parties to weigh in analytics.
+// Two members of a hub are linked by Type, but the pairs are never listed:
+// a device used by n transactions would make n² of them.
+type Hub struct {
+    ID      string
+    Type    string   // SHARED_EMAIL, SHARED_PHONE or SHARED_DEVICE
+    Members []string // user IDs, or transaction IDs for SHARED_DEVICE
+}
+
+// MaxHubFanOut is the most distinct members of one hub that LinkMembers
+// links pairwise. A device or phone used by more parties says little about
+// any two of them, and linking them all would be quadratic again. Path
+// searches walk through hubs of any size.
+const MaxHubFanOut = 50
+
+// DistinctMembers returns ids without empty and repeated values, in order.
+func DistinctMembers(ids []string) []string {
+    seen := make(map[string]bool, len(ids))
+    var distinct []string
+    for _, id := range ids {
+        if id != "" && !seen[id] {
+            seen[id] = true
+            distinct = append(distinct, id)
+        }
+    }
+    return distinct
+}
+
+// LinkMembers calls link once for each pair of distinct ids, in order,
+// unless there are more than MaxHubFanOut of them; it reports whether it
+// did.
+func LinkMembers(ids []string, link func(a, b string)) bool {
+    distinct := DistinctMembers(ids)
+    if len(distinct) > MaxHubFanOut {
+        return false
+    }
+    for i := range distinct {
+        for j := i + 1; j < len(distinct); j++ {
+            link(distinct[i], distinct[j])
+        }
+    }
+    return true
+}
+
+// Snapshot is a read-only copy of users, transactions and shared hubs.
+// Analytics that are simpler to express in Go than in Cypher load one and
+// run the same way against every GraphStore.
+type Snapshot struct {
+    Users        map[string]models.User
+    Transactions []models.Transaction // ordered by timestamp, then ID
+    Hubs         []Hub
+    times        map[string]time.Time
+}
+
+// newSnapshot orders txs by time. A timestamp that isn't RFC3339 is an
+// error rather than the zero time, which would misplace the transaction in
+// every time window.
+func newSnapshot(users map[string]models.User, txs []models.Transaction, hubs []Hub) (*Snapshot, error) {
+    s := &Snapshot{
+        Users:        users,
+        Transactions: txs,
+        Hubs:         hubs,
+        times:        make(map[string]time.Time, len(txs)),
+    }
+    for _, t := range txs {
+        ts, err := time.Parse(time.RFC3339Nano, t.Timestamp)
+        if err != nil {
+            return nil, fmt.Errorf("transaction %s: invalid timestamp %q", t.ID, t.Timestamp)
+        }
+        s.times[t.ID] = ts
+    }
+    sort.SliceStable(s.Transactions, func(i, j int) bool {
+        a, b := s.Transactions[i], s.Transactions[j]
+        if c := s.times[a.ID].Compare(s.times[b.ID]); c != 0 {
+            return c < 0
+        }
+        return a.ID < b.ID
+    })
+    return s, nil
+}
+
+// Time returns the parsed timestamp of a transaction in the snapshot.
+func (s *Snapshot) Time(txID string) time.Time {
+    return s.times[txID]
+}
+
+func (f SnapshotFilter) keep(t models.Transaction, ts time.Time) bool {
+    switch {
+    case !f.From.IsZero() && ts.Before(f.From),
+        !f.To.IsZero() && !ts.Before(f.To),
+        f.MinAmount > 0 && Value(t) < f.MinAmount,
+        hasString(f.ExcludeStatuses, t.Status):
+        return false
+    }
+    return true
+}
+
+// Snapshot loads users and the transactions matching f.
+func (d *Driver) Snapshot(f SnapshotFilter) (*Snapshot, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    users := make(map[string]models.User)
+    var txs []models.Transaction
+    var hubs []Hub
+    _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        users = make(map[string]models.User)
+        txs, hubs = nil, nil
+
+        // 1) Users
+        rs, err := tx.Run(ctx, `MATCH (u:User) RETURN u.id, u.name, u.email, u.phone`, nil)
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            id := r.Values[0].(string)
+            users[id] = models.User{
+                ID:    id,
+                Name:  r.Values[1].(string),
+                Email: r.Values[2].(string),
+                Phone: r.Values[3].(string),
+            }
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 2) Transactions in the window
+        var conds []string
+        params := map[string]any{"legacy": legacyStatus}
+        if !f.From.IsZero() {
+            conds = append(conds, "t.timestamp >= datetime($from)")
+            params["from"] = f.From.Format(time.RFC3339Nano)
+        }
+        if !f.To.IsZero() {
+            conds = append(conds, "t.timestamp < datetime($to)")
+            params["to"] = f.To.Format(time.RFC3339Nano)
+        }
+        if f.MinAmount > 0 {
+            conds = append(conds, "coalesce(t.reportingAmount, t.amount) >= $minAmount")
+            params["minAmount"] = f.MinAmount
+        }
+        if len(f.ExcludeStatuses) > 0 {
+            conds = append(conds, "NOT coalesce(t.status, $legacy) IN $excludeStatuses")
+            params["excludeStatuses"] = f.ExcludeStatuses
+        }
+        rs, err = tx.Run(ctx,
+            `MATCH (u1:User)-[:SENT]->(t:Transaction)-[:RECEIVED_BY]->(u2:User)`+whereClause(conds)+`
+             RETURN t.id, u1.id, u2.id,
+                    t.amount, t.currency, t.timestamp, t.description, t.deviceId,
+                    coalesce(t.ip, ''), coalesce(t.status, $legacy),
+                    `+moneyColumns("t"),
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            t := models.Transaction{
+                ID:          r.Values[0].(string),
+                FromUserID:  r.Values[1].(string),
+                ToUserID:    r.Values[2].(string),
+                Amount:      r.Values[3].(float64),
+                Currency:    r.Values[4].(string),
+                Timestamp:   storedTime(r.Values[5]),
+                Description: r.Values[6].(string),
+                DeviceID:    r.Values[7].(string),
+                IP:          r.Values[8].(string),
+                Status:      r.Values[9].(string),
+            }
+            setMoney(&t, r.Values[10:])
+            txs = append(txs, t)
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+        if !f.IncludeShared {
+            return nil, nil
+        }
+
+        // 3) Memberships of the shared hubs
+        for _, k := range hubKinds {
+            if k.label == "IPAddress" {
+                continue
+            }
+            rs, err = tx.Run(ctx,
+                `MATCH (n:`+k.owner+`)-[:`+k.rel+`]->(h:`+k.label+`)
+                 RETURN h.id, n.id
+                 ORDER BY n.id`,
+                nil,
+            )
+            if err != nil {
+                return nil, err
+            }
+            groups := make(map[string][]string)
+            for rs.Next(ctx) {
+                r := rs.Record()
+                hid := r.Values[0].(string)
+                groups[hid] = append(groups[hid], r.Values[1].(string))
+            }
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            hubs = append(hubs, sharedHubs(k.shared, groups)...)
+        }
+        return nil, nil
+    })
+    if err != nil {
+        return nil, err
+    }
+    return newSnapshot(users, txs, hubs)
+}
+
+// Snapshot copies users and the transactions matching f.
+func (m *MemoryStore) Snapshot(f SnapshotFilter) (*Snapshot, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    users := make(map[string]models.User, len(m.users))
+    for id, u := range m.users {
+        users[id] = u
+    }
+    var txs []models.Transaction
+    for _, id := range m.txIDs {
+        t := m.txs[id]
+        ts, err := time.Parse(time.RFC3339Nano, t.Timestamp)
+        if err != nil {
+            return nil, fmt.Errorf("transaction %s: invalid timestamp %q", id, t.Timestamp)
+        }
+        if f.keep(t, ts) {
+            txs = append(txs, t)
+        }
+    }
+    var hubs []Hub
+    if f.IncludeShared {
+        for _, k := range hubKinds {
+            if k.label != "IPAddress" {
+                hubs = append(hubs, sharedHubs(k.shared, m.hubGroups(k))...)
+            }
+        }
+    }
+    return newSnapshot(users, txs, hubs)
+}
+
+func (s *Snapshot) userNode(id string) models.PathNode {
+    return models.PathNode{ID: id, Type: "User", Name: s.Users[id].Name}
+}
+
+func (s *Snapshot) transactionNode(t models.Transaction) models.PathNode {
+    return models.PathNode{ID: t.ID, Type: "Transaction", DeviceID: t.DeviceID}
+}
+
+// hopSegments renders one transaction as its SENT and RECEIVED_BY segments.
+func (s *Snapshot) hopSegments(t models.Transaction) []models.PathSegment {
+    txNode := s.transactionNode(t)
+    return []models.PathSegment{
+        {From: s.userNode(t.FromUserID), To: txNode, Relationship: "SENT"},
+        {From: txNode, To: s.userNode(t.ToUserID), Relationship: "RECEIVED_BY"},
+    }
+}
+
+// outgoing indexes transactions by sender, keeping timestamp order.
+func (s *Snapshot) outgoing() map[string][]models.Transaction {
+    out := make(map[string][]models.Transaction)
+    for _, t := range s.Transactions {
+        out[t.FromUserID] = append(out[t.FromUserID], t)
+    }
+    return out
+}
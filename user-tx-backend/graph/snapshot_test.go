@@ -0,0 +1,26 @@
+package graph
+
+import (
+    "testing"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+func TestStoredTime(t *testing.T) {
+    // Neo4j's toString() would give "2024-01-01T12:00Z" for this one.
+    whole := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+    if got := storedTime(whole); got != "2024-01-01T12:00:00Z" {
+        t.Errorf("storedTime(%v) = %q, want 2024-01-01T12:00:00Z", whole, got)
+    }
+    if got := storedTime(nil); got != "" {
+        t.Errorf("storedTime(nil) = %q, want empty", got)
+    }
+}
+
+func TestSnapshotRejectsBadTimestamp(t *testing.T) {
+    txs := []models.Transaction{{ID: "t1", Timestamp: "2024-01-01T12:00Z"}}
+    if s, err := newSnapshot(nil, txs, nil); err == nil {
+        t.Errorf("newSnapshot = %v, want an error for a timestamp without seconds", s.Time("t1"))
+    }
+}
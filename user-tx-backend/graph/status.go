@@ -0,0 +1,348 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "strings"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// transactionTransitions lists the statuses a transaction may move to from
+// each status. Failed, reversed and charged-back transactions are final.
+var transactionTransitions = map[string][]string{
+    "pending":      {"settled", "failed"},
+    "settled":      {"reversed", "charged_back"},
+    "failed":       {},
+    "reversed":     {},
+    "charged_back": {},
+}
+
+// legacyStatus is the status of transactions stored before statuses
+// existed; they were all treated as completed transfers.
+const legacyStatus = "settled"
+
+// followUpStatus maps a follow-up relationship to the status it moves its
+// original to.
+var followUpStatus = map[string]string{
+    "REVERSAL_OF":   "reversed",
+    "CHARGEBACK_OF": "charged_back",
+}
+
+// InitialStatus validates the status a transaction is created with;
+// empty means pending.
+func InitialStatus(status string) (string, error) {
+    switch status {
+    case "":
+        return "pending", nil
+    case "pending", "settled":
+        return status, nil
+    }
+    return "", fmt.Errorf("%w: initial status must be pending or settled", ErrInvalidQuery)
+}
+
+// checkStatuses validates a list of statuses to filter on.
+func checkStatuses(statuses []string) error {
+    for _, s := range statuses {
+        if _, ok := transactionTransitions[s]; !ok {
+            return fmt.Errorf("%w: unknown transaction status %q", ErrInvalidQuery, s)
+        }
+    }
+    return nil
+}
+
+// checkTransition enforces transactionTransitions.
+func checkTransition(from, to string) error {
+    if _, ok := transactionTransitions[to]; !ok {
+        return fmt.Errorf("%w: status must be pending, settled, failed, reversed or charged_back", ErrInvalidQuery)
+    }
+    if !hasString(transactionTransitions[from], to) {
+        return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+    }
+    return nil
+}
+
+// CheckFollowUp validates the follow-up fields of a transaction request and
+// returns the relationship and original it names, or "" when it names
+// none. The original must be settled, and the follow-up must return at
+// most its amount, in its currency, from its receiver to its sender.
+func CheckFollowUp(store GraphStore, req models.TransactionRequest) (rel, originalID string, err error) {
+    switch {
+    case req.ReversalOf != "" && req.ChargebackOf != "":
+        return "", "", fmt.Errorf("%w: set at most one of reversalOf and chargebackOf", ErrInvalidQuery)
+    case req.ReversalOf != "":
+        rel, originalID = "REVERSAL_OF", req.ReversalOf
+    case req.ChargebackOf != "":
+        rel, originalID = "CHARGEBACK_OF", req.ChargebackOf
+    default:
+        return "", "", nil
+    }
+
+    orig, conns, err := store.GetTransactionRelationships(originalID)
+    if err != nil {
+        return "", "", err
+    }
+    if err := checkTransition(orig.Status, followUpStatus[rel]); err != nil {
+        return "", "", err
+    }
+    for _, c := range conns.Users {
+        if c.Relationship == "SENT" {
+            orig.FromUserID = c.Node.ID
+        } else if c.Relationship == "RECEIVED_BY" {
+            orig.ToUserID = c.Node.ID
+        }
+    }
+    switch {
+    case req.FromUserID != orig.ToUserID || req.ToUserID != orig.FromUserID:
+        return "", "", fmt.Errorf("%w: a follow-up must go from the original's receiver to its sender", ErrInvalidQuery)
+    case req.Currency != orig.Currency:
+        return "", "", fmt.Errorf("%w: a follow-up must be in the original's currency", ErrInvalidQuery)
+    case req.Money.Minor <= 0 || req.Money.Minor > orig.AmountMinor:
+        return "", "", fmt.Errorf("%w: a follow-up must return a positive amount up to the original's", ErrInvalidQuery)
+    }
+    return rel, originalID, nil
+}
+
+// statusChangeReturn is the projection statusChangeFromRecord reads, with
+// the change bound to s.
+const statusChangeReturn = `
+             RETURN coalesce(s.from, ''), s.to, coalesce(s.reason, ''), s.at
+             ORDER BY s.at, s.seq`
+
+func statusChangeFromRecord(values []any) models.StatusChange {
+    return models.StatusChange{
+        From:   values[0].(string),
+        To:     values[1].(string),
+        Reason: values[2].(string),
+        At:     storedTime(values[3]),
+    }
+}
+
+// readTransactionStatus reads a transaction's status and history.
+func readTransactionStatus(ctx context.Context, tx neo4j.ManagedTransaction, id string) (models.TransactionStatusResponse, error) {
+    resp := models.TransactionStatusResponse{TransactionID: id, History: []models.StatusChange{}}
+    rs, err := tx.Run(ctx,
+        `MATCH (t:Transaction) WHERE t.id = $id
+         RETURN coalesce(t.status, $legacy)`,
+        map[string]any{"id": id, "legacy": legacyStatus},
+    )
+    if err != nil {
+        return resp, err
+    }
+    if !rs.Next(ctx) {
+        if err := rs.Err(); err != nil {
+            return resp, err
+        }
+        return resp, ErrTransactionNotFound
+    }
+    resp.Status = rs.Record().Values[0].(string)
+
+    rs, err = tx.Run(ctx,
+        `MATCH (t:Transaction)-[:HAS_STATUS_CHANGE]->(s:StatusChange)
+         WHERE t.id = $id`+statusChangeReturn,
+        map[string]any{"id": id},
+    )
+    if err != nil {
+        return resp, err
+    }
+    for rs.Next(ctx) {
+        resp.History = append(resp.History, statusChangeFromRecord(rs.Record().Values))
+    }
+    return resp, rs.Err()
+}
+
+// recordStatusQuery moves the transaction $id to $to and appends the change
+// to its history. seq orders changes recorded within the same instant.
+const recordStatusQuery = `
+             MATCH (t:Transaction) WHERE t.id = $id
+             OPTIONAL MATCH (t)-[:HAS_STATUS_CHANGE]->(prev:StatusChange)
+             WITH t, count(prev) AS seq
+             CREATE (t)-[:HAS_STATUS_CHANGE]->(:StatusChange {
+               id:     randomUUID(),
+               from:   $from,
+               to:     $to,
+               reason: $reason,
+               at:     datetime($at),
+               seq:    seq
+             })
+             SET t.status = $to`
+
+// setStatus applies a validated transition inside a write transaction.
+func setStatus(ctx context.Context, tx neo4j.ManagedTransaction, id string, req models.StatusRequest) error {
+    cur, err := readTransactionStatus(ctx, tx, id)
+    if err != nil {
+        return err
+    }
+    if err := checkTransition(cur.Status, req.Status); err != nil {
+        return err
+    }
+    _, err = tx.Run(ctx, recordStatusQuery, map[string]any{
+        "id":     id,
+        "from":   cur.Status,
+        "to":     req.Status,
+        "reason": req.Reason,
+        "at":     time.Now().UTC().Format(time.RFC3339Nano),
+    })
+    return err
+}
+
+// TransactionStatus returns a transaction's status and history.
+func (d *Driver) TransactionStatus(id string) (models.TransactionStatusResponse, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        return readTransactionStatus(ctx, tx, id)
+    })
+    if err != nil {
+        return models.TransactionStatusResponse{}, err
+    }
+    return raw.(models.TransactionStatusResponse), nil
+}
+
+// SetTransactionStatus moves a transaction to a new status. Transitions
+// follow transactionTransitions; a disallowed one fails with
+// ErrInvalidTransition.
+func (d *Driver) SetTransactionStatus(id string, req models.StatusRequest) (models.TransactionStatusResponse, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        if err := setStatus(ctx, tx, id, req); err != nil {
+            return nil, err
+        }
+        return readTransactionStatus(ctx, tx, id)
+    })
+    if err != nil {
+        return models.TransactionStatusResponse{}, err
+    }
+    return raw.(models.TransactionStatusResponse), nil
+}
+
+// LinkFollowUp links a follow-up transaction to its original with rel
+// (REVERSAL_OF or CHARGEBACK_OF) and moves the original to reversed or
+// charged_back.
+func (d *Driver) LinkFollowUp(followUpID, originalID, rel string) error {
+    to, ok := followUpStatus[rel]
+    if !ok {
+        return fmt.Errorf("%w: unknown follow-up relationship %s", ErrInvalidQuery, rel)
+    }
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        if err := setStatus(ctx, tx, originalID, models.StatusRequest{
+            Status: to,
+            Reason: strings.ToLower(strings.TrimSuffix(rel, "_OF")) + " " + followUpID,
+        }); err != nil {
+            return nil, err
+        }
+        rs, err := tx.Run(ctx,
+            `MATCH (f:Transaction), (o:Transaction)
+             WHERE f.id = $followUp AND o.id = $original
+             MERGE (f)-[:`+rel+`]->(o)
+             RETURN f.id`,
+            map[string]any{"followUp": followUpID, "original": originalID},
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rs.Next(ctx) {
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrTransactionNotFound
+        }
+        return nil, nil
+    })
+    return err
+}
+
+// memStatusChange is a history entry of the memory store. It keeps an ID
+// so exports carry StatusChange nodes like the Neo4j store's.
+type memStatusChange struct {
+    id string
+    models.StatusChange
+}
+
+// TransactionStatus returns a transaction's status and history.
+func (m *MemoryStore) TransactionStatus(id string) (models.TransactionStatusResponse, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    t, ok := m.txs[id]
+    if !ok {
+        return models.TransactionStatusResponse{}, ErrTransactionNotFound
+    }
+    return m.statusResponse(t), nil
+}
+
+// statusResponse copies a transaction's history. Callers must hold the
+// lock.
+func (m *MemoryStore) statusResponse(t models.Transaction) models.TransactionStatusResponse {
+    history := []models.StatusChange{}
+    for _, c := range m.history[t.ID] {
+        history = append(history, c.StatusChange)
+    }
+    return models.TransactionStatusResponse{TransactionID: t.ID, Status: t.Status, History: history}
+}
+
+// setStatus applies a validated transition. Callers must hold the write
+// lock.
+func (m *MemoryStore) setStatus(id string, req models.StatusRequest) error {
+    t, ok := m.txs[id]
+    if !ok {
+        return ErrTransactionNotFound
+    }
+    if err := checkTransition(t.Status, req.Status); err != nil {
+        return err
+    }
+    m.history[id] = append(m.history[id], memStatusChange{newID(), models.StatusChange{
+        From:   t.Status,
+        To:     req.Status,
+        Reason: req.Reason,
+        At:     time.Now().UTC().Format(time.RFC3339Nano),
+    }})
+    t.Status = req.Status
+    m.txs[id] = t
+    return nil
+}
+
+// SetTransactionStatus moves a transaction to a new status.
+func (m *MemoryStore) SetTransactionStatus(id string, req models.StatusRequest) (models.TransactionStatusResponse, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if err := m.setStatus(id, req); err != nil {
+        return models.TransactionStatusResponse{}, err
+    }
+    return m.statusResponse(m.txs[id]), nil
+}
+
+// LinkFollowUp links a follow-up transaction to its original and moves the
+// original to reversed or charged_back.
+func (m *MemoryStore) LinkFollowUp(followUpID, originalID, rel string) error {
+    to, ok := followUpStatus[rel]
+    if !ok {
+        return fmt.Errorf("%w: unknown follow-up relationship %s", ErrInvalidQuery, rel)
+    }
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.txs[followUpID]; !ok {
+        return ErrTransactionNotFound
+    }
+    if err := m.setStatus(originalID, models.StatusRequest{
+        Status: to,
+        Reason: strings.ToLower(strings.TrimSuffix(rel, "_OF")) + " " + followUpID,
+    }); err != nil {
+        return err
+    }
+    m.followUps = append(m.followUps, memRel{src: followUpID, dst: originalID, typ: rel})
+    return nil
+}
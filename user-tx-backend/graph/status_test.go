@@ -0,0 +1,112 @@
+package graph
+
+import (
+    "errors"
+    "testing"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+func TestTransactionStatusTransitions(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+
+    // reach lists the transitions that take a pending transaction to each
+    // status.
+    reach := map[string][]string{
+        "pending":      nil,
+        "settled":      {"settled"},
+        "failed":       {"failed"},
+        "reversed":     {"settled", "reversed"},
+        "charged_back": {"settled", "charged_back"},
+    }
+    for from, path := range reach {
+        for to := range reach {
+            if to == from {
+                continue
+            }
+            ts := testStart.Format(time.RFC3339)
+            money, err := s.conv.Money(10, "USD", ts)
+            if err != nil {
+                t.Fatal(err)
+            }
+            id, err := s.CreateTransaction(alice, bob, money, ts, "", "", "", "pending")
+            if err != nil {
+                t.Fatal(err)
+            }
+            for _, status := range path {
+                if _, err := s.SetTransactionStatus(id, models.StatusRequest{Status: status}); err != nil {
+                    t.Fatalf("%s: %v", status, err)
+                }
+            }
+            _, err = s.SetTransactionStatus(id, models.StatusRequest{Status: to, Reason: "test"})
+            if hasString(transactionTransitions[from], to) {
+                if err != nil {
+                    t.Errorf("%s to %s: %v", from, to, err)
+                }
+                continue
+            }
+            if !errors.Is(err, ErrInvalidTransition) {
+                t.Errorf("%s to %s: error = %v, want ErrInvalidTransition", from, to, err)
+            }
+            st, err := s.TransactionStatus(id)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if st.Status != from || len(st.History) != len(path)+1 {
+                t.Errorf("%s to %s: status = %+v, want it left at %s", from, to, st, from)
+            }
+        }
+    }
+
+    id := s.tx(alice, bob, 10, "", "")
+    if _, err := s.SetTransactionStatus(id, models.StatusRequest{Status: "refunded"}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("unknown status: error = %v, want ErrInvalidQuery", err)
+    }
+    if _, err := InitialStatus("reversed"); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("InitialStatus(reversed) error = %v, want ErrInvalidQuery", err)
+    }
+}
+
+func TestFollowUpReversesOnce(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    orig := s.tx(alice, bob, 10, "", "")
+
+    req := func(from, to string, amount float64) models.TransactionRequest {
+        money, err := s.conv.Money(amount, "USD", testStart.Format(time.RFC3339))
+        if err != nil {
+            t.Fatal(err)
+        }
+        return models.TransactionRequest{FromUserID: from, ToUserID: to, Currency: "USD", Money: money, ReversalOf: orig}
+    }
+    for name, r := range map[string]models.TransactionRequest{
+        "wrong direction": req(alice, bob, 10),
+        "over the amount": req(bob, alice, 11),
+    } {
+        if _, _, err := CheckFollowUp(s, r); !errors.Is(err, ErrInvalidQuery) {
+            t.Errorf("%s: error = %v, want ErrInvalidQuery", name, err)
+        }
+    }
+    rel, original, err := CheckFollowUp(s, req(bob, alice, 10))
+    if err != nil || rel != "REVERSAL_OF" || original != orig {
+        t.Fatalf("CheckFollowUp = %s %s, %v; want REVERSAL_OF %s", rel, original, err, orig)
+    }
+    reversal := s.tx(bob, alice, 10, "", "")
+    if err := s.LinkFollowUp(reversal, orig, rel); err != nil {
+        t.Fatal(err)
+    }
+    st, err := s.TransactionStatus(orig)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if st.Status != "reversed" || len(st.History) != 2 || st.History[1].Reason != "reversal "+reversal {
+        t.Errorf("original status = %+v, want reversed by %s", st, reversal)
+    }
+    if _, _, err := CheckFollowUp(s, req(bob, alice, 10)); !errors.Is(err, ErrInvalidTransition) {
+        t.Errorf("second reversal: error = %v, want ErrInvalidTransition", err)
+    }
+}
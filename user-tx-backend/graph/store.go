@@ -0,0 +1,87 @@
+package graph
+
+import (
+    "context"
+    "errors"
+
+    "user-tx-backend/models"
+)
+
+// Errors shared by every GraphStore implementation.
+var (
+    ErrUserNotFound        = errors.New("user not found")
+    ErrTransactionNotFound = errors.New("transaction not found")
+    ErrNoPath              = errors.New("no path found")
+    ErrUserHasTransactions = errors.New("user still has transactions")
+    ErrInvalidQuery        = errors.New("invalid query")
+    ErrAlertNotFound       = errors.New("alert not found")
+    ErrCaseNotFound        = errors.New("case not found")
+    ErrInvalidTransition   = errors.New("invalid status transition")
+    ErrPersonNotFound      = errors.New("person not found")
+    ErrClusterNotFound     = errors.New("cluster not found")
+)
+
+// GraphStore is the storage contract the HTTP handlers depend on.
+// *Driver implements it on top of Neo4j and *MemoryStore keeps the
+// whole graph in process memory.
+type GraphStore interface {
+    CreateUser(name, email, phone string) (string, error)
+    CreateTransaction(
+        fromID, toID string,
+        amount models.Money,
+        timestamp, description, deviceId, ip, status string,
+    ) (string, error)
+    UpdateUser(id string, patch models.UserPatch) (models.User, error)
+    DeleteUser(id string, cascade bool) error
+    UpdateTransaction(id string, patch models.TransactionPatch) (models.Transaction, error)
+    SetTransactionMoney(amounts map[string]models.Money) error
+    DeleteTransaction(id string) error
+    TransactionStatus(id string) (models.TransactionStatusResponse, error)
+    SetTransactionStatus(id string, req models.StatusRequest) (models.TransactionStatusResponse, error)
+    LinkFollowUp(followUpID, originalID, rel string) error
+    ImportUsers(rows []models.UserRequest) ([]models.ImportRowResult, error)
+    ImportTransactions(rows []models.TransactionRequest) ([]models.ImportRowResult, error)
+    GetAllUsers() ([]models.User, error)
+    GetAllTransactions() ([]models.Transaction, error)
+    GetTransaction(id string) (models.Transaction, error)
+    ListUsers(q models.UserQuery) (models.Page[models.User], error)
+    ListTransactions(q models.TransactionQuery) (models.Page[models.Transaction], error)
+    GetUserRelationships(userID string) (models.User, models.UserConnections, error)
+    GetTransactionRelationships(txID string) (models.Transaction, models.TxConnections, error)
+    ShortestPathSegments(fromID, toID string) ([]models.PathSegment, error)
+    ClusterTransactions(excludeStatuses []string) ([]models.TransactionCluster, error)
+    RebuildClusters(check bool) (models.ClusterRebuildReport, error)
+    ClusterGraph(clusterID string) (models.GraphExportResponse, error)
+    ExportGraph() (models.GraphExportResponse, error)
+    StreamGraph(ctx context.Context, out GraphWriter) error
+    RestoreGraph(doc models.GraphExportResponse, opts models.RestoreOptions) (models.RestoreReport, error)
+    History(w Window) (*History, error)
+    Snapshot(f SnapshotFilter) (*Snapshot, error)
+    WriteUserScores(algorithm string, scores map[string]float64) error
+    WriteTransactionScores(name string, scores map[string]float64) error
+    SetWatchlistHits(byUser map[string][]models.WatchlistHit) error
+    CreateAlerts(alerts []models.Alert) ([]models.Alert, error)
+    TransactionAlerts(txID string) ([]models.Alert, error)
+    GetAlert(id string) (models.Alert, error)
+    ListAlerts(q models.AlertQuery) ([]models.Alert, error)
+    UpdateAlert(id string, patch models.AlertPatch) (models.Alert, error)
+    DeleteAlert(id string) error
+    CreateCase(req models.CaseRequest) (models.Case, error)
+    GetCase(id string) (models.Case, error)
+    ListCases(q models.CaseQuery) ([]models.Case, error)
+    UpdateCase(id string, patch models.CasePatch) (models.Case, error)
+    DeleteCase(id string) error
+    AddCaseNote(caseID string, note models.CaseNote) (models.CaseNote, error)
+    AddCaseEvidence(caseID string, ev models.CaseEvidence) (models.CaseEvidence, error)
+    IdentityLinks() ([]models.IdentityLink, error)
+    SetIdentityLinks(userIDs []string, links []models.IdentityLink) error
+    CreatePerson(req models.PersonRequest) (models.Person, error)
+    GetPerson(id string) (models.Person, error)
+    ListPersons() ([]models.Person, error)
+    DeletePerson(id string) error
+}
+
+var (
+    _ GraphStore = (*Driver)(nil)
+    _ GraphStore = (*MemoryStore)(nil)
+)
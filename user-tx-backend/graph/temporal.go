@@ -0,0 +1,824 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// Window selects the part of the graph's history a temporal query sees:
+// the graph as it was at AsOf, or everything that existed at some time in
+// [From, To). Open bounds are zero; the zero Window is the current graph.
+type Window struct {
+    AsOf time.Time
+    From time.Time // inclusive
+    To   time.Time // exclusive
+}
+
+// ParseWindow reads the asOf, from and to query parameters (RFC3339).
+// asOf cannot be combined with from or to.
+func ParseWindow(asOf, from, to string) (Window, error) {
+    var w Window
+    var err error
+    if w.From, w.To, err = parseWindow(from, to); err != nil {
+        return w, err
+    }
+    if !w.From.IsZero() && !w.To.IsZero() && !w.From.Before(w.To) {
+        return w, fmt.Errorf("%w: from must be before to", ErrInvalidQuery)
+    }
+    if asOf == "" {
+        return w, nil
+    }
+    if from != "" || to != "" {
+        return w, fmt.Errorf("%w: asOf cannot be combined with from or to", ErrInvalidQuery)
+    }
+    if w.AsOf, err = time.Parse(time.RFC3339Nano, asOf); err != nil {
+        return w, fmt.Errorf("%w: invalid asOf %q", ErrInvalidQuery, asOf)
+    }
+    return w, nil
+}
+
+// IsZero reports whether w is the current graph.
+func (w Window) IsZero() bool {
+    return w.AsOf.IsZero() && w.From.IsZero() && w.To.IsZero()
+}
+
+// bounds returns w as a half-open interval, AsOf as the instant itself.
+func (w Window) bounds() (time.Time, time.Time) {
+    if !w.AsOf.IsZero() {
+        return w.AsOf, w.AsOf.Add(time.Nanosecond)
+    }
+    return w.From, w.To
+}
+
+// params returns the bounds of w as the $from and $to parameters of the
+// inWindow predicates, null when open.
+func (w Window) params() map[string]any {
+    params := map[string]any{"from": nil, "to": nil, "legacy": legacyStatus}
+    from, to := w.bounds()
+    if !from.IsZero() {
+        params["from"] = from.UTC().Format(time.RFC3339Nano)
+    }
+    if !to.IsZero() {
+        params["to"] = to.UTC().Format(time.RFC3339Nano)
+    }
+    return params
+}
+
+// inWindow is the Cypher counterpart of Lifetime.in: whether the lifetime
+// given by the created and deleted expressions overlaps [$from, $to). An
+// empty deleted is a lifetime that has not ended.
+func inWindow(created, deleted string) string {
+    pred := fmt.Sprintf(`($to IS NULL OR %[1]s IS NULL OR %[1]s < datetime($to))`, created)
+    if deleted != "" {
+        pred += fmt.Sprintf(` AND ($from IS NULL OR %[1]s IS NULL OR %[1]s > datetime($from))`, deleted)
+    }
+    return pred
+}
+
+// Lifetime is when a node or link was recorded and, once it is gone, when
+// it was deleted. A zero Created is unknown: data stored before lifetimes
+// were recorded counts as always there.
+type Lifetime struct {
+    Created time.Time
+    Deleted time.Time
+}
+
+// in reports whether the lifetime overlaps the window.
+func (l Lifetime) in(w Window) bool {
+    from, to := w.bounds()
+    if !to.IsZero() && !l.Created.IsZero() && !l.Created.Before(to) {
+        return false
+    }
+    if !from.IsZero() && !l.Deleted.IsZero() && !l.Deleted.After(from) {
+        return false
+    }
+    return true
+}
+
+// storedInstant reads a stored datetime: a time.Time from Neo4j or an
+// RFC3339 string from an export document. A missing value is zero.
+func storedInstant(v any) (time.Time, error) {
+    switch t := v.(type) {
+    case nil:
+        return time.Time{}, nil
+    case time.Time:
+        return t.UTC(), nil
+    case string:
+        if t == "" {
+            return time.Time{}, nil
+        }
+        parsed, err := time.Parse(time.RFC3339Nano, t)
+        if err != nil {
+            return time.Time{}, fmt.Errorf("invalid stored time %q: %w", t, err)
+        }
+        return parsed.UTC(), nil
+    }
+    return time.Time{}, fmt.Errorf("invalid stored time %v", v)
+}
+
+// storedLifetime reads the createdAt and deletedAt of a node or link.
+func storedLifetime(created, deleted any) (Lifetime, error) {
+    var l Lifetime
+    var err error
+    if l.Created, err = storedInstant(created); err != nil {
+        return l, err
+    }
+    l.Deleted, err = storedInstant(deleted)
+    return l, err
+}
+
+type timedUser struct {
+    models.User
+    Lifetime
+}
+
+type timedTransaction struct {
+    models.Transaction
+    Lifetime
+    cluster string
+}
+
+// timedLink is a link from a user or transaction to an identifier node.
+type timedLink struct {
+    owner, hub, rel string
+    Lifetime
+}
+
+// revision is the values a user or transaction had until it was edited
+// at ended.
+type revision struct {
+    user  models.User
+    tx    models.Transaction
+    ended time.Time
+}
+
+// History is what a store recorded for a window: the current and deleted
+// users and transactions and the identifier links whose lifetimes overlap
+// it, the status changes and follow-up links of those transactions, and
+// the values edited users and transactions had at its end. Deleted
+// entries come first, so for equal times the current one is the latest.
+type History struct {
+    users     []timedUser
+    txs       []timedTransaction
+    links     []timedLink
+    statuses  map[string][]memStatusChange
+    followUps []memRel
+    revisions map[string]revision // by ID, for edits after the window
+}
+
+// View returns the graph as it was in w, as a MemoryStore to run the read
+// methods against. h must have been recorded for w. Users and
+// transactions show the values and the status they had at the end of the
+// window; identifier links that ended earlier in the window are kept, so
+// shared links derived through them are too. Clusters are recomputed for
+// the view, keeping current cluster IDs where they fit.
+func (h *History) View(w Window) (*MemoryStore, error) {
+    _, end := w.bounds()
+    m := NewMemoryStore()
+
+    // The latest link per owner and kind gives the owner's value.
+    var visible []timedLink
+    latest := make(map[string]timedLink)
+    for _, l := range h.links {
+        if !l.in(w) {
+            continue
+        }
+        visible = append(visible, l)
+        key := l.owner + "|" + l.rel
+        if cur, ok := latest[key]; !ok || !l.Created.Before(cur.Created) {
+            latest[key] = l
+        }
+    }
+    value := func(id string, prop string) string {
+        for _, k := range hubKinds {
+            if k.prop != prop {
+                continue
+            }
+            if l, ok := latest[id+"|"+k.rel]; ok {
+                _, v, _ := hubByID(l.hub)
+                return v
+            }
+        }
+        return ""
+    }
+
+    for _, u := range h.users {
+        if !u.in(w) {
+            continue
+        }
+        if rev, ok := h.revisions[u.ID]; ok {
+            u.User = rev.user
+        }
+        u.Email, u.Phone = value(u.ID, "email"), value(u.ID, "phone")
+        if _, ok := m.users[u.ID]; !ok {
+            m.userIDs = append(m.userIDs, u.ID)
+        }
+        m.users[u.ID] = u.User
+        m.created[u.ID] = u.Created
+    }
+    for _, t := range h.txs {
+        if rev, ok := h.revisions[t.ID]; ok {
+            status := t.Status
+            t.Transaction = rev.tx
+            t.Status = status
+        }
+        _, sender := m.users[t.FromUserID]
+        _, receiver := m.users[t.ToUserID]
+        if !t.in(w) || !sender || !receiver {
+            continue
+        }
+        t.DeviceID, t.IP = value(t.ID, "deviceId"), value(t.ID, "ip")
+        if changes := h.statuses[t.ID]; len(changes) > 0 {
+            m.history[t.ID] = nil
+            for i, c := range changes {
+                at, err := time.Parse(time.RFC3339Nano, c.At)
+                if err != nil {
+                    return nil, fmt.Errorf("transaction %s: invalid status change time %q: %w", t.ID, c.At, err)
+                }
+                if i > 0 && !end.IsZero() && !at.Before(end) {
+                    break
+                }
+                t.Status = c.To
+                m.history[t.ID] = append(m.history[t.ID], c)
+            }
+        }
+        if _, ok := m.txs[t.ID]; !ok {
+            m.txIDs = append(m.txIDs, t.ID)
+        }
+        m.txs[t.ID] = t.Transaction
+        m.created[t.ID] = t.Created
+        if t.cluster != "" {
+            m.clusterOf[t.ID] = t.cluster
+        }
+    }
+
+    for _, l := range visible {
+        _, user := m.users[l.owner]
+        _, tx := m.txs[l.owner]
+        if (user || tx) && latest[l.owner+"|"+l.rel] != l {
+            m.earlier = append(m.earlier, memRel{src: l.owner, dst: l.hub, typ: l.rel})
+        }
+    }
+    for _, r := range h.followUps {
+        _, src := m.txs[r.src]
+        _, dst := m.txs[r.dst]
+        if src && dst {
+            m.followUps = append(m.followUps, r)
+        }
+    }
+    m.rebuildClusters(false, smallestID)
+    return m, nil
+}
+
+// View loads the history of store for w and returns the graph as it was
+// in w.
+func View(store GraphStore, w Window) (GraphStore, error) {
+    h, err := store.History(w)
+    if err != nil {
+        return nil, err
+    }
+    return h.View(w)
+}
+
+// historyLabels are the labels of the detached copies History reads
+// deleted users, transactions and identifier links and the values of
+// edited users and transactions from. Nothing else matches them, and
+// exports leave them out.
+var historyLabels = []string{"DeletedUser", "DeletedTransaction", "ExpiredLink", "Revision"}
+
+// expireLinksQuery records the identifier links of the owners in $ids as
+// ExpiredLink nodes, before the owners are deleted.
+func expireLinksQuery(owner string) string {
+    return `UNWIND $ids AS id
+             MATCH (n:` + owner + `)-[r:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->(h)
+             WHERE n.id = id
+             CREATE (:ExpiredLink {
+               id: randomUUID(), owner: n.id, rel: type(r), hub: h.id,
+               createdAt: r.createdAt, deletedAt: datetime()
+             })`
+}
+
+// archiveQueries copy the users or transactions in $ids to DeletedUser or
+// DeletedTransaction nodes; a transaction keeps its endpoints as
+// properties.
+var archiveQueries = map[string]string{
+    "User": `UNWIND $ids AS id
+             MATCH (n:User) WHERE n.id = id
+             CREATE (d:DeletedUser)
+             SET d = properties(n), d.deletedAt = datetime()`,
+    "Transaction": `UNWIND $ids AS id
+             MATCH (n:Transaction) WHERE n.id = id
+             OPTIONAL MATCH (u1:User)-[:SENT]->(n)
+             OPTIONAL MATCH (n)-[:RECEIVED_BY]->(u2:User)
+             CREATE (d:DeletedTransaction)
+             SET d = properties(n), d.fromUserId = u1.id, d.toUserId = u2.id,
+                 d.deletedAt = datetime()`,
+}
+
+// archive keeps the history of users or transactions about to be deleted.
+func archive(ctx context.Context, tx neo4j.ManagedTransaction, owner string, ids []string) error {
+    params := map[string]any{"ids": ids}
+    if _, err := tx.Run(ctx, expireLinksQuery(owner), params); err != nil {
+        return fmt.Errorf("failed to expire links: %w", err)
+    }
+    if _, err := tx.Run(ctx, archiveQueries[owner], params); err != nil {
+        return fmt.Errorf("failed to archive %s: %w", owner, err)
+    }
+    return nil
+}
+
+// reviseQueries copy the user or transaction $id to a Revision node
+// before an update, so windows ending before it see the values it had.
+var reviseQueries = map[string]string{
+    "User": `MATCH (n:User) WHERE n.id = $id
+             CREATE (r:Revision)
+             SET r = properties(n), r.id = randomUUID(), r.owner = n.id,
+                 r.kind = 'User', r.validTo = datetime()`,
+    "Transaction": `MATCH (n:Transaction) WHERE n.id = $id
+             OPTIONAL MATCH (u1:User)-[:SENT]->(n)
+             OPTIONAL MATCH (n)-[:RECEIVED_BY]->(u2:User)
+             CREATE (r:Revision)
+             SET r = properties(n), r.id = randomUUID(), r.owner = n.id,
+                 r.kind = 'Transaction', r.fromUserId = u1.id, r.toUserId = u2.id,
+                 r.validTo = datetime()`,
+}
+
+// revise keeps the values of a user or transaction about to be updated.
+func revise(ctx context.Context, tx neo4j.ManagedTransaction, owner, id string) error {
+    if _, err := tx.Run(ctx, reviseQueries[owner], map[string]any{"id": id}); err != nil {
+        return fmt.Errorf("failed to keep the previous %s: %w", owner, err)
+    }
+    return nil
+}
+
+// alignLinksQuery dates the identifier links of the new owners in $ids
+// from their owner's createdAt, which backdating relies on to find them.
+func alignLinksQuery(owner string) string {
+    return `UNWIND $ids AS id
+             MATCH (n:` + owner + `)-[r:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->()
+             WHERE n.id = id
+             SET r.createdAt = n.createdAt`
+}
+
+// backdateQueries move the start of the new transactions in $ids back to
+// their timestamp when that is earlier than when they were stored, and
+// the start of their senders and receivers back to the earliest of them.
+// The identifier links an owner got when it was stored move with it. An
+// imported ledger so appears at the times it records, not when it was
+// imported.
+var backdateQueries = []string{
+    `UNWIND $ids AS id
+     MATCH (t:Transaction) WHERE t.id = id AND t.timestamp < t.createdAt
+     OPTIONAL MATCH (t)-[r:USED_DEVICE|FROM_IP]->()
+     WHERE r.createdAt = t.createdAt
+     SET r.createdAt = t.timestamp
+     WITH DISTINCT t
+     SET t.createdAt = t.timestamp`,
+    `UNWIND $ids AS id
+     MATCH (u:User)-[:SENT|RECEIVED_BY]-(t:Transaction)
+     WHERE t.id = id
+     WITH u, min(t.createdAt) AS first
+     WHERE first < u.createdAt
+     OPTIONAL MATCH (u)-[r:HAS_EMAIL|HAS_PHONE]->()
+     WHERE r.createdAt = u.createdAt
+     SET r.createdAt = first
+     WITH DISTINCT u, first
+     OPTIONAL MATCH (x:ExpiredLink)
+     WHERE x.owner = u.id AND x.createdAt = u.createdAt
+     SET x.createdAt = first
+     WITH DISTINCT u, first
+     SET u.createdAt = first`,
+}
+
+// backdate runs backdateQueries for the new transactions in ids.
+func backdate(ctx context.Context, tx neo4j.ManagedTransaction, ids []string) error {
+    for _, q := range backdateQueries {
+        if _, err := tx.Run(ctx, q, map[string]any{"ids": ids}); err != nil {
+            return fmt.Errorf("failed to backdate: %w", err)
+        }
+    }
+    return nil
+}
+
+// historyTxColumns are the columns History reads a transaction from, after
+// its ID and endpoints.
+func historyTxColumns(v string) string {
+    return fmt.Sprintf(`%[1]s.amount AS amount, %[1]s.currency AS currency, %[1]s.timestamp AS timestamp,
+                    %[1]s.description AS description, %[1]s.deviceId AS deviceId,
+                    coalesce(%[1]s.ip, '') AS ip, coalesce(%[1]s.status, $legacy) AS status,
+                    `, v) + moneyColumns(v)
+}
+
+// historyTransaction reads the columns of historyTxColumns, which follow
+// the ID and endpoints in values.
+func historyTransaction(values []any) models.Transaction {
+    t := models.Transaction{
+        ID:          values[0].(string),
+        FromUserID:  values[1].(string),
+        ToUserID:    values[2].(string),
+        Amount:      values[3].(float64),
+        Currency:    values[4].(string),
+        Timestamp:   storedTime(values[5]),
+        Description: values[6].(string),
+        DeviceID:    values[7].(string),
+        IP:          values[8].(string),
+        Status:      values[9].(string),
+    }
+    setMoney(&t, values[10:14])
+    return t
+}
+
+// History loads what was recorded for w, with the window bounds applied
+// in the queries: current and deleted users and transactions and the
+// identifier links overlapping it, the status changes and follow-up links
+// of its transactions and, for a window with an end, the Revision holding
+// each edited user's and transaction's values at that end.
+func (d *Driver) History(w Window) (*History, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeRead})
+    defer session.Close(ctx)
+
+    params := w.params()
+    txLifetime := inWindow("coalesce(t.createdAt, t.timestamp)", "t.deletedAt")
+    h := &History{}
+    _, err := session.ExecuteRead(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        h = &History{
+            statuses:  make(map[string][]memStatusChange),
+            revisions: make(map[string]revision),
+        }
+
+        // 1) Users, deleted ones first
+        rs, err := tx.Run(ctx,
+            `MATCH (u:DeletedUser) WHERE `+inWindow("u.createdAt", "u.deletedAt")+`
+             RETURN u.id AS id, coalesce(u.name, '') AS name, coalesce(u.email, '') AS email,
+                    coalesce(u.phone, '') AS phone, u.createdAt AS createdAt, u.deletedAt AS deletedAt
+             ORDER BY u.deletedAt
+             UNION ALL
+             MATCH (u:User) WHERE `+inWindow("u.createdAt", "")+`
+             RETURN u.id AS id, coalesce(u.name, '') AS name, coalesce(u.email, '') AS email,
+                    coalesce(u.phone, '') AS phone, u.createdAt AS createdAt, null AS deletedAt`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            l, err := storedLifetime(r.Values[4], r.Values[5])
+            if err != nil {
+                return nil, fmt.Errorf("user %v: %w", r.Values[0], err)
+            }
+            h.users = append(h.users, timedUser{
+                User: models.User{
+                    ID:    r.Values[0].(string),
+                    Name:  r.Values[1].(string),
+                    Email: r.Values[2].(string),
+                    Phone: r.Values[3].(string),
+                },
+                Lifetime: l,
+            })
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 2) Transactions, deleted ones first
+        rs, err = tx.Run(ctx,
+            `MATCH (t:DeletedTransaction) WHERE `+txLifetime+`
+             RETURN t.id AS id, coalesce(t.fromUserId, '') AS fromUserId, coalesce(t.toUserId, '') AS toUserId,
+                    `+historyTxColumns("t")+`,
+                    t.createdAt AS createdAt, t.deletedAt AS deletedAt, coalesce(t.clusterId, '') AS clusterId
+             ORDER BY t.deletedAt
+             UNION ALL
+             MATCH (u1:User)-[:SENT]->(t:Transaction)-[:RECEIVED_BY]->(u2:User)
+             WHERE `+txLifetime+`
+             RETURN t.id AS id, u1.id AS fromUserId, u2.id AS toUserId,
+                    `+historyTxColumns("t")+`,
+                    t.createdAt AS createdAt, null AS deletedAt, coalesce(t.clusterId, '') AS clusterId`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            t := historyTransaction(r.Values)
+            l, err := storedLifetime(r.Values[14], r.Values[15])
+            if err != nil {
+                return nil, fmt.Errorf("transaction %s: %w", t.ID, err)
+            }
+            if l.Created.IsZero() {
+                // Transactions stored before createdAt existed appear at their timestamp.
+                if l.Created, err = storedInstant(r.Values[5]); err != nil {
+                    return nil, fmt.Errorf("transaction %s: %w", t.ID, err)
+                }
+            }
+            h.txs = append(h.txs, timedTransaction{
+                Transaction: t,
+                Lifetime:    l,
+                cluster:     r.Values[16].(string),
+            })
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 3) Identifier links, ended ones first
+        rs, err = tx.Run(ctx,
+            `MATCH (x:ExpiredLink) WHERE `+inWindow("x.createdAt", "x.deletedAt")+`
+             RETURN x.owner AS owner, x.rel AS rel, x.hub AS hub,
+                    x.createdAt AS createdAt, x.deletedAt AS deletedAt
+             ORDER BY x.deletedAt
+             UNION ALL
+             MATCH (n)-[r:HAS_EMAIL|HAS_PHONE|USED_DEVICE|FROM_IP]->(h)
+             WHERE (n:User OR n:Transaction) AND `+inWindow("r.createdAt", "")+`
+             RETURN n.id AS owner, type(r) AS rel, h.id AS hub,
+                    r.createdAt AS createdAt, null AS deletedAt`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            l, err := storedLifetime(r.Values[3], r.Values[4])
+            if err != nil {
+                return nil, fmt.Errorf("link of %v: %w", r.Values[0], err)
+            }
+            h.links = append(h.links, timedLink{
+                owner:    r.Values[0].(string),
+                rel:      r.Values[1].(string),
+                hub:      r.Values[2].(string),
+                Lifetime: l,
+            })
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 4) Status changes of the window's transactions, oldest first
+        rs, err = tx.Run(ctx,
+            `MATCH (t:Transaction)-[:HAS_STATUS_CHANGE]->(s:StatusChange)
+             WHERE `+inWindow("coalesce(t.createdAt, t.timestamp)", "")+`
+             RETURN t.id, s.id, coalesce(s.from, ''), s.to, coalesce(s.reason, ''), s.at
+             ORDER BY t.id, s.at, s.seq`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            txID := r.Values[0].(string)
+            h.statuses[txID] = append(h.statuses[txID], memStatusChange{r.Values[1].(string), models.StatusChange{
+                From:   r.Values[2].(string),
+                To:     r.Values[3].(string),
+                Reason: r.Values[4].(string),
+                At:     storedTime(r.Values[5]),
+            }})
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+
+        // 5) Follow-up links between the window's transactions
+        rs, err = tx.Run(ctx,
+            `MATCH (a:Transaction)-[r:REVERSAL_OF|CHARGEBACK_OF]->(b:Transaction)
+             WHERE `+inWindow("coalesce(a.createdAt, a.timestamp)", "")+`
+               AND `+inWindow("coalesce(b.createdAt, b.timestamp)", "")+`
+             RETURN a.id, b.id, type(r)`,
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            h.followUps = append(h.followUps, memRel{src: r.Values[0].(string), dst: r.Values[1].(string), typ: r.Values[2].(string)})
+        }
+        if err := rs.Err(); err != nil {
+            return nil, err
+        }
+        if params["to"] == nil {
+            return nil, nil
+        }
+
+        // 6) The earliest revision ending at or after the window's end,
+        // per user and transaction
+        rs, err = tx.Run(ctx,
+            `MATCH (r:Revision)
+             WHERE r.validTo >= datetime($to) AND `+inWindow("r.createdAt", "")+`
+             WITH r ORDER BY r.validTo
+             WITH r.owner AS owner, collect(r)[0] AS r
+             RETURN r.kind, owner, coalesce(r.fromUserId, ''), coalesce(r.toUserId, ''), coalesce(r.name, ''),
+                    `+historyTxColumns("r"),
+            params,
+        )
+        if err != nil {
+            return nil, err
+        }
+        for rs.Next(ctx) {
+            r := rs.Record()
+            id := r.Values[1].(string)
+            if r.Values[0] == "User" {
+                h.revisions[id] = revision{user: models.User{ID: id, Name: r.Values[4].(string)}}
+                continue
+            }
+            values := append([]any{id, r.Values[2], r.Values[3]}, r.Values[5:]...)
+            h.revisions[id] = revision{tx: historyTransaction(values)}
+        }
+        return nil, rs.Err()
+    })
+    if err != nil {
+        return nil, err
+    }
+    return h, nil
+}
+
+// History copies what was recorded for w: current and deleted users and
+// transactions and identifier links overlapping it, the status changes
+// and follow-up links of its transactions and the values edited users and
+// transactions had at its end.
+func (m *MemoryStore) History(w Window) (*History, error) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+
+    h := &History{
+        statuses:  make(map[string][]memStatusChange),
+        revisions: make(map[string]revision),
+    }
+    for _, u := range m.deletedUsers {
+        if u.in(w) {
+            h.users = append(h.users, u)
+        }
+    }
+    for _, id := range m.userIDs {
+        if u := (timedUser{User: m.users[id], Lifetime: Lifetime{Created: m.created[id]}}); u.in(w) {
+            h.users = append(h.users, u)
+        }
+    }
+    for _, t := range m.deletedTxs {
+        if t.in(w) {
+            h.txs = append(h.txs, t)
+        }
+    }
+    kept := make(map[string]bool)
+    for _, id := range m.txIDs {
+        t := timedTransaction{Transaction: m.txs[id], Lifetime: Lifetime{Created: m.created[id]}, cluster: m.clusterOf[id]}
+        if !t.in(w) {
+            continue
+        }
+        h.txs = append(h.txs, t)
+        kept[id] = true
+        if changes := m.history[id]; len(changes) > 0 {
+            h.statuses[id] = append([]memStatusChange{}, changes...)
+        }
+    }
+    for _, l := range m.expired {
+        if l.in(w) {
+            h.links = append(h.links, l)
+        }
+    }
+    for _, k := range hubKinds {
+        groups := m.hubGroups(k)
+        for _, hid := range sortedKeys(groups) {
+            for _, id := range groups[hid] {
+                l := timedLink{owner: id, hub: hid, rel: k.rel, Lifetime: Lifetime{Created: m.linked[id+"|"+k.rel]}}
+                if l.in(w) {
+                    h.links = append(h.links, l)
+                }
+            }
+        }
+    }
+    for _, r := range m.followUps {
+        if kept[r.src] && kept[r.dst] {
+            h.followUps = append(h.followUps, r)
+        }
+    }
+    if _, end := w.bounds(); !end.IsZero() {
+        for id, revs := range m.revisions {
+            for _, r := range revs {
+                if !r.ended.Before(end) {
+                    h.revisions[id] = r
+                    break
+                }
+            }
+        }
+    }
+    return h, nil
+}
+
+// relink records the identifier links of a user or transaction that
+// change from the old values to the new ones: ended links are kept with
+// their lifetime and new ones start at now. Zero values create or delete.
+// Callers must hold the write lock.
+func (m *MemoryStore) relink(id, owner string, now time.Time, oldU, u models.User, oldT, t models.Transaction) {
+    for _, k := range hubKinds {
+        if k.owner != owner {
+            continue
+        }
+        before, after := hubValue(k, oldU, oldT), hubValue(k, u, t)
+        if before == after {
+            continue
+        }
+        key := id + "|" + k.rel
+        if before != "" {
+            m.expired = append(m.expired, timedLink{
+                owner:    id,
+                hub:      hubID(k, before),
+                rel:      k.rel,
+                Lifetime: Lifetime{Created: m.linked[key], Deleted: now},
+            })
+        }
+        delete(m.linked, key)
+        if after != "" {
+            m.linked[key] = now
+        }
+    }
+}
+
+// revise keeps the values of a user or transaction about to be edited at
+// now. Callers must hold the write lock.
+func (m *MemoryStore) revise(id string, now time.Time) {
+    m.revisions[id] = append(m.revisions[id], revision{user: m.users[id], tx: m.txs[id], ended: now})
+}
+
+// record starts the lifetime of a new user or transaction, with its
+// identifier links. Callers must hold the write lock.
+func (m *MemoryStore) record(id, owner string) {
+    now := time.Now().UTC()
+    m.created[id] = now
+    m.relink(id, owner, now, models.User{}, m.users[id], models.Transaction{}, m.txs[id])
+}
+
+// backdate moves the start of the new transaction id back to its
+// timestamp ts when that is earlier than when it was stored, and the start
+// of its sender and receiver with it, as backdateQueries do. Callers must
+// hold the write lock.
+func (m *MemoryStore) backdate(id string, ts time.Time) {
+    if !ts.Before(m.created[id]) {
+        return
+    }
+    m.moveStart(id, ts)
+    t := m.txs[id]
+    for _, uid := range []string{t.FromUserID, t.ToUserID} {
+        if ts.Before(m.created[uid]) {
+            m.moveStart(uid, ts)
+        }
+    }
+}
+
+// moveStart moves the start of a user or transaction back to at, with
+// the identifier links it got when it was stored. Callers must hold the
+// write lock.
+func (m *MemoryStore) moveStart(id string, at time.Time) {
+    created := m.created[id]
+    for _, k := range hubKinds {
+        key := id + "|" + k.rel
+        if c, ok := m.linked[key]; ok && c.Equal(created) {
+            m.linked[key] = at
+        }
+    }
+    for i, l := range m.expired {
+        if l.owner == id && l.Created.Equal(created) {
+            m.expired[i].Created = at
+        }
+    }
+    m.created[id] = at
+}
+
+// retire ends the lifetime of a user or transaction about to be deleted,
+// keeping a copy for temporal views. Callers must hold the write lock.
+func (m *MemoryStore) retire(id, owner string) {
+    now := time.Now().UTC()
+    u, t := m.users[id], m.txs[id]
+    m.relink(id, owner, now, u, models.User{}, t, models.Transaction{})
+    l := Lifetime{Created: m.created[id], Deleted: now}
+    if owner == "User" {
+        m.deletedUsers = append(m.deletedUsers, timedUser{u, l})
+    } else {
+        m.deletedTxs = append(m.deletedTxs, timedTransaction{t, l, m.clusterOf[id]})
+    }
+    delete(m.created, id)
+}
+
+// restoreLifetime starts the lifetime of a restored user or transaction
+// at its exported createdAt, or now, with its identifier links. Restores
+// are lenient, so an unreadable createdAt also starts now. Callers must
+// hold the write lock.
+func (m *MemoryStore) restoreLifetime(id, owner string, props map[string]any) {
+    created, err := storedInstant(props["createdAt"])
+    if err != nil || created.IsZero() {
+        created = time.Now().UTC()
+    }
+    m.created[id] = created
+    for _, k := range hubKinds {
+        if k.owner == owner && hubValue(k, m.users[id], m.txs[id]) != "" {
+            m.linked[id+"|"+k.rel] = created
+        }
+    }
+}
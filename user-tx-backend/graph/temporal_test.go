@@ -0,0 +1,127 @@
+package graph
+
+import (
+    "testing"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+// view returns the graph of s as it was in w.
+func (s *testStore) view(w Window) GraphStore {
+    s.t.Helper()
+    v, err := View(s, w)
+    if err != nil {
+        s.t.Fatalf("View(%+v): %v", w, err)
+    }
+    return v
+}
+
+func TestViewDatesTransactionsFromTimestamp(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    id := s.tx(alice, bob, 10, "dev-1", "203.0.113.1")
+
+    // Stored now, the transaction counts from its timestamp, and so do
+    // its users and their identifiers.
+    users, err := s.view(Window{AsOf: testStart.Add(-time.Second)}).GetAllUsers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 0 {
+        t.Fatalf("users before the first transaction = %+v, want none", users)
+    }
+    v := s.view(Window{AsOf: testStart})
+    got, err := v.GetTransaction(id)
+    if err != nil {
+        t.Fatalf("transaction at its timestamp: %v", err)
+    }
+    if got.DeviceID != "dev-1" || got.IP != "203.0.113.1" {
+        t.Fatalf("transaction at its timestamp = %+v, want its device and IP", got)
+    }
+    users, err = v.GetAllUsers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 2 || users[0].Email != "alice@example.com" {
+        t.Fatalf("users at the first transaction = %+v, want Alice and Bob with their emails", users)
+    }
+}
+
+func TestViewVersionsEdits(t *testing.T) {
+    s := newTestStore(t)
+    alice := s.user("Alice", "alice@example.com", "111")
+    bob := s.user("Bob", "bob@example.com", "222")
+    carol := s.user("Carol", "carol@example.com", "333")
+    id := s.tx(alice, bob, 10, "dev-1", "203.0.113.1")
+
+    before := time.Now().UTC()
+    time.Sleep(time.Millisecond)
+    name, email := "Alicia", "alicia@example.com"
+    if _, err := s.UpdateUser(alice, models.UserPatch{Name: &name, Email: &email}); err != nil {
+        t.Fatal(err)
+    }
+    desc, device := "edited", "dev-2"
+    money, err := s.conv.Money(25, "USD", testStart.Format(time.RFC3339))
+    if err != nil {
+        t.Fatal(err)
+    }
+    if _, err := s.UpdateTransaction(id, models.TransactionPatch{
+        ToUserID:    &carol,
+        Description: &desc,
+        DeviceID:    &device,
+        Money:       &money,
+    }); err != nil {
+        t.Fatal(err)
+    }
+    time.Sleep(time.Millisecond)
+    edited := time.Now().UTC()
+    if err := s.DeleteTransaction(id); err != nil {
+        t.Fatal(err)
+    }
+
+    for _, tc := range []struct {
+        name   string
+        w      Window
+        user   models.User
+        tx     models.Transaction
+        exists bool
+    }{
+        {"asOf before the edits", Window{AsOf: before},
+            models.User{Name: "Alice", Email: "alice@example.com"},
+            models.Transaction{ToUserID: bob, Description: "test", DeviceID: "dev-1", Amount: 10}, true},
+        {"to before the edits", Window{From: testStart, To: before},
+            models.User{Name: "Alice", Email: "alice@example.com"},
+            models.Transaction{ToUserID: bob, Description: "test", DeviceID: "dev-1", Amount: 10}, true},
+        {"asOf after the edits", Window{AsOf: edited},
+            models.User{Name: "Alicia", Email: "alicia@example.com"},
+            models.Transaction{ToUserID: carol, Description: "edited", DeviceID: "dev-2", Amount: 25}, true},
+        {"from after the deletion", Window{From: time.Now().UTC()},
+            models.User{Name: "Alicia", Email: "alicia@example.com"},
+            models.Transaction{}, false},
+    } {
+        v := s.view(tc.w)
+        u, _, err := v.GetUserRelationships(alice)
+        if err != nil {
+            t.Fatalf("%s: user: %v", tc.name, err)
+        }
+        if u.Name != tc.user.Name || u.Email != tc.user.Email {
+            t.Errorf("%s: user = %+v, want %s <%s>", tc.name, u, tc.user.Name, tc.user.Email)
+        }
+        got, err := v.GetTransaction(id)
+        if !tc.exists {
+            if err != ErrTransactionNotFound {
+                t.Errorf("%s: transaction = %+v, %v; want not found", tc.name, got, err)
+            }
+            continue
+        }
+        if err != nil {
+            t.Fatalf("%s: transaction: %v", tc.name, err)
+        }
+        if got.ToUserID != tc.tx.ToUserID || got.Description != tc.tx.Description ||
+            got.DeviceID != tc.tx.DeviceID || got.Amount != tc.tx.Amount {
+            t.Errorf("%s: transaction = %+v, want %+v", tc.name, got, tc.tx)
+        }
+    }
+}
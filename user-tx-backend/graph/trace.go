@@ -0,0 +1,239 @@
+package graph
+
+import (
+    "fmt"
+    "math"
+    "time"
+
+    "user-tx-backend/models"
+)
+
+const (
+    defaultTraceDepth = 5
+    maxTraceDepth     = 10
+)
+
+func normalizeTraceQuery(q models.TraceQuery) (models.TraceQuery, time.Duration, error) {
+    if q.Direction == "" {
+        q.Direction = "forward"
+    }
+    if q.Direction != "forward" && q.Direction != "backward" {
+        return q, 0, fmt.Errorf("%w: direction must be forward or backward", ErrInvalidQuery)
+    }
+    if q.Method == "" {
+        q.Method = "proportional"
+    }
+    if q.Method != "proportional" && q.Method != "fifo" {
+        return q, 0, fmt.Errorf("%w: method must be proportional or fifo", ErrInvalidQuery)
+    }
+    if q.MaxDepth == 0 {
+        q.MaxDepth = defaultTraceDepth
+    }
+    if q.MaxDepth < 1 || q.MaxDepth > maxTraceDepth {
+        return q, 0, fmt.Errorf("%w: maxDepth must be between 1 and %d", ErrInvalidQuery, maxTraceDepth)
+    }
+    if err := checkStatuses(q.ExcludeStatuses); err != nil {
+        return q, 0, err
+    }
+    var horizon time.Duration
+    if q.Horizon != "" {
+        var err error
+        if horizon, err = time.ParseDuration(q.Horizon); err != nil || horizon <= 0 {
+            return q, 0, fmt.Errorf("%w: invalid horizon %q", ErrInvalidQuery, q.Horizon)
+        }
+    }
+    return q, horizon, nil
+}
+
+// incoming indexes transactions by receiver, keeping timestamp order.
+func (s *Snapshot) incoming() map[string][]models.Transaction {
+    in := make(map[string][]models.Transaction)
+    for _, t := range s.Transactions {
+        in[t.ToUserID] = append(in[t.ToUserID], t)
+    }
+    return in
+}
+
+// trace follows the source's funds through the snapshot. Transactions are
+// visited in time order (reverse order going backward), so every
+// contribution to a transaction is known before it is expanded; the
+// strict time ordering also keeps the result acyclic.
+//
+// The holder of traced funds is the receiver of a transaction (forward) or
+// its sender (backward). Its candidate transactions are the ones it sent
+// later (forward) or received earlier (backward), within the horizon. With
+// "proportional" every candidate carries the same share of the traced
+// amount, up to its own amount; with "fifo" candidates absorb the amount
+// earliest first. No transaction is attributed more than its amount.
+// Amounts are values in the reporting currency, so funds can be followed
+// across currencies.
+func (s *Snapshot) trace(src models.Transaction, q models.TraceQuery, horizon time.Duration) models.TraceResponse {
+    resp := models.TraceResponse{
+        Source:    src.ID,
+        Direction: q.Direction,
+        Method:    q.Method,
+        Nodes:     []models.TraceNode{},
+        Edges:     []models.TraceEdge{},
+    }
+    forward := q.Direction == "forward"
+    start := s.Time(src.ID)
+    var byHolder map[string][]models.Transaction
+    if forward {
+        byHolder = s.outgoing()
+    } else {
+        byHolder = s.incoming()
+    }
+
+    resp.Currency = src.ReportingCurrency
+    if resp.Currency == "" {
+        resp.Currency = src.Currency
+    }
+    traced := map[string]float64{src.ID: Value(src)}
+    depth := map[string]int{src.ID: 0}
+
+    order := make([]models.Transaction, len(s.Transactions))
+    copy(order, s.Transactions)
+    if !forward {
+        for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+            order[i], order[j] = order[j], order[i]
+        }
+    }
+
+    for _, t := range order {
+        amount, ok := traced[t.ID]
+        if !ok {
+            continue
+        }
+        resp.Nodes = append(resp.Nodes, models.TraceNode{Transaction: t, Depth: depth[t.ID], TracedAmount: amount})
+        if depth[t.ID] >= q.MaxDepth || amount <= 0 || amount < q.MinAmount {
+            continue
+        }
+
+        holder, ts := t.ToUserID, s.Time(t.ID)
+        if !forward {
+            holder = t.FromUserID
+        }
+        var cands []models.Transaction
+        total := 0.0
+        for _, c := range byHolder[holder] {
+            ct := s.Time(c.ID)
+            if forward && (!ct.After(ts) || horizon > 0 && ct.Sub(start) > horizon) {
+                continue
+            }
+            if !forward && (!ct.Before(ts) || horizon > 0 && start.Sub(ct) > horizon) {
+                continue
+            }
+            cands = append(cands, c)
+            total += Value(c)
+        }
+        if total <= 0 {
+            continue
+        }
+
+        remaining := amount
+        for _, c := range cands {
+            capacity := Value(c) - traced[c.ID]
+            var share float64
+            if q.Method == "fifo" {
+                share = math.Min(capacity, remaining)
+                remaining -= share
+            } else {
+                share = math.Min(capacity, Value(c)*math.Min(1, amount/total))
+            }
+            if share <= 0 {
+                continue
+            }
+            traced[c.ID] += share
+            if _, seen := depth[c.ID]; !seen {
+                depth[c.ID] = depth[t.ID] + 1
+            }
+            edge := models.TraceEdge{From: t.ID, To: c.ID, Via: holder, Amount: share}
+            if !forward {
+                edge.From, edge.To = c.ID, t.ID
+            }
+            resp.Edges = append(resp.Edges, edge)
+        }
+    }
+    return resp
+}
+
+func (s *Snapshot) traceFrom(txID string, q models.TraceQuery) (models.TraceResponse, error) {
+    q, horizon, err := normalizeTraceQuery(q)
+    if err != nil {
+        return models.TraceResponse{}, err
+    }
+    for _, t := range s.Transactions {
+        if t.ID == txID {
+            return s.trace(t, q, horizon), nil
+        }
+    }
+    return models.TraceResponse{}, ErrTransactionNotFound
+}
+
+// TraceFunds follows the funds of a transaction forward to where they went,
+// or backward to where they came from. Transactions in an excluded status
+// are not followed; tracing from one fails with ErrTransactionNotFound.
+func TraceFunds(store GraphStore, txID string, q models.TraceQuery) (models.TraceResponse, error) {
+    snap, err := store.Snapshot(SnapshotFilter{ExcludeStatuses: q.ExcludeStatuses})
+    if err != nil {
+        return models.TraceResponse{}, err
+    }
+    return snap.traceFrom(txID, q)
+}
+
+// TraceSubgraph runs TraceFunds and returns the traced transactions with
+// their senders and receivers in the export format, so the trace can be
+// loaded into the graph view or restored elsewhere. Transactions carry
+// their traced amount as a tracedAmount property.
+func TraceSubgraph(store GraphStore, txID string, q models.TraceQuery) (models.GraphExportResponse, error) {
+    export := models.GraphExportResponse{Nodes: []models.GraphNode{}, Relationships: []models.GraphRelationship{}}
+    snap, err := store.Snapshot(SnapshotFilter{ExcludeStatuses: q.ExcludeStatuses})
+    if err != nil {
+        return export, err
+    }
+    resp, err := snap.traceFrom(txID, q)
+    if err != nil {
+        return export, err
+    }
+
+    seen := make(map[string]bool)
+    addUser := func(id string) {
+        if seen[id] {
+            return
+        }
+        seen[id] = true
+        u := snap.Users[id]
+        export.Nodes = append(export.Nodes, models.GraphNode{
+            ID:   id,
+            Type: "User",
+            Properties: map[string]any{
+                "id":    id,
+                "name":  u.Name,
+                "email": u.Email,
+                "phone": u.Phone,
+            },
+        })
+    }
+    for _, n := range resp.Nodes {
+        t := n.Transaction
+        addUser(t.FromUserID)
+        addUser(t.ToUserID)
+        props := map[string]any{
+            "id":           t.ID,
+            "amount":       t.Amount,
+            "currency":     t.Currency,
+            "timestamp":    t.Timestamp,
+            "description":  t.Description,
+            "deviceId":     t.DeviceID,
+            "status":       t.Status,
+            "tracedAmount": n.TracedAmount,
+        }
+        exportMoney(props, t)
+        export.Nodes = append(export.Nodes, models.GraphNode{ID: t.ID, Type: "Transaction", Properties: props})
+        export.Relationships = append(export.Relationships,
+            models.GraphRelationship{SourceID: t.FromUserID, SourceType: "User", Relationship: "SENT", TargetID: t.ID, TargetType: "Transaction"},
+            models.GraphRelationship{SourceID: t.ID, SourceType: "Transaction", Relationship: "RECEIVED_BY", TargetID: t.ToUserID, TargetType: "User"},
+        )
+    }
+    return export, nil
+}
@@ -0,0 +1,74 @@
+package graph
+
+import (
+    "errors"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestTraceFunds(t *testing.T) {
+    s := newTestStore(t)
+    a := s.user("A", "a@example.com", "1")
+    b := s.user("B", "b@example.com", "2")
+    c := s.user("C", "c@example.com", "3")
+    d := s.user("D", "d@example.com", "4")
+    e := s.user("E", "e@example.com", "5")
+    f := s.user("F", "f@example.com", "6")
+    before := s.tx(b, e, 50, "", "")
+    src := s.tx(a, b, 100, "", "")
+    toC := s.tx(b, c, 60, "", "")
+    toD := s.tx(b, d, 140, "", "")
+    toF := s.tx(c, f, 30, "", "")
+
+    traced := func(q models.TraceQuery, from string) map[string]float64 {
+        t.Helper()
+        resp, err := TraceFunds(s, from, q)
+        if err != nil {
+            t.Fatal(err)
+        }
+        m := make(map[string]float64, len(resp.Nodes))
+        for _, n := range resp.Nodes {
+            m[n.Transaction.ID] = n.TracedAmount
+        }
+        return m
+    }
+
+    for _, tc := range []struct {
+        name string
+        q    models.TraceQuery
+        from string
+        want map[string]float64
+    }{
+        {"proportional", models.TraceQuery{}, src,
+            map[string]float64{src: 100, toC: 30, toD: 70, toF: 30}},
+        {"fifo", models.TraceQuery{Method: "fifo"}, src,
+            map[string]float64{src: 100, toC: 60, toD: 40, toF: 30}},
+        {"maxDepth", models.TraceQuery{MaxDepth: 1}, src,
+            map[string]float64{src: 100, toC: 30, toD: 70}},
+        {"backward", models.TraceQuery{Direction: "backward"}, toD,
+            map[string]float64{toD: 140, src: 100}},
+    } {
+        got := traced(tc.q, tc.from)
+        if len(got) != len(tc.want) {
+            t.Errorf("%s: traced = %v, want %v", tc.name, got, tc.want)
+            continue
+        }
+        for id, amount := range tc.want {
+            if got[id] != amount {
+                t.Errorf("%s: traced = %v, want %v", tc.name, got, tc.want)
+                break
+            }
+        }
+        if _, ok := got[before]; ok {
+            t.Errorf("%s: traced the earlier transfer %s", tc.name, before)
+        }
+    }
+
+    if _, err := TraceFunds(s, src, models.TraceQuery{Method: "lifo"}); !errors.Is(err, ErrInvalidQuery) {
+        t.Errorf("unknown method: error = %v, want ErrInvalidQuery", err)
+    }
+    if _, err := TraceFunds(s, "nothing", models.TraceQuery{}); !errors.Is(err, ErrTransactionNotFound) {
+        t.Errorf("unknown source: error = %v, want ErrTransactionNotFound", err)
+    }
+}
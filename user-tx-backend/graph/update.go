@@ -0,0 +1,496 @@
+package graph
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// optional turns a nil patch field into a Cypher null.
+func optional[T any](p *T) any {
+    if p == nil {
+        return nil
+    }
+    return *p
+}
+
+// patchMoney returns the money properties a patch sets, none unless the
+// handler converted a new amount.
+func patchMoney(patch models.TransactionPatch) map[string]any {
+    if patch.Money == nil {
+        return map[string]any{}
+    }
+    return moneyProps(*patch.Money)
+}
+
+// UpdateUser applies patch to a user, then points it at the Email and Phone
+// nodes of the new values, deleting hubs it was the last user of.
+func (d *Driver) UpdateUser(id string, patch models.UserPatch) (models.User, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        old, err := hubIDs(ctx, tx, ownedHubsQuery("User"), map[string]any{"id": id})
+        if err != nil {
+            return nil, err
+        }
+        if err := revise(ctx, tx, "User", id); err != nil {
+            return nil, fmt.Errorf("UpdateUser: %w", err)
+        }
+        rec, err := tx.Run(ctx,
+            `MATCH (u:User) WHERE u.id = $id
+             SET u.name  = coalesce($name, u.name),
+                 u.email = coalesce($email, u.email),
+                 u.phone = coalesce($phone, u.phone)
+             RETURN u.id, u.name, u.email, u.phone`,
+            map[string]any{
+                "id":    id,
+                "name":  optional(patch.Name),
+                "email": optional(patch.Email),
+                "phone": optional(patch.Phone),
+            },
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rec.Next(ctx) {
+            if err := rec.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrUserNotFound
+        }
+        r := rec.Record()
+        user := models.User{
+            ID:    r.Values[0].(string),
+            Name:  r.Values[1].(string),
+            Email: r.Values[2].(string),
+            Phone: r.Values[3].(string),
+        }
+
+        if err := linkHubs(ctx, tx, "User", []string{id}); err != nil {
+            return nil, fmt.Errorf("UpdateUser: %w", err)
+        }
+        if err := pruneHubs(ctx, tx, old); err != nil {
+            return nil, fmt.Errorf("UpdateUser: %w", err)
+        }
+        return user, nil
+    })
+    if err != nil {
+        return models.User{}, err
+    }
+    return raw.(models.User), nil
+}
+
+// DeleteUser removes a user. A user that still sent or received transactions
+// is only deleted with cascade, which also deletes those transactions.
+func (d *Driver) DeleteUser(id string, cascade bool) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        hubs, err := hubIDs(ctx, tx, ownedHubsQuery("User"), map[string]any{"id": id})
+        if err != nil {
+            return nil, err
+        }
+        var clusters []string
+        rec, err := tx.Run(ctx,
+            `MATCH (u:User) WHERE u.id = $id
+             OPTIONAL MATCH (u)-[:SENT|RECEIVED_BY]-(t:Transaction)
+             RETURN count(t)`,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rec.Next(ctx) {
+            if err := rec.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrUserNotFound
+        }
+        if rec.Record().Values[0].(int64) > 0 {
+            if !cascade {
+                return nil, ErrUserHasTransactions
+            }
+            txHubs, err := hubIDs(ctx, tx,
+                `MATCH (u:User)-[:SENT|RECEIVED_BY]-(:Transaction)-[:USED_DEVICE|FROM_IP]->(h)
+                 WHERE u.id = $id
+                 RETURN DISTINCT h.id`,
+                map[string]any{"id": id},
+            )
+            if err != nil {
+                return nil, err
+            }
+            hubs = append(hubs, txHubs...)
+            rs, err := tx.Run(ctx,
+                `MATCH (u:User)-[:SENT|RECEIVED_BY]-(t:Transaction)
+                 WHERE u.id = $id
+                 RETURN DISTINCT t.id`,
+                map[string]any{"id": id},
+            )
+            if err != nil {
+                return nil, err
+            }
+            var owned []string
+            for rs.Next(ctx) {
+                owned = append(owned, rs.Record().Values[0].(string))
+            }
+            if err := rs.Err(); err != nil {
+                return nil, err
+            }
+            if clusters, err = clustersOf(ctx, tx, owned); err != nil {
+                return nil, err
+            }
+            if err := archive(ctx, tx, "Transaction", owned); err != nil {
+                return nil, err
+            }
+            if _, err := tx.Run(ctx,
+                `MATCH (u:User)-[:SENT|RECEIVED_BY]-(t:Transaction)
+                 WHERE u.id = $id
+                 OPTIONAL MATCH (t)-[:HAS_STATUS_CHANGE]->(s:StatusChange)
+                 DETACH DELETE s
+                 WITH DISTINCT t
+                 DETACH DELETE t`,
+                map[string]any{"id": id},
+            ); err != nil {
+                return nil, err
+            }
+        }
+        if err := archive(ctx, tx, "User", []string{id}); err != nil {
+            return nil, err
+        }
+        if _, err := tx.Run(ctx,
+            `MATCH (u:User) WHERE u.id = $id DETACH DELETE u`,
+            map[string]any{"id": id},
+        ); err != nil {
+            return nil, err
+        }
+        if err := pruneHubs(ctx, tx, hubs); err != nil {
+            return nil, err
+        }
+        if err := splitClusters(ctx, tx, clusters); err != nil {
+            return nil, err
+        }
+        // A Person without users is no longer a resolved entity.
+        _, err = tx.Run(ctx,
+            `MATCH (p:Person) WHERE NOT (p)<-[:RESOLVED_AS]-() DELETE p`,
+            nil,
+        )
+        return nil, err
+    })
+    return err
+}
+
+// UpdateTransaction applies patch to a transaction, rewires SENT/RECEIVED_BY
+// when the sender or receiver changes and points it at the Device and
+// IPAddress nodes of its new values.
+func (d *Driver) UpdateTransaction(
+    id string,
+    patch models.TransactionPatch,
+) (models.Transaction, error) {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    raw, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        old, err := hubIDs(ctx, tx, ownedHubsQuery("Transaction"), map[string]any{"id": id})
+        if err != nil {
+            return nil, err
+        }
+        if err := revise(ctx, tx, "Transaction", id); err != nil {
+            return nil, fmt.Errorf("UpdateTransaction: %w", err)
+        }
+        rec, err := tx.Run(ctx,
+            `MATCH (t:Transaction) WHERE t.id = $id
+             SET t += $money,
+                 t.timestamp   = CASE WHEN $ts IS NULL THEN t.timestamp ELSE datetime($ts) END,
+                 t.description = coalesce($desc, t.description),
+                 t.deviceId    = coalesce($deviceId, t.deviceId),
+                 t.ip          = coalesce($ip, t.ip)
+             RETURN t.id`,
+            map[string]any{
+                "id":       id,
+                "money":    patchMoney(patch),
+                "ts":       optional(patch.Timestamp),
+                "desc":     optional(patch.Description),
+                "deviceId": optional(patch.DeviceID),
+                "ip":       optional(patch.IP),
+            },
+        )
+        if err != nil {
+            return nil, err
+        }
+        if !rec.Next(ctx) {
+            if err := rec.Err(); err != nil {
+                return nil, err
+            }
+            return nil, ErrTransactionNotFound
+        }
+
+        rewires := []struct {
+            userID *string
+            query  string
+        }{
+            {patch.FromUserID, `MATCH (t:Transaction), (u:User)
+                 WHERE t.id = $id AND u.id = $uid
+                 OPTIONAL MATCH (:User)-[r:SENT]->(t)
+                 DELETE r
+                 WITH DISTINCT t, u
+                 CREATE (u)-[:SENT]->(t)
+                 RETURN t.id`},
+            {patch.ToUserID, `MATCH (t:Transaction), (u:User)
+                 WHERE t.id = $id AND u.id = $uid
+                 OPTIONAL MATCH (t)-[r:RECEIVED_BY]->(:User)
+                 DELETE r
+                 WITH DISTINCT t, u
+                 CREATE (t)-[:RECEIVED_BY]->(u)
+                 RETURN t.id`},
+        }
+        for _, rw := range rewires {
+            if rw.userID == nil {
+                continue
+            }
+            res, err := tx.Run(ctx, rw.query, map[string]any{"id": id, "uid": *rw.userID})
+            if err != nil {
+                return nil, err
+            }
+            if !res.Next(ctx) {
+                if err := res.Err(); err != nil {
+                    return nil, err
+                }
+                return nil, ErrUserNotFound
+            }
+        }
+
+        if err := linkHubs(ctx, tx, "Transaction", []string{id}); err != nil {
+            return nil, fmt.Errorf("UpdateTransaction: %w", err)
+        }
+        if err := pruneHubs(ctx, tx, old); err != nil {
+            return nil, fmt.Errorf("UpdateTransaction: %w", err)
+        }
+        if relinked(patch) {
+            if err := leaveCluster(ctx, tx, id); err != nil {
+                return nil, fmt.Errorf("UpdateTransaction: %w", err)
+            }
+            if err := joinCluster(ctx, tx, id); err != nil {
+                return nil, fmt.Errorf("UpdateTransaction: %w", err)
+            }
+        }
+
+        return readTransaction(ctx, tx, id)
+    })
+    if err != nil {
+        return models.Transaction{}, err
+    }
+    return raw.(models.Transaction), nil
+}
+
+// DeleteTransaction removes a transaction together with all its relationships.
+func (d *Driver) DeleteTransaction(id string) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        hubs, err := hubIDs(ctx, tx, ownedHubsQuery("Transaction"), map[string]any{"id": id})
+        if err != nil {
+            return nil, err
+        }
+        clusters, err := clustersOf(ctx, tx, []string{id})
+        if err != nil {
+            return nil, err
+        }
+        if err := archive(ctx, tx, "Transaction", []string{id}); err != nil {
+            return nil, err
+        }
+        rec, err := tx.Run(ctx,
+            `MATCH (t:Transaction) WHERE t.id = $id
+             OPTIONAL MATCH (t)-[:HAS_STATUS_CHANGE]->(s:StatusChange)
+             DETACH DELETE s
+             WITH DISTINCT t
+             DETACH DELETE t
+             RETURN count(*)`,
+            map[string]any{"id": id},
+        )
+        if err != nil {
+            return nil, err
+        }
+        if rec.Next(ctx) && rec.Record().Values[0].(int64) > 0 {
+            if err := pruneHubs(ctx, tx, hubs); err != nil {
+                return nil, err
+            }
+            return nil, splitClusters(ctx, tx, clusters)
+        }
+        if err := rec.Err(); err != nil {
+            return nil, err
+        }
+        return nil, ErrTransactionNotFound
+    })
+    return err
+}
+
+// UpdateUser applies patch to a user.
+func (m *MemoryStore) UpdateUser(id string, patch models.UserPatch) (models.User, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    u, ok := m.users[id]
+    if !ok {
+        return models.User{}, ErrUserNotFound
+    }
+    if patch.Name != nil {
+        u.Name = *patch.Name
+    }
+    if patch.Email != nil {
+        u.Email = *patch.Email
+    }
+    if patch.Phone != nil {
+        u.Phone = *patch.Phone
+    }
+    now := time.Now().UTC()
+    m.revise(id, now)
+    m.relink(id, "User", now, m.users[id], u, models.Transaction{}, models.Transaction{})
+    m.users[id] = u
+    return u, nil
+}
+
+// DeleteUser removes a user, cascading to its transactions when asked to.
+func (m *MemoryStore) DeleteUser(id string, cascade bool) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.users[id]; !ok {
+        return ErrUserNotFound
+    }
+    var owned []string
+    for _, tid := range m.txIDs {
+        if t := m.txs[tid]; t.FromUserID == id || t.ToUserID == id {
+            owned = append(owned, tid)
+        }
+    }
+    if len(owned) > 0 && !cascade {
+        return ErrUserHasTransactions
+    }
+    for _, tid := range owned {
+        m.removeTransaction(tid)
+    }
+    for cid, c := range m.cases {
+        c.UserIDs = removeID(c.UserIDs, id)
+        m.cases[cid] = c
+    }
+    delete(m.hits, id)
+    m.unlinkIdentity(id)
+    m.retire(id, "User")
+    delete(m.users, id)
+    m.userIDs = removeID(m.userIDs, id)
+    return nil
+}
+
+// UpdateTransaction applies patch to a transaction.
+func (m *MemoryStore) UpdateTransaction(
+    id string,
+    patch models.TransactionPatch,
+) (models.Transaction, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    t, ok := m.txs[id]
+    if !ok {
+        return models.Transaction{}, ErrTransactionNotFound
+    }
+    if patch.Timestamp != nil {
+        ts, err := time.Parse(time.RFC3339Nano, *patch.Timestamp)
+        if err != nil {
+            return models.Transaction{}, fmt.Errorf("UpdateTransaction: invalid timestamp %q: %w", *patch.Timestamp, err)
+        }
+        t.Timestamp = ts.Format(time.RFC3339Nano)
+    }
+    if patch.FromUserID != nil {
+        if _, ok := m.users[*patch.FromUserID]; !ok {
+            return models.Transaction{}, ErrUserNotFound
+        }
+        t.FromUserID = *patch.FromUserID
+    }
+    if patch.ToUserID != nil {
+        if _, ok := m.users[*patch.ToUserID]; !ok {
+            return models.Transaction{}, ErrUserNotFound
+        }
+        t.ToUserID = *patch.ToUserID
+    }
+    if patch.Money != nil {
+        applyMoney(&t, *patch.Money)
+    }
+    if patch.Description != nil {
+        t.Description = *patch.Description
+    }
+    if patch.DeviceID != nil {
+        t.DeviceID = *patch.DeviceID
+    }
+    if patch.IP != nil {
+        t.IP = *patch.IP
+    }
+    now := time.Now().UTC()
+    m.revise(id, now)
+    m.relink(id, "Transaction", now, models.User{}, models.User{}, m.txs[id], t)
+    m.txs[id] = t
+    if relinked(patch) {
+        m.leaveCluster(id)
+        m.joinCluster(id)
+    }
+    return t, nil
+}
+
+// DeleteTransaction removes a transaction and its links.
+func (m *MemoryStore) DeleteTransaction(id string) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    if _, ok := m.txs[id]; !ok {
+        return ErrTransactionNotFound
+    }
+    m.removeTransaction(id)
+    return nil
+}
+
+// removeTransaction drops a transaction with its status history, follow-up
+// links and links from alerts and cases, keeping a copy for temporal views.
+// Callers must hold the write lock.
+func (m *MemoryStore) removeTransaction(id string) {
+    m.retire(id, "Transaction")
+    for aid, a := range m.alerts {
+        if a.TransactionID == id {
+            a.TransactionID = ""
+            m.alerts[aid] = a
+        }
+    }
+    for cid, c := range m.cases {
+        c.TransactionIDs = removeID(c.TransactionIDs, id)
+        m.cases[cid] = c
+    }
+    delete(m.txs, id)
+    delete(m.txScores, id)
+    delete(m.history, id)
+    var followUps []memRel
+    for _, r := range m.followUps {
+        if r.src != id && r.dst != id {
+            followUps = append(followUps, r)
+        }
+    }
+    m.followUps = followUps
+    m.txIDs = removeID(m.txIDs, id)
+    m.leaveCluster(id)
+}
+
+func removeID(ids []string, id string) []string {
+    for i, v := range ids {
+        if v == id {
+            return append(ids[:i], ids[i+1:]...)
+        }
+    }
+    return ids
+}
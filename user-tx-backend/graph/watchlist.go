@@ -0,0 +1,80 @@
+package graph
+
+import (
+    "context"
+
+    "github.com/neo4j/neo4j-go-driver/v5/neo4j"
+    "user-tx-backend/models"
+)
+
+// SetWatchlistHits replaces the WATCHLIST_HIT relationships of every user
+// in byUser with the given hits; an empty list clears them. Entries are
+// merged as WatchlistEntry nodes and dropped once nothing points at them.
+func (d *Driver) SetWatchlistHits(byUser map[string][]models.WatchlistHit) error {
+    ctx := context.Background()
+    session := d.drv.NewSession(ctx, neo4j.SessionConfig{AccessMode: neo4j.AccessModeWrite})
+    defer session.Close(ctx)
+
+    rows := make([]map[string]any, 0, len(byUser))
+    for userID, hits := range byUser {
+        hs := make([]map[string]any, len(hits))
+        for i, h := range hits {
+            hs[i] = map[string]any{
+                "entryId":      h.EntryID,
+                "list":         h.List,
+                "name":         h.Name,
+                "program":      h.Program,
+                "matchType":    h.MatchType,
+                "matchedValue": h.MatchedValue,
+                "score":        h.Score,
+                "screenedAt":   h.ScreenedAt,
+            }
+        }
+        rows = append(rows, map[string]any{"userId": userID, "hits": hs})
+    }
+    _, err := session.ExecuteWrite(ctx, func(tx neo4j.ManagedTransaction) (any, error) {
+        if _, err := tx.Run(ctx,
+            `UNWIND $rows AS row
+             MATCH (u:User) WHERE u.id = row.userId
+             OPTIONAL MATCH (u)-[old:WATCHLIST_HIT]->(:WatchlistEntry)
+             DELETE old
+             WITH DISTINCT u, row
+             UNWIND row.hits AS h
+             MERGE (e:WatchlistEntry {id: h.entryId})
+             SET e.list = h.list, e.name = h.name, e.program = h.program
+             CREATE (u)-[:WATCHLIST_HIT {
+               matchType:    h.matchType,
+               matchedValue: h.matchedValue,
+               score:        h.score,
+               screenedAt:   datetime(h.screenedAt)
+             }]->(e)`,
+            map[string]any{"rows": rows},
+        ); err != nil {
+            return nil, err
+        }
+        _, err := tx.Run(ctx,
+            `MATCH (e:WatchlistEntry) WHERE NOT (e)<-[:WATCHLIST_HIT]-() DELETE e`,
+            nil,
+        )
+        return nil, err
+    })
+    return err
+}
+
+// SetWatchlistHits replaces the hits of every user in byUser.
+func (m *MemoryStore) SetWatchlistHits(byUser map[string][]models.WatchlistHit) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+
+    for userID, hits := range byUser {
+        if _, ok := m.users[userID]; !ok {
+            continue
+        }
+        if len(hits) == 0 {
+            delete(m.hits, userID)
+            continue
+        }
+        m.hits[userID] = append([]models.WatchlistHit{}, hits...)
+    }
+    return nil
+}
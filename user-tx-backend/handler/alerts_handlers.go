@@ -0,0 +1,128 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+
+    "github.com/gorilla/mux"
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+// writeStoreError maps the alert and case errors to HTTP statuses.
+func writeStoreError(w http.ResponseWriter, err error) {
+    switch {
+    case errors.Is(err, graph.ErrAlertNotFound),
+        errors.Is(err, graph.ErrCaseNotFound),
+        errors.Is(err, graph.ErrPersonNotFound),
+        errors.Is(err, graph.ErrClusterNotFound),
+        errors.Is(err, graph.ErrUserNotFound),
+        errors.Is(err, graph.ErrTransactionNotFound):
+        http.Error(w, err.Error(), http.StatusNotFound)
+    case errors.Is(err, graph.ErrInvalidQuery):
+        http.Error(w, err.Error(), http.StatusBadRequest)
+    case errors.Is(err, graph.ErrInvalidTransition):
+        http.Error(w, err.Error(), http.StatusConflict)
+    default:
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+    }
+}
+
+// GetAlerts handles GET /api/alerts?status=&severity=&ruleId=&userId=&transactionId=&caseId=&limit=
+// Alerts are listed newest first.
+func (h *Handler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    alerts, err := h.DB.ListAlerts(models.AlertQuery{
+        Status:        q.Get("status"),
+        Severity:      q.Get("severity"),
+        RuleID:        q.Get("ruleId"),
+        UserID:        q.Get("userId"),
+        TransactionID: q.Get("transactionId"),
+        CaseID:        q.Get("caseId"),
+        Limit:         limit,
+    })
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(alerts)
+}
+
+// CreateAlert handles POST /api/alerts, raising an alert by hand. The rule
+// and action default to "manual".
+func (h *Handler) CreateAlert(w http.ResponseWriter, r *http.Request) {
+    var req models.Alert
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    if req.Message == "" {
+        http.Error(w, "message is required", http.StatusBadRequest)
+        return
+    }
+    if req.TransactionID != "" {
+        if _, err := h.DB.TransactionAlerts(req.TransactionID); err != nil {
+            writeStoreError(w, err)
+            return
+        }
+    }
+    if req.RuleID == "" {
+        req.RuleID, req.RuleName = "manual", "Manual alert"
+    }
+    if req.Action == "" {
+        req.Action = "manual"
+    }
+    alerts, err := h.DB.CreateAlerts([]models.Alert{req})
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(alerts[0])
+}
+
+// GetAlert handles GET /api/alerts/{id}
+func (h *Handler) GetAlert(w http.ResponseWriter, r *http.Request) {
+    alert, err := h.DB.GetAlert(mux.Vars(r)["id"])
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(alert)
+}
+
+// UpdateAlert handles PATCH /api/alerts/{id}, changing the status
+// (open, acknowledged, dismissed) or the case. An empty caseId takes the
+// alert off its case.
+func (h *Handler) UpdateAlert(w http.ResponseWriter, r *http.Request) {
+    var patch models.AlertPatch
+    if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    alert, err := h.DB.UpdateAlert(mux.Vars(r)["id"], patch)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(alert)
+}
+
+// DeleteAlert handles DELETE /api/alerts/{id}
+func (h *Handler) DeleteAlert(w http.ResponseWriter, r *http.Request) {
+    if err := h.DB.DeleteAlert(mux.Vars(r)["id"]); err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
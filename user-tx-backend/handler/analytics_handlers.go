@@ -2,29 +2,50 @@ package handler
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
-    "strconv"
 
     "github.com/gorilla/mux"
+    "user-tx-backend/graph"
     "user-tx-backend/models"
 )
 
 // GetUserShortestPath handles GET /api/analytics/shortest-path/users/{from}/{to}
+// with optional ?types=SENT,RECEIVED_BY&directed=true&maxHops=&weight=hops|amount|recency
+// and asOf= or from=&to= to search the graph as it was then
 func (h *Handler) GetUserShortestPath(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
-    fromID, err1 := strconv.ParseInt(vars["from"], 10, 64)
-    toID, err2 := strconv.ParseInt(vars["to"], 10, 64)
-    if err1 != nil || err2 != nil {
+    fromID, toID := vars["from"], vars["to"]
+    if fromID == "" || toID == "" {
         http.Error(w, "invalid user IDs", http.StatusBadRequest)
         return
     }
+    pq, constrained, err := pathParams(r.URL.Query())
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    db, err := h.windowStore(r.URL.Query())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
 
     // Fetch the path segments (with from-node, to-node, relationship)
-    segments, err := h.DB.ShortestPathSegments(fromID, toID)
+    var segments []models.PathSegment
+    var cost float64
+    if constrained {
+        segments, cost, err = graph.FindPath(db, fromID, toID, pq)
+    } else {
+        segments, err = db.ShortestPathSegments(fromID, toID)
+        cost = float64(len(segments))
+    }
     if err != nil {
         // If no path found, return 404, otherwise 500
-        if err.Error() == "no path found" {
+        if errors.Is(err, graph.ErrNoPath) {
             http.Error(w, err.Error(), http.StatusNotFound)
+        } else if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
         } else {
             http.Error(w, err.Error(), http.StatusInternalServerError)
         }
@@ -35,6 +56,7 @@ func (h *Handler) GetUserShortestPath(w http.ResponseWriter, r *http.Request) {
     w.Header().Set("Content-Type", "application/json")
     resp := models.ShortestPathResponse{
         Segments: segments,
+        Cost:     cost,
     }
     if err := json.NewEncoder(w).Encode(resp); err != nil {
         http.Error(w, "failed to serialize response", http.StatusInternalServerError)
@@ -42,15 +64,319 @@ func (h *Handler) GetUserShortestPath(w http.ResponseWriter, r *http.Request) {
     }
 }
 
-// GetTransactionClusters handles GET /api/analytics/transaction-clusters
+// GetTransactionClusters handles GET /api/analytics/transaction-clusters?excludeStatus=failed,reversed&asOf=&from=&to=
 func (h *Handler) GetTransactionClusters(w http.ResponseWriter, r *http.Request) {
-    clusters, err := h.DB.ClusterTransactions()
+    db, err := h.windowStore(r.URL.Query())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    clusters, err := db.ClusterTransactions(listParam(r.URL.Query(), "excludeStatus"))
     if err != nil {
-        http.Error(w, err.Error(), http.StatusInternalServerError)
+        writeStoreError(w, err)
         return
     }
     w.Header().Set("Content-Type", "application/json")
     json.NewEncoder(w).Encode(models.TransactionClustersResponse{
         Clusters: clusters,
     })
-}
\ No newline at end of file
+}
+
+// GetClusterSummary handles GET /api/analytics/transaction-clusters/summary
+// ?excludeStatus=&minSize=&maxSize=&sort=size|users|devices|total|max|first|last|id&order=desc|asc&limit=
+// and asOf= or from=&to=. Largest clusters come first by default.
+func (h *Handler) GetClusterSummary(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    query := models.ClusterSummaryQuery{
+        ExcludeStatuses: listParam(q, "excludeStatus"),
+        Sort:            q.Get("sort"),
+        Desc:            true,
+    }
+    switch q.Get("order") {
+    case "", "desc":
+    case "asc":
+        query.Desc = false
+    default:
+        http.Error(w, "invalid order", http.StatusBadRequest)
+        return
+    }
+    var err error
+    if query.MinSize, err = intParam(q, "minSize", 0); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if query.MaxSize, err = intParam(q, "maxSize", 0); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if query.Limit, err = intParam(q, "limit", 0); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    db, err := h.windowStore(q)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    resp, err := graph.SummarizeClusters(db, query)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetTransactionCluster handles GET /api/analytics/transaction-clusters/{clusterId}?asOf=&from=&to=
+// and returns the cluster's transactions, users, devices and IPs as a graph
+func (h *Handler) GetTransactionCluster(w http.ResponseWriter, r *http.Request) {
+    db, err := h.windowStore(r.URL.Query())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    export, err := db.ClusterGraph(mux.Vars(r)["clusterId"])
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(export)
+}
+
+// GetCycles handles GET /api/analytics/cycles?maxLength=&from=&to=&window=&minAmount=&maxDecay=&chronological=&limit=
+func (h *Handler) GetCycles(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    maxLength, err := intParam(q, "maxLength", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    minAmount, err := floatParam(q, "minAmount")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    maxDecay, err := floatParam(q, "maxDecay")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    query := models.CycleQuery{
+        MaxLength:     maxLength,
+        From:          q.Get("from"),
+        To:            q.Get("to"),
+        MaxElapsed:    q.Get("window"),
+        MaxDecay:      maxDecay,
+        Chronological: q.Get("chronological") == "true",
+        Limit:         limit,
+    }
+    if minAmount != nil {
+        query.MinAmount = *minAmount
+    }
+
+    resp, err := graph.FindCycles(h.DB, query)
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetFanPatterns handles GET /api/analytics/fan-patterns?direction=&window=&minCounterparties=&maxAmount=&minTotal=&from=&to=&limit=
+func (h *Handler) GetFanPatterns(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    minCounterparties, err := intParam(q, "minCounterparties", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    maxAmount, err := floatParam(q, "maxAmount")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    minTotal, err := floatParam(q, "minTotal")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    query := models.FanQuery{
+        Direction:         q.Get("direction"),
+        Window:            q.Get("window"),
+        MinCounterparties: minCounterparties,
+        MaxAmount:         maxAmount,
+        From:              q.Get("from"),
+        To:                q.Get("to"),
+        Limit:             limit,
+    }
+    if minTotal != nil {
+        query.MinTotal = *minTotal
+    }
+
+    suspects, err := graph.FindFanPatterns(h.DB, query)
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    if suspects == nil {
+        suspects = []models.FanSuspect{}
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(models.FanPatternsResponse{Suspects: suspects})
+}
+
+// GetCentrality handles GET /api/analytics/centrality?algorithm=pagerank|degree|betweenness&edges=flow|shared|both&write=true&cursor=&limit=
+func (h *Handler) GetCentrality(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    page, err := graph.ComputeCentrality(h.DB, models.CentralityQuery{
+        Algorithm: q.Get("algorithm"),
+        Edges:     q.Get("edges"),
+        Write:     q.Get("write") == "true",
+        Cursor:    q.Get("cursor"),
+        Limit:     limit,
+    })
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
+
+// GetCommunities handles GET /api/analytics/communities?algorithm=louvain|labelpropagation&projection=user|transaction&edges=&minSize=&limit=
+func (h *Handler) GetCommunities(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    minSize, err := intParam(q, "minSize", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    resp, err := graph.DetectCommunities(h.DB, models.CommunityQuery{
+        Algorithm:  q.Get("algorithm"),
+        Projection: q.Get("projection"),
+        Edges:      q.Get("edges"),
+        MinSize:    minSize,
+        Limit:      limit,
+    })
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetUserPaths handles GET /api/analytics/paths/users/{from}/{to}?k=&all=true&maxHops=
+// and the constraints of GetUserShortestPath.
+func (h *Handler) GetUserPaths(w http.ResponseWriter, r *http.Request) {
+    vars := mux.Vars(r)
+    q := r.URL.Query()
+    pq, _, err := pathParams(q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if pq.K, err = intParam(q, "k", 0); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    pq.All = q.Get("all") == "true"
+
+    resp, err := graph.FindPaths(h.DB, vars["from"], vars["to"], pq)
+    if err != nil {
+        if errors.Is(err, graph.ErrNoPath) {
+            http.Error(w, err.Error(), http.StatusNotFound)
+        } else if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetTransactionTrace handles GET /api/analytics/trace/transaction/{id}?direction=forward|backward&method=proportional|fifo&maxDepth=&horizon=&minAmount=&excludeStatus=&format=export
+func (h *Handler) GetTransactionTrace(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    q := r.URL.Query()
+    maxDepth, err := intParam(q, "maxDepth", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    minAmount, err := floatParam(q, "minAmount")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    tq := models.TraceQuery{
+        Direction:       q.Get("direction"),
+        Method:          q.Get("method"),
+        MaxDepth:        maxDepth,
+        Horizon:         q.Get("horizon"),
+        ExcludeStatuses: listParam(q, "excludeStatus"),
+    }
+    if minAmount != nil {
+        tq.MinAmount = *minAmount
+    }
+
+    var resp any
+    if q.Get("format") == "export" {
+        resp, err = graph.TraceSubgraph(h.DB, id, tq)
+    } else {
+        resp, err = graph.TraceFunds(h.DB, id, tq)
+    }
+    if err != nil {
+        switch {
+        case errors.Is(err, graph.ErrTransactionNotFound):
+            http.Error(w, err.Error(), http.StatusNotFound)
+        case errors.Is(err, graph.ErrInvalidQuery):
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        default:
+            http.Error(w, err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
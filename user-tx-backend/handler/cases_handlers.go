@@ -0,0 +1,121 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/gorilla/mux"
+    "user-tx-backend/models"
+)
+
+// GetCases handles GET /api/cases?status=&assignee=&limit=
+// Cases are listed most recently updated first.
+func (h *Handler) GetCases(w http.ResponseWriter, r *http.Request) {
+    q := r.URL.Query()
+    limit, err := intParam(q, "limit", 0)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    cases, err := h.DB.ListCases(models.CaseQuery{
+        Status:   q.Get("status"),
+        Assignee: q.Get("assignee"),
+        Limit:    limit,
+    })
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(cases)
+}
+
+// CreateCase handles POST /api/cases
+func (h *Handler) CreateCase(w http.ResponseWriter, r *http.Request) {
+    var req models.CaseRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    c, err := h.DB.CreateCase(req)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(c)
+}
+
+// GetCase handles GET /api/cases/{id}
+func (h *Handler) GetCase(w http.ResponseWriter, r *http.Request) {
+    c, err := h.DB.GetCase(mux.Vars(r)["id"])
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(c)
+}
+
+// UpdateCase handles PATCH /api/cases/{id}. A status change outside the
+// case lifecycle is refused with 409.
+func (h *Handler) UpdateCase(w http.ResponseWriter, r *http.Request) {
+    var patch models.CasePatch
+    if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    c, err := h.DB.UpdateCase(mux.Vars(r)["id"], patch)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(c)
+}
+
+// DeleteCase handles DELETE /api/cases/{id}
+func (h *Handler) DeleteCase(w http.ResponseWriter, r *http.Request) {
+    if err := h.DB.DeleteCase(mux.Vars(r)["id"]); err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// AddCaseNote handles POST /api/cases/{id}/notes
+func (h *Handler) AddCaseNote(w http.ResponseWriter, r *http.Request) {
+    var note models.CaseNote
+    if err := json.NewDecoder(r.Body).Decode(&note); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    note, err := h.DB.AddCaseNote(mux.Vars(r)["id"], note)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(note)
+}
+
+// AddCaseEvidence handles POST /api/cases/{id}/evidence. The body carries
+// an analytics result (a cycle, cluster, path, trace...) in data, stored
+// as given.
+func (h *Handler) AddCaseEvidence(w http.ResponseWriter, r *http.Request) {
+    var ev models.CaseEvidence
+    if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    ev, err := h.DB.AddCaseEvidence(mux.Vars(r)["id"], ev)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(ev)
+}
@@ -1,60 +1,287 @@
 package handler
 
 import (
+    "compress/gzip"
     "encoding/csv"
     "encoding/json"
+    "io"
     "net/http"
     "strconv"
+    "strings"
 
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
 )
 
-// ExportGraphJSON handles GET /api/export/json
+// ExportGraphJSON handles GET /api/export/json?asOf=&from=&to=
 func (h *Handler) ExportGraphJSON(w http.ResponseWriter, r *http.Request) {
-    data, err := h.DB.ExportGraph()
+    h.streamExport(w, r, "application/json", "", func(out io.Writer) exportFormat {
+        return &jsonExport{w: out}
+    })
+}
+
+// ExportGraphNDJSON handles GET /api/export/ndjson?asOf=&from=&to= and
+// writes one {"node":...} or {"relationship":...} object per line
+func (h *Handler) ExportGraphNDJSON(w http.ResponseWriter, r *http.Request) {
+    h.streamExport(w, r, "application/x-ndjson", "", func(out io.Writer) exportFormat {
+        return &ndjsonExport{enc: json.NewEncoder(out)}
+    })
+}
+
+// ExportGraphCSV handles GET /api/export/csv?asOf=&from=&to=
+func (h *Handler) ExportGraphCSV(w http.ResponseWriter, r *http.Request) {
+    h.streamExport(w, r, "text/csv", "graph.csv", func(out io.Writer) exportFormat {
+        return &csvExport{w: csv.NewWriter(out)}
+    })
+}
+
+// exportFormat writes a streamed export in one format. Flush pushes out
+// anything it buffers; Close ends the document.
+type exportFormat interface {
+    graph.GraphWriter
+    Flush() error
+    Close() error
+}
+
+// flushEvery is the number of records written between flushes to the client.
+const flushEvery = 1000
+
+// streamExport sends the export to the client as the store produces it,
+// gzipped if the client accepts it. The response has no length, so it is
+// sent chunked. The store stops when the client disconnects. Headers go
+// out with the first record: a store that fails before that gets a 500,
+// after that the connection is dropped so a partial export can't be taken
+// for a complete one.
+func (h *Handler) streamExport(w http.ResponseWriter, r *http.Request, contentType, filename string, format func(io.Writer) exportFormat) {
+    db, err := h.windowStore(r.URL.Query())
     if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    w.Header().Set("Content-Type", contentType)
+    if filename != "" {
+        w.Header().Set("Content-Disposition", "attachment; filename=\""+filename+"\"")
+    }
+    w.Header().Add("Vary", "Accept-Encoding")
+    s := &exportStream{w: w}
+    if acceptsGzip(r.Header.Get("Accept-Encoding")) {
+        w.Header().Set("Content-Encoding", "gzip")
+        s.gz = gzip.NewWriter(w)
+    }
+    s.format = format(s)
+
+    err = db.StreamGraph(r.Context(), s)
+    if err == nil {
+        err = s.format.Close()
+    }
+    if err == nil && s.gz != nil {
+        err = s.gz.Close()
+    }
+    if err == nil {
+        return
+    }
+    if !s.started {
+        w.Header().Del("Content-Encoding")
+        w.Header().Del("Content-Disposition")
         http.Error(w, "export failed: "+err.Error(), http.StatusInternalServerError)
         return
     }
-    w.Header().Set("Content-Type", "application/json")
-    json.NewEncoder(w).Encode(data)
+    panic(http.ErrAbortHandler)
 }
 
-// ExportGraphCSV handles GET /api/export/csv
-func (h *Handler) ExportGraphCSV(w http.ResponseWriter, r *http.Request) {
-    data, err := h.DB.ExportGraph()
+// exportStream passes records to the format and the bytes it writes to
+// the response, through gzip if enabled.
+type exportStream struct {
+    format  exportFormat
+    w       http.ResponseWriter
+    gz      *gzip.Writer
+    started bool
+    records int
+}
+
+func (s *exportStream) Write(p []byte) (int, error) {
+    s.started = true
+    if s.gz != nil {
+        return s.gz.Write(p)
+    }
+    return s.w.Write(p)
+}
+
+func (s *exportStream) Node(n models.GraphNode) error {
+    if err := s.format.Node(n); err != nil {
+        return err
+    }
+    return s.written()
+}
+
+func (s *exportStream) Relationship(r models.GraphRelationship) error {
+    if err := s.format.Relationship(r); err != nil {
+        return err
+    }
+    return s.written()
+}
+
+// written counts a record and flushes every flushEvery records, so the
+// client receives data while the export runs.
+func (s *exportStream) written() error {
+    s.records++
+    if s.records%flushEvery != 0 {
+        return nil
+    }
+    if err := s.format.Flush(); err != nil {
+        return err
+    }
+    if s.gz != nil {
+        if err := s.gz.Flush(); err != nil {
+            return err
+        }
+    }
+    if f, ok := s.w.(http.Flusher); ok {
+        f.Flush()
+    }
+    return nil
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+    for _, part := range strings.Split(header, ",") {
+        coding, params, _ := strings.Cut(part, ";")
+        if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+            continue
+        }
+        q, found := strings.CutPrefix(strings.TrimSpace(params), "q=")
+        if !found {
+            return true
+        }
+        v, err := strconv.ParseFloat(q, 64)
+        return err == nil && v > 0
+    }
+    return false
+}
+
+// jsonExport writes the export as one {"nodes":[...],"relationships":[...]}
+// document, the same as ExportGraph encodes to.
+type jsonExport struct {
+    w       io.Writer
+    section int
+}
+
+// jsonSections open each part of the document, indexed by jsonExport.section.
+var jsonSections = []string{"", `{"nodes":[`, `],"relationships":[`, "]}\n"}
+
+// into moves on to a section, writing the brackets between, or writes the
+// comma before the next element of the current one.
+func (e *jsonExport) into(section int) error {
+    if e.section == section {
+        _, err := io.WriteString(e.w, ",")
+        return err
+    }
+    for e.section < section {
+        e.section++
+        if _, err := io.WriteString(e.w, jsonSections[e.section]); err != nil {
+            return err
+        }
+    }
+    return nil
+}
+
+func (e *jsonExport) element(section int, v any) error {
+    data, err := json.Marshal(v)
     if err != nil {
-        http.Error(w, "export failed: "+err.Error(), http.StatusInternalServerError)
-        return
+        return err
+    }
+    if err := e.into(section); err != nil {
+        return err
+    }
+    _, err = e.w.Write(data)
+    return err
+}
+
+func (e *jsonExport) Node(n models.GraphNode) error { return e.element(1, n) }
+
+func (e *jsonExport) Relationship(r models.GraphRelationship) error { return e.element(2, r) }
+
+func (e *jsonExport) Flush() error { return nil }
+
+func (e *jsonExport) Close() error { return e.into(len(jsonSections) - 1) }
+
+// ndjsonLine is one line of the NDJSON export.
+type ndjsonLine struct {
+    Node         *models.GraphNode         `json:"node,omitempty"`
+    Relationship *models.GraphRelationship `json:"relationship,omitempty"`
+}
+
+type ndjsonExport struct {
+    enc *json.Encoder
+}
+
+func (e *ndjsonExport) Node(n models.GraphNode) error { return e.enc.Encode(ndjsonLine{Node: &n}) }
+
+func (e *ndjsonExport) Relationship(r models.GraphRelationship) error {
+    return e.enc.Encode(ndjsonLine{Relationship: &r})
+}
+
+func (e *ndjsonExport) Flush() error { return nil }
+
+func (e *ndjsonExport) Close() error { return nil }
+
+// csvExport writes a "# Nodes" section and a "# Relationships" section.
+type csvExport struct {
+    w       *csv.Writer
+    section int
+}
+
+// csvSections are the rows that open each section, indexed by csvExport.section.
+var csvSections = [][][]string{
+    nil,
+    {{"# Nodes"}, {"id", "type", "properties"}},
+    {{}, {"# Relationships"}, {"source_id", "source_type", "relationship", "target_id", "target_type"}},
+}
+
+func (e *csvExport) into(section int) error {
+    for e.section < section {
+        e.section++
+        if err := e.w.WriteAll(csvSections[e.section]); err != nil {
+            return err
+        }
     }
+    return nil
+}
+
+func (e *csvExport) Node(n models.GraphNode) error {
+    if err := e.into(1); err != nil {
+        return err
+    }
+    props, _ := json.Marshal(n.Properties)
+    return e.w.Write([]string{
+        n.ID,
+        n.Type,
+        string(props),
+    })
+}
+
+func (e *csvExport) Relationship(r models.GraphRelationship) error {
+    if err := e.into(2); err != nil {
+        return err
+    }
+    return e.w.Write([]string{
+        r.SourceID,
+        r.SourceType,
+        r.Relationship,
+        r.TargetID,
+        r.TargetType,
+    })
+}
 
-    w.Header().Set("Content-Type", "text/csv")
-    w.Header().Set("Content-Disposition", "attachment; filename=\"graph.csv\"")
-    writer := csv.NewWriter(w)
-
-    // Nodes section
-    writer.Write([]string{"# Nodes"})
-    writer.Write([]string{"id", "type", "properties"})
-    for _, n := range data.Nodes {
-        props, _ := json.Marshal(n.Properties)
-        writer.Write([]string{
-            strconv.FormatInt(n.ID, 10),
-            n.Type,
-            string(props),
-        })
-    }
-    writer.Write([]string{}) 
-
-    // Relationships section
-    writer.Write([]string{"# Relationships"})
-    writer.Write([]string{"source_id", "source_type", "relationship", "target_id", "target_type"})
-    for _, r := range data.Relationships {
-        writer.Write([]string{
-            strconv.FormatInt(r.SourceID, 10),
-            r.SourceType,
-            r.Relationship,
-            strconv.FormatInt(r.TargetID, 10),
-            r.TargetType,
-        })
-    }
-    writer.Flush()
+func (e *csvExport) Flush() error {
+    e.w.Flush()
+    return e.w.Error()
+}
+
+func (e *csvExport) Close() error {
+    if err := e.into(len(csvSections) - 1); err != nil {
+        return err
+    }
+    return e.Flush()
 }
@@ -0,0 +1,39 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "user-tx-backend/graph"
+)
+
+// GetFXRates handles GET /api/fx/rates
+func (h *Handler) GetFXRates(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(h.FX.Table())
+}
+
+// ReloadFXRates handles POST /api/fx/reload. A broken rates file is
+// reported and the previous rates stay active. Stored amounts keep the
+// rate they were converted at until POST /api/fx/recompute.
+func (h *Handler) ReloadFXRates(w http.ResponseWriter, r *http.Request) {
+    if err := h.FX.Reload(); err != nil {
+        http.Error(w, "reload failed: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    h.GetFXRates(w, r)
+}
+
+// RecomputeFX handles POST /api/fx/recompute, converting every stored
+// transaction again with the current rates. With ?missing=true only
+// transactions without a reporting amount are converted, e.g. after rates
+// for their currency were added or an old export was restored.
+func (h *Handler) RecomputeFX(w http.ResponseWriter, r *http.Request) {
+    resp, err := graph.ConvertAmounts(h.DB, h.FX, r.URL.Query().Get("missing") != "true")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,523 @@
+package handler
+
+import (
+    "bufio"
+    "bytes"
+    "compress/gzip"
+    "context"
+    "encoding/json"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "github.com/gorilla/mux"
+    "user-tx-backend/fx"
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+    "user-tx-backend/resolve"
+    "user-tx-backend/risk"
+    "user-tx-backend/rules"
+    "user-tx-backend/screening"
+)
+
+// newTestHandler returns a Handler over an empty MemoryStore, with no
+// rules, watchlists or exchange rates configured.
+func newTestHandler(t *testing.T) *Handler {
+    t.Helper()
+    engine, err := rules.NewEngine("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    scorer, err := risk.NewScorer("")
+    if err != nil {
+        t.Fatal(err)
+    }
+    screener, err := screening.NewScreener(nil, screening.DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    resolver, err := resolve.NewResolver(resolve.DefaultThreshold, resolve.DefaultCountry)
+    if err != nil {
+        t.Fatal(err)
+    }
+    conv, err := fx.NewConverter("", "USD")
+    if err != nil {
+        t.Fatal(err)
+    }
+    return NewHandler(graph.NewMemoryStore(), engine, scorer, screener, resolver, conv)
+}
+
+// call runs one request through fn with the given route variables and
+// returns the recorded response.
+func call(fn http.HandlerFunc, method, target string, body any, vars map[string]string) *httptest.ResponseRecorder {
+    var buf bytes.Buffer
+    if body != nil {
+        json.NewEncoder(&buf).Encode(body)
+    }
+    r := httptest.NewRequest(method, target, &buf)
+    if vars != nil {
+        r = mux.SetURLVars(r, vars)
+    }
+    w := httptest.NewRecorder()
+    fn(w, r)
+    return w
+}
+
+// decode reads a JSON response into v, failing the test on a bad status.
+func decode(t *testing.T, w *httptest.ResponseRecorder, status int, v any) {
+    t.Helper()
+    if w.Code != status {
+        t.Fatalf("status = %d, want %d: %s", w.Code, status, w.Body.String())
+    }
+    if v != nil {
+        if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+            t.Fatalf("decode %q: %v", w.Body.String(), err)
+        }
+    }
+}
+
+func (h *Handler) testUser(t *testing.T, name, email, phone string) string {
+    t.Helper()
+    var resp struct{ ID string }
+    w := call(h.CreateUser, "POST", "/api/users", models.UserRequest{Name: name, Email: email, Phone: phone}, nil)
+    decode(t, w, http.StatusCreated, &resp)
+    return resp.ID
+}
+
+func TestAPIUserTransactionFlow(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    carol := h.testUser(t, "Carol", "alice@example.com", "3333333333")
+
+    var created models.CreateTransactionResponse
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID: alice,
+        ToUserID:   carol,
+        Amount:     "42.5",
+        Currency:   "usd",
+        Timestamp:  "2024-01-01T12:00:00Z",
+        DeviceID:   "dev-1",
+    }, nil)
+    decode(t, w, http.StatusCreated, &created)
+    if created.ID == "" {
+        t.Fatal("no transaction id returned")
+    }
+
+    var rel struct {
+        User        models.User            `json:"user"`
+        Connections models.UserConnections `json:"connections"`
+    }
+    w = call(h.GetUserRelationships, "GET", "/api/relationships/user/"+alice, nil, map[string]string{"id": alice})
+    decode(t, w, http.StatusOK, &rel)
+    var sharedEmail, sent bool
+    for _, c := range rel.Connections.Users {
+        sharedEmail = sharedEmail || (c.Node.ID == carol && c.Relationship == "SHARED_EMAIL")
+    }
+    for _, c := range rel.Connections.Transactions {
+        sent = sent || (c.Node.ID == created.ID && c.Relationship == "SENT")
+    }
+    if !sharedEmail || !sent {
+        t.Errorf("Alice's connections = %+v, want Carol over SHARED_EMAIL and the SENT transaction", rel.Connections)
+    }
+
+    var page models.Page[models.Transaction]
+    w = call(h.GetAllTransactions, "GET", "/api/transactions?currency=USD", nil, nil)
+    decode(t, w, http.StatusOK, &page)
+    if len(page.Items) != 1 || page.Items[0].AmountMinor != 4250 {
+        t.Errorf("transactions = %+v, want one of 4250 minor units", page.Items)
+    }
+}
+
+func TestAPITransactionWithoutRate(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+
+    var created models.CreateTransactionResponse
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID: alice,
+        ToUserID:   bob,
+        Amount:     "12.34",
+        Currency:   "EUR",
+        Timestamp:  "2024-01-01T12:00:00Z",
+    }, nil)
+    decode(t, w, http.StatusCreated, &created)
+
+    var page models.Page[models.Transaction]
+    w = call(h.GetAllTransactions, "GET", "/api/transactions", nil, nil)
+    decode(t, w, http.StatusOK, &page)
+    if len(page.Items) != 1 || page.Items[0].AmountMinor != 1234 || page.Items[0].ReportingCurrency != "" {
+        t.Errorf("transactions = %+v, want 1234 EUR without a reporting amount", page.Items)
+    }
+}
+
+func TestAPITransactionDecimalAmount(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+
+    for _, tc := range []struct {
+        amount, currency string
+        minor            int64 // 0 for a rejected amount
+    }{
+        {`0.1`, "USD", 10},
+        {`"19.99"`, "USD", 1999},
+        {`" 1050 "`, "JPY", 1050},
+        {`"10.5"`, "JPY", 0},
+        {`"1e2"`, "USD", 0},
+        {`"ten"`, "USD", 0},
+    } {
+        body := json.RawMessage(`{"fromUserId":"` + alice + `","toUserId":"` + bob + `","amount":` + tc.amount +
+            `,"currency":"` + tc.currency + `","timestamp":"2024-01-01T12:00:00Z"}`)
+        w := call(h.CreateTransaction, "POST", "/api/transactions", body, nil)
+        if tc.minor == 0 {
+            if w.Code != http.StatusBadRequest {
+                t.Errorf("amount %s %s: status = %d, want 400", tc.amount, tc.currency, w.Code)
+            }
+            continue
+        }
+        var created models.CreateTransactionResponse
+        decode(t, w, http.StatusCreated, &created)
+        got, err := h.DB.GetTransaction(created.ID)
+        if err != nil {
+            t.Fatal(err)
+        }
+        if got.AmountMinor != tc.minor {
+            t.Errorf("amount %s %s stored as %d, want %d", tc.amount, tc.currency, got.AmountMinor, tc.minor)
+        }
+    }
+}
+
+func TestAPIPatchKeepsStoredAmount(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+    var created models.CreateTransactionResponse
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID:  alice,
+        ToUserID:    bob,
+        AmountMinor: func() *int64 { v := int64(1050); return &v }(),
+        Currency:    "USD",
+        Timestamp:   "2024-01-01T12:00:00Z",
+    }, nil)
+    decode(t, w, http.StatusCreated, &created)
+    vars := map[string]string{"id": created.ID}
+
+    var got models.Transaction
+    w = call(h.UpdateTransaction, "PATCH", "/api/transactions/"+created.ID, map[string]any{"timestamp": "2024-02-01T12:00:00Z"}, vars)
+    decode(t, w, http.StatusOK, &got)
+    if got.AmountMinor != 1050 || got.Currency != "USD" || got.Timestamp != "2024-02-01T12:00:00Z" {
+        t.Errorf("after a timestamp patch = %+v, want 1050 USD at the new time", got)
+    }
+
+    // 10.50 can't be JPY, which has no minor unit.
+    w = call(h.UpdateTransaction, "PATCH", "/api/transactions/"+created.ID, map[string]any{"currency": "JPY"}, vars)
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("currency patch to JPY status = %d, want 400: %s", w.Code, w.Body.String())
+    }
+    w = call(h.UpdateTransaction, "PATCH", "/api/transactions/"+created.ID, map[string]any{"currency": "EUR"}, vars)
+    got = models.Transaction{}
+    decode(t, w, http.StatusOK, &got)
+    if got.AmountMinor != 1050 || got.Currency != "EUR" || got.ReportingCurrency != "" {
+        t.Errorf("after a currency patch = %+v, want 1050 EUR without a reporting amount", got)
+    }
+}
+
+func TestAPIImportUsersScreensAndLinks(t *testing.T) {
+    h := newTestHandler(t)
+    list := filepath.Join(t.TempDir(), "denylist.csv")
+    if err := os.WriteFile(list, []byte("id,name,email\nd1,Mallory Black,mallory@example.com\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    screener, err := screening.NewScreener([]string{list}, screening.DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    h.Screening = screener
+    existing := h.testUser(t, "John Smith", "john.smith@gmail.com", "1111111111")
+
+    body := "name,email,phone\nMallory Black,mallory@example.com,2222222222\nJohn Smith,johnsmith@gmail.com,3333333333\n"
+    r := httptest.NewRequest("POST", "/api/import/users", strings.NewReader(body))
+    r.Header.Set("Content-Type", "text/csv")
+    w := httptest.NewRecorder()
+    h.ImportUsers(w, r)
+    var report models.ImportReport
+    decode(t, w, http.StatusOK, &report)
+    if report.Accepted != 2 || len(report.Errors) != 0 {
+        t.Fatalf("report = %+v, want both rows accepted", report)
+    }
+    if len(report.Rows[0].WatchlistHits) != 1 {
+        t.Errorf("row 1 = %+v, want one watchlist hit", report.Rows[0])
+    }
+    linked := false
+    for _, l := range report.Rows[1].IdentityLinks {
+        linked = linked || l.From == existing || l.To == existing
+    }
+    if !linked {
+        t.Errorf("row 2 = %+v, want linked to %s", report.Rows[1], existing)
+    }
+    links, err := h.DB.IdentityLinks()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(links) != len(report.Rows[1].IdentityLinks) {
+        t.Errorf("stored links = %+v, want those of row 2", links)
+    }
+}
+
+func TestAPIImportReportsAbortedRows(t *testing.T) {
+    h := newTestHandler(t)
+    body := "name,email,phone\nAlice,alice@example.com,1111111111\n\"Bob,bob@example.com\n"
+    r := httptest.NewRequest("POST", "/api/import/users", strings.NewReader(body))
+    r.Header.Set("Content-Type", "text/csv")
+    w := httptest.NewRecorder()
+    h.ImportUsers(w, r)
+    var report models.ImportReport
+    decode(t, w, http.StatusOK, &report)
+    if report.Accepted != 1 || report.Aborted == "" {
+        t.Fatalf("report = %+v, want row 1 accepted and the abort reported", report)
+    }
+    users, err := h.DB.GetAllUsers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 1 || users[0].ID != report.Rows[0].ID {
+        t.Errorf("stored users = %+v, want the accepted row only", users)
+    }
+
+    r = httptest.NewRequest("POST", "/api/import/users", strings.NewReader(""))
+    r.Header.Set("Content-Type", "text/csv")
+    w = httptest.NewRecorder()
+    h.ImportUsers(w, r)
+    if w.Code != http.StatusBadRequest {
+        t.Errorf("empty body status = %d, want 400", w.Code)
+    }
+}
+
+// failingStore fails the writes named in fail and passes the rest through.
+type failingStore struct {
+    graph.GraphStore
+    fail map[string]bool
+}
+
+var errStoreDown = errors.New("store down")
+
+func (s failingStore) SetWatchlistHits(hits map[string][]models.WatchlistHit) error {
+    if s.fail["SetWatchlistHits"] {
+        return errStoreDown
+    }
+    return s.GraphStore.SetWatchlistHits(hits)
+}
+
+func (s failingStore) CreateAlerts(alerts []models.Alert) ([]models.Alert, error) {
+    if s.fail["CreateAlerts"] {
+        return nil, errStoreDown
+    }
+    return s.GraphStore.CreateAlerts(alerts)
+}
+
+func (s failingStore) SetIdentityLinks(userIDs []string, links []models.IdentityLink) error {
+    if s.fail["SetIdentityLinks"] {
+        return errStoreDown
+    }
+    return s.GraphStore.SetIdentityLinks(userIDs, links)
+}
+
+func (s failingStore) StreamGraph(ctx context.Context, out graph.GraphWriter) error {
+    if s.fail["StreamGraph"] {
+        return errStoreDown
+    }
+    return s.GraphStore.StreamGraph(ctx, out)
+}
+
+func TestAPICreateUserReportsFailedChecks(t *testing.T) {
+    h := newTestHandler(t)
+    h.DB = failingStore{GraphStore: h.DB, fail: map[string]bool{"SetWatchlistHits": true}}
+
+    var resp struct {
+        ID     string   `json:"id"`
+        Errors []string `json:"errors"`
+    }
+    w := call(h.CreateUser, "POST", "/api/users", models.UserRequest{Name: "Alice", Email: "alice@example.com"}, nil)
+    decode(t, w, http.StatusCreated, &resp)
+    if resp.ID == "" || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], "screening failed") {
+        t.Errorf("response = %+v, want the id and the screening error", resp)
+    }
+    users, err := h.DB.GetAllUsers()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(users) != 1 {
+        t.Errorf("users = %+v, want the one created", users)
+    }
+}
+
+func TestAPIUpdateUserReportsFailedResolution(t *testing.T) {
+    h := newTestHandler(t)
+    id := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    h.DB = failingStore{GraphStore: h.DB, fail: map[string]bool{"SetIdentityLinks": true}}
+
+    var resp struct {
+        models.User
+        Errors []string `json:"errors"`
+    }
+    name := "Alicia"
+    w := call(h.UpdateUser, "PATCH", "/api/users/"+id, models.UserPatch{Name: &name}, map[string]string{"id": id})
+    decode(t, w, http.StatusOK, &resp)
+    if resp.Name != "Alicia" || len(resp.Errors) != 1 || !strings.Contains(resp.Errors[0], "resolution/rebuild") {
+        t.Errorf("response = %+v, want the updated user and the resolution error", resp)
+    }
+}
+
+func TestAPICreateTransactionReportsFailedAlerts(t *testing.T) {
+    h := newTestHandler(t)
+    path := filepath.Join(t.TempDir(), "rules.json")
+    if err := os.WriteFile(path, []byte(`{"rules":[{"id":"big","type":"amount_above","threshold":10}]}`), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    engine, err := rules.NewEngine(path)
+    if err != nil {
+        t.Fatal(err)
+    }
+    h.Rules = engine
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+    h.DB = failingStore{GraphStore: h.DB, fail: map[string]bool{"CreateAlerts": true}}
+
+    var created models.CreateTransactionResponse
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID: alice,
+        ToUserID:   bob,
+        Amount:     "50",
+        Currency:   "USD",
+        Timestamp:  "2024-01-01T12:00:00Z",
+    }, nil)
+    decode(t, w, http.StatusCreated, &created)
+    if created.ID == "" || len(created.Alerts) != 1 || len(created.Errors) != 1 {
+        t.Errorf("response = %+v, want the id, the unstored alert and its error", created)
+    }
+    txs, err := h.DB.GetAllTransactions()
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(txs) != 1 || txs[0].ID != created.ID {
+        t.Errorf("transactions = %+v, want only %s", txs, created.ID)
+    }
+}
+
+func TestAPITransactionFromDatetimeLocal(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+
+    // The value of the Add Transaction page's datetime-local input.
+    var created models.CreateTransactionResponse
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID: alice,
+        ToUserID:   bob,
+        Amount:     "10",
+        Currency:   "USD",
+        Timestamp:  "2026-10-17T12:34",
+    }, nil)
+    decode(t, w, http.StatusCreated, &created)
+
+    var page models.Page[models.Transaction]
+    w = call(h.GetAllTransactions, "GET", "/api/transactions", nil, nil)
+    decode(t, w, http.StatusOK, &page)
+    if len(page.Items) != 1 || page.Items[0].Timestamp != "2026-10-17T12:34:00Z" {
+        t.Errorf("transactions = %+v, want one at 2026-10-17T12:34:00Z", page.Items)
+    }
+}
+
+func TestAPIExportStreams(t *testing.T) {
+    h := newTestHandler(t)
+    alice := h.testUser(t, "Alice", "alice@example.com", "1111111111")
+    bob := h.testUser(t, "Bob", "bob@example.com", "2222222222")
+    w := call(h.CreateTransaction, "POST", "/api/transactions", models.TransactionRequest{
+        FromUserID: alice,
+        ToUserID:   bob,
+        Amount:     "10",
+        Currency:   "USD",
+        Timestamp:  "2024-01-01T12:00:00Z",
+        DeviceID:   "dev-1",
+    }, nil)
+    decode(t, w, http.StatusCreated, nil)
+    want, err := h.DB.ExportGraph()
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    r := httptest.NewRequest("GET", "/api/export/json", nil)
+    r.Header.Set("Accept-Encoding", "br, gzip;q=0.5")
+    w = httptest.NewRecorder()
+    h.ExportGraphJSON(w, r)
+    if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "gzip" {
+        t.Fatalf("json export = %d with encoding %q, want 200 gzipped", w.Code, w.Header().Get("Content-Encoding"))
+    }
+    gz, err := gzip.NewReader(w.Body)
+    if err != nil {
+        t.Fatal(err)
+    }
+    var got models.GraphExportResponse
+    if err := json.NewDecoder(gz).Decode(&got); err != nil {
+        t.Fatal(err)
+    }
+    if len(got.Nodes) != len(want.Nodes) || len(got.Relationships) != len(want.Relationships) {
+        t.Errorf("gzipped export has %d nodes and %d relationships, want %d and %d",
+            len(got.Nodes), len(got.Relationships), len(want.Nodes), len(want.Relationships))
+    }
+
+    w = call(h.ExportGraphNDJSON, "GET", "/api/export/ndjson", nil, nil)
+    if w.Code != http.StatusOK || w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Type") != "application/x-ndjson" {
+        t.Fatalf("ndjson export = %d, %v; want 200 uncompressed", w.Code, w.Header())
+    }
+    nodes, rels := 0, 0
+    for sc := bufio.NewScanner(w.Body); sc.Scan(); {
+        var line struct {
+            Node         *models.GraphNode         `json:"node"`
+            Relationship *models.GraphRelationship `json:"relationship"`
+        }
+        if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+            t.Fatalf("line %q: %v", sc.Text(), err)
+        }
+        switch {
+        case line.Node != nil && line.Relationship == nil:
+            nodes++
+        case line.Relationship != nil && line.Node == nil:
+            rels++
+        default:
+            t.Errorf("line %q, want one node or relationship", sc.Text())
+        }
+    }
+    if nodes != len(want.Nodes) || rels != len(want.Relationships) {
+        t.Errorf("ndjson export has %d nodes and %d relationships, want %d and %d", nodes, rels, len(want.Nodes), len(want.Relationships))
+    }
+
+    h.DB = failingStore{GraphStore: h.DB, fail: map[string]bool{"StreamGraph": true}}
+    r = httptest.NewRequest("GET", "/api/export/csv", nil)
+    r.Header.Set("Accept-Encoding", "gzip")
+    w = httptest.NewRecorder()
+    h.ExportGraphCSV(w, r)
+    if w.Code != http.StatusInternalServerError || w.Header().Get("Content-Encoding") != "" || w.Header().Get("Content-Disposition") != "" {
+        t.Errorf("failed export = %d, %v; want a plain 500", w.Code, w.Header())
+    }
+}
+
+func TestAcceptsGzip(t *testing.T) {
+    for header, want := range map[string]bool{
+        "":                  false,
+        "gzip":              true,
+        "br, GZIP":          true,
+        "gzip;q=0":          false,
+        "gzip; q=0.1":       true,
+        "deflate, identity": false,
+    } {
+        if got := acceptsGzip(header); got != want {
+            t.Errorf("acceptsGzip(%q) = %v, want %v", header, got, want)
+        }
+    }
+}
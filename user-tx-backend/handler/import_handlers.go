@@ -0,0 +1,320 @@
+package handler
+
+import (
+    "bufio"
+    "encoding/csv"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "mime"
+    "net/http"
+    "sort"
+    "strings"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+// importBatchSize is the number of valid rows written per store call.
+const importBatchSize = 1000
+
+// importFormat picks "csv" or "ndjson" from ?format= or the Content-Type.
+func importFormat(r *http.Request) string {
+    if f := r.URL.Query().Get("format"); f != "" {
+        return f
+    }
+    mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+    switch mt {
+    case "text/csv":
+        return "csv"
+    case "application/x-ndjson", "application/ndjson", "application/jsonl":
+        return "ndjson"
+    }
+    return ""
+}
+
+// readImportRows streams the request body and calls fn once per row with
+// its 1-based row number. CSV rows arrive as a record keyed by the header
+// columns, NDJSON rows as the raw JSON line. Blank NDJSON lines are skipped.
+func readImportRows(r *http.Request, fn func(row int, rec map[string]string, line []byte)) error {
+    switch importFormat(r) {
+    case "csv":
+        cr := csv.NewReader(r.Body)
+        cr.FieldsPerRecord = -1
+        header, err := cr.Read()
+        if err != nil {
+            return fmt.Errorf("missing CSV header")
+        }
+        for i := range header {
+            header[i] = strings.TrimSpace(header[i])
+        }
+        for row := 1; ; row++ {
+            fields, err := cr.Read()
+            if errors.Is(err, io.EOF) {
+                return nil
+            }
+            if err != nil {
+                return fmt.Errorf("row %d: %v", row, err)
+            }
+            rec := make(map[string]string, len(header))
+            for i, col := range header {
+                if i < len(fields) {
+                    rec[col] = strings.TrimSpace(fields[i])
+                }
+            }
+            fn(row, rec, nil)
+        }
+    case "ndjson":
+        sc := bufio.NewScanner(r.Body)
+        sc.Buffer(make([]byte, 64*1024), 1024*1024)
+        for row := 1; sc.Scan(); row++ {
+            line := sc.Bytes()
+            if len(strings.TrimSpace(string(line))) == 0 {
+                continue
+            }
+            fn(row, nil, line)
+        }
+        return sc.Err()
+    }
+    return errUnsupportedFormat
+}
+
+var errUnsupportedFormat = errors.New("unsupported format: send text/csv or application/x-ndjson, or set ?format=csv|ndjson")
+
+// importBatch buffers valid rows, writes them to the store importBatchSize
+// at a time and collects the per-row outcome.
+type importBatch[T any] struct {
+    rows   []int
+    items  []T
+    write  func([]T) ([]models.ImportRowResult, error)
+    report models.ImportReport
+}
+
+func (b *importBatch[T]) add(row int, item T) {
+    b.rows = append(b.rows, row)
+    b.items = append(b.items, item)
+    if len(b.items) >= importBatchSize {
+        b.flush()
+    }
+}
+
+func (b *importBatch[T]) reject(row int, reason string) {
+    b.record(models.ImportRowResult{Row: row, Status: "rejected", Reason: reason})
+}
+
+func (b *importBatch[T]) record(res models.ImportRowResult) {
+    if res.Status == "accepted" {
+        b.report.Accepted++
+    } else {
+        b.report.Rejected++
+    }
+    b.report.Rows = append(b.report.Rows, res)
+}
+
+func (b *importBatch[T]) flush() {
+    if len(b.items) == 0 {
+        return
+    }
+    results, err := b.write(b.items)
+    for i, row := range b.rows {
+        if err != nil {
+            b.reject(row, "batch failed: "+err.Error())
+            continue
+        }
+        res := results[i]
+        res.Row = row
+        b.record(res)
+    }
+    b.rows, b.items = b.rows[:0], b.items[:0]
+}
+
+// finish flushes the last batch and writes the report sorted by row.
+func (b *importBatch[T]) finish(w http.ResponseWriter) {
+    b.flush()
+    if b.report.Rows == nil {
+        b.report.Rows = []models.ImportRowResult{}
+    }
+    sort.Slice(b.report.Rows, func(i, j int) bool { return b.report.Rows[i].Row < b.report.Rows[j].Row })
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(b.report)
+}
+
+// abort ends an import whose body failed to read partway. The rows read
+// before the error are written like any others and reported, with the
+// error under aborted, so the client sees which rows were imported. A body
+// that fails before its first row answers 400.
+func (b *importBatch[T]) abort(w http.ResponseWriter, err error) {
+    read := len(b.report.Rows) + len(b.items)
+    if read == 0 {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    b.report.Aborted = fmt.Sprintf("import aborted after %d rows: %v", read, err)
+    b.finish(w)
+}
+
+// ImportUsers handles POST /api/import/users with a CSV (name,email,phone)
+// or NDJSON body. Each batch is screened and linked to probable matches
+// like a single new user.
+func (h *Handler) ImportUsers(w http.ResponseWriter, r *http.Request) {
+    b := &importBatch[models.UserRequest]{}
+    b.write = func(rows []models.UserRequest) ([]models.ImportRowResult, error) {
+        results, err := h.DB.ImportUsers(rows)
+        if err != nil {
+            return nil, err
+        }
+        b.report.Errors = append(b.report.Errors, h.checkImportedUsers(rows, results)...)
+        return results, nil
+    }
+    err := readImportRows(r, func(row int, rec map[string]string, line []byte) {
+        var req models.UserRequest
+        if rec != nil {
+            req = models.UserRequest{Name: rec["name"], Email: rec["email"], Phone: rec["phone"]}
+        } else if err := json.Unmarshal(line, &req); err != nil {
+            b.reject(row, "malformed JSON")
+            return
+        }
+        if req.Name == "" {
+            b.reject(row, "missing name")
+            return
+        }
+        b.add(row, req)
+    })
+    if errors.Is(err, errUnsupportedFormat) {
+        http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+        return
+    }
+    if err != nil {
+        b.abort(w, err)
+        return
+    }
+    b.finish(w)
+}
+
+// checkImportedUsers screens the accepted users of a batch and links them
+// to probable matches, adding the outcome to their rows. The users are
+// already written, so a failure does not reject them; it is returned
+// instead, naming the endpoint that catches them up.
+func (h *Handler) checkImportedUsers(rows []models.UserRequest, results []models.ImportRowResult) []string {
+    var users []models.User
+    index := make(map[string]int)
+    for i, res := range results {
+        if res.Status == "accepted" {
+            r := rows[i]
+            users = append(users, models.User{ID: res.ID, Name: r.Name, Email: r.Email, Phone: r.Phone})
+            index[res.ID] = i
+        }
+    }
+    if len(users) == 0 {
+        return nil
+    }
+    var errs []string
+    hits, err := h.Screening.ScreenUsers(h.DB, users)
+    if err != nil {
+        errs = append(errs, fmt.Sprintf("screening %d users failed, run POST /api/screening/rescan: %v", len(users), err))
+    }
+    for id, list := range hits {
+        results[index[id]].WatchlistHits = list
+    }
+    links, err := h.Resolver.LinkUsers(h.DB, users)
+    if err != nil {
+        errs = append(errs, fmt.Sprintf("entity resolution of %d users failed, run POST /api/resolution/rebuild: %v", len(users), err))
+    }
+    for _, l := range links {
+        for _, id := range []string{l.From, l.To} {
+            if i, ok := index[id]; ok {
+                results[i].IdentityLinks = append(results[i].IdentityLinks, l)
+            }
+        }
+    }
+    return errs
+}
+
+// ImportTransactions handles POST /api/import/transactions with a CSV
+// (fromUserId,toUserId,amount,currency,timestamp,description,deviceId and
+// optional ip and status columns) or NDJSON body. Rows start out pending
+// unless their status is settled; reversals and chargebacks cannot be
+// imported. Amounts are converted like those of single transactions.
+// Imported rows are not run through the fraud rules and raise no alerts.
+func (h *Handler) ImportTransactions(w http.ResponseWriter, r *http.Request) {
+    b := &importBatch[models.TransactionRequest]{write: h.DB.ImportTransactions}
+    err := readImportRows(r, func(row int, rec map[string]string, line []byte) {
+        var req models.TransactionRequest
+        if rec != nil {
+            if rec["amount"] == "" {
+                b.reject(row, "invalid amount")
+                return
+            }
+            req = models.TransactionRequest{
+                FromUserID:  rec["fromUserId"],
+                ToUserID:    rec["toUserId"],
+                Amount:      models.Decimal(rec["amount"]),
+                Currency:    rec["currency"],
+                Timestamp:   rec["timestamp"],
+                Description: rec["description"],
+                DeviceID:    rec["deviceId"],
+                IP:          rec["ip"],
+                Status:      rec["status"],
+            }
+        } else if err := json.Unmarshal(line, &req); err != nil {
+            b.reject(row, "malformed JSON")
+            return
+        }
+        switch {
+        case req.FromUserID == "":
+            b.reject(row, "missing fromUserId")
+        case req.ToUserID == "":
+            b.reject(row, "missing toUserId")
+        case req.ReversalOf != "" || req.ChargebackOf != "":
+            b.reject(row, "reversals and chargebacks cannot be imported")
+        default:
+            status, err := graph.InitialStatus(req.Status)
+            if err != nil {
+                b.reject(row, "invalid status")
+                return
+            }
+            req.Status = status
+            if err := h.convertAmount(&req); err != nil {
+                b.reject(row, err.Error())
+                return
+            }
+            b.add(row, req)
+        }
+    })
+    if errors.Is(err, errUnsupportedFormat) {
+        http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+        return
+    }
+    if err != nil {
+        b.abort(w, err)
+        return
+    }
+    b.finish(w)
+}
+
+// ImportGraph handles POST /api/import/graph?mode=merge|replace&dryRun=true&keepIds=true
+// with a document produced by GET /api/export/json.
+func (h *Handler) ImportGraph(w http.ResponseWriter, r *http.Request) {
+    var doc models.GraphExportResponse
+    if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    q := r.URL.Query()
+    report, err := h.DB.RestoreGraph(doc, models.RestoreOptions{
+        Mode:    q.Get("mode"),
+        DryRun:  q.Get("dryRun") == "true",
+        KeepIDs: q.Get("keepIds") == "true",
+    })
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, "restore failed: "+err.Error(), http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(report)
+}
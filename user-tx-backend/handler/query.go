@@ -0,0 +1,90 @@
+package handler
+
+import (
+    "fmt"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+// intParam reads an optional integer query parameter, returning def when absent.
+func intParam(q url.Values, name string, def int) (int, error) {
+    v := q.Get(name)
+    if v == "" {
+        return def, nil
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s", name)
+    }
+    return n, nil
+}
+
+// floatParam reads an optional float query parameter, returning nil when absent.
+func floatParam(q url.Values, name string) (*float64, error) {
+    v := q.Get(name)
+    if v == "" {
+        return nil, nil
+    }
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil {
+        return nil, fmt.Errorf("invalid %s", name)
+    }
+    return &f, nil
+}
+
+// listParam reads an optional comma-separated query parameter.
+func listParam(q url.Values, name string) []string {
+    var list []string
+    for _, v := range strings.Split(q.Get(name), ",") {
+        if v = strings.TrimSpace(v); v != "" {
+            list = append(list, v)
+        }
+    }
+    return list
+}
+
+// sortParams reads the sort, order and cursor/limit parameters shared by
+// every paginated list.
+func sortParams(q url.Values) (sortBy string, desc bool, cursor string, limit int, err error) {
+    switch q.Get("order") {
+    case "", "asc":
+    case "desc":
+        desc = true
+    default:
+        return "", false, "", 0, fmt.Errorf("invalid order")
+    }
+    limit, err = intParam(q, "limit", 0)
+    return q.Get("sort"), desc, q.Get("cursor"), limit, err
+}
+
+// pathParams reads the shortest-path constraints. constrained is false when
+// none are given, so the plain shortest path can be used.
+func pathParams(q url.Values) (pq models.PathQuery, constrained bool, err error) {
+    if v := q.Get("types"); v != "" {
+        for _, t := range strings.Split(v, ",") {
+            pq.Types = append(pq.Types, strings.ToUpper(strings.TrimSpace(t)))
+        }
+    }
+    pq.Directed = q.Get("directed") == "true"
+    if pq.MaxHops, err = intParam(q, "maxHops", 0); err != nil {
+        return pq, false, err
+    }
+    pq.Weight = q.Get("weight")
+    constrained = len(pq.Types) > 0 || pq.Directed || pq.MaxHops != 0 || pq.Weight != ""
+    return pq, constrained, nil
+}
+
+// windowStore returns the store a read with the asOf, from and to query
+// parameters runs against: h.DB when they are absent, otherwise the graph
+// as it was in that window.
+func (h *Handler) windowStore(q url.Values) (graph.GraphStore, error) {
+    win, err := graph.ParseWindow(q.Get("asOf"), q.Get("from"), q.Get("to"))
+    if err != nil || win.IsZero() {
+        return h.DB, err
+    }
+    return graph.View(h.DB, win)
+}
@@ -3,13 +3,12 @@ package handler
 import (
     "encoding/json"
     "net/http"
-    "strconv"
 
     "github.com/gorilla/mux"
     "user-tx-backend/models"
 )
 
-// GetUserRelationships handles GET /api/relationships/user/{id}
+// GetUserRelationships handles GET /api/relationships/user/{id}?asOf=&from=&to=
 func (h *Handler) GetUserRelationships(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     idStr, ok := vars["id"]
@@ -17,13 +16,12 @@ func (h *Handler) GetUserRelationships(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "missing user id", http.StatusBadRequest)
         return
     }
-    uid, err := strconv.ParseInt(idStr, 10, 64)
+    db, err := h.windowStore(r.URL.Query())
     if err != nil {
-        http.Error(w, "invalid user id", http.StatusBadRequest)
+        writeStoreError(w, err)
         return
     }
-
-    user, conns, err := h.DB.GetUserRelationships(uid)
+    user, conns, err := db.GetUserRelationships(idStr)
     if err != nil {
         http.Error(w, "fetch user relationships failed", http.StatusInternalServerError)
         return
@@ -36,7 +34,7 @@ func (h *Handler) GetUserRelationships(w http.ResponseWriter, r *http.Request) {
     json.NewEncoder(w).Encode(resp)
 }
 
-// GetTransactionRelationships handles GET /api/relationships/transaction/{id}
+// GetTransactionRelationships handles GET /api/relationships/transaction/{id}?asOf=&from=&to=
 func (h *Handler) GetTransactionRelationships(w http.ResponseWriter, r *http.Request) {
     vars := mux.Vars(r)
     idStr, ok := vars["id"]
@@ -44,13 +42,12 @@ func (h *Handler) GetTransactionRelationships(w http.ResponseWriter, r *http.Req
         http.Error(w, "missing transaction id", http.StatusBadRequest)
         return
     }
-    txID, err := strconv.ParseInt(idStr, 10, 64)
+    db, err := h.windowStore(r.URL.Query())
     if err != nil {
-        http.Error(w, "invalid transaction id", http.StatusBadRequest)
+        writeStoreError(w, err)
         return
     }
-
-    txNode, conns, err := h.DB.GetTransactionRelationships(txID)
+    txNode, conns, err := db.GetTransactionRelationships(idStr)
     if err != nil {
         http.Error(w, "fetch transaction relationships failed", http.StatusInternalServerError)
         return
@@ -0,0 +1,94 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+
+    "github.com/gorilla/mux"
+    "user-tx-backend/models"
+    "user-tx-backend/resolve"
+)
+
+// GetMergeProposals handles GET /api/resolution/proposals?minConfidence=
+// Groups of users that probably are one person are listed most confident
+// first; minConfidence defaults to resolve.DefaultMinConfidence.
+func (h *Handler) GetMergeProposals(w http.ResponseWriter, r *http.Request) {
+    min, err := floatParam(r.URL.Query(), "minConfidence")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    minConfidence := resolve.DefaultMinConfidence
+    if min != nil {
+        minConfidence = *min
+    }
+    proposals, err := h.Resolver.Proposals(h.DB, minConfidence)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(proposals)
+}
+
+// RebuildResolution handles POST /api/resolution/rebuild, re-deriving the
+// probable-match links of every user, e.g. after a bulk import.
+func (h *Handler) RebuildResolution(w http.ResponseWriter, r *http.Request) {
+    resp, err := h.Resolver.Rebuild(h.DB)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetPersons handles GET /api/resolution/persons
+func (h *Handler) GetPersons(w http.ResponseWriter, r *http.Request) {
+    persons, err := h.DB.ListPersons()
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(persons)
+}
+
+// CreatePerson handles POST /api/resolution/persons, merging the given
+// users into a new Person. It is how a merge proposal is accepted.
+func (h *Handler) CreatePerson(w http.ResponseWriter, r *http.Request) {
+    var req models.PersonRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    p, err := h.DB.CreatePerson(req)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusCreated)
+    json.NewEncoder(w).Encode(p)
+}
+
+// GetPerson handles GET /api/resolution/persons/{id}
+func (h *Handler) GetPerson(w http.ResponseWriter, r *http.Request) {
+    p, err := h.DB.GetPerson(mux.Vars(r)["id"])
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(p)
+}
+
+// DeletePerson handles DELETE /api/resolution/persons/{id}, undoing a
+// merge. The users themselves are kept.
+func (h *Handler) DeletePerson(w http.ResponseWriter, r *http.Request) {
+    if err := h.DB.DeletePerson(mux.Vars(r)["id"]); err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+    "encoding/json"
+    "errors"
+    "net/http"
+
+    "github.com/gorilla/mux"
+    "user-tx-backend/graph"
+    "user-tx-backend/risk"
+)
+
+// GetUserRisk handles GET /api/users/{id}/risk
+func (h *Handler) GetUserRisk(w http.ResponseWriter, r *http.Request) {
+    score, err := h.Risk.User(h.DB, mux.Vars(r)["id"])
+    if errors.Is(err, graph.ErrUserNotFound) {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(score)
+}
+
+// GetTransactionRisk handles GET /api/transactions/{id}/risk
+func (h *Handler) GetTransactionRisk(w http.ResponseWriter, r *http.Request) {
+    score, err := h.Risk.Transaction(h.DB, mux.Vars(r)["id"])
+    if errors.Is(err, graph.ErrTransactionNotFound) {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(score)
+}
+
+// RecomputeRisk handles POST /api/risk/recompute?top=10. Every score is
+// stored as the risk property of its user or transaction.
+func (h *Handler) RecomputeRisk(w http.ResponseWriter, r *http.Request) {
+    top, err := intParam(r.URL.Query(), "top", 10)
+    if err != nil || top < 0 {
+        http.Error(w, "invalid top", http.StatusBadRequest)
+        return
+    }
+    resp, err := h.Risk.Recompute(h.DB, top)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// GetRiskConfig handles GET /api/risk/config
+func (h *Handler) GetRiskConfig(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(h.Risk.Config())
+}
+
+// UpdateRiskConfig handles PUT /api/risk/config. The new weights apply
+// until the config file is reloaded or the server restarts.
+func (h *Handler) UpdateRiskConfig(w http.ResponseWriter, r *http.Request) {
+    var cfg risk.Config
+    if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    if err := h.Risk.SetConfig(cfg); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    h.GetRiskConfig(w, r)
+}
+
+// ReloadRiskConfig handles POST /api/risk/config/reload
+func (h *Handler) ReloadRiskConfig(w http.ResponseWriter, r *http.Request) {
+    if err := h.Risk.Reload(); err != nil {
+        http.Error(w, "reload failed: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    h.GetRiskConfig(w, r)
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+// GetRules handles GET /api/rules
+func (h *Handler) GetRules(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]any{"rules": h.Rules.Rules()})
+}
+
+// ReloadRules handles POST /api/rules/reload. A broken file is reported and
+// the previous rules stay active.
+func (h *Handler) ReloadRules(w http.ResponseWriter, r *http.Request) {
+    if err := h.Rules.Reload(); err != nil {
+        http.Error(w, "reload failed: "+err.Error(), http.StatusBadRequest)
+        return
+    }
+    h.GetRules(w, r)
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+    "encoding/json"
+    "net/http"
+)
+
+// GetWatchlists handles GET /api/screening/lists, summarising the loaded
+// entries per list.
+func (h *Handler) GetWatchlists(w http.ResponseWriter, r *http.Request) {
+    counts := make(map[string]int)
+    for _, e := range h.Screening.Entries() {
+        counts[e.List]++
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(map[string]any{
+        "threshold": h.Screening.Threshold(),
+        "lists":     counts,
+    })
+}
+
+// RescanWatchlists handles POST /api/screening/rescan. The list files are
+// re-read first, then every user is screened and its WATCHLIST_HIT
+// relationships replaced. A broken list file aborts the rescan.
+func (h *Handler) RescanWatchlists(w http.ResponseWriter, r *http.Request) {
+    if h.Screening.Configured() {
+        if err := h.Screening.Reload(); err != nil {
+            http.Error(w, "reload failed: "+err.Error(), http.StatusBadRequest)
+            return
+        }
+    }
+    resp, err := h.Screening.Rescan(h.DB)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
@@ -2,41 +2,343 @@ package handler
 
 import (
     "encoding/json"
+    "errors"
+    "fmt"
     "net/http"
+    "time"
 
+    "github.com/gorilla/mux"
+    "user-tx-backend/fx"
+    "user-tx-backend/graph"
     "user-tx-backend/models"
 )
 
-// CreateTransaction handles POST /api/transactions
+// convertAmount validates the currency and amount of req, given in
+// amountMinor or else in amount, a decimal number or string, and converts it to the reporting currency
+// at the transaction's timestamp into req.Money. The timestamp is rewritten
+// as RFC3339, the only form the stores accept.
+func (h *Handler) convertAmount(req *models.TransactionRequest) error {
+    ts, err := fx.ParseTimestamp(req.Timestamp)
+    if err != nil {
+        return err
+    }
+    req.Timestamp = ts.Format(time.RFC3339Nano)
+    var money models.Money
+    if req.AmountMinor != nil {
+        cur, err := fx.Normalize(req.Currency)
+        if err != nil {
+            return err
+        }
+        if money, err = h.FX.MoneyMinor(*req.AmountMinor, cur, req.Timestamp); err != nil {
+            return err
+        }
+    } else {
+        // A missing amount is zero, as it was when amounts were floats.
+        amount := string(req.Amount)
+        if amount == "" {
+            amount = "0"
+        }
+        if money, err = h.FX.MoneyDecimal(amount, req.Currency, req.Timestamp); err != nil {
+            return err
+        }
+    }
+    req.Money = money
+    req.Amount = models.Decimal(fx.Format(money.Minor, money.Currency))
+    req.Currency = money.Currency
+    return nil
+}
+
+// CreateTransaction handles POST /api/transactions. A transaction starts
+// out pending unless its status is settled. One naming an original in
+// reversalOf or chargebackOf is linked to it, and the original moves to
+// reversed or charged_back. The amount is converted to the reporting
+// currency at the transaction's timestamp.
 func (h *Handler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
     var req models.TransactionRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
         http.Error(w, "invalid JSON", http.StatusBadRequest)
         return
     }
+    if err := h.convertAmount(&req); err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    status, err := graph.InitialStatus(req.Status)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    rel, originalID, err := graph.CheckFollowUp(h.DB, req)
+    if errors.Is(err, graph.ErrTransactionNotFound) {
+        http.Error(w, "unknown original transaction", http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    // Run the fraud rules before writing anything.
+    alerts, reject, err := h.Rules.Evaluate(h.DB, req)
+    if errors.Is(err, graph.ErrInvalidQuery) {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    if err != nil {
+        http.Error(w, "rule evaluation failed: "+err.Error(), http.StatusInternalServerError)
+        return
+    }
+    if reject {
+        alerts, err = h.DB.CreateAlerts(alerts)
+        if err != nil {
+            http.Error(w, "create alerts failed", http.StatusInternalServerError)
+            return
+        }
+        w.Header().Set("Content-Type", "application/json")
+        w.WriteHeader(http.StatusUnprocessableEntity)
+        json.NewEncoder(w).Encode(models.CreateTransactionResponse{Alerts: alerts})
+        return
+    }
+
     id, err := h.DB.CreateTransaction(
         req.FromUserID,
         req.ToUserID,
-        req.Amount,
-        req.Currency,
+        req.Money,
         req.Timestamp,
         req.Description,
         req.DeviceID,
+        req.IP,
+        status,
     )
     if err != nil {
         http.Error(w, "create transaction failed", http.StatusInternalServerError)
         return
     }
+    if rel != "" {
+        if err := h.DB.LinkFollowUp(id, originalID, rel); err != nil {
+            // The original changed in between; drop the follow-up again.
+            if derr := h.DB.DeleteTransaction(id); derr != nil {
+                http.Error(w, fmt.Sprintf("link follow-up failed: %v; transaction %s was written and could not be removed: %v", err, id, derr),
+                    http.StatusInternalServerError)
+                return
+            }
+            writeStoreError(w, err)
+            return
+        }
+    }
+    // The transaction is written: from here on failures are reported with
+    // the 201, so a client retrying on an error doesn't create it twice.
+    resp := models.CreateTransactionResponse{ID: id}
+    for i := range alerts {
+        alerts[i].TransactionID = id
+    }
+    if resp.Alerts, err = h.DB.CreateAlerts(alerts); err != nil {
+        resp.Alerts = alerts
+        resp.Errors = append(resp.Errors, "create alerts failed, the alerts listed were not stored: "+err.Error())
+    }
     w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(map[string]int64{"id": id})
+    json.NewEncoder(w).Encode(resp)
 }
 
-// GetAllTransactions handles GET /api/transactions
+// GetTransactionAlerts handles GET /api/transactions/{id}/alerts
+func (h *Handler) GetTransactionAlerts(w http.ResponseWriter, r *http.Request) {
+    alerts, err := h.DB.TransactionAlerts(mux.Vars(r)["id"])
+    if errors.Is(err, graph.ErrTransactionNotFound) {
+        http.Error(w, err.Error(), http.StatusNotFound)
+        return
+    }
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusInternalServerError)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(alerts)
+}
+
+// GetAllTransactions handles GET /api/transactions with optional filters
+// minAmount, maxAmount, currency, from, to, sender, receiver, deviceId, ip and
+// status, plus sort, order, cursor and limit.
 func (h *Handler) GetAllTransactions(w http.ResponseWriter, r *http.Request) {
-    txs, err := h.DB.GetAllTransactions()
+    q := r.URL.Query()
+    sortBy, desc, cursor, limit, err := sortParams(q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    minAmount, err := floatParam(q, "minAmount")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    maxAmount, err := floatParam(q, "maxAmount")
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    page, err := h.DB.ListTransactions(models.TransactionQuery{
+        MinAmount:  minAmount,
+        MaxAmount:  maxAmount,
+        Currency:   q.Get("currency"),
+        From:       q.Get("from"),
+        To:         q.Get("to"),
+        SenderID:   q.Get("sender"),
+        ReceiverID: q.Get("receiver"),
+        DeviceID:   q.Get("deviceId"),
+        IP:         q.Get("ip"),
+        Status:     q.Get("status"),
+        SortBy:     sortBy,
+        Desc:       desc,
+        Cursor:     cursor,
+        Limit:      limit,
+    })
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, "fetch transactions failed", http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
+
+// UpdateTransaction handles PUT and PATCH /api/transactions/{id}. PUT
+// replaces every field, PATCH only the ones present in the body. A new
+// amount, currency or timestamp converts the amount again.
+func (h *Handler) UpdateTransaction(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    var patch models.TransactionPatch
+    if r.Method == http.MethodPut {
+        var req models.TransactionRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "invalid JSON", http.StatusBadRequest)
+            return
+        }
+        if err := h.convertAmount(&req); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        patch = models.TransactionPatch{
+            FromUserID:  &req.FromUserID,
+            ToUserID:    &req.ToUserID,
+            Amount:      &req.Amount,
+            Currency:    &req.Currency,
+            Timestamp:   &req.Timestamp,
+            Description: &req.Description,
+            DeviceID:    &req.DeviceID,
+            IP:          &req.IP,
+            Money:       &req.Money,
+        }
+    } else if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    } else if patch.Amount != nil || patch.AmountMinor != nil || patch.Currency != nil || patch.Timestamp != nil {
+        // Convert the patched amount with the fields left as they are.
+        cur, err := h.DB.GetTransaction(id)
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        req := models.TransactionRequest{Currency: cur.Currency, Timestamp: cur.Timestamp}
+        if patch.Currency != nil {
+            req.Currency = *patch.Currency
+        }
+        if patch.Timestamp != nil {
+            req.Timestamp = *patch.Timestamp
+        }
+        switch {
+        case patch.AmountMinor != nil:
+            req.AmountMinor = patch.AmountMinor
+        case patch.Amount != nil:
+            req.Amount = *patch.Amount
+        default:
+            // The stored amount, exact, read again in the new currency.
+            minor, err := storedMinor(cur, req.Currency)
+            if err != nil {
+                http.Error(w, err.Error(), http.StatusBadRequest)
+                return
+            }
+            req.AmountMinor = &minor
+        }
+        if err := h.convertAmount(&req); err != nil {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+            return
+        }
+        patch.Money = &req.Money
+        if patch.Timestamp != nil {
+            patch.Timestamp = &req.Timestamp
+        }
+    }
+
+    txn, err := h.DB.UpdateTransaction(id, patch)
+    if err != nil {
+        switch {
+        case errors.Is(err, graph.ErrTransactionNotFound):
+            http.Error(w, err.Error(), http.StatusNotFound)
+        case errors.Is(err, graph.ErrUserNotFound):
+            http.Error(w, "unknown sender or receiver", http.StatusBadRequest)
+        default:
+            http.Error(w, "update transaction failed", http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(txn)
+}
+
+// storedMinor returns the stored amount of t in minor units of currency,
+// failing when currency has fewer decimals than the amount needs.
+func storedMinor(t models.Transaction, currency string) (int64, error) {
+    cur, err := fx.Normalize(currency)
+    if err != nil {
+        return 0, err
+    }
+    if cur == t.Currency {
+        return t.AmountMinor, nil
+    }
+    return fx.ParseMinor(fx.Format(t.AmountMinor, t.Currency), cur)
+}
+
+// DeleteTransaction handles DELETE /api/transactions/{id}
+func (h *Handler) DeleteTransaction(w http.ResponseWriter, r *http.Request) {
+    if err := h.DB.DeleteTransaction(mux.Vars(r)["id"]); err != nil {
+        if errors.Is(err, graph.ErrTransactionNotFound) {
+            http.Error(w, err.Error(), http.StatusNotFound)
+        } else {
+            http.Error(w, "delete transaction failed", http.StatusInternalServerError)
+        }
+        return
+    }
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// GetTransactionStatus handles GET /api/transactions/{id}/status, returning
+// the current status and the history of transitions, oldest first.
+func (h *Handler) GetTransactionStatus(w http.ResponseWriter, r *http.Request) {
+    resp, err := h.DB.TransactionStatus(mux.Vars(r)["id"])
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// SetTransactionStatus handles POST /api/transactions/{id}/status with
+// {"status": ..., "reason": ...}. A transition the lifecycle does not allow
+// is rejected with 409.
+func (h *Handler) SetTransactionStatus(w http.ResponseWriter, r *http.Request) {
+    var req models.StatusRequest
+    if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+    resp, err := h.DB.SetTransactionStatus(mux.Vars(r)["id"], req)
     if err != nil {
-        http.Error(w, "fetch transactions failed", http.StatusInternalServerError)
+        writeStoreError(w, err)
         return
     }
-    json.NewEncoder(w).Encode(txs)
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
 }
@@ -2,21 +2,42 @@ package handler
 
 import (
     "encoding/json"
+    "errors"
     "net/http"
 
+    "github.com/gorilla/mux"
+    "user-tx-backend/fx"
     "user-tx-backend/graph"
     "user-tx-backend/models"
+    "user-tx-backend/resolve"
+    "user-tx-backend/risk"
+    "user-tx-backend/rules"
+    "user-tx-backend/screening"
 )
 
 type Handler struct {
-    DB *graph.Driver
+    DB        graph.GraphStore
+    Rules     *rules.Engine
+    Risk      *risk.Scorer
+    Screening *screening.Screener
+    Resolver  *resolve.Resolver
+    FX        *fx.Converter
 }
 
-func NewHandler(db *graph.Driver) *Handler {
-    return &Handler{DB: db}
+func NewHandler(
+    db graph.GraphStore,
+    engine *rules.Engine,
+    scorer *risk.Scorer,
+    screener *screening.Screener,
+    resolver *resolve.Resolver,
+    conv *fx.Converter,
+) *Handler {
+    return &Handler{DB: db, Rules: engine, Risk: scorer, Screening: screener, Resolver: resolver, FX: conv}
 }
 
-// CreateUser handles POST /api/users
+// CreateUser handles POST /api/users. The new user is screened against the
+// watchlists and linked to probable duplicates; hits and links are
+// returned with its id, and failures of either under errors.
 func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
     var req models.UserRequest
     if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -28,16 +49,121 @@ func (h *Handler) CreateUser(w http.ResponseWriter, r *http.Request) {
         http.Error(w, "create user failed", http.StatusInternalServerError)
         return
     }
+    // The user is written: from here on failures are reported with the
+    // 201, so a client retrying on an error doesn't create it twice.
+    resp := map[string]any{"id": id}
+    var errs []string
+
+    // Screen the new user against the watchlists.
+    user := models.User{ID: id, Name: req.Name, Email: req.Email, Phone: req.Phone}
+    hits, err := h.Screening.ScreenUser(h.DB, user)
+    if err != nil {
+        errs = append(errs, "screening failed, run POST /api/screening/rescan: "+err.Error())
+    }
+    // Link the new user to users that probably are the same person.
+    links, err := h.Resolver.LinkUser(h.DB, user)
+    if err != nil {
+        errs = append(errs, "entity resolution failed, run POST /api/resolution/rebuild: "+err.Error())
+    }
+    if len(hits) > 0 {
+        resp["watchlistHits"] = hits
+    }
+    if len(links) > 0 {
+        resp["identityLinks"] = links
+    }
+    if len(errs) > 0 {
+        resp["errors"] = errs
+    }
     w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(map[string]int64{"id": id})
+    json.NewEncoder(w).Encode(resp)
 }
 
-// GetAllUsers handles GET /api/users
+// GetAllUsers handles GET /api/users?name=&email=&phone=&sort=&order=&cursor=&limit=
+// The name, email and phone parameters are prefix filters.
 func (h *Handler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-    users, err := h.DB.GetAllUsers()
+    q := r.URL.Query()
+    sortBy, desc, cursor, limit, err := sortParams(q)
+    if err != nil {
+        http.Error(w, err.Error(), http.StatusBadRequest)
+        return
+    }
+    page, err := h.DB.ListUsers(models.UserQuery{
+        NamePrefix:  q.Get("name"),
+        EmailPrefix: q.Get("email"),
+        PhonePrefix: q.Get("phone"),
+        SortBy:      sortBy,
+        Desc:        desc,
+        Cursor:      cursor,
+        Limit:       limit,
+    })
+    if err != nil {
+        if errors.Is(err, graph.ErrInvalidQuery) {
+            http.Error(w, err.Error(), http.StatusBadRequest)
+        } else {
+            http.Error(w, "fetch users failed", http.StatusInternalServerError)
+        }
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(page)
+}
+
+// UpdateUser handles PUT and PATCH /api/users/{id}. PUT replaces every
+// field, PATCH only the ones present in the body. The user's probable-match
+// links are derived again from the new values.
+func (h *Handler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    var patch models.UserPatch
+    if r.Method == http.MethodPut {
+        var req models.UserRequest
+        if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+            http.Error(w, "invalid JSON", http.StatusBadRequest)
+            return
+        }
+        patch = models.UserPatch{Name: &req.Name, Email: &req.Email, Phone: &req.Phone}
+    } else if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+        http.Error(w, "invalid JSON", http.StatusBadRequest)
+        return
+    }
+
+    user, err := h.DB.UpdateUser(id, patch)
     if err != nil {
-        http.Error(w, "fetch users failed", http.StatusInternalServerError)
+        if errors.Is(err, graph.ErrUserNotFound) {
+            http.Error(w, err.Error(), http.StatusNotFound)
+        } else {
+            http.Error(w, "update user failed", http.StatusInternalServerError)
+        }
+        return
+    }
+    // The update is written: a failure to relink it is reported with the
+    // 200, as CreateUser does with its 201.
+    resp := struct {
+        models.User
+        Errors []string `json:"errors,omitempty"`
+    }{User: user}
+    if _, err := h.Resolver.LinkUser(h.DB, user); err != nil {
+        resp.Errors = append(resp.Errors, "entity resolution failed, run POST /api/resolution/rebuild: "+err.Error())
+    }
+    w.Header().Set("Content-Type", "application/json")
+    json.NewEncoder(w).Encode(resp)
+}
+
+// DeleteUser handles DELETE /api/users/{id}. Users with transactions are
+// refused with 409 unless ?cascade=true, which deletes those transactions too.
+func (h *Handler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+    id := mux.Vars(r)["id"]
+    cascade := r.URL.Query().Get("cascade") == "true"
+
+    if err := h.DB.DeleteUser(id, cascade); err != nil {
+        switch {
+        case errors.Is(err, graph.ErrUserNotFound):
+            http.Error(w, err.Error(), http.StatusNotFound)
+        case errors.Is(err, graph.ErrUserHasTransactions):
+            http.Error(w, err.Error()+"; retry with ?cascade=true", http.StatusConflict)
+        default:
+            http.Error(w, "delete user failed", http.StatusInternalServerError)
+        }
         return
     }
-    json.NewEncoder(w).Encode(users)
+    w.WriteHeader(http.StatusNoContent)
 }
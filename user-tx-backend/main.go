@@ -1,17 +1,29 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"        
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 
+	"user-tx-backend/fx"
 	"user-tx-backend/graph"
 	"user-tx-backend/handler"
+	"user-tx-backend/models"
+	"user-tx-backend/resolve"
+	"user-tx-backend/risk"
+	"user-tx-backend/rules"
+	"user-tx-backend/screening"
 )
 
 func main() {
@@ -27,40 +39,165 @@ func main() {
 		port = "8080"
 	}
 
-	drv, err := graph.NewDriver(uri, user, pass)
+	// STORE=memory runs the API without Neo4j
+	var store graph.GraphStore
+	if os.Getenv("STORE") == "memory" {
+		store = graph.NewMemoryStore()
+		log.Println("Using in-memory graph store")
+	} else {
+		drv, err := graph.NewDriver(uri, user, pass)
+		if err != nil {
+			log.Fatalf("DataBase connection failed: %v", err)
+		}
+		defer drv.Close()
+		if err := drv.EnsureSchema(); err != nil {
+			log.Fatalf("Schema setup failed: %v", err)
+		}
+		linked, deleted, err := drv.MigrateSharedLinks()
+		if err != nil {
+			log.Fatalf("Identifier migration failed: %v", err)
+		}
+		if linked > 0 || deleted > 0 {
+			log.Printf("Linked %d nodes to identifier nodes, deleted %d pairwise shared links", linked, deleted)
+		}
+		clustered, err := drv.BackfillClusters()
+		if err != nil {
+			log.Fatalf("Cluster backfill failed: %v", err)
+		}
+		if clustered > 0 {
+			log.Printf("Assigned %d transactions to clusters", clustered)
+		}
+		store = drv
+	}
+
+	// exchange rates from FX_RATES (file or URL), reloaded every FX_REFRESH
+	conv, err := fx.NewConverter(os.Getenv("FX_RATES"), os.Getenv("REPORTING_CURRENCY"))
 	if err != nil {
-		log.Fatalf("DataBase connection failed: %v", err)
+		log.Fatalf("Loading exchange rates failed: %v", err)
+	}
+	if v := os.Getenv("FX_REFRESH"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatalf("invalid FX_REFRESH %q", v)
+		}
+		go conv.Refresh(interval, nil)
+	}
+	// CLI subcommands run against the store and exit
+	if len(os.Args) > 1 {
+		if err := runCommand(store, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatalf("%s failed: %v", os.Args[1], err)
+		}
+		return
 	}
-	defer drv.Close()
 
 	// seed sample data
 	if seed == "true" {
-		if err := graph.SeedData(drv); err != nil {
+		if err := graph.SeedData(store, conv); err != nil {
 			log.Fatalf("Data seeding failed: %v", err)
 		}
 		log.Println("Sample data seeded")
 		time.Sleep(500 * time.Millisecond)
 	}
 
+	// fraud rules, reloaded when RULES_FILE changes
+	engine, err := rules.NewEngine(os.Getenv("RULES_FILE"))
+	if err != nil {
+		log.Fatalf("Loading rules failed: %v", err)
+	}
+	go engine.Watch(5*time.Second, nil)
+
+	// risk weights, tunable at runtime through /api/risk/config
+	scorer, err := risk.NewScorer(os.Getenv("RISK_CONFIG"))
+	if err != nil {
+		log.Fatalf("Loading risk config failed: %v", err)
+	}
+
+	// watchlists from WATCHLIST_FILES (comma-separated CSV/XML paths)
+	screener, err := newScreener()
+	if err != nil {
+		log.Fatalf("Loading watchlists failed: %v", err)
+	}
+
+	// entity resolution; seeded users are linked once up front
+	resolver, err := newResolver()
+	if err != nil {
+		log.Fatalf("Entity resolution setup failed: %v", err)
+	}
+	if seed == "true" {
+		if _, err := resolver.Rebuild(store); err != nil {
+			log.Fatalf("Entity resolution failed: %v", err)
+		}
+	}
+
 	router := mux.NewRouter()
 	cors := handlers.CORS(
 		handlers.AllowedOrigins([]string{"*"}),
-		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS"}),
+		handlers.AllowedMethods([]string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
 		handlers.AllowedHeaders([]string{"Content-Type"}),
 	)
 
 	// routes
-	h := handler.NewHandler(drv)
+	h := handler.NewHandler(store, engine, scorer, screener, resolver, conv)
 	router.HandleFunc("/api/users", h.CreateUser).Methods("POST")
 	router.HandleFunc("/api/users", h.GetAllUsers).Methods("GET")
+	router.HandleFunc("/api/users/{id}", h.UpdateUser).Methods("PUT", "PATCH")
+	router.HandleFunc("/api/users/{id}", h.DeleteUser).Methods("DELETE")
+	router.HandleFunc("/api/users/{id}/risk", h.GetUserRisk).Methods("GET")
 	router.HandleFunc("/api/transactions", h.CreateTransaction).Methods("POST")
 	router.HandleFunc("/api/transactions", h.GetAllTransactions).Methods("GET")
+	router.HandleFunc("/api/transactions/{id}", h.UpdateTransaction).Methods("PUT", "PATCH")
+	router.HandleFunc("/api/transactions/{id}", h.DeleteTransaction).Methods("DELETE")
+	router.HandleFunc("/api/transactions/{id}/alerts", h.GetTransactionAlerts).Methods("GET")
+	router.HandleFunc("/api/transactions/{id}/status", h.GetTransactionStatus).Methods("GET")
+	router.HandleFunc("/api/transactions/{id}/status", h.SetTransactionStatus).Methods("POST")
+	router.HandleFunc("/api/transactions/{id}/risk", h.GetTransactionRisk).Methods("GET")
+	router.HandleFunc("/api/risk/recompute", h.RecomputeRisk).Methods("POST")
+	router.HandleFunc("/api/risk/config", h.GetRiskConfig).Methods("GET")
+	router.HandleFunc("/api/risk/config", h.UpdateRiskConfig).Methods("PUT")
+	router.HandleFunc("/api/risk/config/reload", h.ReloadRiskConfig).Methods("POST")
+	router.HandleFunc("/api/screening/lists", h.GetWatchlists).Methods("GET")
+	router.HandleFunc("/api/screening/rescan", h.RescanWatchlists).Methods("POST")
+	router.HandleFunc("/api/resolution/proposals", h.GetMergeProposals).Methods("GET")
+	router.HandleFunc("/api/resolution/rebuild", h.RebuildResolution).Methods("POST")
+	router.HandleFunc("/api/resolution/persons", h.GetPersons).Methods("GET")
+	router.HandleFunc("/api/resolution/persons", h.CreatePerson).Methods("POST")
+	router.HandleFunc("/api/resolution/persons/{id}", h.GetPerson).Methods("GET")
+	router.HandleFunc("/api/resolution/persons/{id}", h.DeletePerson).Methods("DELETE")
+	router.HandleFunc("/api/rules", h.GetRules).Methods("GET")
+	router.HandleFunc("/api/rules/reload", h.ReloadRules).Methods("POST")
+	router.HandleFunc("/api/fx/rates", h.GetFXRates).Methods("GET")
+	router.HandleFunc("/api/fx/reload", h.ReloadFXRates).Methods("POST")
+	router.HandleFunc("/api/fx/recompute", h.RecomputeFX).Methods("POST")
+	router.HandleFunc("/api/alerts", h.GetAlerts).Methods("GET")
+	router.HandleFunc("/api/alerts", h.CreateAlert).Methods("POST")
+	router.HandleFunc("/api/alerts/{id}", h.GetAlert).Methods("GET")
+	router.HandleFunc("/api/alerts/{id}", h.UpdateAlert).Methods("PATCH")
+	router.HandleFunc("/api/alerts/{id}", h.DeleteAlert).Methods("DELETE")
+	router.HandleFunc("/api/cases", h.GetCases).Methods("GET")
+	router.HandleFunc("/api/cases", h.CreateCase).Methods("POST")
+	router.HandleFunc("/api/cases/{id}", h.GetCase).Methods("GET")
+	router.HandleFunc("/api/cases/{id}", h.UpdateCase).Methods("PATCH")
+	router.HandleFunc("/api/cases/{id}", h.DeleteCase).Methods("DELETE")
+	router.HandleFunc("/api/cases/{id}/notes", h.AddCaseNote).Methods("POST")
+	router.HandleFunc("/api/cases/{id}/evidence", h.AddCaseEvidence).Methods("POST")
+	router.HandleFunc("/api/import/users", h.ImportUsers).Methods("POST")
+	router.HandleFunc("/api/import/transactions", h.ImportTransactions).Methods("POST")
+	router.HandleFunc("/api/import/graph", h.ImportGraph).Methods("POST")
 	router.HandleFunc("/api/relationships/user/{id}", h.GetUserRelationships).Methods("GET")
 	router.HandleFunc("/api/relationships/transaction/{id}", h.GetTransactionRelationships).Methods("GET")
     router.HandleFunc("/api/analytics/shortest-path/users/{from}/{to}", h.GetUserShortestPath).Methods("GET")
+	router.HandleFunc("/api/analytics/paths/users/{from}/{to}", h.GetUserPaths).Methods("GET")
     router.HandleFunc("/api/export/json", h.ExportGraphJSON).Methods("GET")
     router.HandleFunc("/api/export/csv",  h.ExportGraphCSV).Methods("GET")
+    router.HandleFunc("/api/export/ndjson", h.ExportGraphNDJSON).Methods("GET")
 	router.HandleFunc("/api/analytics/transaction-clusters", h.GetTransactionClusters).Methods("GET")
+	router.HandleFunc("/api/analytics/transaction-clusters/summary", h.GetClusterSummary).Methods("GET")
+	router.HandleFunc("/api/analytics/transaction-clusters/{clusterId}", h.GetTransactionCluster).Methods("GET")
+	router.HandleFunc("/api/analytics/cycles", h.GetCycles).Methods("GET")
+	router.HandleFunc("/api/analytics/fan-patterns", h.GetFanPatterns).Methods("GET")
+	router.HandleFunc("/api/analytics/centrality", h.GetCentrality).Methods("GET")
+	router.HandleFunc("/api/analytics/communities", h.GetCommunities).Methods("GET")
+	router.HandleFunc("/api/analytics/trace/transaction/{id}", h.GetTransactionTrace).Methods("GET")
     
    
 	addr := ":" + port
@@ -69,3 +206,122 @@ func main() {
 		log.Fatalf("Server failed: %v", err)
 	}
 }
+
+// newScreener loads the lists named by WATCHLIST_FILES, matching names at
+// SCREENING_THRESHOLD (default screening.DefaultThreshold).
+func newScreener() (*screening.Screener, error) {
+	var paths []string
+	for _, p := range strings.Split(os.Getenv("WATCHLIST_FILES"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			paths = append(paths, p)
+		}
+	}
+	threshold := screening.DefaultThreshold
+	if v := os.Getenv("SCREENING_THRESHOLD"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SCREENING_THRESHOLD %q", v)
+		}
+		threshold = t
+	}
+	return screening.NewScreener(paths, threshold)
+}
+
+// newResolver links names at NAME_MATCH_THRESHOLD (default
+// resolve.DefaultThreshold) and reads phone numbers without a country code
+// as numbers of PHONE_DEFAULT_COUNTRY (default resolve.DefaultCountry).
+func newResolver() (*resolve.Resolver, error) {
+	threshold := resolve.DefaultThreshold
+	if v := os.Getenv("NAME_MATCH_THRESHOLD"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid NAME_MATCH_THRESHOLD %q", v)
+		}
+		threshold = t
+	}
+	country := os.Getenv("PHONE_DEFAULT_COUNTRY")
+	if country == "" {
+		country = resolve.DefaultCountry
+	}
+	return resolve.NewResolver(threshold, country)
+}
+
+// runCommand executes a CLI subcommand against the store:
+//
+//	restore -file export.json [-mode merge|replace] [-dry-run] [-keep-ids]
+//	risk [-config risk.json] [-top 10]
+//	rebuild-clusters [-check]
+func runCommand(store graph.GraphStore, name string, args []string) error {
+	switch name {
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ExitOnError)
+		file := fs.String("file", "-", "document from /api/export/json, - for stdin")
+		mode := fs.String("mode", "merge", "merge keeps existing data, replace deletes users and transactions first")
+		dryRun := fs.Bool("dry-run", false, "only report what would be restored and any conflicts")
+		keepIDs := fs.Bool("keep-ids", false, "reuse exported IDs instead of generating new ones")
+		fs.Parse(args)
+
+		var in io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			in = f
+		}
+		var doc models.GraphExportResponse
+		if err := json.NewDecoder(in).Decode(&doc); err != nil {
+			return fmt.Errorf("invalid export document: %w", err)
+		}
+		report, err := store.RestoreGraph(doc, models.RestoreOptions{
+			Mode:    *mode,
+			DryRun:  *dryRun,
+			KeepIDs: *keepIDs,
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+
+	case "risk":
+		fs := flag.NewFlagSet("risk", flag.ExitOnError)
+		config := fs.String("config", os.Getenv("RISK_CONFIG"), "risk weights file, defaults when empty")
+		top := fs.Int("top", 10, "number of highest-risk users and transactions to print")
+		fs.Parse(args)
+
+		scorer, err := risk.NewScorer(*config)
+		if err != nil {
+			return err
+		}
+		resp, err := scorer.Recompute(store, *top)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resp)
+
+	case "rebuild-clusters":
+		fs := flag.NewFlagSet("rebuild-clusters", flag.ExitOnError)
+		check := fs.Bool("check", false, "only report transactions and clusters that are out of date")
+		fs.Parse(args)
+
+		report, err := store.RebuildClusters(*check)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+		if *check && (report.Reassigned > 0 || report.Resized > 0) {
+			return fmt.Errorf("stored clusters are out of date")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown command %q", name)
+}
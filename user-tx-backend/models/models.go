@@ -1,40 +1,163 @@
 package models
 
+import (
+    "bytes"
+    "encoding/json"
+)
+
 // User represents a graph User node.
 type User struct {
-    ID    int64  `json:"id"`
+    ID    string  `json:"id"`
     Name  string `json:"name"`
     Email string `json:"email"`
     Phone string `json:"phone"`
+    // Scores holds scores written back by
+    // GET /api/analytics/centrality?write=true, keyed by algorithm, and the
+    // risk score stored by POST /api/risk/recompute under "risk".
+    Scores map[string]float64 `json:"scores,omitempty"`
 }
 
-// Transaction represents a graph Transaction node.
+// Transaction represents a graph Transaction node. AmountMinor is the exact
+// amount in minor units of Currency; Amount is the same value in major
+// units. The Reporting fields hold the amount converted to the reporting
+// currency at the transaction's time; they are empty for transactions
+// that could not be converted.
 type Transaction struct {
-    ID          int64   `json:"id"`
-    FromUserID  int64   `json:"fromUserId"`
-    ToUserID    int64   `json:"toUserId"`
+    ID          string   `json:"id"`
+    FromUserID  string   `json:"fromUserId"`
+    ToUserID    string   `json:"toUserId"`
     Amount      float64 `json:"amount"`
     Currency    string  `json:"currency"`
     Timestamp   string  `json:"timestamp"`
     Description string  `json:"description"`
     DeviceID    string  `json:"deviceId"`
+    IP          string  `json:"ip,omitempty"`
+    Status      string  `json:"status"`
+
+    AmountMinor          int64   `json:"amountMinor"`
+    ReportingAmount      float64 `json:"reportingAmount,omitempty"`
+    ReportingAmountMinor int64   `json:"reportingAmountMinor,omitempty"`
+    ReportingCurrency    string  `json:"reportingCurrency,omitempty"`
+    FXRate               string  `json:"fxRate,omitempty"`
+}
+
+// Money is a validated transaction amount: minor units of an ISO 4217
+// currency and their value in the reporting currency. The reporting fields
+// are empty when there was no exchange rate.
+type Money struct {
+    Minor             int64
+    Currency          string
+    ReportingMinor    int64
+    ReportingCurrency string
+    Rate              string // decimal, Currency to ReportingCurrency
+}
+
+// Decimal is a request amount as the client wrote it, from a JSON number
+// or a string such as "10.50", so it can be read exactly instead of going
+// through a float.
+type Decimal string
+
+// UnmarshalJSON keeps the text of a number or the content of a string;
+// null leaves d empty.
+func (d *Decimal) UnmarshalJSON(data []byte) error {
+    if bytes.HasPrefix(data, []byte(`"`)) {
+        var s string
+        if err := json.Unmarshal(data, &s); err != nil {
+            return err
+        }
+        *d = Decimal(s)
+        return nil
+    }
+    var n *json.Number
+    if err := json.Unmarshal(data, &n); err != nil {
+        return err
+    }
+    if n != nil {
+        *d = Decimal(*n)
+    }
+    return nil
+}
+
+// FXRate is one entry of the exchange rate table: one unit of Currency is
+// worth Rate units of the table's base currency from Effective on.
+type FXRate struct {
+    Currency  string `json:"currency"`
+    Rate      string `json:"rate"`
+    Effective string `json:"effective"`
 }
 
-// RelConnection wraps any node with its relationship type.
+// FXTable is the response of GET /api/fx/rates.
+type FXTable struct {
+    Source    string   `json:"source,omitempty"`
+    Reporting string   `json:"reportingCurrency"`
+    Base      string   `json:"base,omitempty"`
+    LoadedAt  string   `json:"loadedAt,omitempty"`
+    Rates     []FXRate `json:"rates"`
+}
+
+// FXRecomputeResponse reports a recomputation of reporting amounts.
+type FXRecomputeResponse struct {
+    ReportingCurrency string   `json:"reportingCurrency"`
+    Converted         int      `json:"converted"`
+    Failed            []string `json:"failed"` // transaction IDs without a rate or currency
+}
+
+// StatusChange is one transition in a transaction's status history. From
+// is empty for the status a transaction was created with.
+type StatusChange struct {
+    From   string `json:"from,omitempty"`
+    To     string `json:"to"`
+    Reason string `json:"reason,omitempty"`
+    At     string `json:"at"`
+}
+
+// StatusRequest for POST /api/transactions/{id}/status
+type StatusRequest struct {
+    Status string `json:"status"`
+    Reason string `json:"reason"`
+}
+
+// TransactionStatusResponse is a transaction's current status with its
+// history, oldest first.
+type TransactionStatusResponse struct {
+    TransactionID string         `json:"transactionId"`
+    Status        string         `json:"status"`
+    History       []StatusChange `json:"history"`
+}
+
+// Identifier is a Device, IPAddress, Email or Phone node shared by the
+// transactions or users pointing at it. ID is "<kind>:<value>" (device,
+// ip, email or phone) and Degree the number of nodes pointing at it.
+type Identifier struct {
+    ID     string `json:"id"`
+    Type   string `json:"type"`
+    Value  string `json:"value"`
+    Degree int    `json:"degree"`
+}
+
+// RelConnection wraps any node with its relationship type. Confidence is
+// set on probable-match links from entity resolution.
 type RelConnection[T any] struct {
-    Node         T      `json:"node"`
-    Relationship string `json:"relationship"`
+    Node         T       `json:"node"`
+    Relationship string  `json:"relationship"`
+    Confidence   float64 `json:"confidence,omitempty"`
 }
 
-// UserConnections groups shared‐attribute and performed links.
+// UserConnections groups shared‐attribute and performed links, the email
+// and phone nodes of the user, and the watchlist entries it matched.
 type UserConnections struct {
-    Users        []RelConnection[User]        `json:"users"`
-    Transactions []RelConnection[Transaction] `json:"transactions"`
+    Users        []RelConnection[User]         `json:"users"`
+    Transactions []RelConnection[Transaction]  `json:"transactions"`
+    Identifiers  []RelConnection[Identifier]   `json:"identifiers,omitempty"`
+    Watchlist    []RelConnection[WatchlistHit] `json:"watchlist,omitempty"`
 }
 
-// TxConnections groups users who performed a transaction.
+// TxConnections groups users who performed a transaction, its device and
+// IP nodes, and the transactions sharing them (SHARED_DEVICE, SHARED_IP).
 type TxConnections struct {
-    Users []RelConnection[User] `json:"users"`
+    Users        []RelConnection[User]        `json:"users"`
+    Identifiers  []RelConnection[Identifier]  `json:"identifiers,omitempty"`
+    Transactions []RelConnection[Transaction] `json:"transactions,omitempty"`
 }
 
 // UserRequest for POST /users
@@ -46,13 +169,224 @@ type UserRequest struct {
 
 // TransactionRequest for POST /api/transactions
 type TransactionRequest struct {
-    FromUserID  int64   `json:"fromUserId"`
-    ToUserID    int64   `json:"toUserId"`
-    Amount      float64 `json:"amount"`
+    FromUserID  string   `json:"fromUserId"`
+    ToUserID    string   `json:"toUserId"`
+    Amount      Decimal `json:"amount"`
     Currency    string  `json:"currency"`
     Timestamp   string  `json:"timestamp"`
     Description string  `json:"description"`
     DeviceID    string  `json:"deviceId"`
+    IP          string  `json:"ip"`
+    // AmountMinor gives the amount in minor units of Currency; when set,
+    // Amount is ignored.
+    AmountMinor *int64 `json:"amountMinor,omitempty"`
+    // Money is the validated and converted amount, filled in by the
+    // handler before rules run and the transaction is written.
+    Money Money `json:"-"`
+    // Status is the initial status, "pending" (default) or "settled".
+    Status string `json:"status,omitempty"`
+    // ReversalOf and ChargebackOf make the transaction a follow-up that
+    // returns funds of a settled original; at most one may be set.
+    ReversalOf   string `json:"reversalOf,omitempty"`
+    ChargebackOf string `json:"chargebackOf,omitempty"`
+}
+
+// Alert records a rule match on a transaction request, or an alert raised
+// by hand through POST /api/alerts. TransactionID is empty when the rule
+// rejected the request.
+type Alert struct {
+    ID            string `json:"id"`
+    RuleID        string `json:"ruleId"`
+    RuleName      string `json:"ruleName"`
+    Severity      string `json:"severity"`
+    Action        string `json:"action"`
+    Message       string `json:"message"`
+    Status        string `json:"status"` // open, acknowledged or dismissed
+    TransactionID string `json:"transactionId,omitempty"`
+    SenderID      string `json:"senderId"`
+    ReceiverID    string `json:"receiverId"`
+    CaseID        string `json:"caseId,omitempty"`
+    CreatedAt     string `json:"createdAt"`
+}
+
+// AlertPatch is the body of PATCH /api/alerts/{id}. An empty CaseID
+// removes the alert from its case.
+type AlertPatch struct {
+    Status *string `json:"status"`
+    CaseID *string `json:"caseId"`
+}
+
+// AlertQuery filters GET /api/alerts. Empty fields match everything.
+type AlertQuery struct {
+    Status        string
+    Severity      string
+    RuleID        string
+    UserID        string // sender or receiver
+    TransactionID string
+    CaseID        string
+    Limit         int
+}
+
+// Case groups alerts, users and transactions under investigation.
+type Case struct {
+    ID             string         `json:"id"`
+    Title          string         `json:"title"`
+    Description    string         `json:"description"`
+    Status         string         `json:"status"` // open, investigating, escalated or closed
+    Disposition    string         `json:"disposition,omitempty"`
+    Assignee       string         `json:"assignee"`
+    CreatedAt      string         `json:"createdAt"`
+    UpdatedAt      string         `json:"updatedAt"`
+    ClosedAt       string         `json:"closedAt,omitempty"`
+    AlertIDs       []string       `json:"alertIds"`
+    UserIDs        []string       `json:"userIds"`
+    TransactionIDs []string       `json:"transactionIds"`
+    Notes          []CaseNote     `json:"notes"`
+    Evidence       []CaseEvidence `json:"evidence"`
+}
+
+// CaseRequest is the body of POST /api/cases.
+type CaseRequest struct {
+    Title          string   `json:"title"`
+    Description    string   `json:"description"`
+    Assignee       string   `json:"assignee"`
+    AlertIDs       []string `json:"alertIds"`
+    UserIDs        []string `json:"userIds"`
+    TransactionIDs []string `json:"transactionIds"`
+}
+
+// CasePatch is the body of PATCH /api/cases/{id}. Nil fields are left
+// unchanged; the Add* lists link more entities to the case.
+type CasePatch struct {
+    Title             *string  `json:"title"`
+    Description       *string  `json:"description"`
+    Assignee          *string  `json:"assignee"`
+    Status            *string  `json:"status"`
+    Disposition       *string  `json:"disposition"`
+    AddAlertIDs       []string `json:"addAlertIds"`
+    AddUserIDs        []string `json:"addUserIds"`
+    AddTransactionIDs []string `json:"addTransactionIds"`
+}
+
+// CaseQuery filters GET /api/cases.
+type CaseQuery struct {
+    Status   string
+    Assignee string
+    Limit    int
+}
+
+// CaseNote is a free-text note on a case.
+type CaseNote struct {
+    ID        string `json:"id"`
+    Author    string `json:"author"`
+    Text      string `json:"text"`
+    CreatedAt string `json:"createdAt"`
+}
+
+// CaseEvidence is a frozen copy of an analytics result, such as a cycle,
+// cluster or shortest-path response, attached to a case. Source records the
+// request that produced it.
+type CaseEvidence struct {
+    ID        string          `json:"id"`
+    Kind      string          `json:"kind"`
+    Title     string          `json:"title"`
+    Source    string          `json:"source,omitempty"`
+    Data      json.RawMessage `json:"data"`
+    CreatedAt string          `json:"createdAt"`
+}
+
+// CreateTransactionResponse is returned by POST /api/transactions. Alerts
+// lists the rules the transaction matched. Errors lists what failed after
+// the transaction was written.
+type CreateTransactionResponse struct {
+    ID     string   `json:"id,omitempty"`
+    Alerts []Alert  `json:"alerts,omitempty"`
+    Errors []string `json:"errors,omitempty"`
+}
+
+// ImportRowResult reports the outcome of one row of a bulk import.
+type ImportRowResult struct {
+    Row           int            `json:"row"`
+    Status        string         `json:"status"` // "accepted" or "rejected"
+    ID            string         `json:"id,omitempty"`
+    Reason        string         `json:"reason,omitempty"`
+    WatchlistHits []WatchlistHit `json:"watchlistHits,omitempty"` // imported users only
+    IdentityLinks []IdentityLink `json:"identityLinks,omitempty"` // imported users only
+}
+
+// ImportReport is the response of POST /api/import/users and /api/import/transactions.
+// Errors lists the checks that failed after a batch was written; its rows
+// stay accepted. Aborted is set when the body could not be read to the
+// end: Rows covers the rows before the error, and nothing after it was
+// imported.
+type ImportReport struct {
+    Accepted int               `json:"accepted"`
+    Rejected int               `json:"rejected"`
+    Rows     []ImportRowResult `json:"rows"`
+    Errors   []string          `json:"errors,omitempty"`
+    Aborted  string            `json:"aborted,omitempty"`
+}
+
+// UserPatch for PATCH /api/users/{id}; nil fields are left unchanged.
+type UserPatch struct {
+    Name  *string `json:"name"`
+    Email *string `json:"email"`
+    Phone *string `json:"phone"`
+}
+
+// TransactionPatch for PATCH /api/transactions/{id}; nil fields are left unchanged.
+type TransactionPatch struct {
+    FromUserID  *string  `json:"fromUserId"`
+    ToUserID    *string  `json:"toUserId"`
+    Amount      *Decimal `json:"amount"`
+    Currency    *string  `json:"currency"`
+    Timestamp   *string  `json:"timestamp"`
+    Description *string  `json:"description"`
+    DeviceID    *string  `json:"deviceId"`
+    IP          *string  `json:"ip"`
+    // AmountMinor sets the amount exactly, in minor units.
+    AmountMinor *int64 `json:"amountMinor,omitempty"`
+    // Money is the validated and converted amount, set by the handler
+    // whenever the amount, currency or timestamp changes.
+    Money *Money `json:"-"`
+}
+
+// UserQuery holds the filters, sort order and page for GET /api/users.
+// Prefix filters are case-sensitive.
+type UserQuery struct {
+    NamePrefix  string
+    EmailPrefix string
+    PhonePrefix string
+    SortBy      string // id, name, email or phone
+    Desc        bool
+    Cursor      string
+    Limit       int
+}
+
+// TransactionQuery holds the filters, sort order and page for
+// GET /api/transactions. Zero values disable a filter.
+type TransactionQuery struct {
+    MinAmount  *float64
+    MaxAmount  *float64
+    Currency   string
+    From       string // RFC3339, inclusive
+    To         string // RFC3339, exclusive
+    SenderID   string
+    ReceiverID string
+    DeviceID   string
+    IP         string
+    Status     string
+    SortBy     string // id, amount or timestamp
+    Desc       bool
+    Cursor     string
+    Limit      int
+}
+
+// Page is the envelope for paginated list responses.
+type Page[T any] struct {
+    Items      []T    `json:"items"`
+    NextCursor string `json:"nextCursor,omitempty"`
+    Total      int64  `json:"total"`
 }
 
 // Response wrappers for relationships
@@ -70,7 +404,7 @@ type TransactionRelationships struct {
 }
 
 type PathNode struct {
-    ID       int64  `json:"id"`
+    ID       string  `json:"id"`
     Type     string `json:"type"`           
     Name     string `json:"name,omitempty"` 
     DeviceID string `json:"deviceId,omitempty"`
@@ -86,21 +420,204 @@ type PathSegment struct {
 // response wrapper
 type ShortestPathResponse struct {
     Segments []PathSegment `json:"segments"`
+    Cost     float64       `json:"cost"` // hops unless a weight was requested
+}
+
+// PathQuery constrains and weights a shortest-path search between users.
+type PathQuery struct {
+    Types    []string // allowed relationship types; empty allows all
+    Directed bool     // follow SENT/RECEIVED_BY only in the direction money moves
+    MaxHops  int      // maximum relationships on the path; 0 is unbounded
+    Weight   string   // "hops" (default), "amount" or "recency"
+    K        int      // paths to return from /api/analytics/paths
+    All      bool     // every simple path up to MaxHops instead of the top K
+}
+
+// RankedPath is one of several paths between two users.
+type RankedPath struct {
+    Segments []PathSegment `json:"segments"`
+    Cost     float64       `json:"cost"`
+    Hops     int           `json:"hops"`
+}
+
+// PathsResponse lists distinct paths, cheapest first. Truncated is set when
+// an all-paths search stopped at its limit.
+type PathsResponse struct {
+    Paths     []RankedPath `json:"paths"`
+    Truncated bool         `json:"truncated,omitempty"`
+}
+
+// CycleQuery holds the parameters of GET /api/analytics/cycles.
+type CycleQuery struct {
+    MaxLength     int      // transactions per cycle
+    From          string   // RFC3339, inclusive
+    To            string   // RFC3339, exclusive
+    MaxElapsed    string   // Go duration between first and last transaction
+    MinAmount     float64  // per transaction
+    MaxDecay      *float64 // 0..1, allowed drop in amount from one hop to the next
+    Chronological bool     // hops must happen in time order
+    Limit         int
+}
+
+// Cycle is a round trip of money returning to its origin user. Segments
+// alternate User-SENT->Transaction and Transaction-RECEIVED_BY->User,
+// starting at the earliest transaction.
+type Cycle struct {
+    Segments       []PathSegment `json:"segments"`
+    Length         int           `json:"length"`
+    TotalValue     float64       `json:"totalValue"`
+    ElapsedSeconds float64       `json:"elapsedSeconds"`
+}
+
+// CyclesResponse wraps the cycles found. Truncated is set when the search
+// stopped early on a dense graph and more cycles may exist.
+type CyclesResponse struct {
+    Cycles    []Cycle `json:"cycles"`
+    Truncated bool    `json:"truncated,omitempty"`
+}
+
+// FanQuery holds the parameters of GET /api/analytics/fan-patterns.
+type FanQuery struct {
+    Direction         string   // "in", "out" or "" for both
+    Window            string   // Go duration of the sliding window
+    MinCounterparties int      // distinct senders (in) or receivers (out)
+    MaxAmount         *float64 // only count transfers up to this amount
+    MinTotal          float64  // total moved within the window
+    From              string   // RFC3339, inclusive
+    To                string   // RFC3339, exclusive
+    Limit             int
+}
+
+// FanSuspect is a user whose busiest window of incoming (fan-in) or
+// outgoing (fan-out) transfers crossed the thresholds.
+type FanSuspect struct {
+    User             User          `json:"user"`
+    Direction        string        `json:"direction"`
+    Counterparties   int           `json:"counterparties"`
+    TransactionCount int           `json:"transactionCount"`
+    TotalAmount      float64       `json:"totalAmount"`
+    WindowStart      string        `json:"windowStart"`
+    WindowEnd        string        `json:"windowEnd"`
+    Transactions     []Transaction `json:"transactions"`
+}
+
+// FanPatternsResponse wraps the ranked suspects.
+type FanPatternsResponse struct {
+    Suspects []FanSuspect `json:"suspects"`
+}
+
+// CentralityQuery holds the parameters of GET /api/analytics/centrality.
+type CentralityQuery struct {
+    Algorithm string // "pagerank" (default), "degree" or "betweenness"
+    Edges     string // "flow" (default), "shared" or "both"
+    Write     bool   // store the scores on the User nodes
+    Cursor    string
+    Limit     int
+}
+
+// CentralityScore is one user's score in the projected user graph.
+type CentralityScore struct {
+    UserID string  `json:"userId"`
+    Name   string  `json:"name"`
+    Score  float64 `json:"score"`
+}
+
+// CentralityResponse is one page of scores and the hubs left out of the
+// projection.
+type CentralityResponse struct {
+    Page[CentralityScore]
+    SkippedHubs []SkippedHub `json:"skippedHubs,omitempty"`
+}
+
+// SkippedHub is a shared email, phone or device with more distinct members
+// than the projections link pairwise; it adds no edges to them.
+type SkippedHub struct {
+    ID      string `json:"id"`
+    Type    string `json:"type"`    // SHARED_EMAIL, SHARED_PHONE or SHARED_DEVICE
+    Members int    `json:"members"` // distinct users or transactions it would link
+}
+
+// CommunityQuery holds the parameters of GET /api/analytics/communities.
+type CommunityQuery struct {
+    Algorithm  string // "louvain" (default) or "labelpropagation"
+    Projection string // "user" (default) or "transaction"
+    Edges      string // user projection only: "flow", "shared" or "both" (default)
+    MinSize    int    // smallest community to report
+    Limit      int
+}
+
+// Community is one detected community with its flow summary. For a user
+// projection the members are users; for a transaction projection they are
+// transactions and the flows refer to the users they touch.
+type Community struct {
+    ID           string   `json:"id"` // smallest member ID
+    Size         int      `json:"size"`
+    Members      []string `json:"members"`
+    TotalVolume  float64  `json:"totalVolume"`
+    InternalFlow float64  `json:"internalFlow"`
+    ExternalFlow float64  `json:"externalFlow"`
+}
+
+// CommunitiesResponse is the partition found and its modularity.
+type CommunitiesResponse struct {
+    Algorithm   string      `json:"algorithm"`
+    Projection  string      `json:"projection"`
+    Modularity  float64     `json:"modularity"`
+    Communities []Community `json:"communities"`
+    SkippedHubs []SkippedHub `json:"skippedHubs,omitempty"`
+}
+
+// TraceQuery holds the parameters of GET /api/analytics/trace/transaction/{id}.
+type TraceQuery struct {
+    Direction       string   // "forward" (default) or "backward"
+    Method          string   // "proportional" (default) or "fifo"
+    MaxDepth        int      // transactions away from the source
+    Horizon         string   // Go duration from the source's timestamp
+    MinAmount       float64  // stop following amounts below this
+    ExcludeStatuses []string // transactions in these statuses are not followed
+}
+
+// TraceNode is a transaction reached by the trace with the amount of the
+// source's funds attributed to it.
+type TraceNode struct {
+    Transaction  Transaction `json:"transaction"`
+    Depth        int         `json:"depth"`
+    TracedAmount float64     `json:"tracedAmount"`
+}
+
+// TraceEdge carries traced funds from one transaction to a later one
+// through the user who received the first and sent the second.
+type TraceEdge struct {
+    From   string  `json:"from"`
+    To     string  `json:"to"`
+    Via    string  `json:"via"`
+    Amount float64 `json:"amount"`
+}
+
+// TraceResponse is the DAG of transactions the traced funds went through
+// (forward) or came from (backward).
+type TraceResponse struct {
+    Source    string      `json:"source"`
+    Direction string      `json:"direction"`
+    Method    string      `json:"method"`
+    Currency  string      `json:"currency,omitempty"` // of traced amounts, the reporting currency
+    Nodes     []TraceNode `json:"nodes"`
+    Edges     []TraceEdge `json:"edges"`
 }
 
 // GraphNode represents any node (User or Transaction) for export.
 type GraphNode struct {
-    ID         int64             `json:"id"`
+    ID         string             `json:"id"`
     Type       string            `json:"type"`       
     Properties map[string]any    `json:"properties"` 
 }
 
 // GraphRelationship represents an edge in the graph.
 type GraphRelationship struct {
-    SourceID     int64  `json:"sourceId"`
+    SourceID     string  `json:"sourceId"`
     SourceType   string `json:"sourceType"`
     Relationship string `json:"relationship"`
-    TargetID     int64  `json:"targetId"`
+    TargetID     string  `json:"targetId"`
     TargetType   string `json:"targetType"`
 }
 
@@ -110,13 +627,204 @@ type GraphExportResponse struct {
     Relationships []GraphRelationship `json:"relationships"`
 }
 
+// RestoreOptions controls how POST /api/import/graph applies an export.
+type RestoreOptions struct {
+    Mode    string // "merge" (default) keeps existing data, "replace" deletes users and transactions first
+    DryRun  bool   // only report what would happen
+    KeepIDs bool   // reuse the exported IDs instead of generating new ones
+}
+
+// RestoreConflict describes a node or relationship that was skipped.
+type RestoreConflict struct {
+    Kind   string `json:"kind"` // "node" or "relationship"
+    ID     string `json:"id"`
+    Reason string `json:"reason"`
+}
+
+// RestoreReport is the outcome of a graph restore. Skipped counts the
+// exported nodes (by label) and relationships (by type) that restores
+// leave out by design: alerts, cases and their notes and evidence,
+// watchlist entries, Persons and probable matches.
+type RestoreReport struct {
+    Mode                 string            `json:"mode"`
+    DryRun               bool              `json:"dryRun"`
+    NodesCreated         int               `json:"nodesCreated"`
+    RelationshipsCreated int               `json:"relationshipsCreated"`
+    IDMap                map[string]string `json:"idMap"`
+    Conflicts            []RestoreConflict `json:"conflicts"`
+    Skipped              map[string]int    `json:"skipped,omitempty"`
+}
+
 // TransactionCluster represents a single transaction’s cluster assignment.
 type TransactionCluster struct {
-    TransactionID int64 `json:"transactionId"`
-    ClusterID     int64 `json:"clusterId"`
+    TransactionID string `json:"transactionId"`
+    ClusterID     string `json:"clusterId"`
 }
 
 // TransactionClustersResponse wraps all cluster assignments.
 type TransactionClustersResponse struct {
     Clusters []TransactionCluster `json:"clusters"`
 }
+
+// ClusterSummaryQuery selects and orders the clusters of
+// GET /api/analytics/transaction-clusters/summary. Sort is one of size
+// (default), users, devices, total, max, first, last or id. Zero sizes and
+// Limit are unbounded.
+type ClusterSummaryQuery struct {
+    ExcludeStatuses []string
+    MinSize         int
+    MaxSize         int
+    Sort            string
+    Desc            bool
+    Limit           int
+}
+
+// ClusterSummary describes one transaction cluster. Amounts are in the
+// reporting currency; Currencies are those the transactions were made in.
+type ClusterSummary struct {
+    ClusterID    string   `json:"clusterId"`
+    Transactions int      `json:"transactions"`
+    Users        int      `json:"users"`
+    Devices      int      `json:"devices"`
+    TotalAmount  float64  `json:"totalAmount"`
+    MaxAmount    float64  `json:"maxAmount"`
+    Currency     string   `json:"currency,omitempty"`
+    Currencies   []string `json:"currencies"`
+    FirstSeen    string   `json:"firstSeen"`
+    LastSeen     string   `json:"lastSeen"`
+    SpanSeconds  float64  `json:"spanSeconds"`
+}
+
+// ClusterSummaryResponse lists cluster summaries. Total counts the clusters
+// matching the size filters, before Limit.
+type ClusterSummaryResponse struct {
+    Total    int              `json:"total"`
+    Clusters []ClusterSummary `json:"clusters"`
+}
+
+// ClusterRebuildReport is the outcome of recomputing the stored transaction
+// clusters. Reassigned counts transactions whose clusterId was missing or
+// wrong, Resized the cluster records that were missing, stale or of the
+// wrong size. With Check nothing was written.
+type ClusterRebuildReport struct {
+    Check        bool `json:"check"`
+    Transactions int  `json:"transactions"`
+    Clusters     int  `json:"clusters"`
+    Reassigned   int  `json:"reassigned"`
+    Resized      int  `json:"resized"`
+}
+
+// RiskFactor is one signal's part of a risk score. Value is the raw signal
+// (a count, hop distance or z-score), Score its normalised form in [0, 1],
+// and Contribution the points it adds to the total.
+type RiskFactor struct {
+    Name         string  `json:"name"`
+    Value        float64 `json:"value"`
+    Score        float64 `json:"score"`
+    Weight       float64 `json:"weight"`
+    Contribution float64 `json:"contribution"`
+}
+
+// RiskScore is the risk of a user or transaction on a 0–100 scale with
+// its per-factor breakdown.
+type RiskScore struct {
+    ID      string       `json:"id"`
+    Type    string       `json:"type"` // "user" or "transaction"
+    Score   float64      `json:"score"`
+    Factors []RiskFactor `json:"factors"`
+}
+
+// RiskRecomputeResponse is returned by POST /api/risk/recompute.
+type RiskRecomputeResponse struct {
+    Users           int         `json:"users"`
+    Transactions    int         `json:"transactions"`
+    TopUsers        []RiskScore `json:"topUsers"`
+    TopTransactions []RiskScore `json:"topTransactions"`
+}
+
+// WatchlistEntry is one record of a sanctions list or internal denylist.
+// ID is unique across lists ("<list>:<id in the file>").
+type WatchlistEntry struct {
+    ID       string   `json:"id"`
+    List     string   `json:"list"`
+    Name     string   `json:"name,omitempty"`
+    Aliases  []string `json:"aliases,omitempty"`
+    Program  string   `json:"program,omitempty"`
+    Email    string   `json:"email,omitempty"`
+    Phone    string   `json:"phone,omitempty"`
+    DeviceID string   `json:"deviceId,omitempty"`
+}
+
+// WatchlistHit is a user's match against a watchlist entry, stored as a
+// WATCHLIST_HIT relationship. MatchType is name, alias, email, phone or
+// device; identifier matches score 1.
+type WatchlistHit struct {
+    EntryID      string  `json:"entryId"`
+    List         string  `json:"list"`
+    Name         string  `json:"name,omitempty"`
+    Program      string  `json:"program,omitempty"`
+    MatchType    string  `json:"matchType"`
+    MatchedValue string  `json:"matchedValue"`
+    Score        float64 `json:"score"`
+    ScreenedAt   string  `json:"screenedAt"`
+}
+
+// ScreeningMatch lists the hits of one user.
+type ScreeningMatch struct {
+    UserID string         `json:"userId"`
+    Name   string         `json:"name"`
+    Hits   []WatchlistHit `json:"hits"`
+}
+
+// ScreeningResponse is returned by POST /api/screening/rescan.
+type ScreeningResponse struct {
+    Entries  int              `json:"entries"`
+    Screened int              `json:"screened"`
+    Hits     int              `json:"hits"`
+    Matches  []ScreeningMatch `json:"matches"`
+}
+
+
+// IdentityLink is a probable-match link between two users found by entity
+// resolution: SAME_EMAIL_CANONICAL, SAME_PHONE_E164 or SIMILAR_NAME.
+// Confidence is in (0, 1].
+type IdentityLink struct {
+    From       string  `json:"from"`
+    To         string  `json:"to"`
+    Type       string  `json:"type"`
+    Confidence float64 `json:"confidence"`
+}
+
+// Person is a resolved real-world entity that one or more users belong to
+// through RESOLVED_AS relationships.
+type Person struct {
+    ID        string   `json:"id"`
+    Name      string   `json:"name"`
+    UserIDs   []string `json:"userIds"`
+    CreatedAt string   `json:"createdAt"`
+}
+
+// PersonRequest merges users into a Person. Users that already belong to
+// another Person are moved; Name defaults to the first user's name.
+type PersonRequest struct {
+    Name    string   `json:"name"`
+    UserIDs []string `json:"userIds"`
+}
+
+// MergeProposal is a group of users that probably belong to one Person.
+// Confidence is the weakest pairwise confidence that joins the group;
+// PersonIDs lists the Persons its users already belong to.
+type MergeProposal struct {
+    UserIDs    []string       `json:"userIds"`
+    Users      []User         `json:"users"`
+    Confidence float64        `json:"confidence"`
+    Links      []IdentityLink `json:"links"`
+    PersonIDs  []string       `json:"personIds,omitempty"`
+}
+
+// ResolutionResponse is returned by POST /api/resolution/rebuild.
+type ResolutionResponse struct {
+    Users  int            `json:"users"`
+    Links  int            `json:"links"`
+    ByType map[string]int `json:"byType"`
+}
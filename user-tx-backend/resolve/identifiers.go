@@ -0,0 +1,98 @@
+package resolve
+
+import "strings"
+
+// dotlessDomains lists providers that ignore dots in the local part,
+// mapped to their primary domain.
+var dotlessDomains = map[string]string{
+    "gmail.com":      "gmail.com",
+    "googlemail.com": "gmail.com",
+}
+
+// CanonicalEmail folds an email address to the mailbox it delivers to: it
+// is lowercased, a "+tag" suffix of the local part is dropped, and for
+// providers that ignore them dots are removed. Values that do not look
+// like an address are only lowercased and trimmed.
+func CanonicalEmail(s string) string {
+    s = strings.ToLower(strings.TrimSpace(s))
+    at := strings.LastIndexByte(s, '@')
+    if at <= 0 || at == len(s)-1 {
+        return s
+    }
+    local, domain := s[:at], s[at+1:]
+    if i := strings.IndexByte(local, '+'); i > 0 {
+        local = local[:i]
+    }
+    if d, ok := dotlessDomains[domain]; ok {
+        domain = d
+        local = strings.ReplaceAll(local, ".", "")
+    }
+    return local + "@" + domain
+}
+
+// callingCodes maps the countries accepted as a default to their calling
+// code.
+var callingCodes = map[string]string{
+    "US": "1", "CA": "1", "GB": "44", "IE": "353", "DE": "49", "FR": "33",
+    "ES": "34", "IT": "39", "NL": "31", "BE": "32", "CH": "41", "AT": "43",
+    "SE": "46", "NO": "47", "DK": "45", "PL": "48", "PT": "351", "AU": "61",
+    "NZ": "64", "IN": "91", "JP": "81", "CN": "86", "BR": "55", "MX": "52",
+    "ZA": "27", "SG": "65",
+}
+
+// KnownCountry reports whether country can be used as the default for
+// CanonicalPhone.
+func KnownCountry(country string) bool {
+    _, ok := callingCodes[strings.ToUpper(country)]
+    return ok
+}
+
+// CanonicalPhone parses a phone number into E.164 form ("+" and up to 15
+// digits). Numbers written with "+", "00" or, in the NANP, "011" are taken
+// as international; others are read as national numbers of country (an
+// ISO 3166 code), dropping a leading trunk "0". It returns "" when the
+// number cannot be placed.
+func CanonicalPhone(s, country string) string {
+    s = strings.TrimSpace(s)
+    intl := strings.HasPrefix(s, "+")
+    var b strings.Builder
+    for _, r := range s {
+        if r >= '0' && r <= '9' {
+            b.WriteRune(r)
+        } else if r != ' ' && r != '-' && r != '.' && r != '(' && r != ')' && r != '+' && r != '/' {
+            // Extensions and letters are not part of the number.
+            break
+        }
+    }
+    digits := b.String()
+    cc := callingCodes[strings.ToUpper(country)]
+    switch {
+    case intl:
+    case strings.HasPrefix(digits, "00"):
+        digits, intl = digits[2:], true
+    case cc == "1" && strings.HasPrefix(digits, "011"):
+        digits, intl = digits[3:], true
+    }
+
+    if !intl {
+        switch {
+        case cc == "":
+            return ""
+        case cc == "1":
+            // NANP numbers are ten digits, optionally after a "1".
+            if len(digits) == 11 && digits[0] == '1' {
+                digits = digits[1:]
+            }
+            if len(digits) != 10 {
+                return ""
+            }
+        default:
+            digits = strings.TrimPrefix(digits, "0")
+        }
+        digits = cc + digits
+    }
+    if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+        return ""
+    }
+    return "+" + digits
+}
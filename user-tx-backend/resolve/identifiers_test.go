@@ -0,0 +1,40 @@
+package resolve
+
+import "testing"
+
+func TestCanonicalEmail(t *testing.T) {
+    for in, want := range map[string]string{
+        " Alice@Example.COM ":        "alice@example.com",
+        "alice+news@example.com":     "alice@example.com",
+        "a.l.i.c.e+x@googlemail.com": "alice@gmail.com",
+        "first.last@example.com":     "first.last@example.com",
+        "+tag@example.com":           "+tag@example.com",
+        "not-an-address":             "not-an-address",
+        "trailing@":                  "trailing@",
+    } {
+        if got := CanonicalEmail(in); got != want {
+            t.Errorf("CanonicalEmail(%q) = %q, want %q", in, got, want)
+        }
+    }
+}
+
+func TestCanonicalPhone(t *testing.T) {
+    for _, tc := range []struct {
+        in, country, want string
+    }{
+        {"+1 (111) 111-1111", "US", "+11111111111"},
+        {"1111111111", "US", "+11111111111"},
+        {"1-111-111-1111", "us", "+11111111111"},
+        {"011 44 20 7946 0000", "US", "+442079460000"},
+        {"0044 20 7946 0000", "DE", "+442079460000"},
+        {"020 7946 0000", "GB", "+442079460000"},
+        {"+44 20 7946 0000 ext. 12", "", "+442079460000"},
+        {"111-1111", "US", ""},
+        {"020 7946 0000", "", ""},
+        {"+12", "US", ""},
+    } {
+        if got := CanonicalPhone(tc.in, tc.country); got != tc.want {
+            t.Errorf("CanonicalPhone(%q, %q) = %q, want %q", tc.in, tc.country, got, tc.want)
+        }
+    }
+}
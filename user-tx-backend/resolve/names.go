@@ -0,0 +1,135 @@
+package resolve
+
+import (
+    "sort"
+    "strings"
+    "unicode"
+)
+
+// transliterations folds accented Latin letters and Cyrillic into ASCII.
+var transliterations = map[rune]string{
+    'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ă': "a", 'ą': "a",
+    'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d", 'ð': "d",
+    'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ė': "e", 'ę': "e", 'ě': "e",
+    'ğ': "g", 'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i", 'į': "i",
+    'ł': "l", 'ľ': "l", 'ñ': "n", 'ń': "n", 'ň': "n",
+    'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe",
+    'ř': "r", 'ś': "s", 'š': "s", 'ş': "s", 'ß': "ss", 'ť': "t", 'ţ': "t", 'þ': "th",
+    'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u", 'ű': "u",
+    'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z",
+
+    'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh",
+    'з': "z", 'и': "i", 'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o",
+    'п': "p", 'р': "r", 'с': "s", 'т': "t", 'у': "u", 'ф': "f", 'х': "kh", 'ц': "ts",
+    'ч': "ch", 'ш': "sh", 'щ': "shch", 'ъ': "", 'ы': "y", 'ь': "", 'э': "e", 'ю': "yu",
+    'я': "ya", 'і': "i", 'ї': "yi", 'є': "ye", 'ґ': "g",
+}
+
+// NormalizeName lowercases and transliterates a name, and turns everything
+// that isn't a letter or digit into single spaces.
+func NormalizeName(s string) string {
+    var b strings.Builder
+    space := false
+    for _, r := range strings.ToLower(s) {
+        if t, ok := transliterations[r]; ok {
+            b.WriteString(t)
+            space = false
+            continue
+        }
+        if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+            b.WriteRune(r)
+            space = false
+            continue
+        }
+        if !space && b.Len() > 0 {
+            b.WriteByte(' ')
+            space = true
+        }
+    }
+    return strings.TrimSpace(b.String())
+}
+
+// sortedTokens reorders the words of a normalised name, so "Doe John"
+// compares equal to "John Doe".
+func sortedTokens(s string) string {
+    tokens := strings.Fields(s)
+    sort.Strings(tokens)
+    return strings.Join(tokens, " ")
+}
+
+// NameSimilarity compares two normalised names by Jaro-Winkler similarity,
+// in their given word order and sorted.
+func NameSimilarity(a, b string) float64 {
+    if a == "" || b == "" {
+        return 0
+    }
+    score := JaroWinkler(a, b)
+    if s := JaroWinkler(sortedTokens(a), sortedTokens(b)); s > score {
+        score = s
+    }
+    return score
+}
+
+// JaroWinkler returns the Jaro-Winkler similarity of a and b in [0, 1],
+// with the usual prefix scale of 0.1 over at most four characters.
+func JaroWinkler(a, b string) float64 {
+    s, t := []rune(a), []rune(b)
+    if len(s) == 0 && len(t) == 0 {
+        return 1
+    }
+    if len(s) == 0 || len(t) == 0 {
+        return 0
+    }
+    window := len(s)
+    if len(t) > window {
+        window = len(t)
+    }
+    window = window/2 - 1
+    if window < 0 {
+        window = 0
+    }
+
+    sMatch := make([]bool, len(s))
+    tMatch := make([]bool, len(t))
+    matches := 0
+    for i := range s {
+        lo, hi := i-window, i+window+1
+        if lo < 0 {
+            lo = 0
+        }
+        if hi > len(t) {
+            hi = len(t)
+        }
+        for j := lo; j < hi; j++ {
+            if !tMatch[j] && s[i] == t[j] {
+                sMatch[i], tMatch[j] = true, true
+                matches++
+                break
+            }
+        }
+    }
+    if matches == 0 {
+        return 0
+    }
+    transpositions, k := 0, 0
+    for i := range s {
+        if !sMatch[i] {
+            continue
+        }
+        for !tMatch[k] {
+            k++
+        }
+        if s[i] != t[k] {
+            transpositions++
+        }
+        k++
+    }
+    m := float64(matches)
+    jaro := (m/float64(len(s)) + m/float64(len(t)) + (m-float64(transpositions)/2)/m) / 3
+
+    prefix := 0
+    for prefix < 4 && prefix < len(s) && prefix < len(t) && s[prefix] == t[prefix] {
+        prefix++
+    }
+    return jaro + float64(prefix)*0.1*(1-jaro)
+}
@@ -0,0 +1,40 @@
+package resolve
+
+import (
+    "math"
+    "testing"
+)
+
+func TestJaroWinkler(t *testing.T) {
+    for _, tc := range []struct {
+        a, b string
+        want float64
+    }{
+        {"martha", "marhta", 0.9611},
+        {"dwayne", "duane", 0.84},
+        {"dixon", "dicksonx", 0.8133},
+        {"same", "same", 1},
+        {"", "", 1},
+        {"abc", "", 0},
+        {"abc", "xyz", 0},
+    } {
+        if got := JaroWinkler(tc.a, tc.b); math.Abs(got-tc.want) > 1e-4 {
+            t.Errorf("JaroWinkler(%q, %q) = %.4f, want %.4f", tc.a, tc.b, got, tc.want)
+        }
+        if got, rev := JaroWinkler(tc.a, tc.b), JaroWinkler(tc.b, tc.a); got != rev {
+            t.Errorf("JaroWinkler(%q, %q) = %v but %v reversed", tc.a, tc.b, got, rev)
+        }
+    }
+}
+
+func TestNameSimilarity(t *testing.T) {
+    if got := NormalizeName("  José  O'Brien-Łukasz "); got != "jose o brien lukasz" {
+        t.Errorf("NormalizeName = %q, want %q", got, "jose o brien lukasz")
+    }
+    if got := NameSimilarity(NormalizeName("Doe, John"), NormalizeName("John Doe")); got != 1 {
+        t.Errorf("NameSimilarity of reordered names = %v, want 1", got)
+    }
+    if got := NameSimilarity("", "john doe"); got != 0 {
+        t.Errorf("NameSimilarity with an empty name = %v, want 0", got)
+    }
+}
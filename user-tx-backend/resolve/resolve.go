@@ -0,0 +1,360 @@
+// Package resolve links user records that probably belong to the same
+// real-world person. Emails and phone numbers are canonicalised before
+// they are compared and names are matched fuzzily; the results are stored
+// as SAME_EMAIL_CANONICAL, SAME_PHONE_E164 and SIMILAR_NAME relationships
+// with a confidence, and grouped into merge proposals for Person entities.
+package resolve
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+const (
+    // DefaultThreshold is the lowest name similarity linked as SIMILAR_NAME.
+    DefaultThreshold = 0.9
+    // DefaultCountry places phone numbers written without a country code.
+    DefaultCountry = "US"
+    // DefaultMinConfidence is the lowest pair confidence joined into a
+    // merge proposal.
+    DefaultMinConfidence = 0.8
+)
+
+// Confidence of each kind of evidence. An email or phone number counts the
+// same whether it matched as given (the SHARED_EMAIL and SHARED_PHONE links
+// the store derives) or only once canonicalised, since several people can
+// share one. Names alone are weak evidence, so a SIMILAR_NAME link's
+// confidence is the name similarity scaled by nameWeight.
+const (
+    emailConfidence = 0.95
+    phoneConfidence = 0.9
+    nameWeight      = 0.6
+)
+
+// Resolver derives probable-match links. It holds no state besides its
+// settings and is safe for concurrent use.
+type Resolver struct {
+    threshold float64
+    country   string
+}
+
+// NewResolver returns a resolver linking names at or above threshold and
+// reading national phone numbers as numbers of country.
+func NewResolver(threshold float64, country string) (*Resolver, error) {
+    if threshold <= 0 || threshold > 1 {
+        return nil, fmt.Errorf("threshold must be in (0, 1]")
+    }
+    country = strings.ToUpper(strings.TrimSpace(country))
+    if !KnownCountry(country) {
+        return nil, fmt.Errorf("unknown default country %q", country)
+    }
+    return &Resolver{threshold: threshold, country: country}, nil
+}
+
+// Threshold returns the lowest name similarity linked as SIMILAR_NAME.
+func (r *Resolver) Threshold() float64 {
+    return r.threshold
+}
+
+// Country returns the default country for phone numbers.
+func (r *Resolver) Country() string {
+    return r.country
+}
+
+// identity is a user with its match keys precomputed.
+type identity struct {
+    models.User
+    email string
+    phone string
+    name  string
+}
+
+func (r *Resolver) prepare(u models.User) identity {
+    return identity{
+        User:  u,
+        email: CanonicalEmail(u.Email),
+        phone: CanonicalPhone(u.Phone, r.country),
+        name:  NormalizeName(u.Name),
+    }
+}
+
+// pairLinks returns the probable-match links between two users, directed
+// from the smaller ID. Values that are equal as given are left to the
+// store's SHARED_EMAIL and SHARED_PHONE links.
+func (r *Resolver) pairLinks(a, b identity) []models.IdentityLink {
+    if a.ID > b.ID {
+        a, b = b, a
+    }
+    link := func(typ string, conf float64) models.IdentityLink {
+        return models.IdentityLink{From: a.ID, To: b.ID, Type: typ, Confidence: round(conf)}
+    }
+    var links []models.IdentityLink
+    if a.email != "" && a.email == b.email && a.Email != b.Email {
+        links = append(links, link("SAME_EMAIL_CANONICAL", emailConfidence))
+    }
+    if a.phone != "" && a.phone == b.phone && a.Phone != b.Phone {
+        links = append(links, link("SAME_PHONE_E164", phoneConfidence))
+    }
+    if sim := NameSimilarity(a.name, b.name); sim >= r.threshold {
+        links = append(links, link("SIMILAR_NAME", sim*nameWeight))
+    }
+    return links
+}
+
+// LinkUser replaces the probable-match links of one user, comparing it
+// with every other user. It is used when a user is created or updated.
+func (r *Resolver) LinkUser(store graph.GraphStore, u models.User) ([]models.IdentityLink, error) {
+    return r.LinkUsers(store, []models.User{u})
+}
+
+// LinkUsers is LinkUser for a batch of users, such as a bulk import. Each
+// pair is compared once: the batch against every other user and against
+// itself. The links are stored at once.
+func (r *Resolver) LinkUsers(store graph.GraphStore, batch []models.User) ([]models.IdentityLink, error) {
+    users, err := store.GetAllUsers()
+    if err != nil {
+        return nil, err
+    }
+    ids := make([]string, len(batch))
+    inBatch := make(map[string]bool, len(batch))
+    prepared := make([]identity, len(batch))
+    for i, u := range batch {
+        ids[i] = u.ID
+        inBatch[u.ID] = true
+        prepared[i] = r.prepare(u)
+    }
+    links := []models.IdentityLink{}
+    for _, o := range users {
+        if inBatch[o.ID] {
+            continue
+        }
+        other := r.prepare(o)
+        for _, self := range prepared {
+            links = append(links, r.pairLinks(self, other)...)
+        }
+    }
+    for i := range prepared {
+        for j := i + 1; j < len(prepared); j++ {
+            links = append(links, r.pairLinks(prepared[i], prepared[j])...)
+        }
+    }
+    if err := store.SetIdentityLinks(ids, links); err != nil {
+        return nil, err
+    }
+    return links, nil
+}
+
+// Rebuild compares every pair of users and replaces all probable-match
+// links. Names are compared pairwise, so its cost grows with the square of
+// the number of users.
+func (r *Resolver) Rebuild(store graph.GraphStore) (models.ResolutionResponse, error) {
+    users, err := store.GetAllUsers()
+    if err != nil {
+        return models.ResolutionResponse{}, err
+    }
+    ids := make([]string, len(users))
+    prepared := make([]identity, len(users))
+    for i, u := range users {
+        ids[i] = u.ID
+        prepared[i] = r.prepare(u)
+    }
+    resp := models.ResolutionResponse{Users: len(users), ByType: map[string]int{}}
+    links := []models.IdentityLink{}
+    for i := range prepared {
+        for j := i + 1; j < len(prepared); j++ {
+            for _, l := range r.pairLinks(prepared[i], prepared[j]) {
+                links = append(links, l)
+                resp.ByType[l.Type]++
+            }
+        }
+    }
+    if err := store.SetIdentityLinks(ids, links); err != nil {
+        return models.ResolutionResponse{}, err
+    }
+    resp.Links = len(links)
+    return resp, nil
+}
+
+// Proposals groups users connected by evidence of at least minConfidence
+// into merge proposals. The evidence for a pair is combined as independent
+// signals, 1 - Π(1 - confidence), over its stored links and exact
+// SHARED_EMAIL/SHARED_PHONE matches. Groups whose users already belong to
+// one Person are left out. Proposals are ordered by confidence, then size.
+func (r *Resolver) Proposals(store graph.GraphStore, minConfidence float64) ([]models.MergeProposal, error) {
+    if minConfidence <= 0 || minConfidence > 1 {
+        return nil, fmt.Errorf("%w: minConfidence must be in (0, 1]", graph.ErrInvalidQuery)
+    }
+    users, err := store.GetAllUsers()
+    if err != nil {
+        return nil, err
+    }
+    stored, err := store.IdentityLinks()
+    if err != nil {
+        return nil, err
+    }
+    persons, err := store.ListPersons()
+    if err != nil {
+        return nil, err
+    }
+
+    byID := make(map[string]models.User, len(users))
+    for _, u := range users {
+        byID[u.ID] = u
+    }
+    evidence := append(exactLinks(users), stored...)
+
+    // Combine the evidence per unordered pair.
+    type pair struct{ a, b string }
+    miss := make(map[pair]float64)
+    var order []pair
+    for _, l := range evidence {
+        p := pair{l.From, l.To}
+        if p.a > p.b {
+            p.a, p.b = p.b, p.a
+        }
+        if _, ok := miss[p]; !ok {
+            miss[p] = 1
+            order = append(order, p)
+        }
+        miss[p] *= 1 - l.Confidence
+    }
+
+    parent := make(map[string]string)
+    var find func(string) string
+    find = func(x string) string {
+        if p, ok := parent[x]; ok && p != x {
+            root := find(p)
+            parent[x] = root
+            return root
+        }
+        return x
+    }
+    weakest := make(map[string]float64) // by root
+    for _, p := range order {
+        conf := round(1 - miss[p])
+        if conf < minConfidence {
+            continue
+        }
+        ra, rb := find(p.a), find(p.b)
+        wa, okA := weakest[ra]
+        wb, okB := weakest[rb]
+        w := conf
+        if okA && wa < w {
+            w = wa
+        }
+        if ra != rb {
+            if okB && wb < w {
+                w = wb
+            }
+            parent[rb] = ra
+            delete(weakest, rb)
+        }
+        weakest[ra] = w
+    }
+
+    personOf := make(map[string]string)
+    for _, p := range persons {
+        for _, uid := range p.UserIDs {
+            personOf[uid] = p.ID
+        }
+    }
+
+    groups := make(map[string][]string)
+    for _, u := range users {
+        if _, ok := parent[u.ID]; ok || weakest[u.ID] > 0 {
+            root := find(u.ID)
+            groups[root] = append(groups[root], u.ID)
+        }
+    }
+
+    proposals := []models.MergeProposal{}
+    for root, ids := range groups {
+        if len(ids) < 2 {
+            continue
+        }
+        sort.Strings(ids)
+        prop := models.MergeProposal{
+            UserIDs:    ids,
+            Confidence: weakest[root],
+            Links:      []models.IdentityLink{},
+        }
+        seen := make(map[string]bool)
+        unresolved := false
+        for _, id := range ids {
+            prop.Users = append(prop.Users, byID[id])
+            pid, ok := personOf[id]
+            if !ok {
+                unresolved = true
+            } else if !seen[pid] {
+                seen[pid] = true
+                prop.PersonIDs = append(prop.PersonIDs, pid)
+            }
+        }
+        if !unresolved && len(prop.PersonIDs) == 1 {
+            continue
+        }
+        member := make(map[string]bool, len(ids))
+        for _, id := range ids {
+            member[id] = true
+        }
+        for _, l := range evidence {
+            if member[l.From] && member[l.To] {
+                prop.Links = append(prop.Links, l)
+            }
+        }
+        proposals = append(proposals, prop)
+    }
+    sort.Slice(proposals, func(i, j int) bool {
+        a, b := proposals[i], proposals[j]
+        if a.Confidence != b.Confidence {
+            return a.Confidence > b.Confidence
+        }
+        if len(a.UserIDs) != len(b.UserIDs) {
+            return len(a.UserIDs) > len(b.UserIDs)
+        }
+        return a.UserIDs[0] < b.UserIDs[0]
+    })
+    return proposals, nil
+}
+
+// exactLinks returns SHARED_EMAIL and SHARED_PHONE evidence for users whose
+// raw values are equal, as the store links them.
+func exactLinks(users []models.User) []models.IdentityLink {
+    var links []models.IdentityLink
+    for _, typ := range []string{"SHARED_EMAIL", "SHARED_PHONE"} {
+        byValue := make(map[string][]string)
+        for _, u := range users {
+            v := u.Email
+            if typ == "SHARED_PHONE" {
+                v = u.Phone
+            }
+            if v != "" {
+                byValue[v] = append(byValue[v], u.ID)
+            }
+        }
+        conf := emailConfidence
+        if typ == "SHARED_PHONE" {
+            conf = phoneConfidence
+        }
+        for _, ids := range byValue {
+            sort.Strings(ids)
+            for i := range ids {
+                for j := i + 1; j < len(ids); j++ {
+                    links = append(links, models.IdentityLink{
+                        From: ids[i], To: ids[j], Type: typ, Confidence: conf,
+                    })
+                }
+            }
+        }
+    }
+    return links
+}
+
+// round keeps confidences readable in responses.
+func round(v float64) float64 {
+    return float64(int64(v*1000+0.5)) / 1000
+}
@@ -0,0 +1,657 @@
+// Package risk scores users and transactions from signals already in the
+// graph. Factor weights come from a JSON file and can be changed while the
+// server runs.
+package risk
+
+import (
+    "encoding/json"
+    "fmt"
+    "math"
+    "os"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+// Factor names.
+const (
+    SharedIdentity = "shared_identity" // users linked by SHARED_EMAIL or SHARED_PHONE
+    SharedDevice   = "shared_device"   // SHARED_DEVICE degree
+    ClusterSize    = "cluster_size"    // size of the transaction cluster
+    BadProximity   = "bad_proximity"   // hops to the nearest known-bad user
+    Velocity       = "velocity"        // sender's transactions within the velocity window
+    AmountAnomaly  = "amount_anomaly"  // robust z-score of the amount
+)
+
+var factorNames = []string{SharedIdentity, SharedDevice, ClusterSize, BadProximity, Velocity, AmountAnomaly}
+
+// maxCases bounds the closed cases read for known-bad users.
+const maxCases = 1000
+
+// Config is the layout of the risk config file.
+type Config struct {
+    // Weights per factor. Missing factors get their default weight; a
+    // weight of 0 disables the factor.
+    Weights map[string]float64 `json:"weights"`
+    // KnownBadUsers are user IDs treated as bad. Users on closed cases whose
+    // disposition is one of BadDispositions are added to them.
+    KnownBadUsers   []string `json:"knownBadUsers"`
+    BadDispositions []string `json:"badDispositions"`
+    VelocityWindow  string   `json:"velocityWindow"` // Go duration
+    MaxHops         int      `json:"maxHops"`        // bad_proximity search depth
+
+    window time.Duration
+}
+
+// DefaultConfig returns the weights and settings used without a config
+// file.
+func DefaultConfig() Config {
+    return Config{
+        Weights: map[string]float64{
+            SharedIdentity: 1,
+            SharedDevice:   1,
+            ClusterSize:    0.5,
+            BadProximity:   2,
+            Velocity:       1,
+            AmountAnomaly:  1,
+        },
+        KnownBadUsers:   []string{},
+        BadDispositions: []string{"fraud", "confirmed fraud"},
+        VelocityWindow:  "1h",
+        MaxHops:         3,
+        window:          time.Hour,
+    }
+}
+
+// normalize fills in defaults and checks the config.
+func (c *Config) normalize() error {
+    def := DefaultConfig()
+    weights := make(map[string]float64, len(factorNames))
+    for name, w := range c.Weights {
+        if !hasString(factorNames, name) {
+            return fmt.Errorf("unknown factor %q", name)
+        }
+        if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+            return fmt.Errorf("factor %s: weight must be a non-negative number", name)
+        }
+        weights[name] = w
+    }
+    total := 0.0
+    for _, name := range factorNames {
+        if _, ok := weights[name]; !ok {
+            weights[name] = def.Weights[name]
+        }
+        total += weights[name]
+    }
+    if total == 0 {
+        return fmt.Errorf("at least one weight must be positive")
+    }
+    c.Weights = weights
+
+    if c.VelocityWindow == "" {
+        c.VelocityWindow = def.VelocityWindow
+    }
+    d, err := time.ParseDuration(c.VelocityWindow)
+    if err != nil || d <= 0 {
+        return fmt.Errorf("invalid velocityWindow %q", c.VelocityWindow)
+    }
+    c.window = d
+    if c.MaxHops == 0 {
+        c.MaxHops = def.MaxHops
+    }
+    if c.MaxHops < 1 || c.MaxHops > 10 {
+        return fmt.Errorf("maxHops must be between 1 and 10")
+    }
+    if c.KnownBadUsers == nil {
+        c.KnownBadUsers = []string{}
+    }
+    if c.BadDispositions == nil {
+        c.BadDispositions = def.BadDispositions
+    }
+    return nil
+}
+
+// Scorer holds the active config. It is safe for concurrent use.
+type Scorer struct {
+    path string
+
+    mu  sync.RWMutex
+    cfg Config
+}
+
+// NewScorer loads the config file at path. An empty path yields the
+// default config.
+func NewScorer(path string) (*Scorer, error) {
+    s := &Scorer{path: path, cfg: DefaultConfig()}
+    if path == "" {
+        return s, nil
+    }
+    if err := s.Reload(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// Reload re-reads the config file. On error the current config stays
+// active.
+func (s *Scorer) Reload() error {
+    if s.path == "" {
+        return fmt.Errorf("no risk config file configured")
+    }
+    b, err := os.ReadFile(s.path)
+    if err != nil {
+        return err
+    }
+    var cfg Config
+    if err := json.Unmarshal(b, &cfg); err != nil {
+        return fmt.Errorf("%s: %w", s.path, err)
+    }
+    if err := cfg.normalize(); err != nil {
+        return fmt.Errorf("%s: %w", s.path, err)
+    }
+    s.mu.Lock()
+    s.cfg = cfg
+    s.mu.Unlock()
+    return nil
+}
+
+// Config returns a copy of the active config.
+func (s *Scorer) Config() Config {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    cfg := s.cfg
+    cfg.Weights = make(map[string]float64, len(s.cfg.Weights))
+    for k, v := range s.cfg.Weights {
+        cfg.Weights[k] = v
+    }
+    cfg.KnownBadUsers = append([]string{}, s.cfg.KnownBadUsers...)
+    cfg.BadDispositions = append([]string{}, s.cfg.BadDispositions...)
+    return cfg
+}
+
+// SetConfig replaces the active config until the next Reload. The file is
+// left as is.
+func (s *Scorer) SetConfig(cfg Config) error {
+    if err := cfg.normalize(); err != nil {
+        return err
+    }
+    s.mu.Lock()
+    s.cfg = cfg
+    s.mu.Unlock()
+    return nil
+}
+
+// Result holds the scores of every user and transaction.
+type Result struct {
+    Users        map[string]models.RiskScore
+    Transactions map[string]models.RiskScore
+}
+
+// Compute scores every user and transaction in the store.
+func (s *Scorer) Compute(store graph.GraphStore) (*Result, error) {
+    cfg := s.Config()
+    sig, err := loadSignals(store, cfg)
+    if err != nil {
+        return nil, err
+    }
+    res := &Result{
+        Users:        make(map[string]models.RiskScore, len(sig.snap.Users)),
+        Transactions: make(map[string]models.RiskScore, len(sig.snap.Transactions)),
+    }
+    for id, values := range sig.users() {
+        res.Users[id] = cfg.score(id, "user", values)
+    }
+    for _, t := range sig.snap.Transactions {
+        res.Transactions[t.ID] = cfg.score(t.ID, "transaction", sig.transaction(t))
+    }
+    return res, nil
+}
+
+// User scores one user.
+func (s *Scorer) User(store graph.GraphStore, id string) (models.RiskScore, error) {
+    res, err := s.Compute(store)
+    if err != nil {
+        return models.RiskScore{}, err
+    }
+    score, ok := res.Users[id]
+    if !ok {
+        return models.RiskScore{}, graph.ErrUserNotFound
+    }
+    return score, nil
+}
+
+// Transaction scores one transaction.
+func (s *Scorer) Transaction(store graph.GraphStore, id string) (models.RiskScore, error) {
+    res, err := s.Compute(store)
+    if err != nil {
+        return models.RiskScore{}, err
+    }
+    score, ok := res.Transactions[id]
+    if !ok {
+        return models.RiskScore{}, graph.ErrTransactionNotFound
+    }
+    return score, nil
+}
+
+// Recompute scores everything, stores the scores as the risk property of
+// every User and Transaction, and returns the top highest-risk of each.
+func (s *Scorer) Recompute(store graph.GraphStore, top int) (models.RiskRecomputeResponse, error) {
+    res, err := s.Compute(store)
+    if err != nil {
+        return models.RiskRecomputeResponse{}, err
+    }
+    if err := store.WriteUserScores("risk", totals(res.Users)); err != nil {
+        return models.RiskRecomputeResponse{}, err
+    }
+    if err := store.WriteTransactionScores("risk", totals(res.Transactions)); err != nil {
+        return models.RiskRecomputeResponse{}, err
+    }
+    return models.RiskRecomputeResponse{
+        Users:           len(res.Users),
+        Transactions:    len(res.Transactions),
+        TopUsers:        highest(res.Users, top),
+        TopTransactions: highest(res.Transactions, top),
+    }, nil
+}
+
+func totals(scores map[string]models.RiskScore) map[string]float64 {
+    out := make(map[string]float64, len(scores))
+    for id, s := range scores {
+        out[id] = s.Score
+    }
+    return out
+}
+
+// highest returns the n highest scores, ties broken by ID.
+func highest(scores map[string]models.RiskScore, n int) []models.RiskScore {
+    out := make([]models.RiskScore, 0, len(scores))
+    for _, s := range scores {
+        out = append(out, s)
+    }
+    sort.Slice(out, func(i, j int) bool {
+        if out[i].Score != out[j].Score {
+            return out[i].Score > out[j].Score
+        }
+        return out[i].ID < out[j].ID
+    })
+    if len(out) > n {
+        out = out[:n]
+    }
+    return out
+}
+
+// score combines raw factor values into a 0–100 score.
+func (c Config) score(id, typ string, values map[string]float64) models.RiskScore {
+    total := 0.0
+    for _, name := range factorNames {
+        total += c.Weights[name]
+    }
+    rs := models.RiskScore{ID: id, Type: typ, Factors: make([]models.RiskFactor, 0, len(factorNames))}
+    for _, name := range factorNames {
+        v := values[name]
+        f := models.RiskFactor{Name: name, Value: v, Score: normalize(name, v), Weight: c.Weights[name]}
+        f.Contribution = 100 * f.Weight * f.Score / total
+        rs.Score += f.Contribution
+        rs.Factors = append(rs.Factors, f)
+    }
+    return rs
+}
+
+// normalize maps a raw factor value into [0, 1]. Counts saturate so that
+// the first few links matter most.
+func normalize(name string, v float64) float64 {
+    switch name {
+    case SharedIdentity:
+        return saturate(v, 2)
+    case SharedDevice:
+        return saturate(v, 3)
+    case ClusterSize:
+        return saturate(v-1, 10)
+    case BadProximity:
+        if v < 0 {
+            return 0
+        }
+        return 1 / (1 + v)
+    case Velocity:
+        return saturate(v-1, 5)
+    case AmountAnomaly:
+        return math.Max(0, math.Min(1, v/4))
+    }
+    return 0
+}
+
+// saturate is x/(x+half) for positive x: 0.5 at half, approaching 1.
+func saturate(x, half float64) float64 {
+    if x <= 0 {
+        return 0
+    }
+    return x / (x + half)
+}
+
+// signals holds the raw inputs shared by every score.
+type signals struct {
+    cfg  Config
+    snap *graph.Snapshot
+
+    identity    map[string]map[string]bool // user -> users sharing email or phone
+    devicePeers map[string]map[string]bool // user -> users sharing a device
+    wideHubs    [][]string                 // users of hubs too large to link pairwise
+    wide        map[string]map[string]int  // factor -> user -> peers through wideHubs
+    txDevice    map[string]int             // transaction -> SHARED_DEVICE links
+    clusterSize map[string]int             // transaction -> size of its cluster
+    hops        map[string]int             // user -> hops to a known-bad user
+    velocity    map[string]int             // transaction -> sender's recent transactions
+    anomaly     map[string]float64         // transaction -> amount z-score
+}
+
+func link(m map[string]map[string]bool, a, b string) {
+    if a == b {
+        return
+    }
+    for _, p := range [][2]string{{a, b}, {b, a}} {
+        if m[p[0]] == nil {
+            m[p[0]] = make(map[string]bool)
+        }
+        m[p[0]][p[1]] = true
+    }
+}
+
+func loadSignals(store graph.GraphStore, cfg Config) (*signals, error) {
+    snap, err := store.Snapshot(graph.SnapshotFilter{IncludeShared: true})
+    if err != nil {
+        return nil, err
+    }
+    clusters, err := store.ClusterTransactions(nil)
+    if err != nil {
+        return nil, err
+    }
+    cases, err := store.ListCases(models.CaseQuery{Status: "closed", Limit: maxCases})
+    if err != nil {
+        return nil, err
+    }
+
+    sig := &signals{
+        cfg:         cfg,
+        snap:        snap,
+        identity:    make(map[string]map[string]bool),
+        devicePeers: make(map[string]map[string]bool),
+        wide:        map[string]map[string]int{SharedIdentity: {}, SharedDevice: {}},
+        txDevice:    make(map[string]int),
+        clusterSize: make(map[string]int),
+        velocity:    make(map[string]int),
+        anomaly:     make(map[string]float64),
+    }
+    byID := make(map[string]models.Transaction, len(snap.Transactions))
+    for _, t := range snap.Transactions {
+        byID[t.ID] = t
+    }
+    for _, h := range snap.Hubs {
+        switch h.Type {
+        case "SHARED_EMAIL", "SHARED_PHONE":
+            sig.addPeers(SharedIdentity, sig.identity, h.Members)
+        case "SHARED_DEVICE":
+            // The device is the sender's.
+            var senders []string
+            for _, id := range h.Members {
+                if t, ok := byID[id]; ok {
+                    sig.txDevice[id] += len(h.Members) - 1
+                    senders = append(senders, t.FromUserID)
+                }
+            }
+            sig.addPeers(SharedDevice, sig.devicePeers, senders)
+        }
+    }
+
+    sizes := make(map[string]int)
+    for _, c := range clusters {
+        sizes[c.ClusterID]++
+    }
+    for _, c := range clusters {
+        sig.clusterSize[c.TransactionID] = sizes[c.ClusterID]
+    }
+
+    sig.hops = sig.badHops(cases)
+    sig.scanTransactions()
+    return sig, nil
+}
+
+// addPeers links the distinct users of one hub in peers. A hub too large to
+// link pairwise counts towards each member's peers under factor instead,
+// and badHops crosses it as a whole.
+func (s *signals) addPeers(factor string, peers map[string]map[string]bool, users []string) {
+    if graph.LinkMembers(users, func(a, b string) { link(peers, a, b) }) {
+        return
+    }
+    users = graph.DistinctMembers(users)
+    for _, u := range users {
+        s.wide[factor][u] += len(users) - 1
+    }
+    s.wideHubs = append(s.wideHubs, users)
+}
+
+// peers is the number of users linked to id by the shared attributes of a
+// factor.
+func (s *signals) peers(factor string, id string) float64 {
+    m := s.identity
+    if factor == SharedDevice {
+        m = s.devicePeers
+    }
+    return float64(len(m[id]) + s.wide[factor][id])
+}
+
+// badHops runs a breadth-first search from every known-bad user over the
+// undirected user graph of transfers and shared attributes, up to MaxHops.
+func (s *signals) badHops(cases []models.Case) map[string]int {
+    adj := make(map[string]map[string]bool)
+    for _, t := range s.snap.Transactions {
+        link(adj, t.FromUserID, t.ToUserID)
+    }
+    for _, m := range []map[string]map[string]bool{s.identity, s.devicePeers} {
+        for a, peers := range m {
+            for b := range peers {
+                link(adj, a, b)
+            }
+        }
+    }
+
+    hubsOf := make(map[string][]int)
+    for i, users := range s.wideHubs {
+        for _, u := range users {
+            hubsOf[u] = append(hubsOf[u], i)
+        }
+    }
+    crossed := make(map[int]bool)
+
+    hops := make(map[string]int)
+    var frontier []string
+    seed := func(id string) {
+        if _, ok := s.snap.Users[id]; !ok {
+            return
+        }
+        if _, seen := hops[id]; !seen {
+            hops[id] = 0
+            frontier = append(frontier, id)
+        }
+    }
+    for _, id := range s.cfg.KnownBadUsers {
+        seed(id)
+    }
+    for _, c := range cases {
+        if !hasFold(s.cfg.BadDispositions, c.Disposition) {
+            continue
+        }
+        for _, id := range c.UserIDs {
+            seed(id)
+        }
+    }
+    for d := 1; d <= s.cfg.MaxHops && len(frontier) > 0; d++ {
+        var next []string
+        reach := func(v string) {
+            if _, seen := hops[v]; !seen {
+                hops[v] = d
+                next = append(next, v)
+            }
+        }
+        for _, u := range frontier {
+            for v := range adj[u] {
+                reach(v)
+            }
+            for _, i := range hubsOf[u] {
+                if !crossed[i] {
+                    crossed[i] = true
+                    for _, v := range s.wideHubs[i] {
+                        reach(v)
+                    }
+                }
+            }
+        }
+        frontier = next
+    }
+    return hops
+}
+
+// scanTransactions derives velocity and amount anomaly per transaction.
+// Amounts are compared on a log scale against the sender's own history
+// when it has enough transactions, otherwise against every transaction.
+func (s *signals) scanTransactions() {
+    const minHistory = 5
+
+    sent := make(map[string][]models.Transaction)
+    var all []float64
+    for _, t := range s.snap.Transactions {
+        sent[t.FromUserID] = append(sent[t.FromUserID], t)
+        all = append(all, math.Log1p(math.Max(graph.Value(t), 0)))
+    }
+    global := newRobustStats(all)
+    for _, txs := range sent {
+        stats := global
+        if len(txs) >= minHistory {
+            amounts := make([]float64, len(txs))
+            for i, t := range txs {
+                amounts[i] = math.Log1p(math.Max(graph.Value(t), 0))
+            }
+            stats = newRobustStats(amounts)
+        }
+        start := 0
+        for i, t := range txs {
+            ts := s.snap.Time(t.ID)
+            for ts.Sub(s.snap.Time(txs[start].ID)) >= s.cfg.window {
+                start++
+            }
+            s.velocity[t.ID] = i - start + 1
+            s.anomaly[t.ID] = stats.z(math.Log1p(math.Max(graph.Value(t), 0)))
+        }
+    }
+}
+
+// robustStats is a median / median-absolute-deviation summary.
+type robustStats struct {
+    median, mad float64
+}
+
+func newRobustStats(xs []float64) robustStats {
+    if len(xs) == 0 {
+        return robustStats{}
+    }
+    med := median(xs)
+    dev := make([]float64, len(xs))
+    for i, x := range xs {
+        dev[i] = math.Abs(x - med)
+    }
+    return robustStats{median: med, mad: median(dev)}
+}
+
+// z is the robust z-score of x, capped at ±4 when the spread is zero.
+func (r robustStats) z(x float64) float64 {
+    if r.mad == 0 {
+        switch {
+        case x > r.median:
+            return 4
+        case x < r.median:
+            return -4
+        }
+        return 0
+    }
+    return (x - r.median) / (1.4826 * r.mad)
+}
+
+func median(xs []float64) float64 {
+    s := append([]float64{}, xs...)
+    sort.Float64s(s)
+    n := len(s)
+    if n%2 == 1 {
+        return s[n/2]
+    }
+    return (s[n/2-1] + s[n/2]) / 2
+}
+
+// hopValue reports the hops to a known-bad user, -1 when none is within
+// reach.
+func (s *signals) hopValue(ids ...string) float64 {
+    best := -1
+    for _, id := range ids {
+        if d, ok := s.hops[id]; ok && (best < 0 || d < best) {
+            best = d
+        }
+    }
+    return float64(best)
+}
+
+// users derives the raw factor values of every user. Transaction-based
+// factors take the user's worst transaction: any for cluster size, sent
+// ones for velocity and amount.
+func (s *signals) users() map[string]map[string]float64 {
+    out := make(map[string]map[string]float64, len(s.snap.Users))
+    for id := range s.snap.Users {
+        out[id] = map[string]float64{
+            SharedIdentity: s.peers(SharedIdentity, id),
+            SharedDevice:   s.peers(SharedDevice, id),
+            BadProximity:   s.hopValue(id),
+        }
+    }
+    for _, t := range s.snap.Transactions {
+        size := float64(s.clusterSize[t.ID])
+        if v, ok := out[t.ToUserID]; ok {
+            v[ClusterSize] = math.Max(v[ClusterSize], size)
+        }
+        if v, ok := out[t.FromUserID]; ok {
+            v[ClusterSize] = math.Max(v[ClusterSize], size)
+            v[Velocity] = math.Max(v[Velocity], float64(s.velocity[t.ID]))
+            v[AmountAnomaly] = math.Max(v[AmountAnomaly], s.anomaly[t.ID])
+        }
+    }
+    return out
+}
+
+func (s *signals) transaction(t models.Transaction) map[string]float64 {
+    return map[string]float64{
+        SharedIdentity: math.Max(s.peers(SharedIdentity, t.FromUserID), s.peers(SharedIdentity, t.ToUserID)),
+        SharedDevice:   float64(s.txDevice[t.ID]),
+        ClusterSize:    float64(s.clusterSize[t.ID]),
+        BadProximity:   s.hopValue(t.FromUserID, t.ToUserID),
+        Velocity:       float64(s.velocity[t.ID]),
+        AmountAnomaly:  s.anomaly[t.ID],
+    }
+}
+
+func hasString(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
+
+func hasFold(list []string, s string) bool {
+    for _, v := range list {
+        if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(s)) {
+            return true
+        }
+    }
+    return false
+}
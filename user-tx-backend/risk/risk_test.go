@@ -0,0 +1,48 @@
+package risk
+
+import (
+    "fmt"
+    "testing"
+
+    "user-tx-backend/graph"
+)
+
+func TestSignalsLargeSharedPhone(t *testing.T) {
+    store := graph.NewMemoryStore()
+    n := graph.MaxHubFanOut + 10
+    ids := make([]string, n)
+    for i := range ids {
+        id, err := store.CreateUser(fmt.Sprintf("User %d", i), fmt.Sprintf("u%d@example.com", i), "555")
+        if err != nil {
+            t.Fatal(err)
+        }
+        ids[i] = id
+    }
+    loner, err := store.CreateUser("Loner", "loner@example.com", "999")
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    cfg := DefaultConfig()
+    cfg.KnownBadUsers = []string{ids[0]}
+    cfg.MaxHops = 1
+    if err := cfg.normalize(); err != nil {
+        t.Fatal(err)
+    }
+    sig, err := loadSignals(store, cfg)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if got := sig.peers(SharedIdentity, ids[1]); got != float64(n-1) {
+        t.Errorf("shared identity peers = %v, want %d", got, n-1)
+    }
+    if len(sig.identity) != 0 {
+        t.Errorf("a hub of %d users was linked pairwise", n)
+    }
+    if hops, ok := sig.hops[ids[n-1]]; !ok || hops != 1 {
+        t.Errorf("hops to a known-bad user over the shared phone = %v, %v; want 1", hops, ok)
+    }
+    if _, ok := sig.hops[loner]; ok {
+        t.Error("unconnected user reached a known-bad user")
+    }
+}
@@ -0,0 +1,286 @@
+// Package rules evaluates fraud rules against transaction requests before
+// they are written. Rules are declared in a JSON file and can be reloaded
+// while the server runs. YAML is not supported: reading it would take a
+// dependency outside the standard library.
+package rules
+
+import (
+    "encoding/json"
+    "errors"
+    "fmt"
+    "log"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "user-tx-backend/fx"
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+// Rule types.
+const (
+    AmountAbove     = "amount_above"     // amount > threshold
+    DeviceVelocity  = "device_velocity"  // more than count transactions from the device within window
+    SharedPhone     = "shared_phone"     // sender and receiver share a phone number
+    NewCounterparty = "new_counterparty" // first transfer from sender to receiver, above threshold
+)
+
+// Rule is one entry of the rules file.
+type Rule struct {
+    ID        string  `json:"id"`
+    Name      string  `json:"name"`
+    Type      string  `json:"type"`
+    Severity  string  `json:"severity"`         // free text, e.g. low, medium, high
+    Action    string  `json:"action"`           // "alert" (default) or "reject"
+    Disabled  bool    `json:"disabled,omitempty"`
+    Threshold float64 `json:"threshold,omitempty"`
+    Count     int     `json:"count,omitempty"`
+    Window    string  `json:"window,omitempty"` // Go duration
+
+    window time.Duration
+}
+
+// File is the layout of the rules file.
+type File struct {
+    Rules []Rule `json:"rules"`
+}
+
+func (r *Rule) validate() error {
+    if r.ID == "" {
+        return fmt.Errorf("rule without id")
+    }
+    if r.Name == "" {
+        r.Name = r.ID
+    }
+    switch r.Action {
+    case "":
+        r.Action = "alert"
+    case "alert", "reject":
+    default:
+        return fmt.Errorf("rule %s: action must be alert or reject", r.ID)
+    }
+    switch r.Type {
+    case AmountAbove, NewCounterparty, SharedPhone:
+    case DeviceVelocity:
+        d, err := time.ParseDuration(r.Window)
+        if err != nil || d <= 0 {
+            return fmt.Errorf("rule %s: invalid window %q", r.ID, r.Window)
+        }
+        if r.Count < 1 {
+            return fmt.Errorf("rule %s: count must be positive", r.ID)
+        }
+        r.window = d
+    default:
+        return fmt.Errorf("rule %s: unknown type %q", r.ID, r.Type)
+    }
+    return nil
+}
+
+// Engine holds the active rule set. It is safe for concurrent use.
+type Engine struct {
+    path string
+
+    mu      sync.RWMutex
+    rules   []Rule
+    modTime time.Time
+}
+
+// NewEngine loads the rules file at path. An empty path yields an engine
+// without rules.
+func NewEngine(path string) (*Engine, error) {
+    e := &Engine{path: path}
+    if path == "" {
+        return e, nil
+    }
+    if err := e.Reload(); err != nil {
+        return nil, err
+    }
+    return e, nil
+}
+
+// Reload re-reads the rules file. On error the current rules stay active.
+func (e *Engine) Reload() error {
+    if e.path == "" {
+        return fmt.Errorf("no rules file configured")
+    }
+    if ext := strings.ToLower(filepath.Ext(e.path)); ext == ".yaml" || ext == ".yml" {
+        return fmt.Errorf("%s: rules files must be JSON, YAML is not supported", e.path)
+    }
+    info, err := os.Stat(e.path)
+    if err != nil {
+        return err
+    }
+    b, err := os.ReadFile(e.path)
+    if err != nil {
+        return err
+    }
+    var f File
+    if err := json.Unmarshal(b, &f); err != nil {
+        return fmt.Errorf("%s: %w", e.path, err)
+    }
+    seen := make(map[string]bool)
+    for i := range f.Rules {
+        if err := f.Rules[i].validate(); err != nil {
+            return fmt.Errorf("%s: %w", e.path, err)
+        }
+        if seen[f.Rules[i].ID] {
+            return fmt.Errorf("%s: duplicate rule id %s", e.path, f.Rules[i].ID)
+        }
+        seen[f.Rules[i].ID] = true
+    }
+
+    e.mu.Lock()
+    e.rules = f.Rules
+    e.modTime = info.ModTime()
+    e.mu.Unlock()
+    return nil
+}
+
+// Watch polls the rules file and reloads it whenever it changes, until
+// stop is closed.
+func (e *Engine) Watch(interval time.Duration, stop <-chan struct{}) {
+    if e.path == "" {
+        return
+    }
+    ticker := time.NewTicker(interval)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-stop:
+            return
+        case <-ticker.C:
+            info, err := os.Stat(e.path)
+            if err != nil {
+                continue
+            }
+            e.mu.RLock()
+            changed := !info.ModTime().Equal(e.modTime)
+            e.mu.RUnlock()
+            if !changed {
+                continue
+            }
+            if err := e.Reload(); err != nil {
+                // Don't retry until the file changes again.
+                e.mu.Lock()
+                e.modTime = info.ModTime()
+                e.mu.Unlock()
+                log.Printf("rules: reload failed, keeping previous rules: %v", err)
+            } else {
+                log.Printf("rules: reloaded %s", e.path)
+            }
+        }
+    }
+}
+
+// Rules returns a copy of the active rules.
+func (e *Engine) Rules() []Rule {
+    e.mu.RLock()
+    defer e.mu.RUnlock()
+    return append([]Rule{}, e.rules...)
+}
+
+// Evaluate runs every enabled rule against req and returns one alert per
+// match. reject is true when a matching rule has the reject action. A
+// timestamp that fx.ParseTimestamp cannot read fails with
+// graph.ErrInvalidQuery, since windows are measured from it.
+func (e *Engine) Evaluate(store graph.GraphStore, req models.TransactionRequest) (alerts []models.Alert, reject bool, err error) {
+    at, err := fx.ParseTimestamp(req.Timestamp)
+    if err != nil {
+        return nil, false, fmt.Errorf("%w: %v", graph.ErrInvalidQuery, err)
+    }
+    for _, r := range e.Rules() {
+        if r.Disabled {
+            continue
+        }
+        msg, hit, err := r.match(store, req, at)
+        if err != nil {
+            return nil, false, fmt.Errorf("rule %s: %w", r.ID, err)
+        }
+        if !hit {
+            continue
+        }
+        alerts = append(alerts, models.Alert{
+            RuleID:     r.ID,
+            RuleName:   r.Name,
+            Severity:   r.Severity,
+            Action:     r.Action,
+            Message:    msg,
+            SenderID:   req.FromUserID,
+            ReceiverID: req.ToUserID,
+        })
+        if r.Action == "reject" {
+            reject = true
+        }
+    }
+    return alerts, reject, nil
+}
+
+// match reports whether the rule fires for req and why.
+func (r Rule) match(store graph.GraphStore, req models.TransactionRequest, at time.Time) (string, bool, error) {
+    switch r.Type {
+    case AmountAbove:
+        amount, cur := reportingAmount(req)
+        return fmt.Sprintf("amount %.2f %s above %.2f", amount, cur, r.Threshold), amount > r.Threshold, nil
+
+    case DeviceVelocity:
+        if req.DeviceID == "" {
+            return "", false, nil
+        }
+        page, err := store.ListTransactions(models.TransactionQuery{
+            DeviceID: req.DeviceID,
+            From:     at.Add(-r.window).Format(time.RFC3339Nano),
+            To:       at.Add(time.Nanosecond).Format(time.RFC3339Nano),
+            Limit:    1,
+        })
+        if err != nil {
+            return "", false, err
+        }
+        n := int(page.Total) + 1 // including this one
+        return fmt.Sprintf("%d transactions from device %s within %s", n, req.DeviceID, r.Window), n > r.Count, nil
+
+    case SharedPhone:
+        _, conns, err := store.GetUserRelationships(req.FromUserID)
+        if errors.Is(err, graph.ErrUserNotFound) {
+            return "", false, nil
+        }
+        if err != nil {
+            return "", false, err
+        }
+        for _, c := range conns.Users {
+            // The same number written differently counts too.
+            if (c.Relationship == "SHARED_PHONE" || c.Relationship == "SAME_PHONE_E164") && c.Node.ID == req.ToUserID {
+                return "sender and receiver share a phone number", true, nil
+            }
+        }
+        return "", false, nil
+
+    case NewCounterparty:
+        amount, cur := reportingAmount(req)
+        if amount <= r.Threshold {
+            return "", false, nil
+        }
+        page, err := store.ListTransactions(models.TransactionQuery{
+            SenderID:   req.FromUserID,
+            ReceiverID: req.ToUserID,
+            Limit:      1,
+        })
+        if err != nil {
+            return "", false, err
+        }
+        return fmt.Sprintf("first transfer to this receiver, amount %.2f %s above %.2f", amount, cur, r.Threshold), page.Total == 0, nil
+    }
+    return "", false, nil
+}
+
+// reportingAmount is the request's amount in the reporting currency, which
+// thresholds are expressed in. An amount without an exchange rate counts
+// in its own currency, as in graph.Value.
+func reportingAmount(req models.TransactionRequest) (float64, string) {
+    if req.Money.ReportingCurrency == "" {
+        return fx.Float(req.Money.Minor, req.Money.Currency), req.Money.Currency
+    }
+    return fx.Float(req.Money.ReportingMinor, req.Money.ReportingCurrency), req.Money.ReportingCurrency
+}
@@ -0,0 +1,58 @@
+package rules
+
+import (
+    "errors"
+    "os"
+    "path/filepath"
+    "strings"
+    "testing"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+)
+
+func writeRules(t *testing.T, name, content string) string {
+    t.Helper()
+    path := filepath.Join(t.TempDir(), name)
+    if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    return path
+}
+
+func TestEvaluate(t *testing.T) {
+    e, err := NewEngine(writeRules(t, "rules.json", `{"rules":[
+        {"id":"big","type":"amount_above","threshold":100,"action":"reject"},
+        {"id":"off","type":"amount_above","threshold":0,"disabled":true}
+    ]}`))
+    if err != nil {
+        t.Fatal(err)
+    }
+    store := graph.NewMemoryStore()
+    req := models.TransactionRequest{
+        FromUserID: "a",
+        ToUserID:   "b",
+        Timestamp:  "2024-01-01T12:00:00Z",
+        Money:      models.Money{Minor: 15000, Currency: "USD"},
+    }
+
+    alerts, reject, err := e.Evaluate(store, req)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if !reject || len(alerts) != 1 || alerts[0].RuleID != "big" || alerts[0].SenderID != "a" {
+        t.Errorf("alerts = %+v, reject = %v; want big rejecting", alerts, reject)
+    }
+
+    req.Timestamp = "yesterday"
+    if _, _, err := e.Evaluate(store, req); !errors.Is(err, graph.ErrInvalidQuery) {
+        t.Errorf("unreadable timestamp: error = %v, want ErrInvalidQuery", err)
+    }
+}
+
+func TestNewEngineRejectsYAML(t *testing.T) {
+    _, err := NewEngine(writeRules(t, "rules.yaml", "rules:\n  - id: big\n"))
+    if err == nil || !strings.Contains(err.Error(), "JSON") {
+        t.Errorf("YAML rules file: error = %v, want one asking for JSON", err)
+    }
+}
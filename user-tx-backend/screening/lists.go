@@ -0,0 +1,171 @@
+package screening
+
+import (
+    "encoding/csv"
+    "encoding/xml"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strconv"
+    "strings"
+
+    "user-tx-backend/models"
+)
+
+// loadFile reads a list file. The format follows the extension: .xml is
+// read as an OFAC SDN-style document, anything else as CSV with a header.
+// The list name defaults to the file name without extension.
+func loadFile(path string) ([]models.WatchlistEntry, error) {
+    f, err := os.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer f.Close()
+
+    list := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+    var entries []models.WatchlistEntry
+    if strings.EqualFold(filepath.Ext(path), ".xml") {
+        entries, err = readSDN(f, list)
+    } else {
+        entries, err = readCSV(f, list)
+    }
+    if err != nil {
+        return nil, fmt.Errorf("%s: %w", path, err)
+    }
+    return entries, nil
+}
+
+// readCSV reads a list with a header row. Recognised columns are id, list,
+// name, aliases (separated by ";"), program, email, phone and deviceId;
+// column names are case-insensitive and may use underscores. Rows without
+// an id are numbered.
+func readCSV(r io.Reader, list string) ([]models.WatchlistEntry, error) {
+    cr := csv.NewReader(r)
+    cr.FieldsPerRecord = -1
+    cr.TrimLeadingSpace = true
+    header, err := cr.Read()
+    if err != nil {
+        return nil, fmt.Errorf("reading header: %w", err)
+    }
+    cols := make(map[string]int)
+    for i, h := range header {
+        cols[strings.ReplaceAll(strings.ToLower(strings.TrimSpace(h)), "_", "")] = i
+    }
+    if _, ok := cols["name"]; !ok {
+        if !hasAny(cols, "email", "phone", "deviceid") {
+            return nil, fmt.Errorf("header needs a name, email, phone or deviceId column")
+        }
+    }
+
+    var entries []models.WatchlistEntry
+    for line := 2; ; line++ {
+        rec, err := cr.Read()
+        if err == io.EOF {
+            break
+        }
+        if err != nil {
+            return nil, fmt.Errorf("line %d: %w", line, err)
+        }
+        get := func(col string) string {
+            if i, ok := cols[col]; ok && i < len(rec) {
+                return strings.TrimSpace(rec[i])
+            }
+            return ""
+        }
+        e := models.WatchlistEntry{
+            List:     list,
+            Name:     get("name"),
+            Program:  get("program"),
+            Email:    get("email"),
+            Phone:    get("phone"),
+            DeviceID: get("deviceid"),
+        }
+        if l := get("list"); l != "" {
+            e.List = l
+        }
+        for _, a := range strings.Split(get("aliases"), ";") {
+            if a = strings.TrimSpace(a); a != "" {
+                e.Aliases = append(e.Aliases, a)
+            }
+        }
+        if e.Name == "" && e.Email == "" && e.Phone == "" && e.DeviceID == "" {
+            continue
+        }
+        id := get("id")
+        if id == "" {
+            id = strconv.Itoa(line)
+        }
+        e.ID = e.List + ":" + id
+        entries = append(entries, e)
+    }
+    return entries, nil
+}
+
+func hasAny(cols map[string]int, names ...string) bool {
+    for _, n := range names {
+        if _, ok := cols[n]; ok {
+            return true
+        }
+    }
+    return false
+}
+
+// sdnList mirrors the parts of the OFAC SDN XML that screening uses.
+type sdnList struct {
+    Entries []struct {
+        UID       string   `xml:"uid"`
+        FirstName string   `xml:"firstName"`
+        LastName  string   `xml:"lastName"`
+        Programs  []string `xml:"programList>program"`
+        AKAs      []struct {
+            FirstName string `xml:"firstName"`
+            LastName  string `xml:"lastName"`
+        } `xml:"akaList>aka"`
+        IDs []struct {
+            Type   string `xml:"idType"`
+            Number string `xml:"idNumber"`
+        } `xml:"idList>id"`
+    } `xml:"sdnEntry"`
+}
+
+// readSDN reads an SDN-style XML document. "Email Address" and "Phone
+// Number" ids become identifiers.
+func readSDN(r io.Reader, list string) ([]models.WatchlistEntry, error) {
+    var doc sdnList
+    if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+        return nil, err
+    }
+    entries := make([]models.WatchlistEntry, 0, len(doc.Entries))
+    for i, s := range doc.Entries {
+        id := s.UID
+        if id == "" {
+            id = strconv.Itoa(i + 1)
+        }
+        e := models.WatchlistEntry{
+            ID:      list + ":" + id,
+            List:    list,
+            Name:    joinName(s.FirstName, s.LastName),
+            Program: strings.Join(s.Programs, ", "),
+        }
+        for _, a := range s.AKAs {
+            if name := joinName(a.FirstName, a.LastName); name != "" {
+                e.Aliases = append(e.Aliases, name)
+            }
+        }
+        for _, x := range s.IDs {
+            switch strings.ToLower(strings.TrimSpace(x.Type)) {
+            case "email address":
+                e.Email = strings.TrimSpace(x.Number)
+            case "phone number":
+                e.Phone = strings.TrimSpace(x.Number)
+            }
+        }
+        entries = append(entries, e)
+    }
+    return entries, nil
+}
+
+func joinName(first, last string) string {
+    return strings.TrimSpace(strings.TrimSpace(first) + " " + strings.TrimSpace(last))
+}
@@ -0,0 +1,19 @@
+package screening
+
+import "strings"
+
+// normalizeEmail lowercases and trims an email address.
+func normalizeEmail(s string) string {
+    return strings.ToLower(strings.TrimSpace(s))
+}
+
+// normalizePhone keeps the digits of a phone number.
+func normalizePhone(s string) string {
+    var b strings.Builder
+    for _, r := range s {
+        if r >= '0' && r <= '9' {
+            b.WriteRune(r)
+        }
+    }
+    return b.String()
+}
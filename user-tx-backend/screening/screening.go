@@ -0,0 +1,236 @@
+// Package screening matches users against sanctions lists and internal
+// denylists loaded from local CSV or XML files. Names are compared fuzzily
+// after normalisation; emails, phone numbers and device IDs must match
+// exactly.
+package screening
+
+import (
+    "fmt"
+    "sort"
+    "sync"
+    "time"
+
+    "user-tx-backend/graph"
+    "user-tx-backend/models"
+    "user-tx-backend/resolve"
+)
+
+// DefaultThreshold is the lowest name similarity reported as a hit.
+const DefaultThreshold = 0.9
+
+// minPhoneDigits keeps short numbers from matching by accident.
+const minPhoneDigits = 6
+
+// entry is a list entry with its match keys precomputed.
+type entry struct {
+    models.WatchlistEntry
+    name    string
+    aliases []string
+    email   string
+    phone   string
+}
+
+// Screener holds the loaded lists. It is safe for concurrent use.
+type Screener struct {
+    paths     []string
+    threshold float64
+
+    mu      sync.RWMutex
+    entries []entry
+}
+
+// NewScreener loads the list files at paths. No paths yields a screener
+// without entries, which never reports a hit.
+func NewScreener(paths []string, threshold float64) (*Screener, error) {
+    if threshold <= 0 || threshold > 1 {
+        return nil, fmt.Errorf("threshold must be in (0, 1]")
+    }
+    s := &Screener{paths: paths, threshold: threshold}
+    if len(paths) == 0 {
+        return s, nil
+    }
+    if err := s.Reload(); err != nil {
+        return nil, err
+    }
+    return s, nil
+}
+
+// Reload re-reads every list file. On error the current lists stay
+// active.
+func (s *Screener) Reload() error {
+    var entries []entry
+    seen := make(map[string]bool)
+    for _, p := range s.paths {
+        list, err := loadFile(p)
+        if err != nil {
+            return err
+        }
+        for _, e := range list {
+            if seen[e.ID] {
+                return fmt.Errorf("%s: duplicate entry %s", p, e.ID)
+            }
+            seen[e.ID] = true
+            prepared := entry{
+                WatchlistEntry: e,
+                name:           resolve.NormalizeName(e.Name),
+                email:          normalizeEmail(e.Email),
+                phone:          normalizePhone(e.Phone),
+            }
+            for _, a := range e.Aliases {
+                if n := resolve.NormalizeName(a); n != "" {
+                    prepared.aliases = append(prepared.aliases, n)
+                }
+            }
+            entries = append(entries, prepared)
+        }
+    }
+    s.mu.Lock()
+    s.entries = entries
+    s.mu.Unlock()
+    return nil
+}
+
+// Configured reports whether any list file is set.
+func (s *Screener) Configured() bool {
+    return len(s.paths) > 0
+}
+
+// Threshold returns the lowest name similarity reported as a hit.
+func (s *Screener) Threshold() float64 {
+    return s.threshold
+}
+
+// Entries returns the loaded entries.
+func (s *Screener) Entries() []models.WatchlistEntry {
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+    out := make([]models.WatchlistEntry, len(s.entries))
+    for i, e := range s.entries {
+        out[i] = e.WatchlistEntry
+    }
+    return out
+}
+
+// Screen matches a user, and the devices it sent from, against every
+// entry. Each entry yields at most one hit, its best match; identifier
+// matches take precedence over names. Hits are ordered by score.
+func (s *Screener) Screen(u models.User, devices []string) []models.WatchlistHit {
+    name := resolve.NormalizeName(u.Name)
+    email := normalizeEmail(u.Email)
+    phone := normalizePhone(u.Phone)
+    now := time.Now().UTC().Format(time.RFC3339Nano)
+
+    s.mu.RLock()
+    defer s.mu.RUnlock()
+
+    hits := []models.WatchlistHit{}
+    for _, e := range s.entries {
+        hit := models.WatchlistHit{
+            EntryID:    e.ID,
+            List:       e.List,
+            Name:       e.Name,
+            Program:    e.Program,
+            ScreenedAt: now,
+        }
+        switch {
+        case e.email != "" && e.email == email:
+            hit.MatchType, hit.MatchedValue, hit.Score = "email", u.Email, 1
+        case len(e.phone) >= minPhoneDigits && e.phone == phone:
+            hit.MatchType, hit.MatchedValue, hit.Score = "phone", u.Phone, 1
+        case e.DeviceID != "" && contains(devices, e.DeviceID):
+            hit.MatchType, hit.MatchedValue, hit.Score = "device", e.DeviceID, 1
+        default:
+            hit.MatchType, hit.MatchedValue, hit.Score = "name", u.Name, resolve.NameSimilarity(name, e.name)
+            for _, a := range e.aliases {
+                if sc := resolve.NameSimilarity(name, a); sc > hit.Score {
+                    hit.MatchType, hit.Score = "alias", sc
+                }
+            }
+            if hit.Score < s.threshold {
+                continue
+            }
+        }
+        hits = append(hits, hit)
+    }
+    sort.SliceStable(hits, func(i, j int) bool {
+        if hits[i].Score != hits[j].Score {
+            return hits[i].Score > hits[j].Score
+        }
+        return hits[i].EntryID < hits[j].EntryID
+    })
+    return hits
+}
+
+// ScreenUser screens one user by name, email and phone and stores the
+// result as its WATCHLIST_HIT relationships. It is used when a user is
+// created, before it has sent from any device.
+func (s *Screener) ScreenUser(store graph.GraphStore, u models.User) ([]models.WatchlistHit, error) {
+    byUser, err := s.ScreenUsers(store, []models.User{u})
+    if err != nil {
+        return nil, err
+    }
+    return byUser[u.ID], nil
+}
+
+// ScreenUsers is ScreenUser for a batch of users, such as a bulk import,
+// and stores all their hits at once.
+func (s *Screener) ScreenUsers(store graph.GraphStore, users []models.User) (map[string][]models.WatchlistHit, error) {
+    byUser := make(map[string][]models.WatchlistHit, len(users))
+    for _, u := range users {
+        byUser[u.ID] = s.Screen(u, nil)
+    }
+    if err := store.SetWatchlistHits(byUser); err != nil {
+        return nil, err
+    }
+    return byUser, nil
+}
+
+// Rescan screens every user, including the devices of the transactions
+// it sent, and replaces all WATCHLIST_HIT relationships.
+func (s *Screener) Rescan(store graph.GraphStore) (models.ScreeningResponse, error) {
+    snap, err := store.Snapshot(graph.SnapshotFilter{})
+    if err != nil {
+        return models.ScreeningResponse{}, err
+    }
+    devices := make(map[string][]string)
+    for _, t := range snap.Transactions {
+        if t.DeviceID != "" && !contains(devices[t.FromUserID], t.DeviceID) {
+            devices[t.FromUserID] = append(devices[t.FromUserID], t.DeviceID)
+        }
+    }
+
+    resp := models.ScreeningResponse{
+        Entries:  len(s.Entries()),
+        Screened: len(snap.Users),
+        Matches:  []models.ScreeningMatch{},
+    }
+    byUser := make(map[string][]models.WatchlistHit, len(snap.Users))
+    for id, u := range snap.Users {
+        hits := s.Screen(u, devices[id])
+        byUser[id] = hits
+        if len(hits) > 0 {
+            resp.Hits += len(hits)
+            resp.Matches = append(resp.Matches, models.ScreeningMatch{UserID: id, Name: u.Name, Hits: hits})
+        }
+    }
+    if err := store.SetWatchlistHits(byUser); err != nil {
+        return models.ScreeningResponse{}, err
+    }
+    sort.Slice(resp.Matches, func(i, j int) bool {
+        a, b := resp.Matches[i], resp.Matches[j]
+        if a.Hits[0].Score != b.Hits[0].Score {
+            return a.Hits[0].Score > b.Hits[0].Score
+        }
+        return a.UserID < b.UserID
+    })
+    return resp, nil
+}
+
+func contains(list []string, s string) bool {
+    for _, v := range list {
+        if v == s {
+            return true
+        }
+    }
+    return false
+}
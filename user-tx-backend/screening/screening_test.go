@@ -0,0 +1,65 @@
+package screening
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+
+    "user-tx-backend/models"
+)
+
+func TestScreen(t *testing.T) {
+    path := filepath.Join(t.TempDir(), "sanctions.csv")
+    list := "id,name,aliases,program,email,phone,device_id\n" +
+        "1,Ivan Petrov,Иван Петров;John Smith,SDGT,,,\n" +
+        "2,,,,bad@example.com,,\n" +
+        "3,,,,,+44 20 7946 0000,\n" +
+        "4,,,,,,kiosk-9\n"
+    if err := os.WriteFile(path, []byte(list), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    s, err := NewScreener([]string{path}, DefaultThreshold)
+    if err != nil {
+        t.Fatal(err)
+    }
+    if len(s.Entries()) != 4 || s.Entries()[0].ID != "sanctions:1" {
+        t.Fatalf("entries = %+v, want 4 from the sanctions list", s.Entries())
+    }
+
+    for _, tc := range []struct {
+        name    string
+        user    models.User
+        devices []string
+        want    string // match type of the only hit, or "" for none
+    }{
+        {"name", models.User{Name: "Petrov, Ivan"}, nil, "name"},
+        {"fuzzy name", models.User{Name: "Ivan Petrof"}, nil, "name"},
+        {"alias", models.User{Name: "Jon Smith"}, nil, "alias"},
+        {"email", models.User{Name: "Zed", Email: " BAD@example.com"}, nil, "email"},
+        {"phone", models.User{Name: "Zed", Phone: "442079460000"}, nil, "phone"},
+        {"device", models.User{Name: "Zed"}, []string{"kiosk-9"}, "device"},
+        {"no match", models.User{Name: "Maria Lopez", Email: "maria@example.com", Phone: "123"}, []string{"kiosk-1"}, ""},
+    } {
+        hits := s.Screen(tc.user, tc.devices)
+        if tc.want == "" {
+            if len(hits) != 0 {
+                t.Errorf("%s: hits = %+v, want none", tc.name, hits)
+            }
+            continue
+        }
+        if len(hits) != 1 || hits[0].MatchType != tc.want || hits[0].Score < DefaultThreshold || hits[0].List != "sanctions" {
+            t.Errorf("%s: hits = %+v, want one %s hit", tc.name, hits, tc.want)
+        }
+    }
+
+    if _, err := NewScreener([]string{path}, 0); err == nil {
+        t.Error("NewScreener accepted a threshold of 0")
+    }
+    dup := filepath.Join(t.TempDir(), "dup.csv")
+    if err := os.WriteFile(dup, []byte("id,name\n1,A\n1,B\n"), 0o644); err != nil {
+        t.Fatal(err)
+    }
+    if _, err := NewScreener([]string{dup}, DefaultThreshold); err == nil {
+        t.Error("NewScreener accepted a list with a duplicate id")
+    }
+}
@@ -16,8 +16,8 @@ export default function AddTransaction() {
   const navigate = useNavigate()
 
   useEffect(() => {
-    axios.get('/api/users')
-      .then(res => setUsers(res.data))
+    axios.get('/api/users', { params: { limit: 1000 } })
+      .then(res => setUsers(res.data.items))
       .catch(err => {
         console.error('Failed to load users:', err)
         setError('Error loading users.')
@@ -32,8 +32,8 @@ export default function AddTransaction() {
 
     try {
       await axios.post('/api/transactions', {
-        fromUserId:   Number(fromId),
-        toUserId:     Number(toId),
+        fromUserId:   fromId,
+        toUserId:     toId,
         amount:       Number(amount),
         currency,
         timestamp,
@@ -54,7 +54,12 @@ export default function AddTransaction() {
       setTimeout(() => navigate('/lists'), 1000)
     } catch (err) {
       console.error('Transaction creation error:', err)
-      setError(err.response?.data?.message || 'Failed to create transaction.')
+      const rejectedBy = (err.response?.data?.alerts || [])
+        .filter(a => a.action === 'reject')
+        .map(a => a.ruleName)
+      setError(rejectedBy.length
+        ? `Rejected by rule: ${rejectedBy.join(', ')}`
+        : err.response?.data?.message || 'Failed to create transaction.')
     }
   }
 
@@ -59,8 +59,8 @@ export default function Analytics() {
   // 2) load user list for the selectors
   useEffect(() => {
     axios
-      .get('/api/users')
-      .then(res => setUsers(res.data))
+      .get('/api/users', { params: { limit: 1000 } })
+      .then(res => setUsers(res.data.items))
       .catch(() => setError('Error loading user list.'))
   }, [])
 
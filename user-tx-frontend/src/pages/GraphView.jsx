@@ -26,6 +26,14 @@ export default function GraphView() {
               color: "#333",
             },
           },
+          {
+            // PageRank written back via /api/analytics/centrality?write=true
+            selector: 'node[type="user"][score]',
+            style: {
+              width: "mapData(score, 0, 0.5, 30, 90)",
+              height: "mapData(score, 0, 0.5, 30, 90)",
+            },
+          },
           {
             selector: 'node[type="transaction"]',
             style: {
@@ -38,6 +46,39 @@ export default function GraphView() {
               color: "#333",
             },
           },
+          {
+            selector: 'node[type="watchlist"]',
+            style: {
+              shape: "diamond",
+              "background-color": "#E8474C",
+              label: "data(label)",
+              "text-valign": "bottom",
+              "font-size": "10px",
+              color: "#333",
+            },
+          },
+          {
+            // Email, Phone, Device and IPAddress hubs
+            selector:
+              'node[type="email"], node[type="phone"], node[type="device"], node[type="ipaddress"]',
+            style: {
+              shape: "hexagon",
+              "background-color": "#B8C4CC",
+              label: "data(label)",
+              "text-valign": "bottom",
+              "font-size": "10px",
+              color: "#333",
+            },
+          },
+          {
+            selector: 'edge[relationship="WATCHLIST_HIT"]',
+            style: {
+              "line-color": "#E8474C",
+              width: 2,
+              "target-arrow-shape": "triangle",
+              "target-arrow-color": "#E8474C",
+            },
+          },
           {
             selector:
               'edge[relationship="SHARED_EMAIL"], edge[relationship="SHARED_PHONE"]',
@@ -50,6 +91,39 @@ export default function GraphView() {
               "arrow-scale": 0.8,
             },
           },
+          {
+            selector:
+              'edge[relationship="SAME_EMAIL_CANONICAL"], edge[relationship="SAME_PHONE_E164"], edge[relationship="SIMILAR_NAME"]',
+            style: {
+              "line-style": "dotted",
+              "line-color": "#6A7FDB",
+              width: 2,
+              "target-arrow-shape": "triangle",
+              "target-arrow-color": "#6A7FDB",
+              "arrow-scale": 0.8,
+            },
+          },
+          {
+            selector:
+              'edge[relationship="HAS_EMAIL"], edge[relationship="HAS_PHONE"], edge[relationship="USED_DEVICE"], edge[relationship="FROM_IP"]',
+            style: {
+              "line-color": "#B8C4CC",
+              width: 1,
+              "target-arrow-shape": "triangle",
+              "target-arrow-color": "#B8C4CC",
+              "arrow-scale": 0.6,
+            },
+          },
+          {
+            // Between a reversal or chargeback and its original
+            selector:
+              'edge[relationship="REVERSAL_OF"], edge[relationship="CHARGEBACK_OF"]',
+            style: {
+              "line-style": "dashed",
+              "line-color": "#C0392B",
+              width: 2,
+            },
+          },
           {
             selector: 'edge[relationship="SENT"]',
             style: {
@@ -90,8 +164,8 @@ export default function GraphView() {
   }, [cyRef, cy]);
 
   useEffect(() => {
-    axios.get("/api/users").then((res) => setUsers(res.data));
-    axios.get("/api/transactions").then((res) => setTxns(res.data));
+    axios.get("/api/users", { params: { limit: 1000 } }).then((res) => setUsers(res.data.items));
+    axios.get("/api/transactions", { params: { limit: 1000 } }).then((res) => setTxns(res.data.items));
   }, []);
 
   const loadUserGraph = async (id) => {
@@ -144,6 +218,42 @@ export default function GraphView() {
         });
       });
 
+      (connections.identifiers || []).forEach((rc) => {
+        const h = rc.node;
+        elements.push({
+          data: { id: `h${h.id}`, label: `${h.value} (${h.degree})`, type: h.type.toLowerCase() },
+        });
+        elements.push({
+          data: {
+            id: `e_hub_${user.id}_${h.id}`,
+            source: `u${user.id}`,
+            target: `h${h.id}`,
+            relationship: rc.relationship,
+            label: rc.relationship,
+          },
+        });
+      });
+
+      (connections.watchlist || []).forEach((rc) => {
+        const h = rc.node;
+        elements.push({
+          data: {
+            id: `w${h.entryId}`,
+            label: `${h.list}: ${h.name || h.matchedValue}`,
+            type: "watchlist",
+          },
+        });
+        elements.push({
+          data: {
+            id: `e_watch_${user.id}_${h.entryId}`,
+            source: `u${user.id}`,
+            target: `w${h.entryId}`,
+            relationship: rc.relationship,
+            label: `${h.matchType} ${Math.round(h.score * 100)}%`,
+          },
+        });
+      });
+
       cy.elements().remove();
       cy.add(elements);
       cy.layout({ name: "cose", animate: true }).run();
@@ -194,8 +304,64 @@ export default function GraphView() {
         });
       });
 
+      (connections.identifiers || []).forEach((rc) => {
+        const h = rc.node;
+        elements.push({
+          data: { id: `h${h.id}`, label: `${h.value} (${h.degree})`, type: h.type.toLowerCase() },
+        });
+        elements.push({
+          data: {
+            id: `e_tx_hub_${transaction.id}_${h.id}`,
+            source: `t${transaction.id}`,
+            target: `h${h.id}`,
+            relationship: rc.relationship,
+            label: rc.relationship,
+          },
+        });
+      });
+
+      // Reversals and chargebacks of this transaction, or its original,
+      // followed by transactions on the same device or IP, linked through
+      // its hub
+      (connections.transactions || []).forEach((rc) => {
+        const t = rc.node;
+        if (rc.relationship === "REVERSAL_OF" || rc.relationship === "CHARGEBACK_OF") {
+          elements.push({
+            data: { id: `t${t.id}`, label: `Txn #${t.id} (${t.status})`, type: "transaction" },
+          });
+          elements.push({
+            data: {
+              id: `e_tx_follow_${t.id}_${transaction.id}`,
+              source: `t${t.id}`,
+              target: `t${transaction.id}`,
+              relationship: rc.relationship,
+              label: rc.relationship,
+            },
+          });
+          return;
+        }
+        const hub = (connections.identifiers || []).find((i) =>
+          rc.relationship === "SHARED_DEVICE" ? i.node.type === "Device" : i.node.type === "IPAddress"
+        );
+        if (!hub) return;
+        elements.push({
+          data: { id: `t${t.id}`, label: `Txn #${t.id}`, type: "transaction" },
+        });
+        elements.push({
+          data: {
+            id: `e_tx_hub_${t.id}_${hub.node.id}`,
+            source: `t${t.id}`,
+            target: `h${hub.node.id}`,
+            relationship: hub.relationship,
+            label: hub.relationship,
+          },
+        });
+      });
+
       cy.elements().remove();
-      cy.add(elements);
+      // A transaction may reach this one through several links
+      const seen = new Set();
+      cy.add(elements.filter((el) => !seen.has(el.data.id) && seen.add(el.data.id)));
       cy.layout({ name: "cose", animate: true }).run();
       cy.fit();
     } catch (err) {
@@ -215,11 +381,19 @@ export default function GraphView() {
         const label =
           n.type === "User"
             ? n.properties.name
+            : ["Email", "Phone", "Device", "IPAddress"].includes(n.type)
+            ? n.properties.value
+            : n.type === "StatusChange"
+            ? n.properties.to
             : n.properties.deviceId
             ? `Txn #${n.id} (${n.properties.deviceId})`
             : `Txn #${n.id}`;
 
-        elements.push({ data: { id, label, type: n.type.toLowerCase() } });
+        const data = { id, label, type: n.type.toLowerCase() };
+        if (n.type === "User" && n.properties.pagerank !== undefined) {
+          data.score = n.properties.pagerank;
+        }
+        elements.push({ data });
       });
 
       data.relationships.forEach((r) => {
@@ -20,8 +20,8 @@ export default function Lists() {
   const [txnPage,  setTxnPage]  = useState(1)
 
   useEffect(() => {
-    axios.get('/api/users').then(res => setUsers(res.data))
-    axios.get('/api/transactions').then(res => setTxns(res.data))
+    axios.get('/api/users', { params: { limit: 1000 } }).then(res => setUsers(res.data.items))
+    axios.get('/api/transactions', { params: { limit: 1000 } }).then(res => setTxns(res.data.items))
   }, [])
 
   const filteredUsers = users.filter(u => {